@@ -3,87 +3,154 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/nadmax/nexq/internal/api"
+	"github.com/nadmax/nexq/internal/config"
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
 	"github.com/nadmax/nexq/internal/middleware"
 	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/repository/postgres"
+	"github.com/nadmax/nexq/internal/scheduler"
+	"github.com/nadmax/nexq/internal/tracing"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 func main() {
-	pogocacheAddr := os.Getenv("POGOCACHE_ADDR")
-	if pogocacheAddr == "" {
-		pogocacheAddr = "localhost:9401"
+	cfg, err := config.LoadServer(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	postgresDSN := os.Getenv("POSTGRES_DSN")
-	if postgresDSN == "" {
-		log.Fatal("POSTGRES_DSN is required")
+	logger := logging.New(os.Stderr, cfg.LogFormat)
+	logging.SetLogger(logger)
+	slog.SetDefault(logger)
+
+	if cfg.TracingEnabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), "nexq-server")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Printf("failed to flush traces: %v", err)
+			}
+		}()
+		log.Println("OpenTelemetry tracing enabled")
 	}
 
-	repo, err := postgres.NewPostgresTaskRepository(postgresDSN)
+	repo, err := postgres.NewPostgresTaskRepositoryWithPool(cfg.PostgresDSN, cfg.PostgresPool)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer func() {
-		if repoErr := repo.Close(); repoErr != nil {
-			log.Printf("failed to close Postgres repository: %v", repoErr)
-		}
-	}()
+	backend, err := queue.NewBackend(cfg.QueueBackend, cfg.PogocacheAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	q, err := queue.NewQueue(pogocacheAddr, repo)
+	q, err := queue.NewQueueWithBackend(backend, repo)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	q.SetEventSink(events.NewSink(cfg.EventSink, cfg.EventRedisAddr, cfg.EventChannel))
+	q.SetFailureClassifier(cfg.FailureClassifier)
+
+	if cfg.PayloadCipher != nil {
+		repo.SetPayloadCipher(cfg.PayloadCipher)
+		q.SetPayloadCipher(cfg.PayloadCipher)
+		log.Println("Payload encryption at rest enabled")
+	}
+
 	defer func() {
 		if qErr := q.Close(); qErr != nil {
 			log.Printf("failed to close server queue: %v", qErr)
 		}
 	}()
 
-	go startMetricsCollector(q)
+	q.SetVisibilityTimeout(cfg.VisibilityTimeout)
+	q.SetMaxQueueDepth(cfg.MaxQueueDepth)
+	q.SetMaxPayloadSize(cfg.MaxPayloadBytes)
+	q.SetAggregateMetrics(cfg.AggregateMetrics)
+
+	if err := q.RebuildIndexes(); err != nil {
+		log.Printf("Warning: failed to rebuild task indexes: %v", err)
+	}
+
+	// The background loops are stopped before the deferred queue and
+	// repository closes run.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	defer func() {
+		stopBackground()
+		background.Wait()
+	}()
+
+	background.Go(func() { startMetricsCollector(bgCtx, q) })
+	background.Go(func() { startReclaimer(bgCtx, q, cfg.ReclaimInterval) })
+
+	if cfg.HistoryRetention > 0 {
+		background.Go(func() { startHistoryPruner(bgCtx, repo, cfg.HistoryRetention, cfg.HistoryPruneInterval) })
+		log.Printf("Pruning task history older than %s every %s", cfg.HistoryRetention, cfg.HistoryPruneInterval)
+	}
+
+	sched := scheduler.NewScheduler(q)
+	go sched.Start()
 
 	apiHandler := api.NewAPI(q)
-	handler := middleware.MetricsMiddleware(apiHandler)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	apiHandler.SetTimeFormat(cfg.TimeFormat)
+	apiHandler.SetAllowUnknownTaskTypes(cfg.AllowUnknownTaskTypes)
+	var handler http.Handler = apiHandler
+	if cfg.TaskRateLimit > 0 {
+		limiter := middleware.NewRateLimiter(cfg.TaskRateLimit, cfg.TaskRateBurst)
+		go limiter.StartCleanup(time.Minute, 10*time.Minute)
+		handler = limiter.Middleware(handler)
+		log.Printf("Limiting task creation to %g/s per client (burst %d)", cfg.TaskRateLimit, cfg.TaskRateBurst)
+	}
+	handler = middleware.MetricsMiddleware(middleware.CORS(cfg.CORSAllowedOrigin, middleware.APIKeyAuth(cfg.APIKey, middleware.DecompressGzip(middleware.LimitRequestBody(int64(cfg.MaxRequestBodyBytes), handler)))))
+	handler = middleware.RequestID(middleware.Tracing(handler))
+	if cfg.APIKey == "" {
+		log.Println("Warning: NEXQ_API_KEY is not set, the API is unauthenticated")
 	}
+	port := strconv.Itoa(cfg.Port)
 
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: handler,
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	shutdownChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
 
-	go func() {
-		log.Printf("Server starting on :%s", port)
-		log.Printf("Connected to Pogocache at %s", pogocacheAddr)
-		log.Printf("Metrics available at http://localhost:%s/metrics", port)
+	log.Printf("Server starting on :%s", port)
+	log.Printf("Connected to Pogocache at %s (%s backend)", cfg.PogocacheAddr, cfg.QueueBackend)
+	log.Printf("Metrics available at http://localhost:%s/metrics", port)
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
-		}
-	}()
-
-	<-shutdownChan
-	log.Println("Shutting down server...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+	if err := runServer(server, ln, shutdownChan, shutdownTimeout); err != nil {
+		log.Printf("Server error: %v", err)
 	}
 
+	sched.Stop()
+
 	log.Println("Server stopped")
 }
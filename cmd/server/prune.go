@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nadmax/nexq/internal/repository"
+)
+
+func startHistoryPruner(ctx context.Context, repo repository.TaskRepository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruneTaskHistory(repo, retention)
+		}
+	}
+}
+
+func pruneTaskHistory(repo repository.TaskRepository, retention time.Duration) {
+	deleted, err := repo.PruneTaskHistory(context.Background(), retention)
+	if err != nil {
+		log.Printf("Failed to prune task history: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Pruned %d task history rows older than %s", deleted, retention)
+	}
+}
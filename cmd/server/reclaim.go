@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nadmax/nexq/internal/queue"
+)
+
+func startReclaimer(ctx context.Context, q *queue.Queue, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reclaimExpiredTasks(q)
+		}
+	}
+}
+
+func reclaimExpiredTasks(q *queue.Queue) {
+	reclaimed, err := q.ReclaimExpired()
+	if err != nil {
+		log.Printf("Failed to reclaim expired in-flight tasks: %v", err)
+	}
+
+	if reclaimed > 0 {
+		log.Printf("Re-enqueued %d in-flight tasks past their visibility timeout", reclaimed)
+	}
+}
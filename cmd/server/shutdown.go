@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runServer serves on ln until a signal arrives on stop, then stops accepting
+// connections and waits up to timeout for in-flight requests to finish.
+func runServer(server *http.Server, ln net.Listener, stop <-chan os.Signal, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down server...", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunServer_WaitsForInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})}
+
+	stop := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- runServer(server, ln, stop, 5*time.Second)
+	}()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+
+	resp := <-responses
+	if resp.err != nil {
+		t.Fatalf("in-flight request failed: %v", resp.err)
+	}
+	if resp.body != "done" {
+		t.Errorf("expected body %q, got %q", "done", resp.body)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Error("expected new connections to be refused after shutdown")
+	}
+}
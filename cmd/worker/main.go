@@ -1,60 +1,92 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
-	"time"
 
+	"github.com/nadmax/nexq/internal/config"
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
 	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/repository/postgres"
+	"github.com/nadmax/nexq/internal/tracing"
+	"github.com/nadmax/nexq/internal/webhook"
 	"github.com/nadmax/nexq/internal/worker"
 	"github.com/nadmax/nexq/internal/worker/handlers"
 )
 
 func main() {
-	pogocacheAddr := os.Getenv("POGOCACHE_ADDR")
-	if pogocacheAddr == "" {
-		pogocacheAddr = "localhost:9401"
+	cfg, err := config.LoadWorker(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	postgresDSN := os.Getenv("POSTGRES_DSN")
-	if postgresDSN == "" {
-		log.Fatal("POSTGRES_DSN is required")
+	logger := logging.New(os.Stderr, cfg.LogFormat)
+	logging.SetLogger(logger)
+	slog.SetDefault(logger)
+
+	if cfg.TracingEnabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), "nexq-worker")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Printf("failed to flush traces: %v", err)
+			}
+		}()
+		log.Println("OpenTelemetry tracing enabled")
 	}
 
-	repo, err := postgres.NewPostgresTaskRepository(postgresDSN)
+	repo, err := postgres.NewPostgresTaskRepositoryWithPool(cfg.PostgresDSN, cfg.PostgresPool)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer func() {
-		if repoErr := repo.Close(); repoErr != nil {
-			log.Printf("failed to close Postgres repository: %v", repoErr)
-		}
-	}()
+	backend, err := queue.NewBackend(cfg.QueueBackend, cfg.PogocacheAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	q, err := queue.NewQueue(pogocacheAddr, repo)
+	q, err := queue.NewQueueWithBackend(backend, repo)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	q.SetEventSink(events.NewSink(cfg.EventSink, cfg.EventRedisAddr, cfg.EventChannel))
+	q.SetFailureClassifier(cfg.FailureClassifier)
+
+	if cfg.PayloadCipher != nil {
+		repo.SetPayloadCipher(cfg.PayloadCipher)
+		q.SetPayloadCipher(cfg.PayloadCipher)
+		log.Println("Payload encryption at rest enabled")
+	}
+
+	q.SetMaxLeasesPerWorker(cfg.MaxLeases)
+	q.SetVisibilityTimeout(cfg.VisibilityTimeout)
+
 	defer func() {
 		if qErr := q.Close(); qErr != nil {
 			log.Printf("failed to close worker queue: %v", qErr)
 		}
 	}()
 
-	workerID := os.Getenv("WORKER_ID")
-	if workerID == "" {
-		workerID = fmt.Sprintf("worker-%d", time.Now().Unix())
-	}
-
-	w := worker.NewWorker(workerID, q)
+	w := worker.NewWorker(cfg.WorkerID, q)
+	w.SetWebhookClient(webhook.NewClientWithAllowlist(webhook.DefaultTimeout, cfg.WebhookAllowedNetworks))
 	reportGen := handlers.NewReportGenerator(repo.DB())
+	reportGen.SetMaxRowsInMemory(cfg.ReportMaxRowsInMemory)
+	reportGen.SetReportStore(q)
+	reportGen.SetTaskUpdater(q)
+	if uploader, err := handlers.NewS3Uploader(context.Background()); err != nil {
+		log.Printf("Warning: S3 report destination disabled: %v", err)
+	} else {
+		reportGen.SetUploader(uploader)
+	}
 
 	w.RegisterHandler("generate_report", reportGen.GenerateReportHandler)
 
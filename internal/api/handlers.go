@@ -2,10 +2,13 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,30 +17,91 @@ import (
 	"time"
 
 	"github.com/nadmax/nexq/internal/dashboard"
+	"github.com/nadmax/nexq/internal/encryption"
 	"github.com/nadmax/nexq/internal/httputil"
+	"github.com/nadmax/nexq/internal/logging"
 	"github.com/nadmax/nexq/internal/metrics"
 	"github.com/nadmax/nexq/internal/queue"
+	"github.com/nadmax/nexq/internal/repository"
+	"github.com/nadmax/nexq/internal/scheduler"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/validation"
+	"github.com/nadmax/nexq/internal/version"
+	"github.com/nadmax/nexq/internal/webhook"
+	"github.com/nadmax/nexq/internal/worker/handlers"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type API struct {
-	queue *queue.Queue
-	mux   *http.ServeMux
+	queue      *queue.Queue
+	mux        *http.ServeMux
+	timeFormat task.TimeFormat
+	validators *validation.Registry
+
+	allowUnknownTypes bool
 }
 
 type TaskRequest struct {
-	Type       string             `json:"type"`
-	Payload    map[string]any     `json:"payload"`
-	Priority   *task.TaskPriority `json:"priority"`
-	ScheduleIn *int               `json:"schedule_in"`
+	ID                  *string            `json:"id"`
+	Type                string             `json:"type"`
+	Payload             map[string]any     `json:"payload"`
+	Priority            *task.TaskPriority `json:"priority"`
+	ScheduleIn          *int               `json:"schedule_in"`
+	Tags                []string           `json:"tags"`
+	ExpectedDurationMs  *int               `json:"expected_duration_ms"`
+	IdempotencyKey      string             `json:"idempotency_key"`
+	DedupeWindowSeconds *int               `json:"dedupe_window_seconds"`
+	DependsOn           []string           `json:"depends_on"`
+	Confirmation        string             `json:"confirmation"`
+	CallbackURL         string             `json:"callback_url"`
+	RetryDelaySeconds   *int               `json:"retry_delay_seconds"`
+	DeadLetter          *bool              `json:"dead_letter"`
+	TTLSeconds          *int               `json:"ttl_seconds"`
+}
+
+const (
+	taskSourceQueue   = "queue"
+	taskSourceHistory = "history"
+)
+
+// sourcedTask is a task response tagged with where the task was found: the
+// live queue or the Postgres history.
+type sourcedTask struct {
+	task.FormattedTask
+	Source string
+}
+
+func (s sourcedTask) MarshalJSON() ([]byte, error) {
+	data, err := s.FormattedTask.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := json.Marshal(s.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	data = append(data[:len(data)-1], `,"source":`...)
+	data = append(data, source...)
+	return append(data, '}'), nil
+}
+
+type ScheduleRequest struct {
+	Cron     string             `json:"cron"`
+	Type     string             `json:"type"`
+	Payload  map[string]any     `json:"payload"`
+	Priority *task.TaskPriority `json:"priority"`
+	Tags     []string           `json:"tags"`
 }
 
 func NewAPI(q *queue.Queue) *API {
 	api := &API{
-		queue: q,
-		mux:   http.NewServeMux(),
+		queue:      q,
+		mux:        http.NewServeMux(),
+		timeFormat: task.RFC3339TimeFormat,
+		validators: validation.DefaultRegistry(),
 	}
 
 	api.setupRoutes()
@@ -48,6 +112,7 @@ func (a *API) setupRoutes() {
 	a.mux.HandleFunc("/api/tasks", a.handleTasks)
 	a.mux.HandleFunc("/api/tasks/", a.handleTaskByID)
 	a.mux.HandleFunc("/api/tasks/cancel/", a.handleCancelTask)
+	a.mux.HandleFunc("/api/tasks/search", a.handleSearchTasks)
 
 	dash := dashboard.NewDashboard(a.queue)
 	a.mux.HandleFunc("/api/dashboard/stats", dash.GetStats)
@@ -57,13 +122,30 @@ func (a *API) setupRoutes() {
 	a.mux.HandleFunc("/api/dlq/tasks/", a.handleDLQTaskByID)
 	a.mux.HandleFunc("/api/dlq/stats", a.handleDLQStats)
 
+	a.mux.HandleFunc("/api/queue/stats", a.handleQueueStats)
+	a.mux.HandleFunc("/api/queue/peek", a.handleQueuePeek)
+	a.mux.HandleFunc("/api/workers", a.handleWorkers)
+
 	a.mux.HandleFunc("/api/history/stats", a.handleHistoryStats)
 	a.mux.HandleFunc("/api/history/recent", a.handleRecentHistory)
 	a.mux.HandleFunc("/api/history/task/", a.handleTaskHistory)
 	a.mux.HandleFunc("/api/history/type/", a.handleTasksByType)
+	a.mux.HandleFunc("/api/history/tag/", a.handleTasksByTag)
+
+	a.mux.HandleFunc("/api/schedules", a.handleSchedules)
+	a.mux.HandleFunc("/api/schedules/", a.handleScheduleByID)
+
+	a.mux.HandleFunc("/api/stats", a.handleHistoryStats)
+	a.mux.HandleFunc("/api/stats/duration-outliers", a.handleDurationOutliers)
 
-	a.mux.HandleFunc("/api/reports", a.listReportsHandler)
+	a.mux.HandleFunc("/api/reports", a.handleReports)
+	a.mux.HandleFunc("/api/reports/types", a.handleReportTypes)
 	a.mux.HandleFunc("/api/reports/download/", a.downloadReportHandler)
+	a.mux.HandleFunc("/api/reports/", a.handleReportByID)
+
+	a.mux.HandleFunc("/api/admin/refresh-metrics", a.handleRefreshMetrics)
+
+	a.mux.HandleFunc("/api/version", a.handleVersion)
 
 	a.mux.Handle("/metrics", promhttp.Handler())
 
@@ -71,6 +153,20 @@ func (a *API) setupRoutes() {
 	a.mux.Handle("/", fs)
 }
 
+func (a *API) SetTimeFormat(format task.TimeFormat) {
+	a.timeFormat = format
+}
+
+// SetAllowUnknownTaskTypes disables the check that a worker has registered a
+// handler for the type of each created task or schedule.
+func (a *API) SetAllowUnknownTaskTypes(allow bool) {
+	a.allowUnknownTypes = allow
+}
+
+func (a *API) RegisterPayloadValidator(taskType string, fn validation.Func) {
+	a.validators.Register(taskType, fn)
+}
+
 func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.mux.ServeHTTP(w, r)
 }
@@ -95,7 +191,7 @@ func (a *API) createTask(w http.ResponseWriter, r *http.Request) {
 
 	defer func() {
 		if err := r.Body.Close(); err != nil {
-			log.Printf("failed to close request body: %v", err)
+			logging.FromContext(r.Context()).Warn("failed to close request body", "error", err)
 		}
 	}()
 
@@ -110,46 +206,251 @@ func (a *API) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !a.checkTaskType(w, r, req.Type) {
+		return
+	}
+
+	if fieldErrs := a.validators.Validate(req.Type, req.Payload); len(fieldErrs) > 0 {
+		writePayloadErrors(w, fieldErrs)
+		return
+	}
+
+	mode, err := queue.ParseEnqueueMode(req.Confirmation)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.ID != nil {
+		if err := validateTaskID(*req.ID); err != nil {
+			httputil.WriteJSONError(w, fmt.Sprintf("Invalid id: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.DedupeWindowSeconds != nil {
+		if *req.DedupeWindowSeconds <= 0 {
+			httputil.WriteJSONError(w, "dedupe_window_seconds must be positive", http.StatusBadRequest)
+			return
+		}
+		if req.IdempotencyKey != "" {
+			httputil.WriteJSONError(w, "dedupe_window_seconds cannot be combined with idempotency_key", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.RetryDelaySeconds != nil && *req.RetryDelaySeconds < 0 {
+		httputil.WriteJSONError(w, "retry_delay_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.TTLSeconds != nil && *req.TTLSeconds <= 0 {
+		httputil.WriteJSONError(w, "ttl_seconds must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if err := webhook.ValidateURL(req.CallbackURL); err != nil {
+			httputil.WriteJSONError(w, fmt.Sprintf("Invalid callback_url: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, depID := range req.DependsOn {
+		if _, found := a.queue.LookupStatus(depID); !found {
+			httputil.WriteJSONError(w, fmt.Sprintf("Unknown dependency: %s", depID), http.StatusBadRequest)
+			return
+		}
+	}
+
 	priority := task.MediumPriority
 	if req.Priority != nil {
 		priority = *req.Priority
 	}
 
 	t := task.NewTask(req.Type, req.Payload, priority)
+	if req.ID != nil {
+		t.ID = *req.ID
+	}
+	t.Tags = req.Tags
+	t.ExpectedDurationMs = req.ExpectedDurationMs
+	t.DependsOn = req.DependsOn
+	t.CorrelationID = logging.RequestID(r.Context())
+	t.CallbackURL = req.CallbackURL
+	t.RetryDelaySeconds = req.RetryDelaySeconds
+	t.DeadLetter = req.DeadLetter
 	if req.ScheduleIn != nil {
 		t.ScheduledAt = time.Now().Add(time.Duration(*req.ScheduleIn) * time.Second)
 	}
+	if req.TTLSeconds != nil {
+		expiresAt := t.CreatedAt.Add(time.Duration(*req.TTLSeconds) * time.Second)
+		t.ExpiresAt = &expiresAt
+	}
 
-	if err := a.queue.Enqueue(t); err != nil {
-		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+	status := http.StatusCreated
+	if req.IdempotencyKey != "" {
+		enqueueIdempotent := a.queue.EnqueueIdempotent
+		if req.ID != nil {
+			enqueueIdempotent = a.queue.EnqueueIdempotentWithID
+		}
+		existing, created, err := enqueueIdempotent(t, req.IdempotencyKey, queue.DefaultIdempotencyTTL, mode)
+		if err != nil {
+			writeEnqueueError(w, err)
+			return
+		}
+		if !created {
+			t = existing
+			status = http.StatusOK
+		}
+	} else if req.DedupeWindowSeconds != nil {
+		enqueueDeduplicated := a.queue.EnqueueDeduplicated
+		if req.ID != nil {
+			enqueueDeduplicated = a.queue.EnqueueDeduplicatedWithID
+		}
+		window := time.Duration(*req.DedupeWindowSeconds) * time.Second
+		existing, created, err := enqueueDeduplicated(t, window, mode)
+		if err != nil {
+			writeEnqueueError(w, err)
+			return
+		}
+		if !created {
+			t = existing
+			status = http.StatusOK
+		}
+	} else if req.ID != nil {
+		if err := a.queue.EnqueueWithID(t, mode); err != nil {
+			writeEnqueueError(w, err)
+			return
+		}
+	} else if err := a.queue.EnqueueWithMode(t, mode); err != nil {
+		writeEnqueueError(w, err)
 		return
 	}
 
-	metrics.RecordTaskEnqueued(t.Type, t.Priority)
+	if status == http.StatusCreated {
+		metrics.RecordTaskEnqueued(t.Type, t.Priority)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(t); err != nil {
+	w.Header().Set("Location", taskLocation(t.ID))
+	w.Header().Set("X-Enqueue-Confirmation", string(mode))
+	w.WriteHeader(status)
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormat(t, a.timeFormat)); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (a *API) listTasks(w http.ResponseWriter, _ *http.Request) {
-	tasks, err := a.queue.GetAllTasks()
+// maxTaskIDLength matches the task_id column in PostgreSQL.
+const maxTaskIDLength = 255
+
+// validateTaskID accepts IDs that fit the history table and can be used as a
+// single path segment in the task endpoints.
+func validateTaskID(id string) error {
+	switch {
+	case id == "":
+		return errors.New("must not be empty")
+	case len(id) > maxTaskIDLength:
+		return fmt.Errorf("must be at most %d bytes", maxTaskIDLength)
+	case strings.ContainsAny(id, "/?# "):
+		return errors.New("must not contain '/', '?', '#' or spaces")
+	}
+
+	return nil
+}
+
+// taskLocation is the URL a client polls for the status of a created task.
+func taskLocation(taskID string) string {
+	return "/api/tasks/" + taskID
+}
+
+// queueFullRetryAfter is the Retry-After, in seconds, sent with 503 when the
+// queue is at its maximum depth.
+const queueFullRetryAfter = "5"
+
+func writeEnqueueError(w http.ResponseWriter, err error) {
+	if errors.Is(err, queue.ErrQueueFull) {
+		w.Header().Set("Retry-After", queueFullRetryAfter)
+		httputil.WriteJSONError(w, "Queue is full, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, queue.ErrPayloadTooLarge) {
+		httputil.WriteJSONError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, queue.ErrTaskIDExists) {
+		httputil.WriteJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, queue.ErrReservedPayload) {
+		httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writePayloadErrors(w http.ResponseWriter, fieldErrs []validation.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":  "Invalid payload",
+		"fields": fieldErrs,
+	})
+}
+
+// checkTaskType writes an error response and returns false unless a worker
+// has registered a handler for taskType.
+func (a *API) checkTaskType(w http.ResponseWriter, r *http.Request, taskType string) bool {
+	if a.allowUnknownTypes {
+		return true
+	}
+
+	known, err := a.queue.IsKnownTaskType(taskType)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to look up task type", "type", taskType, "error", err)
+		httputil.WriteJSONError(w, "Failed to look up task type", http.StatusInternalServerError)
+		return false
+	}
+	if !known {
+		httputil.WriteJSONError(w, fmt.Sprintf("Unknown task type: %s", taskType), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func (a *API) listTasks(w http.ResponseWriter, r *http.Request) {
+	var tasks []*task.Task
+	var err error
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		tasks, err = a.queue.GetTasksByTag(tag)
+	} else {
+		tasks, err = a.queue.GetAllTasks()
+	}
 	if err != nil {
 		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormatAll(tasks, a.timeFormat)); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (a *API) handleTaskByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
+	if strings.HasSuffix(path, "/ack") || strings.HasSuffix(path, "/nack") {
+		a.handleTaskAck(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/requeue") {
+		a.handleTaskRequeue(w, r)
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -161,14 +462,206 @@ func (a *API) handleTaskByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := a.queue.GetTask(taskID)
+	// Tasks evicted from the queue may still be in the Postgres history.
+	source := taskSourceQueue
+	t, err := a.queue.GetTask(taskID)
+	if repo := a.queue.GetRepository(); errors.Is(err, queue.ErrTaskNotFound) && repo != nil {
+		source = taskSourceHistory
+		t, err = repo.GetTask(r.Context(), taskID)
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			err = queue.ErrTaskNotFound
+		}
+	}
+	if errors.Is(err, queue.ErrTaskNotFound) {
+		httputil.WriteJSONError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to get task", "task_id", taskID, "source", source, "error", err)
+		httputil.WriteJSONError(w, "Failed to get task", http.StatusInternalServerError)
+		return
+	}
+
+	resp := sourcedTask{FormattedTask: task.WithTimeFormat(t, a.timeFormat), Source: source}
+	var data []byte
+	if httputil.Pretty(r) {
+		data, err = json.MarshalIndent(resp, "", "  ")
+	} else {
+		data, err = json.Marshal(resp)
+	}
 	if err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	// Clients polling a task until it finishes get a 304 while it is
+	// unchanged.
+	etag := taskETag(data)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		logging.FromContext(r.Context()).Warn("failed to write task response", "task_id", taskID, "error", err)
+	}
+}
+
+// taskETag is a strong ETag over the encoded task response.
+func taskETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleTaskAck serves POST /api/tasks/{id}/ack and
+// POST /api/tasks/{id}/nack[?requeue=false] for tasks pulled off the queue
+// and processed outside a worker.
+func (a *API) handleTaskAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
+	action := path[strings.LastIndex(path, "/")+1:]
+	taskID := strings.TrimSuffix(path, "/"+action)
+	if taskID == "" {
+		httputil.WriteJSONError(w, "Task ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	message := "Task acknowledged"
+	if action == "nack" {
+		requeue := true
+		if value := r.URL.Query().Get("requeue"); value != "" {
+			requeue, err = strconv.ParseBool(value)
+			if err != nil {
+				httputil.WriteJSONError(w, "requeue must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err = a.queue.Nack(taskID, requeue)
+		message = "Task negatively acknowledged"
+	} else {
+		err = a.queue.Ack(taskID)
+	}
+
+	if errors.Is(err, queue.ErrTaskNotInFlight) {
+		httputil.WriteJSONError(w, "Task is not in flight", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, queue.ErrTaskLeased) {
+		httputil.WriteJSONError(w, "Task is being processed by a worker", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to "+action+" task", "task_id", taskID, "error", err)
+		httputil.WriteJSONError(w, "Failed to "+action+" task", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(map[string]string{
+		"message": message,
+		"task_id": taskID,
+	}); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleTaskRequeue serves POST /api/tasks/{id}/requeue, which enqueues a
+// copy of a finished task under a new ID.
+func (a *API) handleTaskRequeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/tasks/"), "/requeue")
+	if taskID == "" {
+		httputil.WriteJSONError(w, "Task ID is required", http.StatusBadRequest)
+		return
+	}
+
+	t, err := a.queue.Requeue(taskID)
+	if errors.Is(err, queue.ErrTaskNotFound) {
 		httputil.WriteJSONError(w, "Task not found", http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, queue.ErrTaskNotFinished) {
+		httputil.WriteJSONError(w, "Task has not finished", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, queue.ErrQueueFull) {
+		writeEnqueueError(w, err)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to requeue task", "task_id", taskID, "error", err)
+		httputil.WriteJSONError(w, "Failed to requeue task", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(task); err != nil {
+	w.Header().Set("Location", taskLocation(t.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormat(t, a.timeFormat)); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleSearchTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repo := a.queue.GetRepository()
+	if repo == nil {
+		httputil.WriteJSONError(w, "History not available (PostgreSQL not configured)", http.StatusServiceUnavailable)
+		return
+	}
+
+	substring := r.URL.Query().Get("error")
+	if substring == "" {
+		httputil.WriteJSONError(w, "error query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+
+	tasks, err := repo.SearchTasksByError(r.Context(), substring, limit)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(tasks); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -190,22 +683,128 @@ func (a *API) handleCancelTask(w http.ResponseWriter, r *http.Request) {
 			httputil.WriteJSONError(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		if strings.Contains(err.Error(), "cannot cancel") {
-			httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		if strings.Contains(err.Error(), "cannot cancel") {
+			httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(map[string]string{
+		"message": "Task cancelled successfully",
+		"task_id": taskID,
+	}); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.createSchedule(w, r)
+	case http.MethodGet:
+		a.listSchedules(w, r)
+	default:
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Type == "" {
+		httputil.WriteJSONError(w, "Task type is required", http.StatusBadRequest)
+		return
+	}
+	if !a.checkTaskType(w, r, req.Type) {
+		return
+	}
+	if _, err := scheduler.ParseCron(req.Cron); err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if encryption.HasReservedField(req.Payload) {
+		httputil.WriteJSONError(w, queue.ErrReservedPayload.Error(), http.StatusBadRequest)
+		return
+	}
+
+	priority := task.MediumPriority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+
+	rt := task.NewRecurringTask(req.Cron, req.Type, req.Payload, priority)
+	rt.Tags = req.Tags
+
+	if err := a.queue.SaveRecurringTask(rt); err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := httputil.NewJSONEncoder(w, r).Encode(rt); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) listSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := a.queue.GetRecurringTasks()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(schedules); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleScheduleByID(w http.ResponseWriter, r *http.Request) {
+	scheduleID := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
+	if scheduleID == "" {
+		httputil.WriteJSONError(w, "Schedule ID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rt, err := a.queue.GetRecurringTask(scheduleID)
+		if err != nil {
+			httputil.WriteJSONError(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := httputil.NewJSONEncoder(w, r).Encode(rt); err != nil {
+			httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		deleted, err := a.queue.DeleteRecurringTask(scheduleID)
+		if err != nil {
+			httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			httputil.WriteJSONError(w, "Schedule not found", http.StatusNotFound)
 			return
 		}
 
-		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"message": "Task cancelled successfully",
-		"task_id": taskID,
-	}); err != nil {
-		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
-		return
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -222,7 +821,7 @@ func (a *API) handleDLQTasks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormatAll(tasks, a.timeFormat)); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -241,12 +840,12 @@ func (a *API) handleDLQTaskByID(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		a.getDLQTask(w, taskID)
+		a.getDLQTask(w, r, taskID)
 	case http.MethodDelete:
 		a.purgeDLQTask(w, taskID)
 	case http.MethodPost:
 		if len(parts) == 2 && parts[1] == "retry" {
-			a.retryDLQTask(w, taskID)
+			a.retryDLQTask(w, r, taskID)
 		} else {
 			httputil.WriteJSONError(w, "Invalid endpoint", http.StatusNotFound)
 		}
@@ -255,21 +854,21 @@ func (a *API) handleDLQTaskByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (a *API) getDLQTask(w http.ResponseWriter, taskID string) {
-	task, err := a.queue.GetDeadLetterTask(taskID)
+func (a *API) getDLQTask(w http.ResponseWriter, r *http.Request, taskID string) {
+	t, err := a.queue.GetDeadLetterTask(taskID)
 	if err != nil {
 		httputil.WriteJSONError(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(task); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormat(t, a.timeFormat)); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (a *API) retryDLQTask(w http.ResponseWriter, taskID string) {
+func (a *API) retryDLQTask(w http.ResponseWriter, r *http.Request, taskID string) {
 	t, err := a.queue.GetDeadLetterTask(taskID)
 	if err != nil {
 		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
@@ -287,7 +886,7 @@ func (a *API) retryDLQTask(w http.ResponseWriter, taskID string) {
 		"message": "Task moved back to queue for retry",
 		"task_id": taskID,
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(response); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -315,7 +914,99 @@ func (a *API) handleDLQStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(stats); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+type QueueStatsResponse struct {
+	QueueDepth      int                     `json:"queue_depth"`
+	DeadLetterDepth int                     `json:"dead_letter_depth"`
+	TasksByStatus   map[task.TaskStatus]int `json:"tasks_by_status"`
+}
+
+func (a *API) handleQueueStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	depth, err := a.queue.Depth()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dlqStats, err := a.queue.GetDeadLetterStats()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tasksByStatus, err := a.queue.StatusCounts()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(QueueStatsResponse{
+		QueueDepth:      depth,
+		DeadLetterDepth: dlqStats["total_tasks"].(int),
+		TasksByStatus:   tasksByStatus,
+	}); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+const maxPeek = 100
+
+func (a *API) handleQueuePeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := 10
+	if s := r.URL.Query().Get("n"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed < 1 || parsed > maxPeek {
+			httputil.WriteJSONError(w, fmt.Sprintf("n must be an integer between 1 and %d", maxPeek), http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+
+	tasks, err := a.queue.Peek(n)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to peek queue", "error", err)
+		httputil.WriteJSONError(w, "Failed to peek queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(task.WithTimeFormatAll(tasks, a.timeFormat)); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workers, err := a.queue.ActiveWorkers()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(workers); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -335,9 +1026,13 @@ func (a *API) handleHistoryStats(w http.ResponseWriter, r *http.Request) {
 
 	hours := 24
 	if h := r.URL.Query().Get("hours"); h != "" {
-		if parsed, err := strconv.Atoi(h); err == nil {
-			hours = parsed
+		parsed, err := strconv.Atoi(h)
+		if err != nil || parsed <= 0 {
+			httputil.WriteJSONError(w, "hours must be a positive integer", http.StatusBadRequest)
+			return
 		}
+
+		hours = parsed
 	}
 
 	stats, err := repo.GetTaskStats(r.Context(), hours)
@@ -347,7 +1042,50 @@ func (a *API) handleHistoryStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(stats); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleDurationOutliers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repo := a.queue.GetRepository()
+	if repo == nil {
+		httputil.WriteJSONError(w, "History not available (PostgreSQL not configured)", http.StatusServiceUnavailable)
+		return
+	}
+
+	hours := 24
+	if h := r.URL.Query().Get("hours"); h != "" {
+		parsed, err := strconv.Atoi(h)
+		if err != nil || parsed <= 0 {
+			httputil.WriteJSONError(w, "hours must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		hours = parsed
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+
+	outliers, err := repo.GetDurationOutliers(r.Context(), hours, limit)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(outliers); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -372,14 +1110,28 @@ func (a *API) handleRecentHistory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tasks, err := repo.GetRecentTasks(r.Context(), limit)
+	offset := 0
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	tasks, err := repo.GetRecentTasks(r.Context(), limit, offset)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	total, err := repo.CountTasks(r.Context())
 	if err != nil {
 		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if err := httputil.NewJSONEncoder(w, r).Encode(tasks); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -410,7 +1162,7 @@ func (a *API) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(history); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(history); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -441,29 +1193,165 @@ func (a *API) handleTasksByType(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tasks, err := repo.GetTasksByType(r.Context(), taskType, limit)
+	offset := 0
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	tasks, err := repo.GetTasksByType(r.Context(), taskType, limit, offset)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	total, err := repo.CountTasksByType(r.Context(), taskType)
 	if err != nil {
 		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if err := httputil.NewJSONEncoder(w, r).Encode(tasks); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (a *API) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) handleTasksByTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repo := a.queue.GetRepository()
+	if repo == nil {
+		httputil.WriteJSONError(w, "History not available (PostgreSQL not configured)", http.StatusServiceUnavailable)
+		return
+	}
+
+	tag := strings.TrimPrefix(r.URL.Path, "/api/history/tag/")
+	if tag == "" {
+		httputil.WriteJSONError(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+
+	tasks, err := repo.GetTasksByTag(r.Context(), tag, limit)
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(tasks); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleRefreshMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshot, err := a.queue.RefreshMetrics()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(snapshot); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(version.Get()); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) handleReports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.createReport(w, r)
+	case http.MethodGet:
+		a.listReportsHandler(w, r)
+	default:
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReportTypes lists the report types with the payload fields they
+// accept, so clients can build a report form without hardcoding them.
+func (a *API) handleReportTypes(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(handlers.DescribeReportTypes()); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) createReport(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		httputil.WriteJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := handlers.ParsePayload(payload); err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !a.checkTaskType(w, r, "generate_report") {
+		return
+	}
+
+	t := task.NewTask("generate_report", payload, task.MediumPriority)
+	if err := a.queue.Enqueue(t); err != nil {
+		writeEnqueueError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", taskLocation(t.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := httputil.NewJSONEncoder(w, r).Encode(map[string]string{"task_id": t.ID}); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *API) listReportsHandler(w http.ResponseWriter, r *http.Request) {
 	reportsDir := "./reports"
 	files, err := os.ReadDir(reportsDir)
 	if err != nil {
-		if jErr := json.NewEncoder(w).Encode([]map[string]any{}); jErr != nil {
+		if jErr := httputil.NewJSONEncoder(w, r).Encode([]map[string]any{}); jErr != nil {
 			httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 			return
 		}
@@ -490,7 +1378,7 @@ func (a *API) listReportsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(reports); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(reports); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -526,15 +1414,52 @@ func (a *API) downloadReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	serveReport(w, r, filePath, strings.TrimPrefix(filepath.Ext(filename), "."))
+}
 
-	if strings.HasSuffix(filename, ".csv") {
-		w.Header().Set("Content-Type", "text/csv")
-	} else if strings.HasSuffix(filename, ".json") {
-		w.Header().Set("Content-Type", "application/json")
-	} else {
-		w.Header().Set("Content-Type", "application/octet-stream")
+// handleReportByID serves GET /api/reports/:id/download, where id is the ID
+// of the generate_report task that wrote the report.
+func (a *API) handleReportByID(w http.ResponseWriter, r *http.Request) {
+	reportID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/reports/"), "/download")
+	if !ok || reportID == "" || strings.Contains(reportID, "/") {
+		httputil.WriteJSONError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		httputil.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, err := a.queue.GetReportInfo(reportID)
+	if errors.Is(err, queue.ErrNil) {
+		httputil.WriteJSONError(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to look up report", "report_id", reportID, "error", err)
+		httputil.WriteJSONError(w, "Failed to look up report", http.StatusInternalServerError)
+		return
 	}
 
+	fileInfo, err := os.Stat(info.Path)
+	if err != nil || fileInfo.IsDir() {
+		httputil.WriteJSONError(w, "Report file not found", http.StatusNotFound)
+		return
+	}
+
+	serveReport(w, r, info.Path, info.Format)
+}
+
+func serveReport(w http.ResponseWriter, r *http.Request, filePath, format string) {
+	if filepath.Ext(filePath) == ".gz" {
+		format = "gz"
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filepath.Base(filePath),
+	}))
+	w.Header().Set("Content-Type", handlers.ReportContentType(format))
+
 	http.ServeFile(w, r, filePath)
 }
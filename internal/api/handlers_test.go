@@ -2,18 +2,29 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/metrics"
+	"github.com/nadmax/nexq/internal/middleware"
 	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/repository/mocks"
 	"github.com/nadmax/nexq/internal/repository/models"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/validation"
+	"github.com/nadmax/nexq/internal/version"
+	"github.com/nadmax/nexq/internal/worker/handlers"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,7 +36,10 @@ func setupTestAPI(t *testing.T) (*API, *queue.Queue, *miniredis.Miniredis) {
 	q, err := queue.NewQueue(mr.Addr(), nil)
 	require.NoError(t, err)
 
+	// Most tests enqueue types no worker registered; the check is covered
+	// by TestCreateTask_UnknownType.
 	api := NewAPI(q)
+	api.SetAllowUnknownTaskTypes(true)
 
 	return api, q, mr
 }
@@ -39,10 +53,19 @@ func setupTestAPIWithMockRepo(t *testing.T) (*API, *queue.Queue, *mocks.MockPost
 	require.NoError(t, err)
 
 	api := NewAPI(q)
+	api.SetAllowUnknownTaskTypes(true)
 
 	return api, q, mockRepo, mr
 }
 
+func validEmailPayload() map[string]any {
+	return map[string]any{
+		"to":      "test@example.com",
+		"subject": "Welcome",
+		"body":    "Hello!",
+	}
+}
+
 func TestCreateTask(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
@@ -50,7 +73,7 @@ func TestCreateTask(t *testing.T) {
 
 	reqBody := TaskRequest{
 		Type:    "send_email",
-		Payload: map[string]any{"to": "test@example.com"},
+		Payload: validEmailPayload(),
 	}
 	body, _ := json.Marshal(reqBody)
 
@@ -68,6 +91,164 @@ func TestCreateTask(t *testing.T) {
 	assert.Equal(t, "send_email", tsk.Type)
 	assert.NotEmpty(t, tsk.ID)
 	assert.Equal(t, task.MediumPriority, tsk.Priority)
+	assert.Equal(t, "/api/tasks/"+tsk.ID, w.Header().Get("Location"))
+}
+
+func TestCreateTask_LocationResolvesToTask(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload()})
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	location := w.Header().Get("Location")
+	require.NotEmpty(t, location)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, location, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tsk task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+	assert.Equal(t, location, "/api/tasks/"+tsk.ID)
+	assert.Equal(t, task.PendingStatus, tsk.Status)
+}
+
+func TestCreateTask_GzipBody(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload()})
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	middleware.DecompressGzip(api).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	stored, err := q.GetTask(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "send_email", stored.Type)
+	assert.Equal(t, "test@example.com", stored.Payload["to"])
+}
+
+func TestCreateTask_InvalidPayload(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(TaskRequest{
+		Type:    "send_email",
+		Payload: map[string]any{"to": "not-an-address"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	api.createTask(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp struct {
+		Error  string                  `json:"error"`
+		Fields []validation.FieldError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Invalid payload", resp.Error)
+	assert.Equal(t, []validation.FieldError{
+		{Field: "to", Message: "must be a valid email address"},
+		{Field: "subject", Message: "is required"},
+		{Field: "body", Message: "is required"},
+	}, resp.Fields)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Empty(t, tasks, "invalid payloads must not be enqueued")
+}
+
+func TestCreateTask_CustomPayloadValidator(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	api.RegisterPayloadValidator("resize_image", func(payload map[string]any) []validation.FieldError {
+		if _, ok := payload["url"]; !ok {
+			return []validation.FieldError{{Field: "url", Message: "is required"}}
+		}
+		return nil
+	})
+
+	body, _ := json.Marshal(TaskRequest{Type: "resize_image", Payload: map[string]any{}})
+	w := httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body, _ = json.Marshal(TaskRequest{Type: "resize_image", Payload: map[string]any{"url": "https://example.com/a.png"}})
+	w = httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateTask_CorrelationID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload()})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	req.Header.Set(middleware.RequestIDHeader, "req-abc-123")
+	w := httptest.NewRecorder()
+
+	middleware.RequestID(api).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "req-abc-123", w.Header().Get(middleware.RequestIDHeader))
+
+	var created task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "req-abc-123", created.CorrelationID)
+
+	stored, err := q.GetTask(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "req-abc-123", stored.CorrelationID)
+}
+
+func TestCreateTask_CallbackURL(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload(), CallbackURL: "https://example.com/hooks/nexq"})
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	stored, err := q.GetTask(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/hooks/nexq", stored.CallbackURL)
+
+	for _, invalid := range []string{"ftp://example.com/hook", "/relative/hook"} {
+		body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload(), CallbackURL: invalid})
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+		assert.Contains(t, w.Body.String(), "callback_url")
+	}
 }
 
 func TestCreateTaskWithHistory(t *testing.T) {
@@ -77,7 +258,7 @@ func TestCreateTaskWithHistory(t *testing.T) {
 
 	req := TaskRequest{
 		Type:    "send_email",
-		Payload: map[string]any{"to": "test@example.com"},
+		Payload: validEmailPayload(),
 	}
 	body, err := json.Marshal(req)
 	require.NoError(t, err)
@@ -101,43 +282,55 @@ func TestCreateTaskWithHistory(t *testing.T) {
 	assert.Equal(t, task.PendingStatus, status, "Task should be pending")
 }
 
-func TestCreateTask_WithPriority(t *testing.T) {
+func TestCreateTask_KnownType(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
+	api.SetAllowUnknownTaskTypes(false)
 
-	priority := task.HighPriority
-	reqBody := TaskRequest{
-		Type:     "send_email",
-		Payload:  map[string]any{"to": "test@example.com"},
-		Priority: &priority,
-	}
-	body, _ := json.Marshal(reqBody)
+	require.NoError(t, q.RegisterTaskTypes("send_email"))
 
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload()})
 	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	api.createTask(w, req)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
+}
 
-	var tsk task.Task
-	err := json.Unmarshal(w.Body.Bytes(), &tsk)
+func TestCreateTask_UnknownType(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+	api.SetAllowUnknownTaskTypes(false)
+
+	require.NoError(t, q.RegisterTaskTypes("send_email"))
+
+	body, _ := json.Marshal(TaskRequest{Type: "fly_to_mars", Payload: map[string]any{}})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	api.createTask(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown task type: fly_to_mars")
+
+	tasks, err := q.GetAllTasks()
 	require.NoError(t, err)
-	assert.Equal(t, task.HighPriority, tsk.Priority)
+	assert.Empty(t, tasks)
 }
 
-func TestCreateTask_WithSchedule(t *testing.T) {
+func TestCreateTask_WithPriority(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	scheduleIn := 60
+	priority := task.HighPriority
 	reqBody := TaskRequest{
-		Type:       "send_email",
-		Payload:    map[string]any{"to": "test@example.com"},
-		ScheduleIn: &scheduleIn,
+		Type:     "send_email",
+		Payload:  validEmailPayload(),
+		Priority: &priority,
 	}
 	body, _ := json.Marshal(reqBody)
 
@@ -152,34 +345,36 @@ func TestCreateTask_WithSchedule(t *testing.T) {
 	var tsk task.Task
 	err := json.Unmarshal(w.Body.Bytes(), &tsk)
 	require.NoError(t, err)
-	assert.True(t, tsk.ScheduledAt.After(tsk.CreatedAt))
+	assert.Equal(t, task.HighPriority, tsk.Priority)
 }
 
-func TestCreateTask_InvalidJSON(t *testing.T) {
+func TestCreateTask_WithPriorityName(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBufferString("invalid json"))
+	body := `{"type": "send_email", "payload": {"to": "a@example.com", "subject": "Hi", "body": "Hello"}, "priority": "low"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"priority":"low"`)
+
+	var tsk task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+	assert.Equal(t, task.LowPriority, tsk.Priority)
 }
 
-func TestCreateTask_MissingType(t *testing.T) {
+func TestCreateTask_WithUnknownPriorityName(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	reqBody := TaskRequest{
-		Payload: map[string]any{"to": "test@example.com"},
-	}
-	body, _ := json.Marshal(reqBody)
-
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	body := `{"type": "send_email", "payload": {"to": "a@example.com", "subject": "Hi", "body": "Hello"}, "priority": "urgent"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -188,526 +383,1756 @@ func TestCreateTask_MissingType(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestListTasks(t *testing.T) {
+func TestCreateTask_OutOfRangePriority(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	task1 := task.NewTask("task1", nil, task.MediumPriority)
-	task2 := task.NewTask("task2", nil, task.HighPriority)
-	err := q.Enqueue(task1)
-	assert.NoError(t, err)
-	err = q.Enqueue(task2)
-	assert.NoError(t, err)
+	tests := []struct {
+		priority string
+		expected task.TaskPriority
+	}{
+		{"-5", task.LowPriority},
+		{"7", task.HighPriority},
+	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
-	w := httptest.NewRecorder()
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			body := `{"type": "send_email", "payload": {"to": "a@example.com", "subject": "Hi", "body": "Hello"}, "priority": ` + tt.priority + `}`
+			w := httptest.NewRecorder()
+			api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBufferString(body)))
+			require.Equal(t, http.StatusCreated, w.Code)
+
+			var tsk task.Task
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+			assert.Equal(t, tt.expected, tsk.Priority)
+		})
+	}
+}
 
-	api.listTasks(w, req)
+func TestCreateTask_RetryDelay(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	for _, tt := range []struct {
+		delay    int
+		expected int
+	}{
+		{30, http.StatusCreated},
+		{0, http.StatusCreated},
+		{-1, http.StatusBadRequest},
+	} {
+		delay := tt.delay
+		body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload(), RetryDelaySeconds: &delay})
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		api.createTask(w, req)
+
+		require.Equal(t, tt.expected, w.Code, "retry_delay_seconds=%d", tt.delay)
+		if tt.expected == http.StatusCreated {
+			var tsk task.Task
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+			require.NotNil(t, tsk.RetryDelaySeconds)
+			assert.Equal(t, tt.delay, *tsk.RetryDelaySeconds)
+		}
+	}
+}
 
-	var tasks []*task.Task
-	err = json.Unmarshal(w.Body.Bytes(), &tasks)
-	require.NoError(t, err)
-	assert.Len(t, tasks, 2)
+func TestCreateTask_TTL(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, tt := range []struct {
+		ttl      int
+		expected int
+	}{
+		{60, http.StatusCreated},
+		{0, http.StatusBadRequest},
+		{-5, http.StatusBadRequest},
+	} {
+		ttl := tt.ttl
+		body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload(), TTLSeconds: &ttl})
+		w := httptest.NewRecorder()
+
+		api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+
+		require.Equal(t, tt.expected, w.Code, "ttl_seconds=%d", tt.ttl)
+		if tt.expected == http.StatusCreated {
+			var tsk task.Task
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+			require.NotNil(t, tsk.ExpiresAt)
+			assert.WithinDuration(t, tsk.CreatedAt.Add(time.Minute), *tsk.ExpiresAt, time.Second)
+		}
+	}
 }
 
-func TestListTasks_Empty(t *testing.T) {
+func TestCreateTask_QueueFull(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
-	w := httptest.NewRecorder()
+	q.SetMaxQueueDepth(1)
 
-	api.listTasks(w, req)
+	for _, expected := range []int{http.StatusCreated, http.StatusServiceUnavailable} {
+		body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: validEmailPayload()})
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
 
-	assert.Equal(t, http.StatusOK, w.Code)
+		api.createTask(w, req)
 
-	var tasks []*task.Task
-	err := json.Unmarshal(w.Body.Bytes(), &tasks)
-	require.NoError(t, err)
-	assert.Len(t, tasks, 0)
+		require.Equal(t, expected, w.Code)
+		if expected == http.StatusServiceUnavailable {
+			assert.Equal(t, "5", w.Header().Get("Retry-After"))
+			assert.Contains(t, w.Body.String(), "Queue is full")
+		}
+	}
 }
 
-func TestGetTaskByID(t *testing.T) {
+func TestCreateTask_PayloadTooLarge(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
-	err := q.Enqueue(tsk)
-	assert.NoError(t, err)
+	payload := validEmailPayload()
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil)
-	w := httptest.NewRecorder()
+	for _, tc := range []struct {
+		limit    int
+		expected int
+	}{
+		{limit: len(data), expected: http.StatusCreated},
+		{limit: len(data) - 1, expected: http.StatusRequestEntityTooLarge},
+	} {
+		q.SetMaxPayloadSize(tc.limit)
 
-	api.handleTaskByID(w, req)
+		body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: payload})
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
 
-	assert.Equal(t, http.StatusOK, w.Code)
+		api.createTask(w, req)
 
-	var retrieved task.Task
-	err = json.Unmarshal(w.Body.Bytes(), &retrieved)
-	require.NoError(t, err)
-	assert.Equal(t, tsk.ID, retrieved.ID)
-	assert.Equal(t, tsk.Type, retrieved.Type)
+		assert.Equal(t, tc.expected, w.Code, "limit %d", tc.limit)
+	}
 }
 
-func TestGetTaskByID_NotFound(t *testing.T) {
+func TestCreateTask_ReservedPayloadField(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks/non-existent", nil)
+	payload := validEmailPayload()
+	payload["_encrypted"] = "not really ciphertext"
+
+	body, _ := json.Marshal(TaskRequest{Type: "send_email", Payload: payload})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
 
-	api.handleTaskByID(w, req)
+	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "reserved _encrypted field")
 }
 
-func TestHandleTasks_MethodNotAllowed(t *testing.T) {
+func TestCreateTask_WithSchedule(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/tasks", nil)
+	scheduleIn := 60
+	reqBody := TaskRequest{
+		Type:       "send_email",
+		Payload:    validEmailPayload(),
+		ScheduleIn: &scheduleIn,
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	api.handleTasks(w, req)
+	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var tsk task.Task
+	err := json.Unmarshal(w.Body.Bytes(), &tsk)
+	require.NoError(t, err)
+	assert.True(t, tsk.ScheduledAt.After(tsk.CreatedAt))
 }
 
-func TestHandleTaskByID_MethodNotAllowed(t *testing.T) {
+func TestCreateTask_InvalidJSON(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks/123", nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBufferString("invalid json"))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	api.handleTaskByID(w, req)
+	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestServeHTTP(t *testing.T) {
+func TestCreateTask_MissingType(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	reqBody := TaskRequest{
+		Payload: validEmailPayload(),
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	api.ServeHTTP(w, req)
+	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestHandleCancelTask_Success(t *testing.T) {
+func TestCreateTask_IdempotencyKey(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("send_email", map[string]any{"to": "test@example.com"}, task.MediumPriority)
-	err := q.Enqueue(tsk)
-	require.NoError(t, err)
+	reqBody := TaskRequest{
+		Type:           "send_email",
+		Payload:        validEmailPayload(),
+		IdempotencyKey: "order-123",
+	}
+	body, _ := json.Marshal(reqBody)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/"+tsk.ID, nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
+	api.createTask(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
 
-	api.handleCancelTask(w, req)
+	var first task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
 
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	api.createTask(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response map[string]string
-	err = json.NewDecoder(w.Body).Decode(&response)
+	var second task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Equal(t, first.ID, second.ID)
+
+	tasks, err := q.GetAllTasks()
 	require.NoError(t, err)
-	assert.Equal(t, "Task cancelled successfully", response["message"])
-	assert.Equal(t, tsk.ID, response["task_id"])
+	assert.Len(t, tasks, 1)
 }
 
-func TestHandleCancelTask_MethodNotAllowed(t *testing.T) {
+func TestCreateTask_DedupeWindow(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/tasks/cancel/task-123", nil)
+	window := 30
+	body, _ := json.Marshal(TaskRequest{
+		Type:                "send_email",
+		Payload:             validEmailPayload(),
+		DedupeWindowSeconds: &window,
+	})
+
 	w := httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusCreated, w.Code)
 
-	api.handleCancelTask(w, req)
+	var first task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusOK, w.Code)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Method not allowed")
+	var second task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Equal(t, first.ID, second.ID)
+
+	mr.FastForward(time.Minute)
+
+	w = httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
 }
 
-func TestHandleCancelTask_MissingTaskID(t *testing.T) {
+func TestCreateTask_DedupeWindowInvalid(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/", nil)
+	zero, window := 0, 30
+	tests := []struct {
+		name string
+		req  TaskRequest
+	}{
+		{"zero window", TaskRequest{Type: "send_email", Payload: validEmailPayload(), DedupeWindowSeconds: &zero}},
+		{"with idempotency key", TaskRequest{Type: "send_email", Payload: validEmailPayload(), DedupeWindowSeconds: &window, IdempotencyKey: "order-123"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.req)
+			w := httptest.NewRecorder()
+			api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestCreateTask_CustomID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	id := "order-42"
+	body, _ := json.Marshal(TaskRequest{ID: &id, Type: "send_email", Payload: validEmailPayload()})
+
 	w := httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	require.Equal(t, http.StatusCreated, w.Code)
 
-	api.handleCancelTask(w, req)
+	var created task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, id, created.ID)
+	assert.Equal(t, "/api/tasks/"+id, w.Header().Get("Location"))
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusConflict, w.Code)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
+	tasks, err := q.GetAllTasks()
 	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Task ID required")
+	assert.Len(t, tasks, 1)
 }
 
-func TestHandleCancelTask_NotFound(t *testing.T) {
+func TestCreateTask_CustomIDWithIdempotencyKey(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/non-existent-task", nil)
+	id := "order-42"
+	body, _ := json.Marshal(TaskRequest{ID: &id, Type: "send_email", Payload: validEmailPayload(), IdempotencyKey: "order-42"})
+
+	for _, expected := range []int{http.StatusCreated, http.StatusOK} {
+		w := httptest.NewRecorder()
+		api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+		require.Equal(t, expected, w.Code)
+
+		var got task.Task
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, id, got.ID)
+	}
+}
+
+func TestCreateTask_InvalidCustomID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, id := range []string{"", "a/b", "a b", strings.Repeat("x", maxTaskIDLength+1)} {
+		body, _ := json.Marshal(TaskRequest{ID: &id, Type: "send_email", Payload: validEmailPayload()})
+		w := httptest.NewRecorder()
+		api.createTask(w, httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "id %q", id)
+	}
+}
+
+func TestCreateTask_WithDependencies(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	parent := task.NewTask("parent", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(parent))
+
+	body, _ := json.Marshal(TaskRequest{Type: "child", DependsOn: []string{parent.ID}})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
+	api.createTask(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
 
-	api.handleCancelTask(w, req)
+	var tsk task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tsk))
+	assert.Equal(t, []string{parent.ID}, tsk.DependsOn)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	body, _ = json.Marshal(TaskRequest{Type: "child", DependsOn: []string{"missing"}})
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	api.createTask(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestHandleDLQTasks_Success(t *testing.T) {
+func TestListTasks(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks", nil)
+	task1 := task.NewTask("task1", nil, task.MediumPriority)
+	task2 := task.NewTask("task2", nil, task.HighPriority)
+	err := q.Enqueue(task1)
+	assert.NoError(t, err)
+	err = q.Enqueue(task2)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTasks(w, req)
+	api.listTasks(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var tasks []*task.Task
-	err := json.NewDecoder(w.Body).Decode(&tasks)
+	err = json.Unmarshal(w.Body.Bytes(), &tasks)
 	require.NoError(t, err)
-	// When there are no tasks in DLQ, it should return an empty slice
-	assert.Len(t, tasks, 0)
+	assert.Len(t, tasks, 2)
 }
 
-func TestHandleDLQTasks_MethodNotAllowed(t *testing.T) {
+func TestCreateTask_WithTags(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks", nil)
+	reqBody := TaskRequest{
+		Type:    "send_email",
+		Payload: validEmailPayload(),
+		Tags:    []string{"tenant-a", "batch-42"},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
 
-	api.handleDLQTasks(w, req)
+	api.createTask(w, req)
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var tsk task.Task
+	err := json.Unmarshal(w.Body.Bytes(), &tsk)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant-a", "batch-42"}, tsk.Tags)
 }
 
-func TestGetDLQTask_Success(t *testing.T) {
+func TestListTasks_FilterByTag(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	// Create a task and move it to DLQ
-	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
-	tsk.RetryCount = 3
-	err := q.MoveToDeadLetter(tsk, "max retries exceeded")
-	require.NoError(t, err)
+	task1 := task.NewTask("task1", nil, task.MediumPriority)
+	task1.Tags = []string{"tenant-a"}
+	task2 := task.NewTask("task2", nil, task.HighPriority)
+	task2.Tags = []string{"tenant-b"}
+	require.NoError(t, q.Enqueue(task1))
+	require.NoError(t, q.Enqueue(task2))
 
-	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/"+tsk.ID, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks?tag=tenant-b", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.listTasks(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var retrieved task.Task
-	err = json.NewDecoder(w.Body).Decode(&retrieved)
+	var tasks []*task.Task
+	err := json.Unmarshal(w.Body.Bytes(), &tasks)
 	require.NoError(t, err)
-	assert.Equal(t, tsk.ID, retrieved.ID)
-	assert.Equal(t, tsk.Type, retrieved.Type)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task2.ID, tasks[0].ID)
 }
 
-func TestGetDLQTask_NotFound(t *testing.T) {
+func TestListTasks_Empty(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/non-existent", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.listTasks(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tasks []*task.Task
+	err := json.Unmarshal(w.Body.Bytes(), &tasks)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 0)
 }
 
-func TestPurgeDLQTask_Success(t *testing.T) {
+func TestGetTaskByID(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
-	err := q.MoveToDeadLetter(tsk, "test error")
-	require.NoError(t, err)
+	tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	err := q.Enqueue(tsk)
+	assert.NoError(t, err)
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/dlq/tasks/"+tsk.ID, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.handleTaskByID(w, req)
 
-	assert.Equal(t, http.StatusNoContent, w.Code)
-	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var retrieved task.Task
+	err = json.Unmarshal(w.Body.Bytes(), &retrieved)
+	require.NoError(t, err)
+	assert.Equal(t, tsk.ID, retrieved.ID)
+	assert.Equal(t, tsk.Type, retrieved.Type)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Equal(t, "queue", raw["source"])
 }
 
-func TestRetryDLQTask_Success(t *testing.T) {
+func TestGetTaskByID_DeadLettered(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
-	err := q.MoveToDeadLetter(tsk, "test error")
-	require.NoError(t, err)
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	require.NoError(t, q.MoveToDeadLetter(tsk, "boom"))
 
-	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/"+tsk.ID+"/retry", nil)
 	w := httptest.NewRecorder()
+	api.handleTaskByID(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil))
 
-	api.handleDLQTaskByID(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	var response map[string]string
-	err = json.NewDecoder(w.Body).Decode(&response)
-	require.NoError(t, err)
-	assert.Equal(t, "Task moved back to queue for retry", response["message"])
-	assert.Equal(t, tsk.ID, response["task_id"])
+	require.Equal(t, http.StatusOK, w.Code)
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Equal(t, "queue", raw["source"])
+	assert.Equal(t, string(task.DeadLetterStatus), raw["status"])
 }
 
-func TestRetryDLQTask_NotFound(t *testing.T) {
+func TestGetTaskByID_ETag(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/non-existent/retry", nil)
+	tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
 	w := httptest.NewRecorder()
+	api.handleTaskByID(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
 
-	api.handleDLQTaskByID(w, req)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	api.handleTaskByID(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	tsk.Status = task.RunningStatus
+	require.NoError(t, q.UpdateTask(tsk))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	api.handleTaskByID(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "a changed task is sent again")
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
 }
 
-func TestHandleDLQTaskByID_MissingTaskID(t *testing.T) {
+func TestGetTaskByID_Pretty(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/", nil)
-	w := httptest.NewRecorder()
+	tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
 
-	api.handleDLQTaskByID(w, req)
+	w := httptest.NewRecorder()
+	api.handleTaskByID(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, bytes.Count(w.Body.Bytes(), []byte("\n")), "compact output is a single line")
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	api.handleTaskByID(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID+"?pretty=true", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Greater(t, bytes.Count(w.Body.Bytes(), []byte("\n")), 1)
+	assert.Contains(t, w.Body.String(), "\n  \"id\": ")
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Task ID is required")
+	var retrieved task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &retrieved))
+	assert.Equal(t, tsk.ID, retrieved.ID)
 }
 
-func TestHandleDLQTaskByID_InvalidEndpoint(t *testing.T) {
-	api, q, mr := setupTestAPI(t)
+func TestGetTaskByID_FallsBackToHistory(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/task-123/invalid", nil)
+	archived := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	archived.Status = task.CompletedStatus
+	mockRepo.Tasks[archived.ID] = archived
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+archived.ID, nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.handleTaskByID(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, http.StatusOK, w.Code)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Invalid endpoint")
+	var retrieved task.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &retrieved))
+	assert.Equal(t, archived.ID, retrieved.ID)
+	assert.Equal(t, task.CompletedStatus, retrieved.Status)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Equal(t, "history", raw["source"])
+	assert.Equal(t, []string{archived.ID}, mockRepo.GetTaskCalls)
 }
 
-func TestHandleDLQTaskByID_MethodNotAllowed(t *testing.T) {
-	api, q, mr := setupTestAPI(t)
+func TestGetTaskByID_NotFoundInHistory(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPut, "/api/dlq/tasks/task-123", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/non-existent", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.handleTaskByID(w, req)
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestHandleDLQStats_Success(t *testing.T) {
+func TestGetTaskByID_UnixMillisTimeFormat(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	// Add some tasks to DLQ
-	tsk1 := task.NewTask("task_type_1", nil, task.MediumPriority)
-	tsk2 := task.NewTask("task_type_2", nil, task.HighPriority)
+	api.SetTimeFormat(task.UnixMillisTimeFormat)
 
-	err := q.MoveToDeadLetter(tsk1, "error 1")
-	require.NoError(t, err)
-	err = q.MoveToDeadLetter(tsk2, "error 2")
-	require.NoError(t, err)
+	tsk := task.NewTask("test", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
 
-	req := httptest.NewRequest(http.MethodGet, "/api/dlq/stats", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+tsk.ID, nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQStats(w, req)
+	api.handleTaskByID(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var stats map[string]any
-	err = json.NewDecoder(w.Body).Decode(&stats)
-	require.NoError(t, err)
-	assert.NotNil(t, stats)
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Equal(t, float64(tsk.CreatedAt.UnixMilli()), raw["created_at"])
 }
 
-func TestHandleDLQStats_MethodNotAllowed(t *testing.T) {
+func TestGetTaskByID_NotFound(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/dlq/stats", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/non-existent", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQStats(w, req)
-
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	api.handleTaskByID(w, req)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Method not allowed")
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestHandleCancelTask_CannotCancel(t *testing.T) {
+func TestGetTaskByID_StoreUnavailable(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
-	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("send_email", nil, task.MediumPriority)
-	err := q.Enqueue(tsk)
-	require.NoError(t, err)
-
-	_, err = q.Dequeue()
-	require.NoError(t, err)
+	mr.Close()
 
-	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/"+tsk.ID, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/some-id", nil)
 	w := httptest.NewRecorder()
 
-	api.handleCancelTask(w, req)
+	api.handleTaskByID(w, req)
 
-	if w.Code == http.StatusBadRequest {
-		var errResp map[string]string
-		err = json.NewDecoder(w.Body).Decode(&errResp)
-		require.NoError(t, err)
-		assert.NotEmpty(t, errResp["error"])
-	}
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
-func TestPurgeDLQTask_NonExistent(t *testing.T) {
+func TestHandleTasks_MethodNotAllowed(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/dlq/tasks/non-existent", nil)
+	req := httptest.NewRequest(http.MethodDelete, "/api/tasks", nil)
 	w := httptest.NewRecorder()
 
-	api.handleDLQTaskByID(w, req)
+	api.handleTasks(w, req)
 
-	assert.Equal(t, http.StatusNoContent, w.Code)
-	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
-func TestHistoryStatsWithMockRepo(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestHandleTaskByID_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.TaskStats = []models.TaskStats{
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/123", nil)
+	w := httptest.NewRecorder()
+
+	api.handleTaskByID(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestServeHTTP(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleCancelTask_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("send_email", map[string]any{"to": "test@example.com"}, task.MediumPriority)
+	err := q.Enqueue(tsk)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/"+tsk.ID, nil)
+	w := httptest.NewRecorder()
+
+	api.handleCancelTask(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]string
+	err = json.NewDecoder(w.Body).Decode(&response)
+	require.NoError(t, err)
+	assert.Equal(t, "Task cancelled successfully", response["message"])
+	assert.Equal(t, tsk.ID, response["task_id"])
+}
+
+func TestHandleCancelTask_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/cancel/task-123", nil)
+	w := httptest.NewRecorder()
+
+	api.handleCancelTask(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Method not allowed")
+}
+
+func TestHandleCancelTask_MissingTaskID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/", nil)
+	w := httptest.NewRecorder()
+
+	api.handleCancelTask(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Task ID required")
+}
+
+func TestHandleCancelTask_NotFound(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/non-existent-task", nil)
+	w := httptest.NewRecorder()
+
+	api.handleCancelTask(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleDLQTasks_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTasks(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tasks []*task.Task
+	err := json.NewDecoder(w.Body).Decode(&tasks)
+	require.NoError(t, err)
+	// When there are no tasks in DLQ, it should return an empty slice
+	assert.Len(t, tasks, 0)
+}
+
+func TestHandleDLQTasks_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTasks(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestGetDLQTask_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	// Create a task and move it to DLQ
+	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
+	tsk.RetryCount = 3
+	err := q.MoveToDeadLetter(tsk, "max retries exceeded")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/"+tsk.ID, nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var retrieved task.Task
+	err = json.NewDecoder(w.Body).Decode(&retrieved)
+	require.NoError(t, err)
+	assert.Equal(t, tsk.ID, retrieved.ID)
+	assert.Equal(t, tsk.Type, retrieved.Type)
+}
+
+func TestGetDLQTask_NotFound(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/non-existent", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPurgeDLQTask_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
+	err := q.MoveToDeadLetter(tsk, "test error")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/dlq/tasks/"+tsk.ID, nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestRetryDLQTask_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("failed_task", map[string]any{"data": "test"}, task.MediumPriority)
+	err := q.MoveToDeadLetter(tsk, "test error")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/"+tsk.ID+"/retry", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]string
+	err = json.NewDecoder(w.Body).Decode(&response)
+	require.NoError(t, err)
+	assert.Equal(t, "Task moved back to queue for retry", response["message"])
+	assert.Equal(t, tsk.ID, response["task_id"])
+}
+
+func TestRetryDLQTask_NotFound(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/non-existent/retry", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestHandleDLQTaskByID_MissingTaskID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dlq/tasks/", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Task ID is required")
+}
+
+func TestHandleDLQTaskByID_InvalidEndpoint(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dlq/tasks/task-123/invalid", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Invalid endpoint")
+}
+
+func TestHandleDLQTaskByID_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/dlq/tasks/task-123", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleDLQStats_Success(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	// Add some tasks to DLQ
+	tsk1 := task.NewTask("task_type_1", nil, task.MediumPriority)
+	tsk2 := task.NewTask("task_type_2", nil, task.HighPriority)
+
+	err := q.MoveToDeadLetter(tsk1, "error 1")
+	require.NoError(t, err)
+	err = q.MoveToDeadLetter(tsk2, "error 2")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dlq/stats", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQStats(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats map[string]any
+	err = json.NewDecoder(w.Body).Decode(&stats)
+	require.NoError(t, err)
+	assert.NotNil(t, stats)
+}
+
+func TestHandleQueueStats(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var pending []*task.Task
+	for range 3 {
+		tsk := task.NewTask("send_email", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+		pending = append(pending, tsk)
+	}
+	require.NoError(t, q.DeleteTask(pending[0].ID))
+	require.NoError(t, q.MoveToDeadLetter(task.NewTask("generate_report", nil, task.LowPriority), "boom"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/queue/stats", nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats QueueStatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, 2, stats.QueueDepth)
+	assert.Equal(t, 1, stats.DeadLetterDepth)
+	assert.Equal(t, map[task.TaskStatus]int{
+		task.PendingStatus:    2,
+		task.DeadLetterStatus: 1,
+	}, stats.TasksByStatus)
+}
+
+func TestHandleQueuePeek(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var enqueued []*task.Task
+	for range 3 {
+		tsk := task.NewTask("send_email", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+		enqueued = append(enqueued, tsk)
+	}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/queue/peek?n=2", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var tasks []task.Task
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tasks))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, enqueued[0].ID, tasks[0].ID)
+	assert.Equal(t, enqueued[1].ID, tasks[1].ID)
+
+	depth, err := q.Depth()
+	require.NoError(t, err)
+	assert.Equal(t, 3, depth)
+}
+
+func TestHandleQueuePeek_InvalidN(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, n := range []string{"0", "-1", "abc", "101"} {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/queue/peek?n="+n, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "n=%s", n)
+	}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/queue/peek", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleQueueStats_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/queue/stats", nil)
+	w := httptest.NewRecorder()
+
+	api.handleQueueStats(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleTaskAck(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/"+tsk.ID+"/ack", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	acked, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, acked.Status)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandleTaskAck_WorkerOwned(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Claim("worker-1")
+	require.NoError(t, err)
+
+	for _, action := range []string{"ack", "nack"} {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+tsk.ID+"/"+action, nil))
+		assert.Equal(t, http.StatusConflict, w.Code, action)
+	}
+}
+
+func TestHandleTaskRequeue(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	completed := task.NewTask("send_email", validEmailPayload(), task.MediumPriority)
+	require.NoError(t, q.Enqueue(completed))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NoError(t, q.Ack(completed.ID))
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+completed.ID+"/requeue", nil))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var requeued task.Task
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&requeued))
+	assert.NotEqual(t, completed.ID, requeued.ID)
+	assert.Equal(t, "/api/tasks/"+requeued.ID, w.Header().Get("Location"))
+	assert.Equal(t, task.PendingStatus, requeued.Status)
+	assert.Equal(t, completed.Payload, requeued.Payload)
+
+	failed := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(failed))
+	failed.Status = task.FailedStatus
+	require.NoError(t, q.UpdateTask(failed))
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+failed.ID+"/requeue", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestHandleTaskRequeue_Errors(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	pending := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(pending))
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+pending.ID+"/requeue", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/missing/requeue", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/"+pending.ID+"/requeue", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleTaskNack(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	requeued := task.NewTask("send_email", nil, task.MediumPriority)
+	dropped := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(requeued))
+	require.NoError(t, q.Enqueue(dropped))
+	for range 2 {
+		_, err := q.Dequeue()
+		require.NoError(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+requeued.ID+"/nack", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+dropped.ID+"/nack?requeue=false", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	next, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, requeued.ID, next.ID)
+
+	_, err = q.GetDeadLetterTask(dropped.ID)
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/"+next.ID+"/nack?requeue=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleTaskAck_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/some-id/ack", nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleWorkers(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/workers", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	require.NoError(t, q.Heartbeat(queue.WorkerInfo{ID: "worker-1", LastSeen: time.Now(), CurrentTask: "task-1"}, time.Minute))
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+
+	var workers []queue.WorkerInfo
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&workers))
+	require.Len(t, workers, 1)
+	assert.Equal(t, "worker-1", workers[0].ID)
+	assert.Equal(t, "task-1", workers[0].CurrentTask)
+}
+
+func TestHandleWorkers_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/workers", nil)
+	w := httptest.NewRecorder()
+
+	api.handleWorkers(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleDLQStats_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/dlq/stats", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQStats(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Method not allowed")
+}
+
+func TestHandleCancelTask_CannotCancel(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	err := q.Enqueue(tsk)
+	require.NoError(t, err)
+
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel/"+tsk.ID, nil)
+	w := httptest.NewRecorder()
+
+	api.handleCancelTask(w, req)
+
+	if w.Code == http.StatusBadRequest {
+		var errResp map[string]string
+		err = json.NewDecoder(w.Body).Decode(&errResp)
+		require.NoError(t, err)
+		assert.NotEmpty(t, errResp["error"])
+	}
+}
+
+func TestPurgeDLQTask_NonExistent(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/dlq/tasks/non-existent", nil)
+	w := httptest.NewRecorder()
+
+	api.handleDLQTaskByID(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestHistoryStatsWithMockRepo(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.TaskStats = []models.TaskStats{
+		{
+			Type:          "send_email",
+			Status:        "completed",
+			Count:         10,
+			AvgDurationMs: 250.5,
+			MaxDurationMs: 500,
+			MinDurationMs: 100,
+			AvgRetries:    0.2,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/history/stats", nil)
+
+	api.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats []models.TaskStats
+	err := json.NewDecoder(w.Body).Decode(&stats)
+	require.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "send_email", stats[0].Type)
+	assert.Equal(t, 10, stats[0].Count)
+}
+
+func TestHistoryStatsWithoutRepo(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/history/stats", nil)
+
+	api.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandleStats(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.TaskStats = []models.TaskStats{
 		{
 			Type:          "send_email",
 			Status:        "completed",
-			Count:         10,
-			AvgDurationMs: 250.5,
-			MaxDurationMs: 500,
-			MinDurationMs: 100,
-			AvgRetries:    0.2,
+			Count:         4,
+			AvgDurationMs: 120.5,
+			MaxDurationMs: 200,
+			MinDurationMs: 80,
+			AvgRetries:    0.5,
 		},
 	}
 
+	t.Run("defaults to 24 hours", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/stats", nil)
+
+		api.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var stats []map[string]any
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+		require.Len(t, stats, 1)
+		assert.Equal(t, "send_email", stats[0]["type"])
+		assert.Equal(t, "completed", stats[0]["status"])
+		assert.Equal(t, float64(4), stats[0]["count"])
+		assert.Equal(t, 120.5, stats[0]["avg_duration_ms"])
+		assert.Equal(t, float64(200), stats[0]["max_duration_ms"])
+		assert.Equal(t, float64(80), stats[0]["min_duration_ms"])
+		assert.Equal(t, 0.5, stats[0]["avg_retries"])
+		assert.Equal(t, 24, mockRepo.TaskStatsHours[len(mockRepo.TaskStatsHours)-1])
+	})
+
+	t.Run("passes hours through", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/stats?hours=72", nil)
+
+		api.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 72, mockRepo.TaskStatsHours[len(mockRepo.TaskStatsHours)-1])
+	})
+
+	for _, hours := range []string{"0", "-5", "abc"} {
+		t.Run("rejects hours="+hours, func(t *testing.T) {
+			calls := len(mockRepo.TaskStatsHours)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/stats?hours="+hours, nil)
+
+			api.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Len(t, mockRepo.TaskStatsHours, calls)
+		})
+	}
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo.GetTaskStatsError = errors.New("database error")
+		defer func() { mockRepo.GetTaskStatsError = nil }()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/stats", nil)
+
+		api.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandleStatsWithoutRepo(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "/api/history/stats", nil)
+	r := httptest.NewRequest("GET", "/api/stats", nil)
 
 	api.ServeHTTP(w, r)
 
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandleRecentHistory_Success(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	duration1 := 250
+	duration2 := 150
+	mockRepo.RecentTasks = []models.RecentTask{
+		{
+			TaskID:     "task-1",
+			Type:       "send_email",
+			Status:     string(task.CompletedStatus),
+			CreatedAt:  time.Now().Add(-1 * time.Hour),
+			DurationMs: &duration1,
+		},
+		{
+			TaskID:     "task-2",
+			Type:       "notification",
+			Status:     string(task.CompletedStatus),
+			CreatedAt:  time.Now().Add(-30 * time.Minute),
+			DurationMs: &duration2,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+
+	api.handleRecentHistory(w, r)
+
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var stats []models.TaskStats
-	err := json.NewDecoder(w.Body).Decode(&stats)
+	var tasks []models.RecentTask
+	err := json.NewDecoder(w.Body).Decode(&tasks)
 	require.NoError(t, err)
-	assert.Len(t, stats, 1)
-	assert.Equal(t, "send_email", stats[0].Type)
-	assert.Equal(t, 10, stats[0].Count)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "send_email", tasks[0].Type)
+}
+
+func TestHandleRecentHistory_WithLimit(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.RecentTasks = []models.RecentTask{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent?limit=50", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleRecentHistory_WithOffset(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for i := range 5 {
+		mockRepo.RecentTasks = append(mockRepo.RecentTasks, models.RecentTask{
+			TaskID: fmt.Sprintf("task-%d", i),
+			Type:   "send_email",
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent?limit=2&offset=2", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+
+	var tasks []models.RecentTask
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tasks))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "task-2", tasks[0].TaskID)
+	assert.Equal(t, "task-3", tasks[1].TaskID)
+}
+
+func TestHandleRecentHistory_InvalidLimit(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.RecentTasks = []models.RecentTask{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent?limit=invalid", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleRecentHistory_MethodNotAllowed(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/history/recent", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleRecentHistory_NoRepository(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "PostgreSQL not configured")
+}
+
+func TestHandleRecentHistory_RepositoryError(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.GetRecentTasksError = errors.New("database connection failed")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+
+	api.handleRecentHistory(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "database connection failed")
+}
+
+func TestHandleTaskHistory_Success(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	taskID := "task-123"
+	mockRepo.ExecutionLog = []mocks.LogExecutionCall{
+		{
+			TaskID:        taskID,
+			AttemptNumber: 1,
+			Status:        "pending",
+			DurationMs:    0,
+			WorkerID:      "worker-1",
+		},
+		{
+			TaskID:        taskID,
+			AttemptNumber: 1,
+			Status:        "completed",
+			DurationMs:    250,
+			WorkerID:      "worker-1",
+		},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/task/"+taskID, nil)
+
+	api.handleTaskHistory(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var history []map[string]any
+	err := json.NewDecoder(w.Body).Decode(&history)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, taskID, history[0]["task_id"])
+	assert.Equal(t, "pending", history[0]["status"])
+	assert.Equal(t, "completed", history[1]["status"])
+}
+
+func TestHandleTaskHistory_Routed(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	taskID := "task-456"
+	require.NoError(t, q.LogExecution(taskID, 1, "failed", 120, "timeout", "worker-1"))
+	require.NoError(t, q.LogExecution(taskID, 2, "completed", 80, "", "worker-2"))
+	require.NoError(t, q.LogExecution("other-task", 1, "completed", 10, "", "worker-1"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/task/"+taskID, nil)
+
+	api.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var history []map[string]any
+	err := json.NewDecoder(w.Body).Decode(&history)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, float64(1), history[0]["attempt_number"])
+	assert.Equal(t, "timeout", history[0]["error_message"])
+	assert.Equal(t, "worker-2", history[1]["worker_id"])
+}
+
+func TestHandleTaskHistory_MissingTaskID(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/task/", nil)
+
+	api.handleTaskHistory(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Task ID is required")
+}
+
+func TestHandleTaskHistory_MethodNotAllowed(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/history/task/task-123", nil)
+
+	api.handleTaskHistory(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
-func TestHistoryStatsWithoutRepo(t *testing.T) {
+func TestHandleTaskHistory_NoRepository(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "/api/history/stats", nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/history/task/task-123", nil)
 
-	api.ServeHTTP(w, r)
+	api.handleTaskHistory(w, r)
 
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 }
 
-func TestHandleRecentHistory_Success(t *testing.T) {
+func TestHandleTaskHistory_RepositoryError(t *testing.T) {
 	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	duration1 := 250
-	duration2 := 150
+	mockRepo.GetTaskHistoryError = errors.New("query failed")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/task/task-123", nil)
+
+	api.handleTaskHistory(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "query failed")
+}
+
+func TestHandleTasksByType_Success(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	taskType := "send_email"
+	duration1 := 200
+	duration2 := 300
 	mockRepo.RecentTasks = []models.RecentTask{
 		{
 			TaskID:     "task-1",
-			Type:       "send_email",
+			Type:       taskType,
 			Status:     string(task.CompletedStatus),
-			CreatedAt:  time.Now().Add(-1 * time.Hour),
+			CreatedAt:  time.Now(),
 			DurationMs: &duration1,
 		},
 		{
 			TaskID:     "task-2",
-			Type:       "notification",
+			Type:       taskType,
 			Status:     string(task.CompletedStatus),
-			CreatedAt:  time.Now().Add(-30 * time.Minute),
+			CreatedAt:  time.Now(),
 			DurationMs: &duration2,
 		},
 	}
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/"+taskType, nil)
 
-	api.handleRecentHistory(w, r)
+	api.handleTasksByType(w, r)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -715,311 +2140,777 @@ func TestHandleRecentHistory_Success(t *testing.T) {
 	err := json.NewDecoder(w.Body).Decode(&tasks)
 	require.NoError(t, err)
 	assert.Len(t, tasks, 2)
-	assert.Equal(t, "send_email", tasks[0].Type)
+	assert.Equal(t, taskType, tasks[0].Type)
 }
 
-func TestHandleRecentHistory_WithLimit(t *testing.T) {
+func TestHandleTasksByType_WithLimit(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.RecentTasks = []models.RecentTask{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email?limit=25", nil)
+
+	api.handleTasksByType(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleTasksByType_WithOffset(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.RecentTasks = []models.RecentTask{
+		{TaskID: "task-1", Type: "send_email"},
+		{TaskID: "task-2", Type: "notification"},
+		{TaskID: "task-3", Type: "send_email"},
+		{TaskID: "task-4", Type: "send_email"},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email?limit=10&offset=1", nil)
+
+	api.handleTasksByType(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+
+	var tasks []models.RecentTask
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tasks))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "task-3", tasks[0].TaskID)
+	assert.Equal(t, "task-4", tasks[1].TaskID)
+}
+
+func TestHandleTasksByType_MissingType(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/", nil)
+
+	api.handleTasksByType(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "Task type is required")
+}
+
+func TestHandleTasksByType_MethodNotAllowed(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/api/history/type/send_email", nil)
+
+	api.handleTasksByType(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandleTasksByType_NoRepository(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email", nil)
+
+	api.handleTasksByType(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandleTasksByType_RepositoryError(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.GetTasksByTypeError = errors.New("database error")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email", nil)
+
+	api.handleTasksByType(w, r)
+
+	// Debug
+	t.Logf("Status Code: %d", w.Code)
+	t.Logf("Response: %s", w.Body.String())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var errResp map[string]string
+	err := json.NewDecoder(w.Body).Decode(&errResp)
+	require.NoError(t, err)
+	assert.Contains(t, errResp["error"], "database error")
+}
+
+func TestHandleTasksByTag_Success(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.RecentTasks = []models.RecentTask{
+		{TaskID: "task-1", Type: "send_email", Tags: []string{"tenant-a"}, CreatedAt: time.Now()},
+		{TaskID: "task-2", Type: "send_email", Tags: []string{"tenant-b"}, CreatedAt: time.Now()},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/tag/tenant-a", nil)
+
+	api.handleTasksByTag(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tasks []models.RecentTask
+	err := json.NewDecoder(w.Body).Decode(&tasks)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "task-1", tasks[0].TaskID)
+}
+
+func TestHandleTasksByTag_MissingTag(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/tag/", nil)
+
+	api.handleTasksByTag(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleTasksByTag_NoRepository(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/history/tag/tenant-a", nil)
+
+	api.handleTasksByTag(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandleDurationOutliers_Success(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.DurationOutliers = []models.DurationOutlier{
+		{TaskID: "task-1", Type: "generate_report", ExpectedDurationMs: 1000, DurationMs: 9000, BreachMs: 8000},
+		{TaskID: "task-2", Type: "send_email", ExpectedDurationMs: 200, DurationMs: 700, BreachMs: 500},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/stats/duration-outliers?hours=6", nil)
+
+	api.handleDurationOutliers(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var outliers []models.DurationOutlier
+	err := json.NewDecoder(w.Body).Decode(&outliers)
+	require.NoError(t, err)
+	require.Len(t, outliers, 2)
+	assert.Equal(t, "task-1", outliers[0].TaskID)
+	assert.Equal(t, 8000, outliers[0].BreachMs)
+}
+
+func TestHandleDurationOutliers_InvalidHours(t *testing.T) {
+	api, q, _, mr := setupTestAPIWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, hours := range []string{"abc", "-1"} {
+		t.Run(hours, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/stats/duration-outliers?hours="+hours, nil)
+
+			api.handleDurationOutliers(w, r)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "hours must be a positive integer")
+		})
+	}
+}
+
+func TestHandleDurationOutliers_NoRepository(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/stats/duration-outliers", nil)
+
+	api.handleDurationOutliers(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandleDurationOutliers_RepositoryError(t *testing.T) {
 	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.RecentTasks = []models.RecentTask{}
+	mockRepo.GetOutliersError = errors.New("query failed")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/stats/duration-outliers", nil)
+
+	api.handleDurationOutliers(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(ScheduleRequest{
+		Cron: "0 * * * *",
+		Type: "generate_report",
+		Payload: map[string]any{
+			"report_type": "task_summary",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/schedules", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var rt task.RecurringTask
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rt))
+	assert.NotEmpty(t, rt.ID)
+	assert.Equal(t, "0 * * * *", rt.Cron)
+
+	schedules, err := q.GetRecurringTasks()
+	require.NoError(t, err)
+	assert.Len(t, schedules, 1)
+}
+
+func TestCreateSchedule_InvalidCron(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(ScheduleRequest{Cron: "every tuesday", Type: "cleanup"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/schedules", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateSchedule_ReservedPayloadField(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	body, _ := json.Marshal(ScheduleRequest{Cron: "@hourly", Type: "cleanup", Payload: map[string]any{"_encrypted": "x"}})
 
+	req := httptest.NewRequest(http.MethodPost, "/api/schedules", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/recent?limit=50", nil)
 
-	api.handleRecentHistory(w, r)
+	api.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "reserved _encrypted field")
 }
 
-func TestHandleRecentHistory_InvalidLimit(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestListAndDeleteSchedules(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.RecentTasks = []models.RecentTask{}
+	rt := task.NewRecurringTask("@hourly", "cleanup", nil, task.LowPriority)
+	require.NoError(t, q.SaveRecurringTask(rt))
 
+	req := httptest.NewRequest(http.MethodGet, "/api/schedules", nil)
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/recent?limit=invalid", nil)
+	api.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	api.handleRecentHistory(w, r)
+	var schedules []task.RecurringTask
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedules))
+	require.Len(t, schedules, 1)
+	assert.Equal(t, rt.ID, schedules[0].ID)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	req = httptest.NewRequest(http.MethodDelete, "/api/schedules/"+rt.ID, nil)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/schedules/"+rt.ID, nil)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestHandleRecentHistory_MethodNotAllowed(t *testing.T) {
-	api, q, _, mr := setupTestAPIWithMockRepo(t)
+func TestCreateReport(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodPost, "/api/history/recent", nil)
+	reportTypes := []string{
+		"task_summary",
+		"worker_performance",
+		"failure_analysis",
+		"hourly_breakdown",
+		"retry_analysis",
+	}
 
-	api.handleRecentHistory(w, r)
+	for _, reportType := range reportTypes {
+		t.Run(reportType, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]any{
+				"report_type": reportType,
+				"format":      "json",
+			})
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+			req := httptest.NewRequest(http.MethodPost, "/api/reports", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			api.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+
+			var resp map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.NotEmpty(t, resp["task_id"])
+
+			queued, err := q.GetTask(resp["task_id"])
+			require.NoError(t, err)
+			assert.Equal(t, "generate_report", queued.Type)
+			assert.Equal(t, reportType, queued.Payload["report_type"])
+		})
+	}
 }
 
-func TestHandleRecentHistory_NoRepository(t *testing.T) {
+func TestReportTypes(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
+	req := httptest.NewRequest(http.MethodGet, "/api/reports/types", nil)
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+	api.ServeHTTP(w, req)
 
-	api.handleRecentHistory(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var types []handlers.ReportType
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &types))
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "PostgreSQL not configured")
+	var names []string
+	for _, rt := range types {
+		names = append(names, rt.Name)
+		assert.NotEmpty(t, rt.Description, rt.Name)
+		assert.NotEmpty(t, rt.Fields, rt.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		"task_summary",
+		"worker_performance",
+		"failure_analysis",
+		"hourly_breakdown",
+		"retry_analysis",
+	}, names)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/reports/types", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
-func TestHandleRecentHistory_RepositoryError(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestCreateReport_InvalidPayload(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.GetRecentTasksError = errors.New("database connection failed")
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "unsupported report type", body: `{"report_type": "revenue"}`},
+		{name: "missing report type", body: `{"format": "csv"}`},
+		{name: "invalid JSON", body: `{not json`},
+	}
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/recent", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
 
-	api.handleRecentHistory(w, r)
+			api.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
+	tasks, err := q.GetAllTasks()
 	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "database connection failed")
+	assert.Empty(t, tasks)
 }
 
-func TestHandleTaskHistory_Success(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestCreateReport_UnknownType(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
+	api.SetAllowUnknownTaskTypes(false)
 
-	taskID := "task-123"
-	mockRepo.ExecutionLog = []mocks.LogExecutionCall{
-		{
-			TaskID:        taskID,
-			AttemptNumber: 1,
-			Status:        "pending",
-			DurationMs:    0,
-			WorkerID:      "worker-1",
-		},
-		{
-			TaskID:        taskID,
-			AttemptNumber: 1,
-			Status:        "completed",
-			DurationMs:    250,
-			WorkerID:      "worker-1",
-		},
-	}
+	require.NoError(t, q.RegisterTaskTypes("send_email"))
 
+	req := httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(`{"report_type": "task_summary"}`))
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/task/"+taskID, nil)
 
-	api.handleTaskHistory(w, r)
+	api.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown task type: generate_report")
 
-	var history []map[string]any
-	err := json.NewDecoder(w.Body).Decode(&history)
+	tasks, err := q.GetAllTasks()
 	require.NoError(t, err)
-	assert.Len(t, history, 2)
-	assert.Equal(t, taskID, history[0]["task_id"])
-	assert.Equal(t, "pending", history[0]["status"])
-	assert.Equal(t, "completed", history[1]["status"])
+	assert.Empty(t, tasks)
+
+	require.NoError(t, q.RegisterTaskTypes("generate_report"))
+
+	req = httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(`{"report_type": "task_summary"}`))
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
 }
 
-func TestHandleTaskHistory_MissingTaskID(t *testing.T) {
-	api, q, _, mr := setupTestAPIWithMockRepo(t)
-	defer mr.Close()
+func TestCreateReport_StoreUnavailable(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/task/", nil)
+	mr.Close()
 
-	api.handleTaskHistory(w, r)
+	req := httptest.NewRequest(http.MethodPost, "/api/reports", strings.NewReader(`{"report_type": "task_summary"}`))
+	w := httptest.NewRecorder()
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	api.ServeHTTP(w, req)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Task ID is required")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "task_id")
 }
 
-func TestHandleTaskHistory_MethodNotAllowed(t *testing.T) {
-	api, q, _, mr := setupTestAPIWithMockRepo(t)
+func TestDownloadReportByID(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodPost, "/api/history/task/task-123", nil)
-
-	api.handleTaskHistory(w, r)
+	dir := t.TempDir()
+	tests := []struct {
+		format      string
+		content     string
+		contentType string
+	}{
+		{format: "csv", content: "Task Type,Total\nemail,10\n", contentType: "text/csv"},
+		{format: "json", content: `[{"Task Type":"email"}]`, contentType: "application/json"},
+	}
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(dir, "nexq_task_summary_20240101_000000."+tt.format)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+			reportID := "report-" + tt.format
+			require.NoError(t, q.SaveReportInfo(queue.ReportInfo{ID: reportID, Path: path, Format: tt.format, GeneratedAt: time.Now()}))
+
+			w := httptest.NewRecorder()
+			api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/reports/"+reportID+"/download", nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename=nexq_task_summary_20240101_000000.`+tt.format, w.Header().Get("Content-Disposition"))
+			assert.Equal(t, tt.content, w.Body.String())
+		})
+	}
 }
 
-func TestHandleTaskHistory_NoRepository(t *testing.T) {
+func TestDownloadReportByID_Compressed(t *testing.T) {
 	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/task/task-123", nil)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("Task Type,Total\nemail,10\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
 
-	api.handleTaskHistory(w, r)
+	path := filepath.Join(t.TempDir(), "nexq_task_summary_20240101_000000.csv.gz")
+	require.NoError(t, os.WriteFile(path, compressed.Bytes(), 0644))
+	require.NoError(t, q.SaveReportInfo(queue.ReportInfo{ID: "report-gz", Path: path, Format: "gz", GeneratedAt: time.Now()}))
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/reports/report-gz/download", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `attachment; filename=nexq_task_summary_20240101_000000.csv.gz`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, compressed.Bytes(), w.Body.Bytes())
 }
 
-func TestHandleTaskHistory_RepositoryError(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestDownloadReportByID_NotFound(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.GetTaskHistoryError = errors.New("query failed")
-
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/task/task-123", nil)
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/reports/unknown/download", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
 
-	api.handleTaskHistory(w, r)
+	missing := filepath.Join(t.TempDir(), "deleted.csv")
+	require.NoError(t, q.SaveReportInfo(queue.ReportInfo{ID: "deleted", Path: missing, Format: "csv"}))
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/reports/deleted/download", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "query failed")
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/reports/deleted/download", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
-func TestHandleTasksByType_Success(t *testing.T) {
+func TestMissingTimestampsAreOmitted(t *testing.T) {
 	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	taskType := "send_email"
-	duration1 := 200
-	duration2 := 300
+	pending := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(pending))
+
 	mockRepo.RecentTasks = []models.RecentTask{
-		{
-			TaskID:     "task-1",
-			Type:       taskType,
-			Status:     string(task.CompletedStatus),
-			CreatedAt:  time.Now(),
-			DurationMs: &duration1,
-		},
-		{
-			TaskID:     "task-2",
-			Type:       taskType,
-			Status:     string(task.CompletedStatus),
-			CreatedAt:  time.Now(),
-			DurationMs: &duration2,
-		},
+		{TaskID: pending.ID, Type: "test_task", Status: string(task.PendingStatus), CreatedAt: time.Now()},
 	}
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/type/"+taskType, nil)
+	decode := func(t *testing.T, path string) any {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
 
-	api.handleTasksByType(w, r)
+		var raw any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+		return raw
+	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	for _, format := range []task.TimeFormat{task.RFC3339TimeFormat, task.UnixMillisTimeFormat} {
+		t.Run("task "+string(format), func(t *testing.T) {
+			api.SetTimeFormat(format)
 
-	var tasks []models.RecentTask
-	err := json.NewDecoder(w.Body).Decode(&tasks)
-	require.NoError(t, err)
-	assert.Len(t, tasks, 2)
-	assert.Equal(t, taskType, tasks[0].Type)
+			raw := decode(t, "/api/tasks/"+pending.ID).(map[string]any)
+			assert.NotContains(t, raw, "started_at")
+			assert.NotContains(t, raw, "completed_at")
+			assert.NotContains(t, raw, "moved_to_dlq_at")
+		})
+	}
+
+	t.Run("recent history", func(t *testing.T) {
+		raw := decode(t, "/api/history/recent").([]any)
+		require.Len(t, raw, 1)
+		assert.NotContains(t, raw[0], "completed_at")
+	})
 }
 
-func TestHandleTasksByType_WithLimit(t *testing.T) {
-	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
+func TestRefreshMetrics(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.RecentTasks = []models.RecentTask{}
+	metrics.UpdateQueueDepth(0)
+	metrics.UpdateDeadLetterQueueDepth(0)
+
+	for range 3 {
+		require.NoError(t, q.Enqueue(task.NewTask("send_email", nil, task.MediumPriority)))
+	}
+	dead := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.MoveToDeadLetter(dead, "boom"))
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email?limit=25", nil)
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/refresh-metrics", nil))
 
-	api.handleTasksByType(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	var snapshot queue.MetricsSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.Equal(t, 3, snapshot.QueueDepth)
+	assert.Equal(t, 1, snapshot.DeadLetterQueueDepth)
+	assert.Equal(t, 3, snapshot.TasksByStatus[task.PendingStatus]["send_email"])
+
+	metric := &dto.Metric{}
+	require.NoError(t, metrics.QueueDepth.Write(metric))
+	assert.Equal(t, float64(3), metric.Gauge.GetValue())
+
+	metric = &dto.Metric{}
+	require.NoError(t, metrics.DeadLetterQueueDepth.Write(metric))
+	assert.Equal(t, float64(1), metric.Gauge.GetValue())
 }
 
-func TestHandleTasksByType_MissingType(t *testing.T) {
-	api, q, _, mr := setupTestAPIWithMockRepo(t)
+func TestRefreshMetrics_MethodNotAllowed(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/type/", nil)
-
-	api.handleTasksByType(w, r)
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/refresh-metrics", nil))
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "Task type is required")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
-func TestHandleTasksByType_MethodNotAllowed(t *testing.T) {
-	api, q, _, mr := setupTestAPIWithMockRepo(t)
+func TestVersion(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodDelete, "/api/history/type/send_email", nil)
+	t.Run("defaults when unset", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/version", nil))
 
-	api.handleTasksByType(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
 
-	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		var info version.Info
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+		assert.Equal(t, version.Info{Version: "dev", Commit: "unknown", BuildTime: "unknown"}, info)
+	})
+
+	t.Run("reports injected values", func(t *testing.T) {
+		defer func(v, c, b string) {
+			version.Version, version.Commit, version.BuildTime = v, c, b
+		}(version.Version, version.Commit, version.BuildTime)
+
+		version.Version = "v1.4.0"
+		version.Commit = "abc1234"
+		version.BuildTime = "2026-01-02T03:04:05Z"
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"version":"v1.4.0","commit":"abc1234","build_time":"2026-01-02T03:04:05Z"}`, w.Body.String())
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/version", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
 }
 
-func TestHandleTasksByType_NoRepository(t *testing.T) {
-	api, q, mr := setupTestAPI(t)
+func TestCreateTask_Confirmation(t *testing.T) {
+	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email", nil)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body)))
+		return w
+	}
 
-	api.handleTasksByType(w, r)
+	t.Run("defaults to durable", func(t *testing.T) {
+		w := post(`{"type":"test_task"}`)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "durable", w.Header().Get("X-Enqueue-Confirmation"))
+	})
+
+	t.Run("unsupported mode", func(t *testing.T) {
+		w := post(`{"type":"test_task","confirmation":"eventually"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("durable fails when not persisted", func(t *testing.T) {
+		mockRepo.SaveTaskError = errors.New("database unavailable")
+		defer func() { mockRepo.SaveTaskError = nil }()
+
+		w := post(`{"type":"test_task","confirmation":"durable"}`)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("fast", func(t *testing.T) {
+		w := post(`{"type":"test_task","confirmation":"fast"}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "fast", w.Header().Get("X-Enqueue-Confirmation"))
+	})
 }
 
-func TestHandleTasksByType_RepositoryError(t *testing.T) {
+func TestHandleSearchTasks(t *testing.T) {
 	api, q, mockRepo, mr := setupTestAPIWithMockRepo(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	mockRepo.GetTasksByTypeError = errors.New("database error")
+	mockRepo.RecentTasks = []models.RecentTask{
+		{TaskID: "task-1", Type: "webhook", FailureReason: "dial tcp: i/o Timeout"},
+		{TaskID: "task-2", Type: "email", FailureReason: "smtp: mailbox full"},
+		{TaskID: "task-3", Type: "email"},
+	}
 
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/api/history/type/send_email", nil)
+	t.Run("returns matching tasks", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/search?error=timeout", nil))
 
-	api.handleTasksByType(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
 
-	// Debug
-	t.Logf("Status Code: %d", w.Code)
-	t.Logf("Response: %s", w.Body.String())
+		var tasks []models.RecentTask
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&tasks))
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "task-1", tasks[0].TaskID)
+	})
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	t.Run("requires error parameter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/search", nil))
 
-	var errResp map[string]string
-	err := json.NewDecoder(w.Body).Decode(&errResp)
-	require.NoError(t, err)
-	assert.Contains(t, errResp["error"], "database error")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo.SearchByErrorError = errors.New("database error")
+		defer func() { mockRepo.SearchByErrorError = nil }()
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/search?error=timeout", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/tasks/search?error=timeout", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func TestHandleSearchTasks_NoRepository(t *testing.T) {
+	api, q, mr := setupTestAPI(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/search?error=timeout", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 }
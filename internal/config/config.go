@@ -0,0 +1,374 @@
+// Package config loads and validates the environment configuration of the server and worker.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nadmax/nexq/internal/encryption"
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/queue"
+	"github.com/nadmax/nexq/internal/repository/postgres"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/webhook"
+)
+
+type Config struct {
+	PogocacheAddr string
+	QueueBackend  queue.BackendKind
+	PostgresDSN   string
+	PayloadCipher *encryption.PayloadCipher
+	PostgresPool  postgres.PoolOptions
+	LogFormat     logging.Format
+
+	VisibilityTimeout time.Duration
+	EventSink         events.SinkKind
+	EventRedisAddr    string
+	EventChannel      string
+	FailureClassifier *task.FailureClassifier
+	// TracingEnabled is set when an OTLP endpoint is configured; the exporter
+	// reads the OTEL_EXPORTER_OTLP_* variables itself.
+	TracingEnabled bool
+}
+
+type ServerConfig struct {
+	Config
+	Port                 int
+	TimeFormat           task.TimeFormat
+	HistoryRetention     time.Duration
+	HistoryPruneInterval time.Duration
+	APIKey               string
+	TaskRateLimit        float64
+	TaskRateBurst        int
+	CORSAllowedOrigin    string
+	ReclaimInterval      time.Duration
+	// AllowUnknownTaskTypes lets the API accept task types no worker has
+	// registered a handler for.
+	AllowUnknownTaskTypes bool
+	// MaxQueueDepth is the number of pending tasks past which new tasks are
+	// rejected; zero means unlimited.
+	MaxQueueDepth int
+	// MaxPayloadBytes caps the JSON-encoded size of a task payload; zero
+	// means unlimited.
+	MaxPayloadBytes int
+	// MaxRequestBodyBytes caps the size of a request body after gzip
+	// decompression; zero means unlimited.
+	MaxRequestBodyBytes int
+	// AggregateMetrics reports the task gauge under a single "all" type
+	// instead of scanning every task on each refresh to break it down by type.
+	AggregateMetrics bool
+	// HTTP server timeouts, guarding against clients that hold connections
+	// open; zero disables a timeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+type WorkerConfig struct {
+	Config
+	WorkerID              string
+	MaxLeases             int
+	ReportMaxRowsInMemory int
+	// WebhookAllowedNetworks are internal networks task callbacks may still
+	// be delivered to.
+	WebhookAllowedNetworks []*net.IPNet
+}
+
+type loader struct {
+	getenv func(string) string
+	errs   []error
+}
+
+func (l *loader) fail(key string, format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (l *loader) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(l.errs...))
+}
+
+func (l *loader) loadCommon() Config {
+	cfg := Config{
+		PogocacheAddr: l.getenv("POGOCACHE_ADDR"),
+		PostgresDSN:   l.secret("POSTGRES_DSN"),
+
+		VisibilityTimeout: queue.DefaultVisibilityTimeout,
+		EventRedisAddr:    l.getenv("EVENT_REDIS_ADDR"),
+		EventChannel:      l.getenv("EVENT_CHANNEL"),
+	}
+
+	if cfg.PogocacheAddr == "" {
+		cfg.PogocacheAddr = "localhost:9401"
+	}
+	if _, port, err := net.SplitHostPort(cfg.PogocacheAddr); err != nil {
+		l.fail("POGOCACHE_ADDR", "must be in host:port form, got %q", cfg.PogocacheAddr)
+	} else if !validPort(port) {
+		l.fail("POGOCACHE_ADDR", "port must be between 1 and 65535, got %q", port)
+	}
+
+	queueBackend, err := queue.ParseBackendKind(l.getenv("QUEUE_BACKEND"))
+	if err != nil {
+		l.fail("QUEUE_BACKEND", "%v", err)
+	}
+	cfg.QueueBackend = queueBackend
+
+	if cfg.PostgresDSN == "" {
+		l.fail("POSTGRES_DSN", "is required")
+	}
+
+	payloadCipher, err := encryption.LoadPayloadCipher(l.getenv("PAYLOAD_ENCRYPTION_KEY"))
+	if err != nil {
+		l.fail("PAYLOAD_ENCRYPTION_KEY", "%v", err)
+	}
+	cfg.PayloadCipher = payloadCipher
+
+	cfg.PostgresPool = l.loadPool()
+
+	logFormat, err := logging.ParseFormat(l.getenv("LOG_FORMAT"))
+	if err != nil {
+		l.fail("LOG_FORMAT", "%v", err)
+	}
+	cfg.LogFormat = logFormat
+
+	if l.nonNegativeDuration("VISIBILITY_TIMEOUT", &cfg.VisibilityTimeout) && cfg.VisibilityTimeout == 0 {
+		l.fail("VISIBILITY_TIMEOUT", "must be positive")
+	}
+
+	eventSink, err := events.ParseSinkKind(l.getenv("EVENT_SINK"))
+	if err != nil {
+		l.fail("EVENT_SINK", "%v", err)
+	}
+	cfg.EventSink = eventSink
+	if cfg.EventRedisAddr == "" {
+		cfg.EventRedisAddr = cfg.PogocacheAddr
+	}
+	if cfg.EventChannel == "" {
+		cfg.EventChannel = events.DefaultChannel
+	}
+
+	failureClassifier, err := task.ParseFailureClassifier(l.getenv("FAILURE_CATEGORY_PATTERNS"))
+	if err != nil {
+		l.fail("FAILURE_CATEGORY_PATTERNS", "%v", err)
+	}
+	cfg.FailureClassifier = failureClassifier
+
+	cfg.TracingEnabled = l.getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		l.getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+
+	return cfg
+}
+
+func (l *loader) loadPool() postgres.PoolOptions {
+	pool := postgres.DefaultPoolOptions()
+
+	openOK := l.nonNegativeInt("POSTGRES_MAX_OPEN_CONNS", &pool.MaxOpenConns)
+	idleOK := l.nonNegativeInt("POSTGRES_MAX_IDLE_CONNS", &pool.MaxIdleConns)
+
+	if openOK && pool.MaxOpenConns == 0 {
+		l.fail("POSTGRES_MAX_OPEN_CONNS", "must be positive")
+	} else if openOK && idleOK && pool.MaxIdleConns > pool.MaxOpenConns {
+		l.fail("POSTGRES_MAX_IDLE_CONNS", "must not exceed POSTGRES_MAX_OPEN_CONNS (%d), got %d", pool.MaxOpenConns, pool.MaxIdleConns)
+	}
+
+	l.nonNegativeDuration("POSTGRES_CONN_MAX_LIFETIME", &pool.ConnMaxLifetime)
+
+	return pool
+}
+
+// secret reads a value that may be mounted as a file: when key_FILE is set,
+// the trimmed contents of that file are used, otherwise key itself.
+func (l *loader) secret(key string) string {
+	path := l.getenv(key + "_FILE")
+	if path == "" {
+		return l.getenv(key)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.fail(key+"_FILE", "%v", err)
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func (l *loader) nonNegativeInt(key string, dst *int) bool {
+	value := l.getenv(key)
+	if value == "" {
+		return true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		l.fail(key, "must be a non-negative integer, got %q", value)
+		return false
+	}
+
+	*dst = parsed
+	return true
+}
+
+func (l *loader) boolean(key string, dst *bool) bool {
+	value := l.getenv(key)
+	if value == "" {
+		return true
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(key, "must be a boolean, got %q", value)
+		return false
+	}
+
+	*dst = parsed
+	return true
+}
+
+func (l *loader) nonNegativeDuration(key string, dst *time.Duration) bool {
+	value := l.getenv(key)
+	if value == "" {
+		return true
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		l.fail(key, "must be a non-negative duration, got %q", value)
+		return false
+	}
+
+	*dst = parsed
+	return true
+}
+
+func LoadServer(getenv func(string) string) (*ServerConfig, error) {
+	l := &loader{getenv: getenv}
+	cfg := &ServerConfig{
+		Config:               l.loadCommon(),
+		Port:                 8080,
+		HistoryPruneInterval: time.Hour,
+		APIKey:               getenv("NEXQ_API_KEY"),
+		TaskRateBurst:        10,
+		CORSAllowedOrigin:    getenv("CORS_ALLOWED_ORIGIN"),
+		ReclaimInterval:      30 * time.Second,
+		ReadHeaderTimeout:    10 * time.Second,
+		ReadTimeout:          30 * time.Second,
+		WriteTimeout:         60 * time.Second,
+		IdleTimeout:          120 * time.Second,
+		MaxRequestBodyBytes:  1 << 20,
+	}
+
+	if cfg.CORSAllowedOrigin == "" {
+		cfg.CORSAllowedOrigin = "*"
+	}
+
+	if port := getenv("PORT"); port != "" {
+		if !validPort(port) {
+			l.fail("PORT", "must be an integer between 1 and 65535, got %q", port)
+		} else {
+			cfg.Port, _ = strconv.Atoi(port)
+		}
+	}
+
+	timeFormat, err := task.ParseTimeFormat(getenv("TIME_FORMAT"))
+	if err != nil {
+		l.fail("TIME_FORMAT", "%v", err)
+	}
+	cfg.TimeFormat = timeFormat
+
+	l.nonNegativeDuration("HISTORY_RETENTION", &cfg.HistoryRetention)
+	if l.nonNegativeDuration("HISTORY_PRUNE_INTERVAL", &cfg.HistoryPruneInterval) && cfg.HistoryPruneInterval == 0 {
+		l.fail("HISTORY_PRUNE_INTERVAL", "must be positive")
+	}
+
+	if rateLimit := getenv("TASK_RATE_LIMIT"); rateLimit != "" {
+		parsed, err := strconv.ParseFloat(rateLimit, 64)
+		if err != nil || parsed < 0 {
+			l.fail("TASK_RATE_LIMIT", "must be a non-negative number, got %q", rateLimit)
+		} else {
+			cfg.TaskRateLimit = parsed
+		}
+	}
+	if l.nonNegativeInt("TASK_RATE_BURST", &cfg.TaskRateBurst) && cfg.TaskRateBurst == 0 {
+		l.fail("TASK_RATE_BURST", "must be positive")
+	}
+
+	if l.nonNegativeDuration("RECLAIM_INTERVAL", &cfg.ReclaimInterval) && cfg.ReclaimInterval == 0 {
+		l.fail("RECLAIM_INTERVAL", "must be positive")
+	}
+
+	l.boolean("ALLOW_UNKNOWN_TASK_TYPES", &cfg.AllowUnknownTaskTypes)
+	l.nonNegativeInt("MAX_QUEUE_DEPTH", &cfg.MaxQueueDepth)
+	l.nonNegativeInt("MAX_PAYLOAD_BYTES", &cfg.MaxPayloadBytes)
+	l.nonNegativeInt("MAX_REQUEST_BODY_BYTES", &cfg.MaxRequestBodyBytes)
+	l.boolean("METRICS_AGGREGATE_TYPES", &cfg.AggregateMetrics)
+
+	l.nonNegativeDuration("HTTP_READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout)
+	l.nonNegativeDuration("HTTP_READ_TIMEOUT", &cfg.ReadTimeout)
+	l.nonNegativeDuration("HTTP_WRITE_TIMEOUT", &cfg.WriteTimeout)
+	l.nonNegativeDuration("HTTP_IDLE_TIMEOUT", &cfg.IdleTimeout)
+
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func LoadWorker(getenv func(string) string) (*WorkerConfig, error) {
+	l := &loader{getenv: getenv}
+	cfg := &WorkerConfig{
+		Config:                l.loadCommon(),
+		WorkerID:              getenv("WORKER_ID"),
+		ReportMaxRowsInMemory: 10000,
+	}
+
+	if cfg.WorkerID == "" {
+		cfg.WorkerID = fmt.Sprintf("worker-%d", time.Now().Unix())
+	}
+
+	if maxLeases := getenv("WORKER_MAX_LEASES"); maxLeases != "" {
+		parsed, err := strconv.Atoi(maxLeases)
+		if err != nil || parsed < 0 {
+			l.fail("WORKER_MAX_LEASES", "must be a non-negative integer, got %q", maxLeases)
+		} else {
+			cfg.MaxLeases = parsed
+		}
+	}
+
+	if maxRows := getenv("REPORT_MAX_ROWS_IN_MEMORY"); maxRows != "" {
+		parsed, err := strconv.Atoi(maxRows)
+		if err != nil || parsed < 1 {
+			l.fail("REPORT_MAX_ROWS_IN_MEMORY", "must be a positive integer, got %q", maxRows)
+		} else {
+			cfg.ReportMaxRowsInMemory = parsed
+		}
+	}
+
+	allowedNetworks, err := webhook.ParseAllowedNetworks(getenv("WEBHOOK_ALLOWED_NETWORKS"))
+	if err != nil {
+		l.fail("WEBHOOK_ALLOWED_NETWORKS", "%v", err)
+	}
+	cfg.WebhookAllowedNetworks = allowedNetworks
+
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
@@ -0,0 +1,488 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/queue"
+	"github.com/nadmax/nexq/internal/repository/postgres"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestLoadServer_Defaults(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost:9401", cfg.PogocacheAddr)
+	assert.Equal(t, queue.RedisBackendKind, cfg.QueueBackend)
+	assert.Equal(t, "postgres://localhost/nexq", cfg.PostgresDSN)
+	assert.Equal(t, 8080, cfg.Port)
+	assert.Equal(t, task.RFC3339TimeFormat, cfg.TimeFormat)
+	assert.Nil(t, cfg.PayloadCipher)
+	assert.Equal(t, postgres.DefaultPoolOptions(), cfg.PostgresPool)
+	assert.Equal(t, "*", cfg.CORSAllowedOrigin)
+	assert.Equal(t, logging.TextFormat, cfg.LogFormat)
+	assert.Equal(t, events.NoneSinkKind, cfg.EventSink)
+	assert.Equal(t, "localhost:9401", cfg.EventRedisAddr)
+	assert.Equal(t, events.DefaultChannel, cfg.EventChannel)
+}
+
+func TestLoadServer_Valid(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POGOCACHE_ADDR":         "cache:9401",
+		"QUEUE_BACKEND":          "pogocache",
+		"POSTGRES_DSN":           "postgres://localhost/nexq",
+		"PORT":                   "9090",
+		"NEXQ_API_KEY":           "secret-key",
+		"CORS_ALLOWED_ORIGIN":    "https://dashboard.example.com",
+		"TIME_FORMAT":            "unix_ms",
+		"PAYLOAD_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "cache:9401", cfg.PogocacheAddr)
+	assert.Equal(t, queue.PogocacheBackendKind, cfg.QueueBackend)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, "secret-key", cfg.APIKey)
+	assert.Equal(t, "https://dashboard.example.com", cfg.CORSAllowedOrigin)
+	assert.Equal(t, task.UnixMillisTimeFormat, cfg.TimeFormat)
+	assert.NotNil(t, cfg.PayloadCipher)
+}
+
+func TestLoadServer_ReportsAllErrors(t *testing.T) {
+	_, err := LoadServer(envFrom(map[string]string{
+		"POGOCACHE_ADDR":         "cache",
+		"PORT":                   "99999",
+		"TIME_FORMAT":            "iso",
+		"PAYLOAD_ENCRYPTION_KEY": "short",
+		"LOG_FORMAT":             "xml",
+		"QUEUE_BACKEND":          "memcached",
+		"EVENT_SINK":             "kafka",
+	}))
+	require.Error(t, err)
+
+	for _, key := range []string{"POGOCACHE_ADDR", "POSTGRES_DSN", "PORT", "TIME_FORMAT", "PAYLOAD_ENCRYPTION_KEY", "LOG_FORMAT", "QUEUE_BACKEND", "EVENT_SINK"} {
+		assert.Contains(t, err.Error(), key)
+	}
+}
+
+func TestLoadServer_InvalidPort(t *testing.T) {
+	for _, port := range []string{"abc", "0", "-1", "65536"} {
+		t.Run(port, func(t *testing.T) {
+			_, err := LoadServer(envFrom(map[string]string{
+				"POSTGRES_DSN": "postgres://localhost/nexq",
+				"PORT":         port,
+			}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "PORT")
+		})
+	}
+}
+
+func TestLoadServer_HistoryPruning(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+		}))
+		require.NoError(t, err)
+		assert.Zero(t, cfg.HistoryRetention)
+		assert.Equal(t, time.Hour, cfg.HistoryPruneInterval)
+	})
+
+	t.Run("parses retention and interval", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":           "postgres://localhost/nexq",
+			"HISTORY_RETENTION":      "720h",
+			"HISTORY_PRUNE_INTERVAL": "15m",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 720*time.Hour, cfg.HistoryRetention)
+		assert.Equal(t, 15*time.Minute, cfg.HistoryPruneInterval)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		_, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":           "postgres://localhost/nexq",
+			"HISTORY_RETENTION":      "30d",
+			"HISTORY_PRUNE_INTERVAL": "0s",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "HISTORY_RETENTION")
+		assert.Contains(t, err.Error(), "HISTORY_PRUNE_INTERVAL")
+	})
+}
+
+func TestLoadServer_TaskRateLimit(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+		}))
+		require.NoError(t, err)
+		assert.Zero(t, cfg.TaskRateLimit)
+		assert.Equal(t, 10, cfg.TaskRateBurst)
+	})
+
+	t.Run("parses rate and burst", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":    "postgres://localhost/nexq",
+			"TASK_RATE_LIMIT": "2.5",
+			"TASK_RATE_BURST": "20",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 2.5, cfg.TaskRateLimit)
+		assert.Equal(t, 20, cfg.TaskRateBurst)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		_, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":    "postgres://localhost/nexq",
+			"TASK_RATE_LIMIT": "-1",
+			"TASK_RATE_BURST": "0",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TASK_RATE_LIMIT")
+		assert.Contains(t, err.Error(), "TASK_RATE_BURST")
+	})
+}
+
+func TestLoadServer_Reclaim(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, queue.DefaultVisibilityTimeout, cfg.VisibilityTimeout)
+		assert.Equal(t, 30*time.Second, cfg.ReclaimInterval)
+	})
+
+	t.Run("parses timeout and interval", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":       "postgres://localhost/nexq",
+			"VISIBILITY_TIMEOUT": "15m",
+			"RECLAIM_INTERVAL":   "1m",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Minute, cfg.VisibilityTimeout)
+		assert.Equal(t, time.Minute, cfg.ReclaimInterval)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		_, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":       "postgres://localhost/nexq",
+			"VISIBILITY_TIMEOUT": "0s",
+			"RECLAIM_INTERVAL":   "soon",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "VISIBILITY_TIMEOUT")
+		assert.Contains(t, err.Error(), "RECLAIM_INTERVAL")
+	})
+}
+
+func TestLoadServer_AllowUnknownTaskTypes(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.False(t, cfg.AllowUnknownTaskTypes)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":             "postgres://localhost/nexq",
+		"ALLOW_UNKNOWN_TASK_TYPES": "true",
+	}))
+	require.NoError(t, err)
+	assert.True(t, cfg.AllowUnknownTaskTypes)
+
+	_, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":             "postgres://localhost/nexq",
+		"ALLOW_UNKNOWN_TASK_TYPES": "sometimes",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ALLOW_UNKNOWN_TASK_TYPES")
+}
+
+func TestLoadServer_AggregateMetrics(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.False(t, cfg.AggregateMetrics)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":            "postgres://localhost/nexq",
+		"METRICS_AGGREGATE_TYPES": "true",
+	}))
+	require.NoError(t, err)
+	assert.True(t, cfg.AggregateMetrics)
+}
+
+func TestLoadServer_MaxQueueDepth(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.MaxQueueDepth)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":    "postgres://localhost/nexq",
+		"MAX_QUEUE_DEPTH": "10000",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 10000, cfg.MaxQueueDepth)
+
+	_, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":    "postgres://localhost/nexq",
+		"MAX_QUEUE_DEPTH": "-1",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_QUEUE_DEPTH")
+}
+
+func TestLoadServer_MaxPayloadBytes(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.MaxPayloadBytes)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":      "postgres://localhost/nexq",
+		"MAX_PAYLOAD_BYTES": "65536",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 65536, cfg.MaxPayloadBytes)
+
+	_, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":      "postgres://localhost/nexq",
+		"MAX_PAYLOAD_BYTES": "big",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_PAYLOAD_BYTES")
+}
+
+func TestLoadServer_MaxRequestBodyBytes(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 1<<20, cfg.MaxRequestBodyBytes)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":           "postgres://localhost/nexq",
+		"MAX_REQUEST_BODY_BYTES": "0",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.MaxRequestBodyBytes)
+
+	_, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":           "postgres://localhost/nexq",
+		"MAX_REQUEST_BODY_BYTES": "-1",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_REQUEST_BODY_BYTES")
+}
+
+func TestLoadServer_HTTPTimeouts(t *testing.T) {
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, cfg.ReadHeaderTimeout)
+	assert.Equal(t, 30*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, 60*time.Second, cfg.WriteTimeout)
+	assert.Equal(t, 120*time.Second, cfg.IdleTimeout)
+
+	cfg, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":             "postgres://localhost/nexq",
+		"HTTP_READ_HEADER_TIMEOUT": "2s",
+		"HTTP_READ_TIMEOUT":        "5s",
+		"HTTP_WRITE_TIMEOUT":       "0",
+		"HTTP_IDLE_TIMEOUT":        "1m",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, cfg.ReadHeaderTimeout)
+	assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, time.Duration(0), cfg.WriteTimeout)
+	assert.Equal(t, time.Minute, cfg.IdleTimeout)
+
+	_, err = LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN":       "postgres://localhost/nexq",
+		"HTTP_READ_TIMEOUT":  "soon",
+		"HTTP_WRITE_TIMEOUT": "-1s",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP_READ_TIMEOUT")
+	assert.Contains(t, err.Error(), "HTTP_WRITE_TIMEOUT")
+}
+
+func TestLoad_TracingEnabled(t *testing.T) {
+	cfg, err := LoadWorker(envFrom(map[string]string{
+		"POSTGRES_DSN": "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.False(t, cfg.TracingEnabled)
+
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+			key:            "http://collector:4318",
+		}))
+		require.NoError(t, err)
+		assert.True(t, cfg.TracingEnabled, key)
+	}
+}
+
+func TestLoad_PostgresDSNFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn")
+	require.NoError(t, os.WriteFile(path, []byte("postgres://secret/nexq\n"), 0o600))
+
+	cfg, err := LoadServer(envFrom(map[string]string{
+		"POSTGRES_DSN_FILE": path,
+		"POSTGRES_DSN":      "postgres://localhost/nexq",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://secret/nexq", cfg.PostgresDSN, "the file takes precedence and is trimmed")
+
+	_, err = LoadWorker(envFrom(map[string]string{
+		"POSTGRES_DSN_FILE": filepath.Join(t.TempDir(), "missing"),
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "POSTGRES_DSN_FILE")
+}
+
+func TestLoad_FailureCategoryPatterns(t *testing.T) {
+	cfg, err := LoadWorker(envFrom(map[string]string{
+		"POSTGRES_DSN":              "postgres://localhost/nexq",
+		"FAILURE_CATEGORY_PATTERNS": "quota=rate limit|429",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, task.FailureCategory("quota"), cfg.FailureClassifier.Classify("HTTP 429"))
+
+	_, err = LoadWorker(envFrom(map[string]string{
+		"POSTGRES_DSN":              "postgres://localhost/nexq",
+		"FAILURE_CATEGORY_PATTERNS": "quota",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FAILURE_CATEGORY_PATTERNS")
+}
+
+func TestLoadServer_PostgresPool(t *testing.T) {
+	t.Run("parses pool settings", func(t *testing.T) {
+		cfg, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":               "postgres://localhost/nexq",
+			"POSTGRES_MAX_OPEN_CONNS":    "100",
+			"POSTGRES_MAX_IDLE_CONNS":    "20",
+			"POSTGRES_CONN_MAX_LIFETIME": "10m",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, postgres.PoolOptions{
+			MaxOpenConns:    100,
+			MaxIdleConns:    20,
+			ConnMaxLifetime: 10 * time.Minute,
+		}, cfg.PostgresPool)
+	})
+
+	t.Run("rejects idle above open", func(t *testing.T) {
+		_, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":            "postgres://localhost/nexq",
+			"POSTGRES_MAX_OPEN_CONNS": "4",
+			"POSTGRES_MAX_IDLE_CONNS": "8",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "POSTGRES_MAX_IDLE_CONNS")
+	})
+
+	t.Run("rejects malformed values", func(t *testing.T) {
+		_, err := LoadServer(envFrom(map[string]string{
+			"POSTGRES_DSN":               "postgres://localhost/nexq",
+			"POSTGRES_MAX_OPEN_CONNS":    "many",
+			"POSTGRES_CONN_MAX_LIFETIME": "forever",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "POSTGRES_MAX_OPEN_CONNS")
+		assert.Contains(t, err.Error(), "POSTGRES_CONN_MAX_LIFETIME")
+	})
+}
+
+func TestLoadWorker(t *testing.T) {
+	t.Run("defaults worker ID", func(t *testing.T) {
+		cfg, err := LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+		}))
+		require.NoError(t, err)
+		assert.Contains(t, cfg.WorkerID, "worker-")
+	})
+
+	t.Run("ignores server-only variables", func(t *testing.T) {
+		cfg, err := LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+			"WORKER_ID":    "worker-1",
+			"PORT":         "not-a-port",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "worker-1", cfg.WorkerID)
+	})
+
+	t.Run("parses max leases", func(t *testing.T) {
+		cfg, err := LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN":      "postgres://localhost/nexq",
+			"WORKER_MAX_LEASES": "4",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 4, cfg.MaxLeases)
+	})
+
+	t.Run("parses report rows in memory", func(t *testing.T) {
+		cfg, err := LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN": "postgres://localhost/nexq",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 10000, cfg.ReportMaxRowsInMemory)
+
+		cfg, err = LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN":              "postgres://localhost/nexq",
+			"REPORT_MAX_ROWS_IN_MEMORY": "500",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 500, cfg.ReportMaxRowsInMemory)
+	})
+
+	t.Run("parses webhook allowed networks", func(t *testing.T) {
+		cfg, err := LoadWorker(envFrom(map[string]string{
+			"POSTGRES_DSN":             "postgres://localhost/nexq",
+			"WEBHOOK_ALLOWED_NETWORKS": "10.0.0.0/8,192.168.1.0/24",
+		}))
+		require.NoError(t, err)
+		require.Len(t, cfg.WebhookAllowedNetworks, 2)
+		assert.Equal(t, "10.0.0.0/8", cfg.WebhookAllowedNetworks[0].String())
+	})
+
+	t.Run("reports all errors", func(t *testing.T) {
+		_, err := LoadWorker(envFrom(map[string]string{
+			"POGOCACHE_ADDR":            "cache:port",
+			"WORKER_MAX_LEASES":         "-1",
+			"REPORT_MAX_ROWS_IN_MEMORY": "0",
+			"WEBHOOK_ALLOWED_NETWORKS":  "10.0.0.1",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "POGOCACHE_ADDR")
+		assert.Contains(t, err.Error(), "POSTGRES_DSN")
+		assert.Contains(t, err.Error(), "WORKER_MAX_LEASES")
+		assert.Contains(t, err.Error(), "REPORT_MAX_ROWS_IN_MEMORY")
+		assert.Contains(t, err.Error(), "WEBHOOK_ALLOWED_NETWORKS")
+	})
+}
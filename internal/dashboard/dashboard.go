@@ -2,8 +2,9 @@
 package dashboard
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/nadmax/nexq/internal/httputil"
@@ -16,16 +17,26 @@ type Dashboard struct {
 }
 
 type Stats struct {
-	TotalTasks      int            `json:"total_tasks"`
-	PendingTasks    int            `json:"pending_tasks"`
-	RunningTasks    int            `json:"running_tasks"`
-	CompletedTasks  int            `json:"completed_tasks"`
-	FailedTasks     int            `json:"failed_tasks"`
-	CancelledTasks  int            `json:"cancelled_tasks"`
-	DeadLetterTasks int            `json:"dead_letter_tasks"`
-	TasksByType     map[string]int `json:"tasks_by_type"`
-	AverageWaitTime string         `json:"average_wait_time"`
-	LastUpdated     time.Time      `json:"last_updated"`
+	TotalTasks      int `json:"total_tasks"`
+	PendingTasks    int `json:"pending_tasks"`
+	RunningTasks    int `json:"running_tasks"`
+	CompletedTasks  int `json:"completed_tasks"`
+	FailedTasks     int `json:"failed_tasks"`
+	CancelledTasks  int `json:"cancelled_tasks"`
+	DeadLetterTasks int `json:"dead_letter_tasks"`
+	// TasksByType, TasksByPriority, AverageWaitTime and AvgDurationByType
+	// are only filled in for detailed stats, which scan every task.
+	TasksByType     map[string]int `json:"tasks_by_type,omitempty"`
+	TasksByPriority map[string]int `json:"tasks_by_priority,omitempty"`
+	AverageWaitTime string         `json:"average_wait_time,omitempty"`
+	// SuccessRate and FailureRate are percentages of finished tasks:
+	// completed versus failed or dead-lettered.
+	SuccessRate string `json:"success_rate"`
+	FailureRate string `json:"failure_rate"`
+	// AvgDurationByType only has entries for types with at least one
+	// completed task.
+	AvgDurationByType map[string]string `json:"avg_duration_by_type,omitempty"`
+	LastUpdated       time.Time         `json:"last_updated"`
 }
 
 type TaskHistory struct {
@@ -33,7 +44,7 @@ type TaskHistory struct {
 	Type        string          `json:"type"`
 	Status      task.TaskStatus `json:"status"`
 	CreatedAt   time.Time       `json:"created_at"`
-	CompletedAt *time.Time      `json:"completed_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
 	Duration    string          `json:"duration"`
 }
 
@@ -41,45 +52,123 @@ func NewDashboard(q *queue.Queue) *Dashboard {
 	return &Dashboard{queue: q}
 }
 
+// GetStats reports task counts from the per-status counters. ?detail=true
+// adds the per-type, per-priority, wait and duration breakdowns, which scan
+// every task. With ?window=1h only tasks created within that duration are
+// counted, which implies detail.
 func (d *Dashboard) GetStats(w http.ResponseWriter, r *http.Request) {
-	tasks, err := d.queue.GetAllTasks()
-	if err != nil {
-		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
-		return
+	var window time.Duration
+	if s := r.URL.Query().Get("window"); s != "" {
+		parsed, err := time.ParseDuration(s)
+		if err != nil || parsed <= 0 {
+			httputil.WriteJSONError(w, "window must be a positive duration (e.g. 1h, 30m)", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+	detail := window > 0 || r.URL.Query().Get("detail") == "true"
+
+	var tasks []*task.Task
+	var err error
+	if detail {
+		tasks, err = d.queue.GetAllTasks()
+		if err != nil {
+			httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var counts map[task.TaskStatus]int
+	if window > 0 {
+		// Dead-lettered tasks leave the task index for the DLQ.
+		deadLetters, err := d.queue.GetDeadLetterTasks()
+		if err != nil {
+			httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tasks = append(tasks, deadLetters...)
+
+		cutoff := time.Now().Add(-window)
+		tasks = slices.DeleteFunc(tasks, func(t *task.Task) bool {
+			return t.CreatedAt.Before(cutoff)
+		})
+
+		// The status counters are all-time, so count the windowed tasks
+		// directly.
+		counts = make(map[task.TaskStatus]int)
+		for _, t := range tasks {
+			counts[t.Status]++
+		}
+	} else {
+		counts, err = d.queue.StatusCounts()
+		if err != nil {
+			httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	stats := Stats{
-		TotalTasks:  len(tasks),
-		TasksByType: make(map[string]int),
-		LastUpdated: time.Now(),
+		PendingTasks:    counts[task.PendingStatus],
+		RunningTasks:    counts[task.RunningStatus],
+		CompletedTasks:  counts[task.CompletedStatus],
+		FailedTasks:     counts[task.FailedStatus],
+		CancelledTasks:  counts[task.CancelledStatus],
+		DeadLetterTasks: counts[task.DeadLetterStatus],
+		LastUpdated:     time.Now(),
+	}
+	for _, n := range counts {
+		stats.TotalTasks += n
+	}
+
+	if detail {
+		addBreakdowns(&stats, tasks)
+	}
+
+	finished := stats.CompletedTasks + stats.FailedTasks + stats.DeadLetterTasks
+	if finished > 0 {
+		stats.SuccessRate = formatRate(stats.CompletedTasks, finished)
+		stats.FailureRate = formatRate(stats.FailedTasks+stats.DeadLetterTasks, finished)
+	} else {
+		stats.SuccessRate = "N/A"
+		stats.FailureRate = "N/A"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := httputil.NewJSONEncoder(w, r).Encode(stats); err != nil {
+		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
+}
+
+func addBreakdowns(stats *Stats, tasks []*task.Task) {
+	stats.TasksByType = make(map[string]int)
+	stats.TasksByPriority = make(map[string]int)
+	stats.AvgDurationByType = make(map[string]string)
 
 	var totalWaitTime time.Duration
 	waitCount := 0
+	totalDurationByType := make(map[string]time.Duration)
+	durationCountByType := make(map[string]int)
 
 	for _, t := range tasks {
-		switch t.Status {
-		case task.PendingStatus:
-			stats.PendingTasks++
-		case task.RunningStatus:
-			stats.RunningTasks++
-		case task.CompletedStatus:
-			stats.CompletedTasks++
-		case task.FailedStatus:
-			stats.FailedTasks++
-		case task.CancelledStatus:
-			stats.CancelledTasks++
-		case task.DeadLetterStatus:
-			stats.DeadLetterTasks++
-		}
-
 		stats.TasksByType[t.Type]++
+		stats.TasksByPriority[t.Priority.String()]++
 
 		if t.StartedAt != nil {
 			waitTime := t.StartedAt.Sub(t.CreatedAt)
 			totalWaitTime += waitTime
 			waitCount++
 		}
+
+		if t.Status == task.CompletedStatus && t.StartedAt != nil && t.CompletedAt != nil {
+			totalDurationByType[t.Type] += t.CompletedAt.Sub(*t.StartedAt)
+			durationCountByType[t.Type]++
+		}
+	}
+
+	for taskType, total := range totalDurationByType {
+		avgDuration := total / time.Duration(durationCountByType[taskType])
+		stats.AvgDurationByType[taskType] = avgDuration.Round(time.Millisecond).String()
 	}
 
 	if waitCount > 0 {
@@ -88,14 +177,15 @@ func (d *Dashboard) GetStats(w http.ResponseWriter, r *http.Request) {
 	} else {
 		stats.AverageWaitTime = "N/A"
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
-		return
-	}
+func formatRate(n, total int) string {
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
 }
 
+// GetRecentTasks lists tasks that finished in the last 24 hours. Dead-lettered
+// tasks are included, timestamped by when they were moved to the DLQ if they
+// never completed.
 func (d *Dashboard) GetRecentTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := d.queue.GetAllTasks()
 	if err != nil {
@@ -103,34 +193,55 @@ func (d *Dashboard) GetRecentTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	deadLetters, err := d.queue.GetDeadLetterTasks()
+	if err != nil {
+		httputil.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The live copy of a dead-lettered task is stale, so the DLQ copy
+	// replaces it.
+	deadLetterIDs := make(map[string]bool, len(deadLetters))
+	for _, t := range deadLetters {
+		deadLetterIDs[t.ID] = true
+	}
+	tasks = slices.DeleteFunc(tasks, func(t *task.Task) bool {
+		return deadLetterIDs[t.ID]
+	})
+	tasks = append(tasks, deadLetters...)
+
 	cutoff := time.Now().Add(-24 * time.Hour)
 	history := []TaskHistory{}
 
-	for _, task := range tasks {
-		if task.CompletedAt == nil {
+	for _, t := range tasks {
+		finishedAt := t.CompletedAt
+		if finishedAt == nil && t.Status == task.DeadLetterStatus {
+			finishedAt = t.MoveToDLQAt
+		}
+		if finishedAt == nil {
 			continue
 		}
-		if task.CompletedAt.Before(cutoff) {
+		if finishedAt.Before(cutoff) {
 			continue
 		}
 
 		var duration string
-		if task.StartedAt != nil {
-			duration = task.CompletedAt.Sub(*task.StartedAt).Round(time.Millisecond).String()
+		if t.StartedAt != nil {
+			duration = finishedAt.Sub(*t.StartedAt).Round(time.Millisecond).String()
 		}
 
 		history = append(history, TaskHistory{
-			TaskID:      task.ID,
-			Type:        task.Type,
-			Status:      task.Status,
-			CreatedAt:   task.CreatedAt,
-			CompletedAt: task.CompletedAt,
+			TaskID:      t.ID,
+			Type:        t.Type,
+			Status:      t.Status,
+			CreatedAt:   t.CreatedAt,
+			CompletedAt: finishedAt,
 			Duration:    duration,
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(history); err != nil {
+	if err := httputil.NewJSONEncoder(w, r).Encode(history); err != nil {
 		httputil.WriteJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
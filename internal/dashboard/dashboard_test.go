@@ -71,10 +71,33 @@ func TestGetStats_Empty(t *testing.T) {
 	assert.Equal(t, 0, stats.RunningTasks)
 	assert.Equal(t, 0, stats.CompletedTasks)
 	assert.Equal(t, 0, stats.FailedTasks)
-	assert.Equal(t, "N/A", stats.AverageWaitTime)
+	assert.Empty(t, stats.AverageWaitTime)
+	assert.Equal(t, "N/A", stats.SuccessRate)
+	assert.Equal(t, "N/A", stats.FailureRate)
 	assert.NotZero(t, stats.LastUpdated)
 }
 
+func TestGetStats_BreakdownsNeedDetail(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	require.NoError(t, q.Enqueue(task.NewTask("email", nil, task.HighPriority)))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	w := httptest.NewRecorder()
+	dash.GetStats(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), "tasks_by_type")
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.PendingTasks)
+	assert.Empty(t, stats.TasksByType)
+	assert.Empty(t, stats.TasksByPriority)
+}
+
 func TestGetStats_WithTasks(t *testing.T) {
 	dash, q, mr := setupTestDashboard(t)
 	defer mr.Close()
@@ -82,14 +105,12 @@ func TestGetStats_WithTasks(t *testing.T) {
 
 	pending := task.NewTask("pending_task", nil, task.MediumPriority)
 	pending.Status = task.PendingStatus
-	require.NoError(t, q.Enqueue(pending))
 	require.NoError(t, q.UpdateTask(pending))
 
 	running := task.NewTask("running_task", nil, task.MediumPriority)
 	running.Status = task.RunningStatus
 	now := time.Now()
 	running.StartedAt = &now
-	require.NoError(t, q.Enqueue(running))
 	require.NoError(t, q.UpdateTask(running))
 
 	completed := task.NewTask("completed_task", nil, task.MediumPriority)
@@ -98,12 +119,10 @@ func TestGetStats_WithTasks(t *testing.T) {
 	completedTime := time.Now()
 	completed.StartedAt = &startTime
 	completed.CompletedAt = &completedTime
-	require.NoError(t, q.Enqueue(completed))
 	require.NoError(t, q.UpdateTask(completed))
 
 	failed := task.NewTask("failed_task", nil, task.MediumPriority)
 	failed.Status = task.FailedStatus
-	require.NoError(t, q.Enqueue(failed))
 	require.NoError(t, q.UpdateTask(failed))
 
 	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
@@ -142,7 +161,7 @@ func TestGetStats_TasksByType(t *testing.T) {
 	require.NoError(t, q.Enqueue(image2))
 	require.NoError(t, q.Enqueue(report))
 
-	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?detail=true", nil)
 	w := httptest.NewRecorder()
 
 	dash.GetStats(w, req)
@@ -156,6 +175,37 @@ func TestGetStats_TasksByType(t *testing.T) {
 	assert.Equal(t, 1, stats.TasksByType["generate_report"])
 }
 
+func TestGetStats_TasksByPriority(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, priority := range []task.TaskPriority{
+		task.HighPriority,
+		task.HighPriority,
+		task.HighPriority,
+		task.MediumPriority,
+		task.MediumPriority,
+		task.LowPriority,
+	} {
+		require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, priority)))
+	}
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?detail=true", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, map[string]int{
+		"high":   3,
+		"medium": 2,
+		"low":    1,
+	}, stats.TasksByPriority)
+}
+
 func TestGetStats_AverageWaitTime(t *testing.T) {
 	dash, q, mr := setupTestDashboard(t)
 	defer mr.Close()
@@ -177,7 +227,7 @@ func TestGetStats_AverageWaitTime(t *testing.T) {
 	require.NoError(t, q.Enqueue(task2))
 	require.NoError(t, q.UpdateTask(task2))
 
-	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?detail=true", nil)
 	w := httptest.NewRecorder()
 
 	dash.GetStats(w, req)
@@ -189,6 +239,149 @@ func TestGetStats_AverageWaitTime(t *testing.T) {
 	assert.Contains(t, stats.AverageWaitTime, "s")
 }
 
+func TestGetStats_AvgDurationByType(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	enqueueCompleted := func(taskType string, duration time.Duration) {
+		tk := task.NewTask(taskType, nil, task.MediumPriority)
+		startedAt := time.Now().Add(-time.Minute)
+		completedAt := startedAt.Add(duration)
+		tk.StartedAt = &startedAt
+		tk.CompletedAt = &completedAt
+		tk.Status = task.CompletedStatus
+		require.NoError(t, q.UpdateTask(tk))
+	}
+	enqueueCompleted("send_email", 1*time.Second)
+	enqueueCompleted("send_email", 3*time.Second)
+	enqueueCompleted("generate_report", 10*time.Second)
+
+	pending := task.NewTask("process_image", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(pending))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?detail=true", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, map[string]string{
+		"send_email":      "2s",
+		"generate_report": "10s",
+	}, stats.AvgDurationByType)
+}
+
+func TestGetStats_SuccessAndFailureRate(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, status := range []task.TaskStatus{
+		task.CompletedStatus,
+		task.CompletedStatus,
+		task.CompletedStatus,
+		task.FailedStatus,
+		task.PendingStatus,
+		task.CancelledStatus,
+	} {
+		tk := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tk))
+		tk.Status = status
+		require.NoError(t, q.UpdateTask(tk))
+	}
+
+	dead := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dead))
+	require.NoError(t, q.MoveToDeadLetter(dead, "boom"))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, "60.0%", stats.SuccessRate)
+	assert.Equal(t, "40.0%", stats.FailureRate)
+}
+
+func TestGetStats_Window(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	recent := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(recent))
+
+	old := task.NewTask("generate_report", nil, task.MediumPriority)
+	old.CreatedAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, q.Enqueue(old))
+	old.Status = task.CompletedStatus
+	require.NoError(t, q.UpdateTask(old))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?window=1h", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	require.Equal(t, 200, w.Code)
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, 1, stats.TotalTasks)
+	assert.Equal(t, 1, stats.PendingTasks)
+	assert.Equal(t, 0, stats.CompletedTasks)
+	assert.Equal(t, map[string]int{"send_email": 1}, stats.TasksByType)
+
+	req = httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	w = httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 2, stats.TotalTasks)
+	assert.Equal(t, 1, stats.CompletedTasks)
+}
+
+func TestGetStats_WindowCountsDeadLetters(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	dead := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dead))
+	require.NoError(t, q.MoveToDeadLetter(dead, "boom"))
+
+	w := httptest.NewRecorder()
+	dash.GetStats(w, httptest.NewRequest("GET", "/api/dashboard/stats?window=1h", nil))
+
+	require.Equal(t, 200, w.Code)
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.DeadLetterTasks)
+	assert.Equal(t, 1, stats.TotalTasks)
+	assert.Equal(t, map[string]int{"send_email": 1}, stats.TasksByType)
+}
+
+func TestGetStats_InvalidWindow(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for _, window := range []string{"soon", "-1h", "0s"} {
+		req := httptest.NewRequest("GET", "/api/dashboard/stats?window="+window, nil)
+		w := httptest.NewRecorder()
+
+		dash.GetStats(w, req)
+
+		assert.Equal(t, 400, w.Code, window)
+	}
+}
+
 func TestGetStats_NoStartedTasks(t *testing.T) {
 	dash, q, mr := setupTestDashboard(t)
 	defer mr.Close()
@@ -204,7 +397,7 @@ func TestGetStats_NoStartedTasks(t *testing.T) {
 	require.NoError(t, q.Enqueue(task2))
 	require.NoError(t, q.UpdateTask(task2))
 
-	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	req := httptest.NewRequest("GET", "/api/dashboard/stats?detail=true", nil)
 	w := httptest.NewRecorder()
 
 	dash.GetStats(w, req)
@@ -418,7 +611,6 @@ func TestGetRecentTasks_MultipleTasks(t *testing.T) {
 		tsk.Status = task.CompletedStatus
 		completedTime := now.Add(-time.Duration(i) * time.Hour)
 		tsk.CompletedAt = &completedTime
-		require.NoError(t, q.Enqueue(tsk))
 		require.NoError(t, q.UpdateTask(tsk))
 	}
 
@@ -447,28 +639,24 @@ func TestGetStats_MixedStatusCounts(t *testing.T) {
 	for range 10 {
 		tsk := task.NewTask("pending", nil, task.MediumPriority)
 		tsk.Status = task.PendingStatus
-		require.NoError(t, q.Enqueue(tsk))
 		require.NoError(t, q.UpdateTask(tsk))
 	}
 
 	for range 5 {
 		tsk := task.NewTask("running", nil, task.MediumPriority)
 		tsk.Status = task.RunningStatus
-		require.NoError(t, q.Enqueue(tsk))
 		require.NoError(t, q.UpdateTask(tsk))
 	}
 
 	for range 3 {
 		tsk := task.NewTask("completed", nil, task.MediumPriority)
 		tsk.Status = task.CompletedStatus
-		require.NoError(t, q.Enqueue(tsk))
 		require.NoError(t, q.UpdateTask(tsk))
 	}
 
 	for range 2 {
 		tsk := task.NewTask("failed", nil, task.MediumPriority)
 		tsk.Status = task.FailedStatus
-		require.NoError(t, q.Enqueue(tsk))
 		require.NoError(t, q.UpdateTask(tsk))
 	}
 
@@ -487,6 +675,35 @@ func TestGetStats_MixedStatusCounts(t *testing.T) {
 	assert.Equal(t, 2, stats.FailedTasks)
 }
 
+func TestGetStats_CountsDeadLetterAndCancelled(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	cancelled := task.NewTask("cancelled", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(cancelled))
+	require.NoError(t, q.CancelTask(cancelled.ID))
+
+	dead := task.NewTask("dead", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dead))
+	dead.Status = task.FailedStatus
+	require.NoError(t, q.UpdateTask(dead))
+	require.NoError(t, q.MoveToDeadLetter(dead, "max retries exceeded"))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/stats", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetStats(w, req)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, 2, stats.TotalTasks)
+	assert.Equal(t, 1, stats.CancelledTasks)
+	assert.Equal(t, 1, stats.DeadLetterTasks)
+	assert.Equal(t, 0, stats.FailedTasks)
+}
+
 func TestGetStatsWithRepository(t *testing.T) {
 	dash, q, mockRepo, mr := setupTestDashboardWithMockRepo(t)
 	defer mr.Close()
@@ -829,3 +1046,45 @@ func ptrTime(t time.Time) *time.Time {
 func ptrInt(i int) *int {
 	return &i
 }
+
+func TestTaskHistory_OmitsMissingCompletedAt(t *testing.T) {
+	data, err := json.Marshal(TaskHistory{
+		TaskID:    "task-1",
+		Type:      "test_task",
+		Status:    task.FailedStatus,
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.NotContains(t, raw, "completed_at")
+}
+
+func TestGetRecentTasks_IncludesDeadLetterTasks(t *testing.T) {
+	dash, q, mr := setupTestDashboard(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	dead := task.NewTask("send_email", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dead))
+	startedAt := time.Now().Add(-time.Second)
+	dead.StartedAt = &startedAt
+	require.NoError(t, q.MoveToDeadLetter(dead, "max retries exceeded"))
+
+	req := httptest.NewRequest("GET", "/api/dashboard/history", nil)
+	w := httptest.NewRecorder()
+
+	dash.GetRecentTasks(w, req)
+
+	require.Equal(t, 200, w.Code)
+	var history []TaskHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+
+	require.Len(t, history, 1)
+	assert.Equal(t, dead.ID, history[0].TaskID)
+	assert.Equal(t, task.DeadLetterStatus, history[0].Status)
+	require.NotNil(t, history[0].CompletedAt)
+	assert.WithinDuration(t, *dead.MoveToDLQAt, *history[0].CompletedAt, time.Millisecond)
+	assert.NotEmpty(t, history[0].Duration)
+}
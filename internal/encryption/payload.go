@@ -0,0 +1,126 @@
+// Package encryption provides AES-GCM encryption for task payloads at rest.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const encryptedPayloadField = "_encrypted"
+
+type PayloadCipher struct {
+	aead cipher.AEAD
+}
+
+func NewPayloadCipher(key []byte) (*PayloadCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload encryption key: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PayloadCipher{aead: aead}, nil
+}
+
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("payload encryption key must be base64 encoded: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("payload encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+func LoadPayloadCipher(encodedKey string) (*PayloadCipher, error) {
+	if encodedKey == "" {
+		return nil, nil
+	}
+
+	key, err := ParseKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPayloadCipher(key)
+}
+
+// HasReservedField reports whether payload uses the key encrypted payloads
+// are stored under. The queue rejects such payloads so plaintext can never
+// pass for ciphertext.
+func HasReservedField(payload map[string]any) bool {
+	_, ok := payload[encryptedPayloadField]
+	return ok
+}
+
+func IsEncrypted(payload map[string]any) bool {
+	if len(payload) != 1 {
+		return false
+	}
+
+	_, ok := payload[encryptedPayloadField].(string)
+	return ok
+}
+
+func (c *PayloadCipher) Encrypt(payload map[string]any) (map[string]any, error) {
+	if payload == nil {
+		return nil, nil
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+
+	return map[string]any{
+		encryptedPayloadField: base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+func (c *PayloadCipher) Decrypt(payload map[string]any) (map[string]any, error) {
+	if !IsEncrypted(payload) {
+		return payload, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload[encryptedPayloadField].(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted payload: %w", err)
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("encrypted payload is too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+
+	var decrypted map[string]any
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		return nil, err
+	}
+
+	return decrypted, nil
+}
@@ -0,0 +1,114 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey() []byte {
+	return []byte("0123456789abcdef0123456789abcdef")
+}
+
+func TestPayloadCipher_RoundTrip(t *testing.T) {
+	c, err := NewPayloadCipher(testKey())
+	require.NoError(t, err)
+
+	payload := map[string]any{"to": "user@example.com", "token": "secret"}
+
+	encrypted, err := c.Encrypt(payload)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted[encryptedPayloadField], "user@example.com")
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+}
+
+func TestPayloadCipher_UsesRandomNonce(t *testing.T) {
+	c, err := NewPayloadCipher(testKey())
+	require.NoError(t, err)
+
+	payload := map[string]any{"to": "user@example.com"}
+
+	first, err := c.Encrypt(payload)
+	require.NoError(t, err)
+	second, err := c.Encrypt(payload)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestPayloadCipher_PlaintextPassesThrough(t *testing.T) {
+	c, err := NewPayloadCipher(testKey())
+	require.NoError(t, err)
+
+	payload := map[string]any{"to": "user@example.com"}
+
+	decrypted, err := c.Decrypt(payload)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+
+	encrypted, err := c.Encrypt(nil)
+	require.NoError(t, err)
+	assert.Nil(t, encrypted)
+}
+
+func TestPayloadCipher_EncryptsLookalikePayload(t *testing.T) {
+	c, err := NewPayloadCipher(testKey())
+	require.NoError(t, err)
+
+	payload := map[string]any{encryptedPayloadField: "plaintext"}
+	assert.True(t, HasReservedField(payload))
+
+	encrypted, err := c.Encrypt(payload)
+	require.NoError(t, err)
+	assert.NotEqual(t, payload, encrypted)
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+}
+
+func TestPayloadCipher_WrongKey(t *testing.T) {
+	c, err := NewPayloadCipher(testKey())
+	require.NoError(t, err)
+
+	other, err := NewPayloadCipher([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt(map[string]any{"to": "user@example.com"})
+	require.NoError(t, err)
+
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(testKey()))
+	require.NoError(t, err)
+	assert.Equal(t, testKey(), key)
+
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+func TestLoadPayloadCipher(t *testing.T) {
+	c, err := LoadPayloadCipher("")
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = LoadPayloadCipher(base64.StdEncoding.EncodeToString(testKey()))
+	require.NoError(t, err)
+	assert.NotNil(t, c)
+
+	_, err = LoadPayloadCipher(strings.Repeat("A", 7))
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+// Package events publishes task lifecycle events so downstream systems can react to them.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/redis/go-redis/v9"
+)
+
+type Type string
+
+const (
+	Enqueued     Type = "task.enqueued"
+	Started      Type = "task.started"
+	Completed    Type = "task.completed"
+	Failed       Type = "task.failed"
+	Retrying     Type = "task.retrying"
+	DeadLettered Type = "task.dead_lettered"
+)
+
+const DefaultChannel = "nexq:task-events"
+
+type TaskEvent struct {
+	Type          Type      `json:"type"`
+	TaskID        string    `json:"task_id"`
+	TaskType      string    `json:"task_type"`
+	RetryCount    int       `json:"retry_count"`
+	Error         string    `json:"error,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func NewTaskEvent(eventType Type, t *task.Task, reason string) TaskEvent {
+	return TaskEvent{
+		Type:          eventType,
+		TaskID:        t.ID,
+		TaskType:      t.Type,
+		RetryCount:    t.RetryCount,
+		Error:         reason,
+		CorrelationID: t.CorrelationID,
+		Timestamp:     time.Now(),
+	}
+}
+
+// EventSink receives task lifecycle events. Publish is called inline on the
+// queue and worker paths, so implementations must not block for long and
+// must handle their own delivery errors.
+type EventSink interface {
+	Publish(event TaskEvent)
+}
+
+type NopSink struct{}
+
+func (NopSink) Publish(TaskEvent) {}
+
+// LogSink writes every event to the shared structured logger.
+type LogSink struct{}
+
+func (LogSink) Publish(event TaskEvent) {
+	logging.Logger().Info("task event",
+		"event", event.Type,
+		"task_id", event.TaskID,
+		"type", event.TaskType,
+		"retry_count", event.RetryCount,
+		"error", event.Error)
+}
+
+// RedisSink publishes every event as JSON on a Redis pub/sub channel.
+type RedisSink struct {
+	client  *redis.Client
+	channel string
+	timeout time.Duration
+}
+
+func NewRedisSink(addr, channel string) *RedisSink {
+	return &RedisSink{
+		client:  redis.NewClient(&redis.Options{Addr: addr}),
+		channel: channel,
+		timeout: time.Second,
+	}
+}
+
+func (s *RedisSink) Publish(event TaskEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		logging.Logger().Error("failed to encode task event", "task_id", event.TaskID, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if err := s.client.Publish(ctx, s.channel, data).Err(); err != nil {
+		logging.Logger().Error("failed to publish task event", "task_id", event.TaskID, "event", event.Type, "error", err)
+	}
+}
+
+func (s *RedisSink) Close() error {
+	return s.client.Close()
+}
+
+type SinkKind string
+
+const (
+	NoneSinkKind  SinkKind = "none"
+	LogSinkKind   SinkKind = "log"
+	RedisSinkKind SinkKind = "redis"
+)
+
+func ParseSinkKind(s string) (SinkKind, error) {
+	switch SinkKind(s) {
+	case "", NoneSinkKind:
+		return NoneSinkKind, nil
+	case LogSinkKind, RedisSinkKind:
+		return SinkKind(s), nil
+	default:
+		return "", fmt.Errorf("unsupported event sink: %s (available: none, log, redis)", s)
+	}
+}
+
+// NewSink creates a sink of the given kind. redisAddr and channel are only
+// used by the redis sink.
+func NewSink(kind SinkKind, redisAddr, channel string) EventSink {
+	switch kind {
+	case LogSinkKind:
+		return LogSink{}
+	case RedisSinkKind:
+		return NewRedisSink(redisAddr, channel)
+	default:
+		return NopSink{}
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSinkKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected SinkKind
+		wantErr  bool
+	}{
+		{input: "", expected: NoneSinkKind},
+		{input: "none", expected: NoneSinkKind},
+		{input: "log", expected: LogSinkKind},
+		{input: "redis", expected: RedisSinkKind},
+		{input: "kafka", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			kind, err := ParseSinkKind(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, kind)
+		})
+	}
+}
+
+func TestNewTaskEvent(t *testing.T) {
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	tsk.RetryCount = 2
+	tsk.CorrelationID = "req-1"
+
+	event := NewTaskEvent(Failed, tsk, "boom")
+
+	assert.Equal(t, Failed, event.Type)
+	assert.Equal(t, tsk.ID, event.TaskID)
+	assert.Equal(t, "send_email", event.TaskType)
+	assert.Equal(t, 2, event.RetryCount)
+	assert.Equal(t, "boom", event.Error)
+	assert.Equal(t, "req-1", event.CorrelationID)
+	assert.False(t, event.Timestamp.IsZero())
+}
+
+func TestLogSink(t *testing.T) {
+	previous := logging.Logger()
+	defer logging.SetLogger(previous)
+
+	var buf bytes.Buffer
+	logging.SetLogger(logging.New(&buf, logging.JSONFormat))
+
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	LogSink{}.Publish(NewTaskEvent(Completed, tsk, ""))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, string(Completed), entry["event"])
+	assert.Equal(t, tsk.ID, entry["task_id"])
+}
+
+func TestRedisSink(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	sub := mr.NewSubscriber()
+	defer sub.Close()
+	sub.Subscribe(DefaultChannel)
+
+	received := make(chan string, 1)
+	go func() {
+		received <- (<-sub.Messages()).Message
+	}()
+
+	sink := NewRedisSink(mr.Addr(), DefaultChannel)
+	defer func() { _ = sink.Close() }()
+
+	tsk := task.NewTask("send_email", nil, task.MediumPriority)
+	sink.Publish(NewTaskEvent(Enqueued, tsk, ""))
+
+	var event TaskEvent
+	require.NoError(t, json.Unmarshal([]byte(<-received), &event))
+	assert.Equal(t, Enqueued, event.Type)
+	assert.Equal(t, tsk.ID, event.TaskID)
+}
+
+func TestNewSink(t *testing.T) {
+	assert.IsType(t, NopSink{}, NewSink(NoneSinkKind, "", ""))
+	assert.IsType(t, LogSink{}, NewSink(LogSinkKind, "", ""))
+	assert.IsType(t, &RedisSink{}, NewSink(RedisSinkKind, "localhost:6379", DefaultChannel))
+}
@@ -3,7 +3,9 @@ package httputil
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
 )
 
 func WriteJSONError(w http.ResponseWriter, message string, status int) {
@@ -14,3 +16,21 @@ func WriteJSONError(w http.ResponseWriter, message string, status int) {
 		"error": message,
 	})
 }
+
+// Pretty reports whether the request asked for indented JSON with
+// ?pretty=true. Anything that does not parse as a boolean counts as false.
+func Pretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// NewJSONEncoder returns an encoder writing to w that indents its output when
+// the request asked for it and stays compact otherwise.
+func NewJSONEncoder(w io.Writer, r *http.Request) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if Pretty(r) {
+		enc.SetIndent("", "  ")
+	}
+
+	return enc
+}
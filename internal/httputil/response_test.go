@@ -0,0 +1,33 @@
+package httputil
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewJSONEncoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		lines  int
+	}{
+		{"compact by default", "/api/tasks", 1},
+		{"pretty", "/api/tasks?pretty=true", 4},
+		{"pretty disabled", "/api/tasks?pretty=false", 1},
+		{"invalid value", "/api/tasks?pretty=yes", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if err := NewJSONEncoder(&buf, r).Encode(map[string]string{"id": "1", "type": "test"}); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if got := bytes.Count(buf.Bytes(), []byte("\n")); got != tt.lines {
+				t.Errorf("output has %d lines, want %d: %q", got, tt.lines, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package logging provides the structured logger shared by the server and worker.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+type Format string
+
+const (
+	TextFormat Format = "text"
+	JSONFormat Format = "json"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", TextFormat:
+		return TextFormat, nil
+	case JSONFormat:
+		return JSONFormat, nil
+	default:
+		return "", fmt.Errorf("unsupported log format: %s (available: text, json)", s)
+	}
+}
+
+func New(w io.Writer, format Format) *slog.Logger {
+	if format == JSONFormat {
+		return slog.New(slog.NewJSONHandler(w, nil))
+	}
+
+	return slog.New(slog.NewTextHandler(w, nil))
+}
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(New(os.Stderr, TextFormat))
+}
+
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the shared logger, tagged with the request ID when the
+// context carries one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return Logger().With("request_id", id)
+	}
+
+	return Logger()
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{input: "", expected: TextFormat},
+		{input: "text", expected: TextFormat},
+		{input: "json", expected: JSONFormat},
+		{input: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			format, err := ParseFormat(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, JSONFormat).Info("task completed", "task_id", "task-1", "duration_ms", 42)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "task completed", entry["msg"])
+	assert.Equal(t, "task-1", entry["task_id"])
+	assert.Equal(t, float64(42), entry["duration_ms"])
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, TextFormat).Info("task completed", "task_id", "task-1")
+
+	assert.True(t, strings.Contains(buf.String(), "task_id=task-1"), buf.String())
+}
+
+func TestSetLogger(t *testing.T) {
+	previous := Logger()
+	defer SetLogger(previous)
+
+	var buf bytes.Buffer
+	SetLogger(New(&buf, JSONFormat))
+	Logger().Warn("something happened")
+
+	assert.Contains(t, buf.String(), `"msg":"something happened"`)
+}
+
+func TestFromContext(t *testing.T) {
+	previous := Logger()
+	defer SetLogger(previous)
+
+	var buf bytes.Buffer
+	SetLogger(New(&buf, JSONFormat))
+
+	FromContext(context.Background()).Info("no request")
+	FromContext(WithRequestID(context.Background(), "req-1")).Info("with request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.NotContains(t, lines[0], "request_id")
+	assert.Contains(t, lines[1], `"request_id":"req-1"`)
+}
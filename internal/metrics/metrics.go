@@ -2,6 +2,7 @@
 package metrics
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/nadmax/nexq/internal/task"
@@ -52,6 +53,13 @@ var (
 		},
 		[]string{"type"},
 	)
+	TasksExpired = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nexq_tasks_expired_total",
+			Help: "Total number of tasks dropped after their TTL expired",
+		},
+		[]string{"type"},
+	)
 	TasksInQueue = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "nexq_tasks_in_queue",
@@ -88,7 +96,13 @@ var (
 			Help:    "HTTP request duration in seconds",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"method", "endpoint"},
+		[]string{"method", "endpoint", "status_class"},
+	)
+	HTTPRequestsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nexq_http_requests_in_flight",
+			Help: "Current number of HTTP requests being served",
+		},
 	)
 	QueueDepth = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -102,6 +116,19 @@ var (
 			Help: "Current depth of the dead letter queue",
 		},
 	)
+	DelayedTasks = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nexq_tasks_delayed",
+			Help: "Current number of pending tasks scheduled to run in the future",
+		},
+	)
+	WorkerTasksInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nexq_worker_tasks_in_flight",
+			Help: "Current number of tasks being processed by each worker",
+		},
+		[]string{"worker"},
+	)
 	WorkersActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "nexq_workers_active",
@@ -136,6 +163,10 @@ func RecordTaskDeadLettered(taskType string) {
 	TasksDeadLettered.WithLabelValues(taskType).Inc()
 }
 
+func RecordTaskExpired(taskType string) {
+	TasksExpired.WithLabelValues(taskType).Inc()
+}
+
 func RecordTaskWaitTime(taskType string, priority task.TaskPriority, waitTime time.Duration) {
 	TaskWaitTime.WithLabelValues(taskType, priority.String()).Observe(waitTime.Seconds())
 }
@@ -157,11 +188,20 @@ func UpdateDeadLetterQueueDepth(depth int) {
 	DeadLetterQueueDepth.Set(float64(depth))
 }
 
+func UpdateDelayedTasks(count int) {
+	DelayedTasks.Set(float64(count))
+}
+
 func UpdateActiveWorkers(count int) {
 	WorkersActive.Set(float64(count))
 }
 
-func RecordHTTPRequest(method, endpoint, status string, duration time.Duration) {
+func RecordHTTPRequest(method, endpoint, status, statusClass string, duration time.Duration) {
 	HTTPRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
-	HTTPRequestDuration.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+	HTTPRequestDuration.WithLabelValues(method, endpoint, statusClass).Observe(duration.Seconds())
+}
+
+// StatusClass groups an HTTP status code into its class, e.g. 404 -> "4xx".
+func StatusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
 }
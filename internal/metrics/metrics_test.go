@@ -109,6 +109,17 @@ func TestRecordTaskDeadLettered(t *testing.T) {
 	assert.Equal(t, 1.0, count, "dead lettered counter should be 1")
 }
 
+func TestRecordTaskExpired(t *testing.T) {
+	TasksExpired.Reset()
+
+	taskType := "expired-task"
+	RecordTaskExpired(taskType)
+	RecordTaskExpired(taskType)
+
+	count := getCounterValue(t, TasksExpired, taskType)
+	assert.Equal(t, 2.0, count, "expired counter should be 2")
+}
+
 func TestRecordTaskWaitTime(t *testing.T) {
 	TaskWaitTime.Reset()
 
@@ -222,6 +233,20 @@ func TestUpdateDeadLetterQueueDepth(t *testing.T) {
 	}
 }
 
+func TestUpdateDelayedTasks(t *testing.T) {
+	counts := []int{0, 3, 42}
+
+	for _, count := range counts {
+		UpdateDelayedTasks(count)
+
+		metric := &dto.Metric{}
+		err := DelayedTasks.Write(metric)
+		require.NoError(t, err)
+
+		assert.Equal(t, float64(count), metric.Gauge.GetValue())
+	}
+}
+
 func TestUpdateActiveWorkers(t *testing.T) {
 	counts := []int{0, 1, 5, 10, 20}
 
@@ -245,6 +270,7 @@ func TestRecordHTTPRequest(t *testing.T) {
 		method   string
 		endpoint string
 		status   string
+		class    string
 		duration time.Duration
 	}{
 		{
@@ -252,6 +278,7 @@ func TestRecordHTTPRequest(t *testing.T) {
 			method:   "GET",
 			endpoint: "/tasks",
 			status:   "200",
+			class:    "2xx",
 			duration: 50 * time.Millisecond,
 		},
 		{
@@ -259,6 +286,7 @@ func TestRecordHTTPRequest(t *testing.T) {
 			method:   "POST",
 			endpoint: "/tasks",
 			status:   "500",
+			class:    "5xx",
 			duration: 100 * time.Millisecond,
 		},
 		{
@@ -266,21 +294,33 @@ func TestRecordHTTPRequest(t *testing.T) {
 			method:   "GET",
 			endpoint: "/unknown",
 			status:   "404",
+			class:    "4xx",
 			duration: 10 * time.Millisecond,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			RecordHTTPRequest(tt.method, tt.endpoint, tt.status, tt.duration)
+			RecordHTTPRequest(tt.method, tt.endpoint, tt.status, tt.class, tt.duration)
 
 			count := getCounterValue(t, HTTPRequestsTotal, tt.method, tt.endpoint, tt.status)
 			assert.Greater(t, count, 0.0, "request counter should be incremented")
 
-			sum := getHistogramSum(t, HTTPRequestDuration, tt.method, tt.endpoint)
+			sum := getHistogramSum(t, HTTPRequestDuration, tt.method, tt.endpoint, tt.class)
 			assert.Greater(t, sum, 0.0, "duration should be recorded")
 		})
 	}
+
+	successSum := getHistogramSum(t, HTTPRequestDuration, "POST", "/tasks", "2xx")
+	assert.Zero(t, successSum, "errored requests should not share the success series")
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", StatusClass(200))
+	assert.Equal(t, "2xx", StatusClass(204))
+	assert.Equal(t, "3xx", StatusClass(304))
+	assert.Equal(t, "4xx", StatusClass(404))
+	assert.Equal(t, "5xx", StatusClass(503))
 }
 
 func TestTaskDurationHistogramBuckets(t *testing.T) {
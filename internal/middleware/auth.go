@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/nadmax/nexq/internal/httputil"
+)
+
+// APIKeyAuth requires an "Authorization: Bearer <key>" header on /api/
+// requests. Other paths, such as /metrics, /health and the dashboard assets,
+// stay public. An empty key disables authentication.
+func APIKeyAuth(key string, next http.Handler) http.Handler {
+	if key == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nexq"`)
+			httputil.WriteJSONError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
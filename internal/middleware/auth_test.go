@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	handler := APIKeyAuth("secret-key", okHandler())
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{
+			name:          "authorized",
+			path:          "/api/tasks",
+			authorization: "Bearer secret-key",
+			expected:      http.StatusOK,
+		},
+		{
+			name:     "missing header",
+			path:     "/api/tasks",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:          "wrong key",
+			path:          "/api/tasks",
+			authorization: "Bearer other-key",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "wrong scheme",
+			path:          "/api/tasks",
+			authorization: "Basic secret-key",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:     "metrics stays public",
+			path:     "/metrics",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "health stays public",
+			path:     "/health",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "dashboard assets stay public",
+			path:     "/index.html",
+			expected: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status code %d, got %d", tt.expected, rec.Code)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401 response")
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_NoKeyConfigured(t *testing.T) {
+	handler := APIKeyAuth("", okHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/nadmax/nexq/internal/httputil"
+)
+
+// LimitRequestBody reads request bodies up front and rejects those larger
+// than maxBytes with 413, before a handler decodes them. It must run inside
+// DecompressGzip so the limit applies to the decompressed body. Zero
+// disables the limit.
+func LimitRequestBody(maxBytes int64, next http.Handler) http.Handler {
+	if maxBytes <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httputil.WriteJSONError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			httputil.WriteJSONError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
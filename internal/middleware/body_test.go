@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	var received int
+	handler := DecompressGzip(LimitRequestBody(64, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected read error: %v", err)
+		}
+		received = len(body)
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		expected int
+		received int
+	}{
+		{
+			name:     "within limit",
+			body:     []byte(strings.Repeat("a", 64)),
+			expected: http.StatusOK,
+			received: 64,
+		},
+		{
+			name:     "over limit",
+			body:     []byte(strings.Repeat("a", 65)),
+			expected: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "gzip expanding past the limit",
+			encoding: "gzip",
+			body:     gzipBytes(t, strings.Repeat("a", 1<<20)),
+			expected: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "gzip within limit",
+			encoding: "gzip",
+			body:     gzipBytes(t, strings.Repeat("a", 32)),
+			expected: http.StatusOK,
+			received: 32,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = 0
+			req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if received != tt.received {
+				t.Errorf("expected handler to read %d bytes, got %d", tt.received, received)
+			}
+		})
+	}
+}
+
+func TestLimitRequestBody_Disabled(t *testing.T) {
+	handler := LimitRequestBody(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(strings.Repeat("a", 1024)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
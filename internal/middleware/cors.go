@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods   = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders   = "Authorization, Content-Encoding, Content-Type, If-None-Match, X-Request-ID"
+	corsExposeHeaders  = "ETag, Location, Retry-After, X-Enqueue-Confirmation, X-Request-ID, X-Total-Count"
+	corsPreflightCache = "600"
+)
+
+// CORS adds cross-origin headers to /api/ responses so the dashboard can be
+// served from another origin, and answers preflight requests with 204
+// before they reach authentication.
+func CORS(allowedOrigin string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", allowedOrigin)
+		if allowedOrigin != "*" {
+			h.Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", corsPreflightCache)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	called := false
+	handler := CORS("*", APIKeyAuth("secret-key", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/tasks", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if called {
+		t.Error("expected preflight to be answered without calling the handler")
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": corsAllowMethods,
+		"Access-Control-Allow-Headers": corsAllowHeaders,
+	}
+	for header, value := range expected {
+		if got := rr.Header().Get(header); got != value {
+			t.Errorf("expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestCORS_ActualRequest(t *testing.T) {
+	handler := CORS("https://dashboard.example.com", okHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("expected configured origin, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != corsExposeHeaders {
+		t.Errorf("expected exposed headers %q, got %q", corsExposeHeaders, got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("expected Vary Origin, got %q", got)
+	}
+}
+
+func TestCORS_ListsHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		list, header string
+	}{
+		{corsAllowHeaders, "Content-Encoding"},
+		{corsAllowHeaders, "If-None-Match"},
+		{corsExposeHeaders, "ETag"},
+		{corsExposeHeaders, "Location"},
+	} {
+		if !slices.Contains(strings.Split(tc.list, ", "), tc.header) {
+			t.Errorf("expected %q to list %s", tc.list, tc.header)
+		}
+	}
+}
+
+func TestCORS_IgnoresNonAPIPaths(t *testing.T) {
+	handler := CORS("*", okHandler())
+
+	for _, path := range []string{"/metrics", "/index.html"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: expected no CORS headers, got origin %q", path, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/nadmax/nexq/internal/httputil"
+)
+
+// DecompressGzip transparently decodes request bodies sent with
+// "Content-Encoding: gzip", so handlers read the plain body. A body that is
+// not valid gzip is rejected with 400.
+func DecompressGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			httputil.WriteJSONError(w, "Invalid gzip request body", http.StatusBadRequest)
+			return
+		}
+		defer func() { _ = body.Close() }()
+
+		r = r.Clone(r.Context())
+		r.Body = body
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		next.ServeHTTP(w, r)
+	})
+}
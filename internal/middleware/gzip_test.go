@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecompressGzip(t *testing.T) {
+	var received string
+	handler := DecompressGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = string(body)
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Content-Encoding should be removed, got %q", r.Header.Get("Content-Encoding"))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		expected int
+		decoded  string
+	}{
+		{
+			name:     "gzip body",
+			encoding: "gzip",
+			body:     gzipBytes(t, `{"type":"send_email"}`),
+			expected: http.StatusOK,
+			decoded:  `{"type":"send_email"}`,
+		},
+		{
+			name:     "plain body",
+			body:     []byte(`{"type":"send_email"}`),
+			expected: http.StatusOK,
+			decoded:  `{"type":"send_email"}`,
+		},
+		{
+			name:     "malformed gzip",
+			encoding: "gzip",
+			body:     []byte("not gzip"),
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			req := httptest.NewRequest(http.MethodPost, "/api/tasks", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if received != tt.decoded {
+				t.Errorf("expected body %q, got %q", tt.decoded, received)
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
-// Package middleware provides HTTP middleware for metrics collection.
+// Package middleware provides the HTTP middleware wrapped around the API server.
 package middleware
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,10 @@ var recordHTTPRequest = metrics.RecordHTTPRequest
 
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.HTTPRequestsInFlight.Inc()
+		// Deferred so a panicking handler still releases its slot.
+		defer metrics.HTTPRequestsInFlight.Dec()
+
 		start := time.Now()
 		wrapped := &responseWriter{
 			ResponseWriter: w,
@@ -35,27 +40,65 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start)
 		endpoint := normalizeEndpoint(r.URL.Path)
 		status := strconv.Itoa(wrapped.statusCode)
+		statusClass := metrics.StatusClass(wrapped.statusCode)
 
-		recordHTTPRequest(r.Method, endpoint, status, duration)
+		recordHTTPRequest(r.Method, endpoint, status, statusClass, duration)
 	})
 }
 
+// idRoute describes a route prefix followed by a single high-cardinality
+// segment, optionally followed by one of a fixed set of actions.
+type idRoute struct {
+	prefix      string
+	placeholder string
+	actions     []string
+}
+
+// idRoutes is matched in order, so longer prefixes must come first.
+var idRoutes = []idRoute{
+	{prefix: "/api/tasks/cancel/", placeholder: ":id"},
+	{prefix: "/api/tasks/", placeholder: ":id", actions: []string{"ack", "nack", "requeue"}},
+	{prefix: "/api/dlq/tasks/", placeholder: ":id", actions: []string{"retry"}},
+	{prefix: "/api/history/task/", placeholder: ":id"},
+	{prefix: "/api/history/type/", placeholder: ":type"},
+	{prefix: "/api/history/tag/", placeholder: ":tag"},
+	{prefix: "/api/schedules/", placeholder: ":id"},
+	{prefix: "/api/reports/download/", placeholder: ":filename"},
+	{prefix: "/api/reports/", placeholder: ":id", actions: []string{"download"}},
+	{prefix: "/api/workers/", placeholder: ":id"},
+}
+
+// staticRoutes live under an idRoutes prefix but are fixed paths, not IDs.
+var staticRoutes = []string{"/api/reports/types", "/api/tasks/search"}
+
+// normalizeEndpoint collapses the ID segment of known routes so metrics are
+// labelled per route rather than per resource. Anything after the ID other
+// than a known action is labelled "other", so arbitrary paths cannot grow the
+// label set.
 func normalizeEndpoint(path string) string {
-	switch {
-	case strings.HasPrefix(path, "/api/tasks/") && !strings.Contains(path[11:], "/"):
-		return "/api/tasks/:id"
-	case strings.HasPrefix(path, "/api/dlq/tasks/"):
-		parts := strings.Split(strings.TrimPrefix(path, "/api/dlq/tasks/"), "/")
-		if len(parts) >= 2 && parts[1] == "retry" {
-			return "/api/dlq/tasks/:id/retry"
+	if slices.Contains(staticRoutes, path) {
+		return path
+	}
+
+	for _, route := range idRoutes {
+		rest, ok := strings.CutPrefix(path, route.prefix)
+		if !ok {
+			continue
 		}
 
-		return "/api/dlq/tasks/:id"
-	case strings.HasPrefix(path, "/api/history/task/"):
-		return "/api/history/task/:id"
-	case strings.HasPrefix(path, "/api/history/type/"):
-		return "/api/history/type/:type"
-	default:
-		return path
+		id, action, nested := strings.Cut(rest, "/")
+		if id == "" {
+			return path
+		}
+		if !nested {
+			return route.prefix + route.placeholder
+		}
+		if slices.Contains(route.actions, action) {
+			return route.prefix + route.placeholder + "/" + action
+		}
+
+		return route.prefix + route.placeholder + "/other"
 	}
+
+	return path
 }
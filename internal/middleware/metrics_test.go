@@ -5,6 +5,9 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/nadmax/nexq/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 type mockMetricsRecorder struct {
@@ -12,18 +15,20 @@ type mockMetricsRecorder struct {
 }
 
 type metricRecord struct {
-	method   string
-	endpoint string
-	status   string
-	duration time.Duration
+	method      string
+	endpoint    string
+	status      string
+	statusClass string
+	duration    time.Duration
 }
 
-func (m *mockMetricsRecorder) record(method, endpoint, status string, duration time.Duration) {
+func (m *mockMetricsRecorder) record(method, endpoint, status, statusClass string, duration time.Duration) {
 	m.records = append(m.records, metricRecord{
-		method:   method,
-		endpoint: endpoint,
-		status:   status,
-		duration: duration,
+		method:      method,
+		endpoint:    endpoint,
+		status:      status,
+		statusClass: statusClass,
+		duration:    duration,
 	})
 }
 
@@ -35,8 +40,8 @@ var mockRecorder = &mockMetricsRecorder{}
 
 func setupMock() func() {
 	original := recordHTTPRequest
-	recordHTTPRequest = func(method, endpoint, status string, duration time.Duration) {
-		mockRecorder.record(method, endpoint, status, duration)
+	recordHTTPRequest = func(method, endpoint, status, statusClass string, duration time.Duration) {
+		mockRecorder.record(method, endpoint, status, statusClass, duration)
 	}
 	return func() { recordHTTPRequest = original }
 }
@@ -114,9 +119,14 @@ func TestNormalizeEndpoint(t *testing.T) {
 			expected: "/api/tasks/:id",
 		},
 		{
-			name:     "task with nested path (should not normalize)",
+			name:     "task with unknown action",
 			path:     "/api/tasks/123/subtask",
-			expected: "/api/tasks/123/subtask",
+			expected: "/api/tasks/:id/other",
+		},
+		{
+			name:     "schedule with nested path",
+			path:     "/api/schedules/550e8400-e29b-41d4-a716-446655440000/foo/bar",
+			expected: "/api/schedules/:id/other",
 		},
 		{
 			name:     "dlq task by id",
@@ -143,6 +153,76 @@ func TestNormalizeEndpoint(t *testing.T) {
 			path:     "/api/history/type/notification-email",
 			expected: "/api/history/type/:type",
 		},
+		{
+			name:     "history by tag",
+			path:     "/api/history/tag/tenant-a",
+			expected: "/api/history/tag/:tag",
+		},
+		{
+			name:     "schedule by id",
+			path:     "/api/schedules/abc-123",
+			expected: "/api/schedules/:id",
+		},
+		{
+			name:     "task ack",
+			path:     "/api/tasks/123/ack",
+			expected: "/api/tasks/:id/ack",
+		},
+		{
+			name:     "task requeue",
+			path:     "/api/tasks/123/requeue",
+			expected: "/api/tasks/:id/requeue",
+		},
+		{
+			name:     "cancel task",
+			path:     "/api/tasks/cancel/123",
+			expected: "/api/tasks/cancel/:id",
+		},
+		{
+			name:     "report by id",
+			path:     "/api/reports/abc123",
+			expected: "/api/reports/:id",
+		},
+		{
+			name:     "report download by id",
+			path:     "/api/reports/abc123/download",
+			expected: "/api/reports/:id/download",
+		},
+		{
+			name:     "report download by filename",
+			path:     "/api/reports/download/report_123.csv",
+			expected: "/api/reports/download/:filename",
+		},
+		{
+			name:     "task search",
+			path:     "/api/tasks/search",
+			expected: "/api/tasks/search",
+		},
+		{
+			name:     "report types",
+			path:     "/api/reports/types",
+			expected: "/api/reports/types",
+		},
+		{
+			name:     "reports list",
+			path:     "/api/reports",
+			expected: "/api/reports",
+		},
+		{
+			name:     "worker by id",
+			path:     "/api/workers/worker-1",
+			expected: "/api/workers/:id",
+		},
+		{
+			name:     "workers list",
+			path:     "/api/workers",
+			expected: "/api/workers",
+		},
+		{
+			name:     "prefix without id",
+			path:     "/api/schedules/",
+			expected: "/api/schedules/",
+		},
 		{
 			name:     "root path",
 			path:     "/",
@@ -192,6 +272,7 @@ func TestMetricsMiddleware(t *testing.T) {
 		expectedMethod     string
 		expectedEndpoint   string
 		expectedStatusCode string
+		expectedClass      string
 	}{
 		{
 			name:               "GET task by id with 200",
@@ -201,6 +282,7 @@ func TestMetricsMiddleware(t *testing.T) {
 			expectedMethod:     http.MethodGet,
 			expectedEndpoint:   "/api/tasks/:id",
 			expectedStatusCode: "200",
+			expectedClass:      "2xx",
 		},
 		{
 			name:               "POST task with 201",
@@ -210,6 +292,7 @@ func TestMetricsMiddleware(t *testing.T) {
 			expectedMethod:     http.MethodPost,
 			expectedEndpoint:   "/api/tasks",
 			expectedStatusCode: "201",
+			expectedClass:      "2xx",
 		},
 		{
 			name:               "DELETE task with 404",
@@ -219,6 +302,7 @@ func TestMetricsMiddleware(t *testing.T) {
 			expectedMethod:     http.MethodDelete,
 			expectedEndpoint:   "/api/tasks/:id",
 			expectedStatusCode: "404",
+			expectedClass:      "4xx",
 		},
 		{
 			name:               "GET dlq task retry with 200",
@@ -228,6 +312,7 @@ func TestMetricsMiddleware(t *testing.T) {
 			expectedMethod:     http.MethodGet,
 			expectedEndpoint:   "/api/dlq/tasks/:id/retry",
 			expectedStatusCode: "200",
+			expectedClass:      "2xx",
 		},
 		{
 			name:               "internal server error",
@@ -237,6 +322,7 @@ func TestMetricsMiddleware(t *testing.T) {
 			expectedMethod:     http.MethodGet,
 			expectedEndpoint:   "/api/tasks/:id",
 			expectedStatusCode: "500",
+			expectedClass:      "5xx",
 		},
 	}
 
@@ -273,6 +359,9 @@ func TestMetricsMiddleware(t *testing.T) {
 			if m.status != tt.expectedStatusCode {
 				t.Errorf("expected status %q, got %q", tt.expectedStatusCode, m.status)
 			}
+			if m.statusClass != tt.expectedClass {
+				t.Errorf("expected status class %q, got %q", tt.expectedClass, m.statusClass)
+			}
 			if m.duration <= 0 {
 				t.Error("expected duration > 0")
 			}
@@ -330,3 +419,61 @@ func TestMetricsMiddleware_RecordsDuration(t *testing.T) {
 		t.Errorf("expected duration >= %v, got %v", delay, recorded.duration)
 	}
 }
+
+func TestMetricsMiddleware_InFlightGauge(t *testing.T) {
+	cleanup := setupMock()
+	defer cleanup()
+
+	metrics.HTTPRequestsInFlight.Set(0)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := MetricsMiddleware(testHandler)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	}()
+
+	<-started
+	if got := testutil.ToFloat64(metrics.HTTPRequestsInFlight); got != 1 {
+		t.Errorf("expected 1 request in flight, got %v", got)
+	}
+
+	close(release)
+	<-done
+	if got := testutil.ToFloat64(metrics.HTTPRequestsInFlight); got != 0 {
+		t.Errorf("expected no requests in flight, got %v", got)
+	}
+}
+
+func TestMetricsMiddleware_InFlightGaugeOnPanic(t *testing.T) {
+	cleanup := setupMock()
+	defer cleanup()
+
+	metrics.HTTPRequestsInFlight.Set(0)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	handler := MetricsMiddleware(testHandler)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the handler panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	}()
+
+	if got := testutil.ToFloat64(metrics.HTTPRequestsInFlight); got != 0 {
+		t.Errorf("expected no requests in flight after a panic, got %v", got)
+	}
+}
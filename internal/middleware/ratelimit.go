@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nadmax/nexq/internal/httputil"
+	"golang.org/x/time/rate"
+)
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter applies a token bucket per client IP to task creation
+// requests. Clients are keyed by the connection's remote address, so a
+// proxy in front of the server is seen as a single client.
+type RateLimiter struct {
+	limit   rate.Limit
+	burst   int
+	now     func() time.Time
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+}
+
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		now:     time.Now,
+		clients: make(map[string]*clientLimiter),
+	}
+}
+
+// Middleware limits POST /api/tasks and passes every other request through.
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/tasks" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wait, ok := rl.allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			httputil.WriteJSONError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) allow(ip string) (time.Duration, bool) {
+	now := rl.now()
+
+	rl.mu.Lock()
+	c, exists := rl.clients[ip]
+	if !exists {
+		c = &clientLimiter{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = now
+	rl.mu.Unlock()
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return time.Second, false
+	}
+
+	wait := reservation.DelayFrom(now)
+	if wait > 0 {
+		reservation.CancelAt(now)
+		return wait, false
+	}
+
+	return 0, true
+}
+
+// Cleanup forgets clients that have not sent a request for longer than idle
+// and returns how many were removed.
+func (rl *RateLimiter) Cleanup(idle time.Duration) int {
+	cutoff := rl.now().Add(-idle)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	removed := 0
+	for ip, c := range rl.clients {
+		if c.lastSeen.Before(cutoff) {
+			delete(rl.clients, ip)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+func (rl *RateLimiter) StartCleanup(interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.Cleanup(idle)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
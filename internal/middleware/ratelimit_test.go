@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func createTaskRequest(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks", nil)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestRateLimiter_ExceedsBurst(t *testing.T) {
+	rl := NewRateLimiter(1, 3)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+	handler := rl.Middleware(okHandler())
+
+	for i := range 3 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, createTaskRequest("10.0.0.1:1234"))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, http.StatusOK, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, createTaskRequest("10.0.0.1:1234"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+
+	now = now.Add(time.Second)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, createTaskRequest("10.0.0.1:1234"))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d after refill, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRateLimiter_PerClient(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	handler := rl.Middleware(okHandler())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, createTaskRequest("10.0.0.1:1234"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, createTaskRequest("10.0.0.1:5678"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected same IP on another port to be limited, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, createTaskRequest("10.0.0.2:1234"))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected another client to be allowed, got %d", rr.Code)
+	}
+}
+
+func TestRateLimiter_OnlyLimitsTaskCreation(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	handler := rl.Middleware(okHandler())
+
+	requests := []*http.Request{
+		createTaskRequest("10.0.0.1:1234"),
+		httptest.NewRequest(http.MethodGet, "/api/tasks", nil),
+		httptest.NewRequest(http.MethodGet, "/api/tasks", nil),
+		httptest.NewRequest(http.MethodPost, "/api/tasks/abc/cancel", nil),
+	}
+
+	for _, req := range requests {
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status %d, got %d", req.Method, req.URL.Path, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+func TestRateLimiter_Cleanup(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+	handler := rl.Middleware(okHandler())
+
+	handler.ServeHTTP(httptest.NewRecorder(), createTaskRequest("10.0.0.1:1234"))
+	now = now.Add(5 * time.Minute)
+	handler.ServeHTTP(httptest.NewRecorder(), createTaskRequest("10.0.0.2:1234"))
+
+	if removed := rl.Cleanup(time.Minute); removed != 1 {
+		t.Errorf("expected 1 idle client removed, got %d", removed)
+	}
+	if _, exists := rl.clients["10.0.0.1"]; exists {
+		t.Error("expected idle client to be forgotten")
+	}
+	if _, exists := rl.clients["10.0.0.2"]; !exists {
+		t.Error("expected recent client to be kept")
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nadmax/nexq/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "echoes caller id", incoming: "req-123", keep: true},
+		{name: "generates missing id"},
+		{name: "replaces id with spaces", incoming: "not valid"},
+		{name: "replaces oversized id", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if got == "" {
+				t.Fatal("expected X-Request-ID response header")
+			}
+			if got != seen {
+				t.Errorf("expected context id %q to match header %q", seen, got)
+			}
+			if tt.keep && got != tt.incoming {
+				t.Errorf("expected id %q to round-trip, got %q", tt.incoming, got)
+			}
+			if !tt.keep && got == tt.incoming {
+				t.Errorf("expected id %q to be replaced", tt.incoming)
+			}
+		})
+	}
+}
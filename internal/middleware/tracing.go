@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span around each request, continuing any trace the
+// caller propagated. It must run inside RequestID so the span can carry the
+// correlation ID, and costs next to nothing while tracing is not set up.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		endpoint := normalizeEndpoint(r.URL.Path)
+
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+endpoint,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(endpoint),
+				attribute.String(tracing.CorrelationIDKey, logging.RequestID(r.Context())),
+			))
+		defer span.End()
+
+		wrapped := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+		}
+	})
+}
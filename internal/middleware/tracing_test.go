@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nadmax/nexq/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	var inSpan bool
+	handler := RequestID(Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inSpan = trace.SpanFromContext(r.Context()).SpanContext().IsValid()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/abc-123", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !inSpan {
+		t.Error("expected the handler to run inside the request span")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/tasks/:id" {
+		t.Errorf("expected span name %q, got %q", "GET /api/tasks/:id", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("expected a server span, got %v", span.SpanKind())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected error status for a 503, got %v", span.Status().Code)
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs[tracing.CorrelationIDKey].AsString(); got != "req-123" {
+		t.Errorf("expected correlation id %q, got %q", "req-123", got)
+	}
+	if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusServiceUnavailable {
+		t.Errorf("expected status code attribute %d, got %d", http.StatusServiceUnavailable, got)
+	}
+}
+
+func TestTracing_NoProvider(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+
+	called := false
+	handler := Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if trace.SpanFromContext(r.Context()).SpanContext().IsValid() {
+			t.Error("expected no recording span without a tracer provider")
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if !called {
+		t.Error("expected the handler to be called")
+	}
+}
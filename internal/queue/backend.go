@@ -0,0 +1,332 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNil is returned by Backend.Get and Backend.GetSet when the key does not
+// exist.
+var ErrNil = errors.New("key does not exist")
+
+// ErrBackendUnavailable wraps connection errors that persisted through the
+// client's retries, e.g. while Redis is restarting.
+var ErrBackendUnavailable = errors.New("queue backend unavailable")
+
+const backendDialTimeout = 5 * time.Second
+
+type retryPolicy struct {
+	maxRetries  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	dialRetries int
+	dialBackoff time.Duration
+}
+
+// backendRetry is how hard the Redis client retries a command on a broken
+// connection before it reports ErrBackendUnavailable. Each retry dials a
+// fresh connection, so the queue recovers on its own once the server is back.
+var backendRetry = retryPolicy{
+	maxRetries:  3,
+	minBackoff:  50 * time.Millisecond,
+	maxBackoff:  time.Second,
+	dialRetries: 5,
+	dialBackoff: 100 * time.Millisecond,
+}
+
+// Backend is the key-value store the queue keeps its state in. Set and
+// sorted-set operations are part of the interface so that stores without
+// native support can emulate them on top of plain keys.
+type Backend interface {
+	Ping(ctx context.Context) error
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// SetXX overwrites key only if it exists and reports whether it did.
+	SetXX(ctx context.Context, key, value string) (bool, error)
+	GetSet(ctx context.Context, key, value string) (string, error)
+	Del(ctx context.Context, keys ...string) (int64, error)
+	Exists(ctx context.Context, keys ...string) (int64, error)
+	Incr(ctx context.Context, key string) (int64, error)
+	Decr(ctx context.Context, key string) (int64, error)
+	// Expire sets a key's time to live. It is a no-op for a missing key.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Scan returns every key matching the glob pattern.
+	Scan(ctx context.Context, match string) ([]string, error)
+
+	SAdd(ctx context.Context, key, member string) error
+	SRem(ctx context.Context, key, member string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SCard(ctx context.Context, key string) (int64, error)
+	SIsMember(ctx context.Context, key, member string) (bool, error)
+
+	ZAdd(ctx context.Context, key, member string, score float64) error
+	// ZRem reports whether member was present, so callers can use it to
+	// claim a member exactly once.
+	ZRem(ctx context.Context, key, member string) (bool, error)
+	// ZRangeByScore returns the members scored at most max.
+	ZRangeByScore(ctx context.Context, key string, max float64) ([]string, error)
+
+	Close() error
+}
+
+type BackendKind string
+
+const (
+	// RedisBackendKind uses native Redis commands, including sets and sorted sets.
+	RedisBackendKind BackendKind = "redis"
+	// PogocacheBackendKind only uses the key-value commands Pogocache implements.
+	PogocacheBackendKind BackendKind = "pogocache"
+)
+
+func ParseBackendKind(s string) (BackendKind, error) {
+	switch s {
+	case "", string(RedisBackendKind):
+		return RedisBackendKind, nil
+	case string(PogocacheBackendKind):
+		return PogocacheBackendKind, nil
+	default:
+		return "", fmt.Errorf("unsupported queue backend: %s (available: redis, pogocache)", s)
+	}
+}
+
+// NewBackend creates a backend of the given kind connected to addr.
+func NewBackend(kind BackendKind, addr string) (Backend, error) {
+	switch kind {
+	case RedisBackendKind:
+		return NewRedisBackend(addr), nil
+	case PogocacheBackendKind:
+		return NewPogocacheBackend(addr), nil
+	default:
+		return nil, fmt.Errorf("unsupported queue backend: %s", kind)
+	}
+}
+
+type RedisBackend struct {
+	client *redis.Client
+}
+
+var _ Backend = (*RedisBackend)(nil)
+
+func NewRedisBackend(addr string) *RedisBackend {
+	client := redis.NewClient(&redis.Options{
+		Addr:               addr,
+		MaxRetries:         backendRetry.maxRetries,
+		MinRetryBackoff:    backendRetry.minBackoff,
+		MaxRetryBackoff:    backendRetry.maxBackoff,
+		DialTimeout:        backendDialTimeout,
+		DialerRetries:      backendRetry.dialRetries,
+		DialerRetryTimeout: backendRetry.dialBackoff,
+	})
+	client.AddHook(availabilityHook{})
+
+	return &RedisBackend{client: client}
+}
+
+// availabilityHook wraps the client's command processing, which already
+// includes its retries, and tags connection errors with
+// ErrBackendUnavailable. The error is set on the command too, since that is
+// where the typed command helpers read it from.
+type availabilityHook struct{}
+
+func (availabilityHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (availabilityHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := markUnavailable(next(ctx, cmd))
+		if err != nil {
+			cmd.SetErr(err)
+		}
+
+		return err
+	}
+}
+
+func (availabilityHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if cmdErr := cmd.Err(); cmdErr != nil {
+				cmd.SetErr(markUnavailable(cmdErr))
+			}
+		}
+
+		return markUnavailable(err)
+	}
+}
+
+func markUnavailable(err error) error {
+	if errors.Is(err, ErrBackendUnavailable) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+
+	return err
+}
+
+func (b *RedisBackend) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) (string, error) {
+	value, err := b.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNil
+	}
+
+	return value, err
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return b.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (b *RedisBackend) SetXX(ctx context.Context, key, value string) (bool, error) {
+	return b.client.SetXX(ctx, key, value, 0).Result()
+}
+
+func (b *RedisBackend) GetSet(ctx context.Context, key, value string) (string, error) {
+	previous, err := b.client.GetSet(ctx, key, value).Result()
+	if err == redis.Nil {
+		return "", ErrNil
+	}
+
+	return previous, err
+}
+
+func (b *RedisBackend) Del(ctx context.Context, keys ...string) (int64, error) {
+	return b.client.Del(ctx, keys...).Result()
+}
+
+func (b *RedisBackend) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return b.client.Exists(ctx, keys...).Result()
+}
+
+func (b *RedisBackend) Incr(ctx context.Context, key string) (int64, error) {
+	return b.client.Incr(ctx, key).Result()
+}
+
+func (b *RedisBackend) Decr(ctx context.Context, key string) (int64, error) {
+	return b.client.Decr(ctx, key).Result()
+}
+
+func (b *RedisBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return b.client.Expire(ctx, key, ttl).Err()
+}
+
+func (b *RedisBackend) Scan(ctx context.Context, match string) ([]string, error) {
+	return scanKeys(ctx, b.client, match)
+}
+
+func (b *RedisBackend) SAdd(ctx context.Context, key, member string) error {
+	return b.client.SAdd(ctx, key, member).Err()
+}
+
+func (b *RedisBackend) SRem(ctx context.Context, key, member string) error {
+	return b.client.SRem(ctx, key, member).Err()
+}
+
+func (b *RedisBackend) SMembers(ctx context.Context, key string) ([]string, error) {
+	return b.client.SMembers(ctx, key).Result()
+}
+
+func (b *RedisBackend) SCard(ctx context.Context, key string) (int64, error) {
+	return b.client.SCard(ctx, key).Result()
+}
+
+func (b *RedisBackend) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	return b.client.SIsMember(ctx, key, member).Result()
+}
+
+func (b *RedisBackend) ZAdd(ctx context.Context, key, member string, score float64) error {
+	return b.client.ZAdd(ctx, key, redis.Z{Score: score, Member: member}).Err()
+}
+
+func (b *RedisBackend) ZRem(ctx context.Context, key, member string) (bool, error) {
+	removed, err := b.client.ZRem(ctx, key, member).Result()
+	return removed > 0, err
+}
+
+func (b *RedisBackend) ZRangeByScore(ctx context.Context, key string, max float64) ([]string, error) {
+	return b.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatFloat(max, 'f', -1, 64),
+	}).Result()
+}
+
+func (b *RedisBackend) Close() error {
+	return b.client.Close()
+}
+
+// trackStatusScript is trackStatus run server-side: it swaps the status key,
+// KEYS[1], and moves one count from the previous status counter to the new
+// one. Every counter is passed in KEYS[2..], with its status name at the same
+// index of ARGV, so the script only touches keys it declares.
+var trackStatusScript = redis.NewScript(`
+local previous = redis.call('GETSET', KEYS[1], ARGV[1])
+if previous == ARGV[1] then
+	return 0
+end
+for i = 2, #KEYS do
+	if ARGV[i] == ARGV[1] then
+		redis.call('INCR', KEYS[i])
+	elseif ARGV[i] == previous then
+		redis.call('DECR', KEYS[i])
+	end
+end
+return 1
+`)
+
+// storeTask sends the writes of Queue.storeTask as one pipeline. EVAL is used
+// rather than EVALSHA since a pipeline cannot fall back on NOSCRIPT.
+func (b *RedisBackend) storeTask(ctx context.Context, taskID, data string, status task.TaskStatus) error {
+	keys := make([]string, 0, len(countedStatuses)+1)
+	args := make([]any, 0, len(countedStatuses)+1)
+	keys = append(keys, "status:"+taskID)
+	args = append(args, string(status))
+	for _, counted := range countedStatuses {
+		keys = append(keys, "stats:"+string(counted))
+		args = append(args, string(counted))
+	}
+
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "task:"+taskID, data, 0)
+		pipe.SAdd(ctx, "tasks:index", taskID)
+		trackStatusScript.Eval(ctx, pipe, keys, args...)
+		return nil
+	})
+
+	return err
+}
+
+func scanKeys(ctx context.Context, client *redis.Client, match string) ([]string, error) {
+	var keys []string
+
+	iter := client.Scan(ctx, 0, match, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
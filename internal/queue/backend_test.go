@@ -0,0 +1,433 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var backendKinds = []BackendKind{RedisBackendKind, PogocacheBackendKind}
+
+func setupTestBackend(t *testing.T, kind BackendKind) (Backend, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+
+	backend, err := NewBackend(kind, mr.Addr())
+	require.NoError(t, err)
+
+	return backend, mr
+}
+
+func TestParseBackendKind(t *testing.T) {
+	kind, err := ParseBackendKind("")
+	require.NoError(t, err)
+	assert.Equal(t, RedisBackendKind, kind)
+
+	kind, err = ParseBackendKind("pogocache")
+	require.NoError(t, err)
+	assert.Equal(t, PogocacheBackendKind, kind)
+
+	_, err = ParseBackendKind("memcached")
+	assert.Error(t, err)
+}
+
+func TestBackend_KeyValue(t *testing.T) {
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+			defer func() { _ = b.Close() }()
+			ctx := context.Background()
+
+			_, err := b.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNil)
+
+			previous, err := b.GetSet(ctx, "status:1", "pending")
+			assert.ErrorIs(t, err, ErrNil)
+			assert.Empty(t, previous)
+
+			previous, err = b.GetSet(ctx, "status:1", "running")
+			require.NoError(t, err)
+			assert.Equal(t, "pending", previous)
+
+			acquired, err := b.SetNX(ctx, "lock", "a", time.Minute)
+			require.NoError(t, err)
+			assert.True(t, acquired)
+			acquired, err = b.SetNX(ctx, "lock", "b", time.Minute)
+			require.NoError(t, err)
+			assert.False(t, acquired)
+
+			updated, err := b.SetXX(ctx, "lock", "c")
+			require.NoError(t, err)
+			assert.True(t, updated)
+			updated, err = b.SetXX(ctx, "missing", "c")
+			require.NoError(t, err)
+			assert.False(t, updated)
+			_, err = b.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNil)
+
+			n, err := b.Incr(ctx, "counter")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+			n, err = b.Decr(ctx, "counter")
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), n)
+
+			require.NoError(t, b.Set(ctx, "schedule:a", "1", 0))
+			require.NoError(t, b.Set(ctx, "schedule:b", "2", 0))
+			keys, err := b.Scan(ctx, "schedule:*")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"schedule:a", "schedule:b"}, keys)
+
+			n, err = b.Exists(ctx, "schedule:a", "schedule:b", "schedule:c")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+
+			n, err = b.Del(ctx, "schedule:a", "schedule:c")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+		})
+	}
+}
+
+func TestBackend_Sets(t *testing.T) {
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+			defer func() { _ = b.Close() }()
+			ctx := context.Background()
+
+			require.NoError(t, b.SAdd(ctx, "tasks:index", "a"))
+			require.NoError(t, b.SAdd(ctx, "tasks:index", "b"))
+			require.NoError(t, b.SAdd(ctx, "tasks:index", "b"))
+			require.NoError(t, b.SAdd(ctx, "dlq:index", "c"))
+
+			members, err := b.SMembers(ctx, "tasks:index")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "b"}, members)
+
+			count, err := b.SCard(ctx, "tasks:index")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), count)
+
+			require.NoError(t, b.SRem(ctx, "tasks:index", "a"))
+			isMember, err := b.SIsMember(ctx, "tasks:index", "a")
+			require.NoError(t, err)
+			assert.False(t, isMember)
+			isMember, err = b.SIsMember(ctx, "tasks:index", "b")
+			require.NoError(t, err)
+			assert.True(t, isMember)
+		})
+	}
+}
+
+func TestBackend_SortedSets(t *testing.T) {
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+			defer func() { _ = b.Close() }()
+			ctx := context.Background()
+
+			require.NoError(t, b.ZAdd(ctx, "inflight", "early", 100))
+			require.NoError(t, b.ZAdd(ctx, "inflight", "late", 300))
+
+			members, err := b.ZRangeByScore(ctx, "inflight", 200)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"early"}, members)
+
+			removed, err := b.ZRem(ctx, "inflight", "early")
+			require.NoError(t, err)
+			assert.True(t, removed)
+			removed, err = b.ZRem(ctx, "inflight", "early")
+			require.NoError(t, err)
+			assert.False(t, removed)
+		})
+	}
+}
+
+func TestBackend_ConcurrentSetWrites(t *testing.T) {
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+			defer func() { _ = b.Close() }()
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, b.SAdd(ctx, "tasks:index", strconv.Itoa(i)))
+					assert.NoError(t, b.ZAdd(ctx, "inflight", strconv.Itoa(i), float64(i)))
+				}()
+			}
+			wg.Wait()
+
+			count, err := b.SCard(ctx, "tasks:index")
+			require.NoError(t, err)
+			assert.Equal(t, int64(20), count)
+
+			members, err := b.ZRangeByScore(ctx, "inflight", 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"0", "1", "2"}, members)
+		})
+	}
+}
+
+// shortenBackendRetry keeps tests that take the backend down from waiting
+// out the production retry policy.
+func shortenBackendRetry(t *testing.T) {
+	previous := backendRetry
+	backendRetry = retryPolicy{
+		maxRetries:  1,
+		minBackoff:  time.Millisecond,
+		maxBackoff:  time.Millisecond,
+		dialRetries: 1,
+		dialBackoff: time.Millisecond,
+	}
+	t.Cleanup(func() { backendRetry = previous })
+}
+
+func TestBackend_Reconnect(t *testing.T) {
+	shortenBackendRetry(t)
+
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+			defer func() { _ = b.Close() }()
+			ctx := context.Background()
+
+			require.NoError(t, b.Set(ctx, "queue:head", "1", 0))
+
+			mr.Close()
+			_, err := b.Get(ctx, "queue:head")
+			assert.ErrorIs(t, err, ErrBackendUnavailable)
+			assert.ErrorIs(t, b.Set(ctx, "queue:head", "2", 0), ErrBackendUnavailable)
+
+			require.NoError(t, mr.Restart())
+			value, err := b.Get(ctx, "queue:head")
+			require.NoError(t, err)
+			assert.Equal(t, "1", value)
+
+			_, err = b.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNil)
+			assert.NotErrorIs(t, err, ErrBackendUnavailable)
+		})
+	}
+}
+
+func TestQueue_RecoversAfterBackendRestart(t *testing.T) {
+	shortenBackendRetry(t)
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(first))
+
+	mr.Close()
+	assert.ErrorIs(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)), ErrBackendUnavailable)
+
+	require.NoError(t, mr.Restart())
+	second := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(second))
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, dequeued.ID)
+}
+
+// roundTripCounter counts commands and pipelines sent by a Redis client.
+type roundTripCounter struct {
+	n atomic.Int64
+}
+
+func (c *roundTripCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (c *roundTripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.n.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (c *roundTripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.n.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+// setupCountedQueue returns a queue on a Redis backend that counts its round
+// trips. With pipelined false the backend is hidden behind the Backend
+// interface, so the queue falls back to one command per write.
+func setupCountedQueue(t testing.TB, pipelined bool) (*Queue, *roundTripCounter, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+
+	rb := NewRedisBackend(mr.Addr())
+	counter := &roundTripCounter{}
+	rb.client.AddHook(counter)
+
+	var backend Backend = rb
+	if !pipelined {
+		backend = struct{ Backend }{rb}
+	}
+	q, err := NewQueueWithBackend(backend, nil)
+	require.NoError(t, err)
+
+	return q, counter, mr
+}
+
+func TestStartTask_PipelinedMatchesSequential(t *testing.T) {
+	type snapshot struct {
+		task   *task.Task
+		status task.TaskStatus
+		counts map[task.TaskStatus]int
+		trips  int64
+	}
+
+	run := func(pipelined bool) snapshot {
+		q, counter, mr := setupCountedQueue(t, pipelined)
+		defer mr.Close()
+		defer func() { _ = q.Close() }()
+
+		tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.HighPriority)
+		tsk.ID = "task-1"
+		tsk.CreatedAt = time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+		tsk.ScheduledAt = tsk.CreatedAt
+		require.NoError(t, q.Enqueue(tsk))
+		claimed, err := q.Claim("worker-1")
+		require.NoError(t, err)
+
+		startedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		claimed.Status = task.RunningStatus
+		claimed.StartedAt = &startedAt
+
+		before := counter.n.Load()
+		require.NoError(t, q.StartTask(claimed, "worker-1"))
+		trips := counter.n.Load() - before
+
+		claimed.Status = task.CompletedStatus
+		require.NoError(t, q.UpdateTask(claimed))
+
+		stored, err := q.GetTask(claimed.ID)
+		require.NoError(t, err)
+		status, found := q.LookupStatus(claimed.ID)
+		require.True(t, found)
+		counts, err := q.StatusCounts()
+		require.NoError(t, err)
+
+		return snapshot{task: stored, status: status, counts: counts, trips: trips}
+	}
+
+	pipelined := run(true)
+	sequential := run(false)
+
+	assert.Equal(t, sequential.task, pipelined.task)
+	assert.Equal(t, sequential.status, pipelined.status)
+	assert.Equal(t, sequential.counts, pipelined.counts)
+	assert.Equal(t, map[task.TaskStatus]int{task.CompletedStatus: 1}, pipelined.counts)
+	assert.Equal(t, int64(1), pipelined.trips)
+	assert.Greater(t, sequential.trips, pipelined.trips)
+}
+
+// BenchmarkStartTask reports round trips per running transition. miniredis
+// has no network latency and interprets Lua slowly, so ns/op understates what
+// the pipeline saves against a real server.
+func BenchmarkStartTask(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		pipelined bool
+	}{
+		{"pipelined", true},
+		{"sequential", false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			q, counter, mr := setupCountedQueue(b, bc.pipelined)
+			defer mr.Close()
+			defer func() { _ = q.Close() }()
+
+			tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+			tsk.Status = task.RunningStatus
+			before := counter.n.Load()
+
+			b.ResetTimer()
+			for b.Loop() {
+				require.NoError(b, q.StartTask(tsk, "worker-1"))
+			}
+
+			b.ReportMetric(float64(counter.n.Load()-before)/float64(b.N), "roundtrips/op")
+		})
+	}
+}
+
+func TestQueue_Backends(t *testing.T) {
+	for _, kind := range backendKinds {
+		t.Run(string(kind), func(t *testing.T) {
+			b, mr := setupTestBackend(t, kind)
+			defer mr.Close()
+
+			q, err := NewQueueWithBackend(b, nil)
+			require.NoError(t, err)
+			defer func() { _ = q.Close() }()
+
+			first := task.NewTask("test_task", nil, task.HighPriority)
+			second := task.NewTask("test_task", nil, task.LowPriority)
+			require.NoError(t, q.Enqueue(first))
+			require.NoError(t, q.Enqueue(second))
+
+			tasks, err := q.GetAllTasks()
+			require.NoError(t, err)
+			assert.Len(t, tasks, 2)
+
+			q.SetVisibilityTimeout(time.Millisecond)
+			claimed, err := q.Claim("worker-1")
+			require.NoError(t, err)
+			require.Equal(t, first.ID, claimed.ID)
+
+			time.Sleep(5 * time.Millisecond)
+			reclaimed, err := q.ReclaimExpired()
+			require.NoError(t, err)
+			assert.Equal(t, 1, reclaimed)
+
+			q.SetVisibilityTimeout(DefaultVisibilityTimeout)
+			dequeued, err := q.Dequeue()
+			require.NoError(t, err)
+			require.Equal(t, second.ID, dequeued.ID)
+			require.NoError(t, q.Nack(second.ID, false))
+
+			counts, err := q.StatusCounts()
+			require.NoError(t, err)
+			assert.Equal(t, map[task.TaskStatus]int{
+				task.PendingStatus:    1,
+				task.DeadLetterStatus: 1,
+			}, counts)
+
+			stats, err := q.GetDeadLetterStats()
+			require.NoError(t, err)
+			assert.Equal(t, 1, stats["total_tasks"])
+
+			require.NoError(t, q.Heartbeat(WorkerInfo{ID: "worker-1", LastSeen: time.Now()}, time.Minute))
+			workers, err := q.ActiveWorkers()
+			require.NoError(t, err)
+			require.Len(t, workers, 1)
+			assert.Equal(t, "worker-1", workers[0].ID)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nadmax/nexq/internal/task"
+)
+
+// EnqueueDeduplicated drops t if a task with the same type and payload was
+// enqueued within window, returning that task instead. Unlike an idempotency
+// key, the match is on content, which suits debouncing jobs such as cache
+// invalidations.
+func (q *Queue) EnqueueDeduplicated(t *task.Task, window time.Duration, mode EnqueueMode) (*task.Task, bool, error) {
+	key, err := dedupeKey(t)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return q.enqueueOnce(t, key, window, func() error {
+		return q.EnqueueWithMode(t, mode)
+	})
+}
+
+// EnqueueDeduplicatedWithID is EnqueueDeduplicated for a client-supplied
+// task ID.
+func (q *Queue) EnqueueDeduplicatedWithID(t *task.Task, window time.Duration, mode EnqueueMode) (*task.Task, bool, error) {
+	key, err := dedupeKey(t)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return q.enqueueOnce(t, key, window, func() error {
+		return q.EnqueueWithID(t, mode)
+	})
+}
+
+// dedupeKey hashes the task type and payload. Payloads are maps, which
+// encoding/json marshals with sorted keys, so equal payloads hash equally.
+func dedupeKey(t *task.Task) (string, error) {
+	payload, err := json.Marshal(t.Payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload for deduplication: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(t.Type))
+	h.Write([]byte{0})
+	h.Write(payload)
+
+	return "dedupe:" + hex.EncodeToString(h.Sum(nil)), nil
+}
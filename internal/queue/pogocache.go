@@ -0,0 +1,215 @@
+package queue
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/redis/go-redis/v9"
+)
+
+// PogocacheBackend talks to Pogocache over its Redis-compatible protocol but
+// restricts itself to the plain key-value commands Pogocache implements. Each
+// set and sorted set is stored as a single JSON-encoded key mapping members to
+// scores, so reads are one GET. Pogocache has no WATCH or scripting, so
+// writers to a collection take a short SET NX lock around the
+// read-modify-write.
+type PogocacheBackend struct {
+	*RedisBackend
+}
+
+func NewPogocacheBackend(addr string) *PogocacheBackend {
+	return &PogocacheBackend{RedisBackend: NewRedisBackend(addr)}
+}
+
+const (
+	// collectionLockTTL bounds how long a crashed writer can block a
+	// collection.
+	collectionLockTTL = 5 * time.Second
+	// collectionLockRetry is how long a writer waits before retrying a held
+	// lock.
+	collectionLockRetry = time.Millisecond
+)
+
+// storeTask shadows the pipelined RedisBackend version, which relies on EVAL.
+func (b *PogocacheBackend) storeTask(ctx context.Context, taskID, data string, status task.TaskStatus) error {
+	return storeTaskSequentially(ctx, b, taskID, data, status)
+}
+
+// GetSet uses SET with the GET option, which swaps the value atomically.
+func (b *PogocacheBackend) GetSet(ctx context.Context, key, value string) (string, error) {
+	previous, err := b.client.SetArgs(ctx, key, value, redis.SetArgs{Get: true}).Result()
+	if err == redis.Nil {
+		return "", ErrNil
+	}
+
+	return previous, err
+}
+
+func (b *PogocacheBackend) SAdd(ctx context.Context, key, member string) error {
+	return b.update(ctx, key, func(members map[string]float64) bool {
+		if _, ok := members[member]; ok {
+			return false
+		}
+		members[member] = 0
+		return true
+	})
+}
+
+func (b *PogocacheBackend) SRem(ctx context.Context, key, member string) error {
+	_, err := b.remove(ctx, key, member)
+	return err
+}
+
+func (b *PogocacheBackend) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := b.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(members))
+	for member := range members {
+		list = append(list, member)
+	}
+
+	return list, nil
+}
+
+func (b *PogocacheBackend) SCard(ctx context.Context, key string) (int64, error) {
+	members, err := b.load(ctx, key)
+	return int64(len(members)), err
+}
+
+func (b *PogocacheBackend) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	members, err := b.load(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := members[member]
+	return ok, nil
+}
+
+func (b *PogocacheBackend) ZAdd(ctx context.Context, key, member string, score float64) error {
+	return b.update(ctx, key, func(members map[string]float64) bool {
+		if current, ok := members[member]; ok && current == score {
+			return false
+		}
+		members[member] = score
+		return true
+	})
+}
+
+func (b *PogocacheBackend) ZRem(ctx context.Context, key, member string) (bool, error) {
+	return b.remove(ctx, key, member)
+}
+
+func (b *PogocacheBackend) ZRangeByScore(ctx context.Context, key string, max float64) ([]string, error) {
+	members, err := b.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []string
+	for member, score := range members {
+		if score <= max {
+			inRange = append(inRange, member)
+		}
+	}
+	slices.SortFunc(inRange, func(a, b string) int {
+		return cmp.Or(cmp.Compare(members[a], members[b]), cmp.Compare(a, b))
+	})
+
+	return inRange, nil
+}
+
+func (b *PogocacheBackend) remove(ctx context.Context, key, member string) (bool, error) {
+	removed := false
+	err := b.update(ctx, key, func(members map[string]float64) bool {
+		if _, ok := members[member]; !ok {
+			return false
+		}
+		delete(members, member)
+		removed = true
+		return true
+	})
+
+	return removed, err
+}
+
+// load decodes the collection stored at key; a missing key is empty.
+func (b *PogocacheBackend) load(ctx context.Context, key string) (map[string]float64, error) {
+	members := make(map[string]float64)
+
+	data, err := b.Get(ctx, key)
+	if err == ErrNil {
+		return members, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(data), &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// update applies fn to the collection at key under the collection's lock and
+// writes it back if fn reports a change.
+func (b *PogocacheBackend) update(ctx context.Context, key string, fn func(map[string]float64) bool) error {
+	lockKey := key + ":lock"
+	token := uuid.NewString()
+
+	for {
+		acquired, err := b.SetNX(ctx, lockKey, token, collectionLockTTL)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(collectionLockRetry):
+		}
+	}
+	defer b.unlock(lockKey, token)
+
+	members, err := b.load(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !fn(members) {
+		return nil
+	}
+
+	if len(members) == 0 {
+		_, err := b.Del(ctx, key)
+		return err
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+
+	return b.Set(ctx, key, string(data), 0)
+}
+
+// unlock releases the lock unless it expired and another writer took it.
+func (b *PogocacheBackend) unlock(lockKey, token string) {
+	ctx := context.Background()
+	if holder, err := b.Get(ctx, lockKey); err == nil && holder == token {
+		_, _ = b.Del(ctx, lockKey)
+	}
+}
+
+var _ Backend = (*PogocacheBackend)(nil)
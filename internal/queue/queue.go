@@ -3,85 +3,533 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/nadmax/nexq/internal/encryption"
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
 	"github.com/nadmax/nexq/internal/metrics"
 	"github.com/nadmax/nexq/internal/repository"
 	"github.com/nadmax/nexq/internal/task"
-	"github.com/redis/go-redis/v9"
+)
+
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+type EnqueueMode string
+
+const (
+	// DurableEnqueue confirms an enqueue only once the task is persisted.
+	DurableEnqueue EnqueueMode = "durable"
+	// FastEnqueue confirms as soon as the task is stored and records its
+	// history in the background, accepting that it may be lost.
+	FastEnqueue EnqueueMode = "fast"
+	// bestEffortEnqueue records the history before queueing the task but
+	// does not fail the enqueue when that write fails. It backs Enqueue,
+	// which re-enqueues tasks that would otherwise be dropped.
+	bestEffortEnqueue EnqueueMode = "best_effort"
+)
+
+func ParseEnqueueMode(s string) (EnqueueMode, error) {
+	switch EnqueueMode(s) {
+	case "", DurableEnqueue:
+		return DurableEnqueue, nil
+	case FastEnqueue:
+		return FastEnqueue, nil
+	default:
+		return "", fmt.Errorf("unsupported confirmation mode: %s (available: durable, fast)", s)
+	}
+}
+
+// DefaultVisibilityTimeout is how long a dequeued task may stay in flight
+// before ReclaimExpired puts it back in the queue. It is longer than the
+// worker's handler timeout so a slow task is not run twice.
+const DefaultVisibilityTimeout = 10 * time.Minute
+
+var (
+	ErrLeaseLimitReached = errors.New("worker lease limit reached")
+	ErrTaskNotInFlight   = errors.New("task is not in flight")
+	ErrTaskLeased        = errors.New("task is leased by a worker")
+	ErrTaskNotFinished   = errors.New("task has not finished")
+	ErrQueueFull         = errors.New("queue is full")
+	ErrPayloadTooLarge   = errors.New("payload too large")
+	ErrReservedPayload   = errors.New("payload uses the reserved _encrypted field")
+	ErrTaskIDExists      = errors.New("task ID already exists")
+	ErrTaskNotFound      = repository.ErrTaskNotFound
 )
 
 type Queue struct {
-	client *redis.Client
-	repo   repository.TaskRepository
-	ctx    context.Context
+	backend    Backend
+	repo       repository.TaskRepository
+	cipher     *encryption.PayloadCipher
+	maxLeases  int
+	maxDepth   int
+	maxPayload int
+	aggregate  bool
+	ctx        context.Context
+	pending    sync.WaitGroup
+	closeOnce  sync.Once
+
+	visibilityTimeout time.Duration
+	events            events.EventSink
+	failures          *task.FailureClassifier
 }
 
 func NewQueue(redisAddr string, repo repository.TaskRepository) (*Queue, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
+	return NewQueueWithBackend(NewRedisBackend(redisAddr), repo)
+}
 
+func NewQueueWithBackend(backend Backend, repo repository.TaskRepository) (*Queue, error) {
 	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	if err := backend.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to queue backend: %w", err)
 	}
 
 	return &Queue{
-		client: client,
-		repo:   repo,
-		ctx:    ctx,
+		backend: backend,
+		repo:    repo,
+		ctx:     ctx,
+
+		visibilityTimeout: DefaultVisibilityTimeout,
+		events:            events.NopSink{},
+		failures:          task.DefaultFailureClassifier(),
 	}, nil
 }
 
+func (q *Queue) SetFailureClassifier(c *task.FailureClassifier) {
+	q.failures = c
+}
+
+// RecordFailure sets the task's error and its failure category.
+func (q *Queue) RecordFailure(t *task.Task, reason string) {
+	t.Error = reason
+	t.FailureCategory = q.failures.Classify(reason)
+}
+
+func (q *Queue) SetEventSink(sink events.EventSink) {
+	q.events = sink
+}
+
+// PublishEvent sends a task lifecycle event to the configured sink. The
+// queue publishes enqueued, retrying, completed and dead-lettered events
+// itself; workers publish started and failed.
+func (q *Queue) PublishEvent(eventType events.Type, t *task.Task, reason string) {
+	q.events.Publish(events.NewTaskEvent(eventType, t, reason))
+}
+
+// publishEnqueued reports a task entering the queue, as a retry when it has
+// already been attempted.
+func (q *Queue) publishEnqueued(t *task.Task) {
+	if t.RetryCount > 0 {
+		q.PublishEvent(events.Retrying, t, t.Error)
+		return
+	}
+
+	q.PublishEvent(events.Enqueued, t, "")
+}
+
+func (q *Queue) SetPayloadCipher(c *encryption.PayloadCipher) {
+	q.cipher = c
+}
+
+func (q *Queue) encode(t *task.Task) (string, error) {
+	if q.cipher == nil {
+		return t.ToJSON()
+	}
+
+	payload, err := q.cipher.Encrypt(t.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	stored := *t
+	stored.Payload = payload
+
+	return stored.ToJSON()
+}
+
+func (q *Queue) decode(data string) (*task.Task, error) {
+	t, err := task.TaskFromJSON(data)
+	if err != nil || q.cipher == nil {
+		return t, err
+	}
+
+	payload, err := q.cipher.Decrypt(t.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Payload = payload
+	return t, nil
+}
+
+// Enqueue queues t, logging rather than returning a failure to record its
+// history.
 func (q *Queue) Enqueue(t *task.Task) error {
-	if q.repo != nil {
-		t.Status = task.PendingStatus
+	return q.EnqueueWithMode(t, bestEffortEnqueue)
+}
+
+func (q *Queue) EnqueueWithMode(t *task.Task, mode EnqueueMode) error {
+	if err := q.checkPayload(t); err != nil {
+		return err
+	}
+	if err := q.checkCapacity(t); err != nil {
+		return err
+	}
+
+	t.Status = task.PendingStatus
+
+	switch {
+	case q.repo == nil:
+		if err := q.push(t); err != nil {
+			return err
+		}
+	case mode == FastEnqueue:
+		if err := q.enqueueFast(t); err != nil {
+			return err
+		}
+	default:
 		if err := q.repo.SaveTask(q.ctx, t); err != nil {
+			if mode == DurableEnqueue {
+				return fmt.Errorf("failed to persist task: %w", err)
+			}
 			log.Printf("Warning: failed to save task in database: %v", err)
 		}
+
+		if err := q.push(t); err != nil {
+			return err
+		}
 	}
 
-	data, err := t.ToJSON()
-	if err != nil {
+	metrics.RecordTaskEnqueued(t.Type, t.Priority)
+	q.publishEnqueued(t)
+
+	return nil
+}
+
+// enqueueFast stores t right away, so it can be read back, but only queues it
+// once its history row has been written in the background. A worker can then
+// never update a row that does not exist yet, nor have the insert overwrite
+// its updates.
+func (q *Queue) enqueueFast(t *task.Task) error {
+	if err := q.store(t); err != nil {
 		return err
 	}
 
-	seq, err := q.client.Incr(q.ctx, "queue:tail").Result()
-	if err != nil {
+	saved := *t
+	q.pending.Go(func() {
+		if err := q.repo.SaveTask(q.ctx, &saved); err != nil {
+			log.Printf("Warning: failed to save task in database: %v", err)
+		}
+		if err := q.place(&saved); err != nil {
+			log.Printf("Warning: failed to queue task %s: %v", saved.ID, err)
+		}
+	})
+
+	return nil
+}
+
+func (q *Queue) Defer(t *task.Task, delay time.Duration) error {
+	t.Status = task.PendingStatus
+	t.ScheduledAt = time.Now().Add(delay)
+
+	if q.repo != nil {
+		if err := q.repo.UpdateTaskStatus(q.ctx, t.ID, task.PendingStatus, ""); err != nil {
+			log.Printf("Warning: failed to update task status: %v", err)
+		}
+	}
+
+	return q.push(t)
+}
+
+// push stores t and queues it.
+func (q *Queue) push(t *task.Task) error {
+	if err := q.store(t); err != nil {
 		return err
 	}
 
-	if err := q.client.Set(
-		q.ctx,
-		fmt.Sprintf("queue:item:%d", seq),
-		t.ID,
-		0,
-	).Err(); err != nil {
+	return q.place(t)
+}
+
+// store writes t, indexes it and counts it under its status, without queueing
+// it.
+func (q *Queue) store(t *task.Task) error {
+	data, err := q.encode(t)
+	if err != nil {
 		return err
 	}
 
-	if err := q.client.Set(
+	if err := q.backend.Set(
 		q.ctx,
 		"task:"+t.ID,
 		data,
 		0,
-	).Err(); err != nil {
+	); err != nil {
+		return err
+	}
+	if err := q.backend.SAdd(q.ctx, "tasks:index", t.ID); err != nil {
 		return err
 	}
+	return q.trackStatus(t.ID, t.Status)
+}
 
-	metrics.RecordTaskEnqueued(t.Type, t.Priority)
+// place adds a stored task to the queue. A task scheduled in the future is
+// parked in the scheduled set instead, and only joins the queue once it is
+// due.
+func (q *Queue) place(t *task.Task) error {
+	if t.ScheduledAt.After(time.Now()) {
+		return q.schedule(t)
+	}
+
+	return q.appendItem(t.ID)
+}
+
+// appendItem adds taskID at the tail of the queue and records the slot
+// under queue:pos:<id>, so the task can be found without walking the queue.
+func (q *Queue) appendItem(taskID string) error {
+	seq, err := q.backend.Incr(q.ctx, "queue:tail")
+	if err != nil {
+		return err
+	}
+
+	itemKey := fmt.Sprintf("queue:item:%d", seq)
+	if err := q.backend.Set(q.ctx, itemKey, taskID, 0); err != nil {
+		return err
+	}
+
+	return q.backend.Set(q.ctx, "queue:pos:"+taskID, itemKey, 0)
+}
+
+// dropItem removes a queue or DLQ slot along with its position key, unless
+// the position already points at a newer slot for the same task.
+func (q *Queue) dropItem(posPrefix, itemKey, taskID string) (bool, error) {
+	deleted, err := q.backend.Del(q.ctx, itemKey)
+	if err != nil {
+		return false, err
+	}
+
+	pos, err := q.backend.Get(q.ctx, posPrefix+taskID)
+	if err == ErrNil {
+		return deleted > 0, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if pos == itemKey {
+		if _, err := q.backend.Del(q.ctx, posPrefix+taskID); err != nil {
+			return false, err
+		}
+	}
+
+	return deleted > 0, nil
+}
+
+// expire drops a task whose TTL passed before a worker picked it up. The
+// history keeps it as failed.
+func (q *Queue) expire(t *task.Task) {
+	metrics.RecordTaskExpired(t.Type)
+	if q.repo != nil {
+		reason := "expired before it could run"
+		if err := q.repo.FailTask(q.ctx, t.ID, reason, q.failures.Classify(reason), 0); err != nil {
+			log.Printf("Warning: failed to record task expiry: %v", err)
+		}
+	}
+	if err := q.DeleteTask(t.ID); err != nil {
+		log.Printf("Warning: failed to delete expired task %s: %v", t.ID, err)
+	}
+}
+
+func (q *Queue) schedule(t *task.Task) error {
+	return q.backend.ZAdd(q.ctx, "scheduled", t.ID, float64(t.ScheduledAt.UnixMilli()))
+}
+
+// promoteScheduled moves scheduled tasks that are due to the tail of the
+// queue. Removing the set member first makes sure only one caller promotes a
+// given task.
+func (q *Queue) promoteScheduled() error {
+	due, err := q.backend.ZRangeByScore(q.ctx, "scheduled", float64(time.Now().UnixMilli()))
+	if err != nil {
+		return err
+	}
+
+	for _, taskID := range due {
+		removed, err := q.backend.ZRem(q.ctx, "scheduled", taskID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		if err := q.appendItem(taskID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *Queue) EnqueueIdempotent(t *task.Task, key string, ttl time.Duration, mode EnqueueMode) (*task.Task, bool, error) {
+	return q.enqueueIdempotent(t, key, ttl, func() error {
+		return q.EnqueueWithMode(t, mode)
+	})
+}
+
+// EnqueueIdempotentWithID is EnqueueIdempotent for a client-supplied task ID.
+// A replay with the same key returns the original task rather than
+// ErrTaskIDExists.
+func (q *Queue) EnqueueIdempotentWithID(t *task.Task, key string, ttl time.Duration, mode EnqueueMode) (*task.Task, bool, error) {
+	return q.enqueueIdempotent(t, key, ttl, func() error {
+		return q.EnqueueWithID(t, mode)
+	})
+}
+
+func (q *Queue) enqueueIdempotent(t *task.Task, key string, ttl time.Duration, enqueue func() error) (*task.Task, bool, error) {
+	return q.enqueueOnce(t, "idempotency:"+key, ttl, enqueue)
+}
+
+// enqueueOnce enqueues t unless lockKey already maps to a task, in which case
+// that task is returned instead. The mapping lives for ttl. A mapping to a
+// task that no longer exists anywhere, e.g. because it was deleted, is
+// dropped and t is enqueued in its place.
+func (q *Queue) enqueueOnce(t *task.Task, lockKey string, ttl time.Duration, enqueue func() error) (*task.Task, bool, error) {
+	for {
+		acquired, err := q.backend.SetNX(q.ctx, lockKey, t.ID, ttl)
+		if err != nil {
+			return nil, false, err
+		}
+		if acquired {
+			break
+		}
+
+		existingID, err := q.backend.Get(q.ctx, lockKey)
+		if err == ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		existing, err := q.findTask(existingID)
+		if errors.Is(err, ErrTaskNotFound) {
+			if _, err := q.backend.Del(q.ctx, lockKey); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to load task for %s: %w", lockKey, err)
+		}
+
+		return existing, false, nil
+	}
+
+	if err := enqueue(); err != nil {
+		q.backend.Del(q.ctx, lockKey)
+		return nil, false, err
+	}
+
+	return t, true, nil
+}
+
+// findTask looks a task up wherever it may be: the queue or the DLQ, the
+// in-flight copy a worker holds between dequeuing and starting it, and
+// finally the history.
+func (q *Queue) findTask(taskID string) (*task.Task, error) {
+	t, err := q.GetTask(taskID)
+	if !errors.Is(err, ErrTaskNotFound) {
+		return t, err
+	}
+
+	data, err := q.backend.Get(q.ctx, "inflight:"+taskID)
+	if err == nil {
+		return q.decode(data)
+	}
+	if err != ErrNil {
+		return nil, err
+	}
+
+	if q.repo == nil {
+		return nil, ErrTaskNotFound
+	}
+	return q.repo.GetTask(q.ctx, taskID)
+}
+
+// EnqueueWithID enqueues a task whose ID was supplied by the client rather
+// than generated, failing with ErrTaskIDExists if the ID is already taken.
+func (q *Queue) EnqueueWithID(t *task.Task, mode EnqueueMode) error {
+	if err := q.reserveTaskID(t.ID); err != nil {
+		return err
+	}
+
+	if err := q.EnqueueWithMode(t, mode); err != nil {
+		q.backend.Del(q.ctx, "taskid:"+t.ID)
+		return err
+	}
+
+	return nil
+}
+
+// reserveTaskID claims a task ID for the idempotency TTL, by which time the
+// task's own status key marks it as taken. IDs of tasks that only survive in
+// the history are taken too; a history lookup that fails rejects the ID
+// rather than risk overwriting another task's row.
+func (q *Queue) reserveTaskID(taskID string) error {
+	if _, err := q.GetTask(taskID); err == nil {
+		return ErrTaskIDExists
+	} else if !errors.Is(err, ErrTaskNotFound) {
+		return err
+	}
+	tracked, err := q.backend.Exists(q.ctx, "status:"+taskID)
+	if err != nil {
+		return err
+	}
+	if tracked > 0 {
+		return ErrTaskIDExists
+	}
+	if q.repo != nil {
+		_, err := q.repo.GetTask(q.ctx, taskID)
+		if err == nil {
+			return ErrTaskIDExists
+		}
+		if !errors.Is(err, repository.ErrTaskNotFound) {
+			return fmt.Errorf("failed to check task ID in history: %w", err)
+		}
+	}
+
+	acquired, err := q.backend.SetNX(q.ctx, "taskid:"+taskID, "1", DefaultIdempotencyTTL)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return ErrTaskIDExists
+	}
 
 	return nil
 }
 
 func (q *Queue) Dequeue() (*task.Task, error) {
+	return q.dequeue("")
+}
+
+// dequeue pops the next due task. With an owner, the task's lease is
+// recorded before it becomes visible in the in-flight set, so Ack and Nack
+// can never see a worker's task without its lease.
+func (q *Queue) dequeue(owner string) (*task.Task, error) {
+	if err := q.promoteScheduled(); err != nil {
+		return nil, err
+	}
+
 	for {
-		headStr, _ := q.client.Get(q.ctx, "queue:head").Result()
-		tailStr, _ := q.client.Get(q.ctx, "queue:tail").Result()
+		headStr, _ := q.backend.Get(q.ctx, "queue:head")
+		tailStr, _ := q.backend.Get(q.ctx, "queue:tail")
 		head := int64(0)
 		tail := int64(0)
 		if headStr != "" {
@@ -91,45 +539,59 @@ func (q *Queue) Dequeue() (*task.Task, error) {
 			tail, _ = strconv.ParseInt(tailStr, 10, 64)
 		}
 
-		log.Printf("Dequeue: head=%d, tail=%d", head, tail)
-
 		if head >= tail {
 			return nil, nil
 		}
 
-		newHead, err := q.client.Incr(q.ctx, "queue:head").Result()
+		newHead, err := q.backend.Incr(q.ctx, "queue:head")
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("Dequeue: newHead=%d", newHead)
-
 		itemKey := fmt.Sprintf("queue:item:%d", newHead)
-		taskID, err := q.client.Get(q.ctx, itemKey).Result()
+		taskID, err := q.backend.Get(q.ctx, itemKey)
+		if err == ErrNil {
+			continue
+		}
 		if err != nil {
-			log.Printf("Dequeue: queue:item:%d not found, error: %v", newHead, err)
+			logging.Logger().Debug("dequeue: failed to read queue item", "position", newHead, "error", err)
 			return nil, nil
 		}
 
-		log.Printf("Dequeue: found taskID=%s at position %d", taskID, newHead)
-
-		data, err := q.client.Get(q.ctx, "task:"+taskID).Result()
+		data, err := q.backend.Get(q.ctx, "task:"+taskID)
 		if err != nil {
-			log.Printf("Dequeue: task:%s not found, error: %v", taskID, err)
+			logging.Logger().Debug("dequeue: failed to read task", "task_id", taskID, "error", err)
 			return nil, nil
 		}
 
-		t, err := task.TaskFromJSON(data)
+		t, err := q.decode(data)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("Dequeue: task %s has status %s", t.ID, t.Status)
-
 		if t.Status == task.CancelledStatus {
-			log.Printf("Dequeue: skipping cancelled task %s", t.ID)
-			q.client.Del(q.ctx, itemKey)
-			q.client.Del(q.ctx, "task:"+taskID)
+			logging.Logger().Debug("dequeue: skipping cancelled task", "task_id", t.ID, "type", t.Type)
+			q.dropItem("queue:pos:", itemKey, taskID)
+			if err := q.DeleteTask(taskID); err != nil {
+				log.Printf("Warning: failed to delete cancelled task %s: %v", taskID, err)
+			}
+			continue
+		}
+
+		if t.Expired(time.Now()) {
+			logging.Logger().Debug("dequeue: dropping expired task", "task_id", t.ID, "type", t.Type)
+			q.dropItem("queue:pos:", itemKey, taskID)
+			q.expire(t)
+			continue
+		}
+
+		// Queued before it was due, e.g. by a Defer on a task that was still
+		// waiting in the queue: park it until its time comes.
+		if t.ScheduledAt.After(time.Now()) {
+			q.dropItem("queue:pos:", itemKey, taskID)
+			if err := q.schedule(t); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -142,56 +604,741 @@ func (q *Queue) Dequeue() (*task.Task, error) {
 			}
 		}
 
-		q.client.Del(q.ctx, itemKey)
-		q.client.Del(q.ctx, "task:"+taskID)
+		if owner != "" {
+			if err := q.backend.Set(q.ctx, "lease:"+t.ID, owner, 0); err != nil {
+				return nil, err
+			}
+		}
+		if err := q.markInFlight(t); err != nil {
+			return nil, err
+		}
 
-		log.Printf("Dequeue: returning task %s", t.ID)
+		q.dropItem("queue:pos:", itemKey, taskID)
+		q.backend.Del(q.ctx, "task:"+taskID)
+		q.backend.SRem(q.ctx, "tasks:index", taskID)
+		if err := q.trackStatus(t.ID, task.RunningStatus); err != nil {
+			log.Printf("Warning: failed to update status counters: %v", err)
+		}
 		return t, nil
 	}
 }
 
-func (q *Queue) CompleteTask(t *task.Task, durationMs int) error {
-	duration := time.Duration(durationMs) * time.Millisecond
-	metrics.RecordTaskCompleted(t.Type, duration)
-
-	if q.repo != nil {
-		return q.repo.CompleteTask(q.ctx, t.ID, durationMs)
-	}
-
-	return nil
+func (q *Queue) SetVisibilityTimeout(d time.Duration) {
+	q.visibilityTimeout = d
 }
 
-func (q *Queue) CancelTask(taskID string) error {
-	data, err := q.client.Get(q.ctx, "task:"+taskID).Result()
-	if err != nil {
-		return fmt.Errorf("task not found: %w", err)
-	}
+// markInFlight keeps a copy of a dequeued task in the in-flight set until
+// Settle is called, so ReclaimExpired can recover it if the worker dies.
+func (q *Queue) markInFlight(t *task.Task) error {
+	inFlight := *t
+	now := time.Now()
+	inFlight.StartedAt = &now
 
-	t, err := task.TaskFromJSON(data)
+	data, err := q.encode(&inFlight)
 	if err != nil {
 		return err
 	}
 
-	if t.Status != task.PendingStatus && t.Status != task.RunningStatus {
-		return fmt.Errorf("cannot cancel task with status: %s", t.Status)
+	if err := q.backend.Set(q.ctx, "inflight:"+t.ID, data, 0); err != nil {
+		return err
 	}
 
-	t.Status = task.CancelledStatus
-	now := time.Now()
-	t.CompletedAt = &now
-
-	if q.repo != nil {
-		if err := q.repo.UpdateTaskStatus(q.ctx, t.ID, task.CancelledStatus, "cancelled by user"); err != nil {
-			log.Printf("Warning: failed to update task status in database: %v", err)
-		}
-	}
+	deadline := time.Now().Add(q.visibilityTimeout)
+	return q.backend.ZAdd(q.ctx, "inflight", t.ID, float64(deadline.UnixMilli()))
+}
 
-	updatedData, err := t.ToJSON()
-	if err != nil {
+// Settle removes a task from the in-flight set once its worker is done with
+// it, whether it completed, was rescheduled or was dead-lettered.
+func (q *Queue) Settle(taskID string) error {
+	if _, err := q.backend.ZRem(q.ctx, "inflight", taskID); err != nil {
 		return err
 	}
 
-	if err := q.client.Set(q.ctx, "task:"+taskID, updatedData, 0).Err(); err != nil {
+	_, err := q.backend.Del(q.ctx, "inflight:"+taskID)
+	return err
+}
+
+// ReclaimExpired re-enqueues in-flight tasks whose visibility timeout passed
+// without being settled, typically because their worker crashed. It returns
+// the number of tasks put back in the queue.
+func (q *Queue) ReclaimExpired() (int, error) {
+	expired, err := q.backend.ZRangeByScore(q.ctx, "inflight", float64(time.Now().UnixMilli()))
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := 0
+	for _, taskID := range expired {
+		t, err := q.takeInFlight(taskID, true)
+		if errors.Is(err, ErrTaskNotInFlight) {
+			// Settled or reclaimed by someone else in the meantime.
+			continue
+		}
+		if err != nil {
+			return reclaimed, err
+		}
+
+		t.Status = task.PendingStatus
+		t.StartedAt = nil
+		if err := q.push(t); err != nil {
+			return reclaimed, err
+		}
+		if q.repo != nil {
+			if err := q.repo.UpdateTaskStatus(q.ctx, t.ID, task.PendingStatus, ""); err != nil {
+				log.Printf("Warning: failed to update task status: %v", err)
+			}
+		}
+
+		reclaimed++
+	}
+
+	return reclaimed, nil
+}
+
+// takeInFlight removes a task from the in-flight set and releases its lease,
+// returning the task as it was dequeued. Removing the set member first makes
+// sure only one caller ever takes a given task. Unless leased is set, a task
+// held by a worker is put back and ErrTaskLeased returned, so a manual ack
+// cannot settle a task out from under its worker.
+func (q *Queue) takeInFlight(taskID string, leased bool) (*task.Task, error) {
+	removed, err := q.backend.ZRem(q.ctx, "inflight", taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrTaskNotInFlight
+	}
+
+	if !leased {
+		owned, err := q.backend.Exists(q.ctx, "lease:"+taskID)
+		if err != nil {
+			return nil, err
+		}
+		if owned > 0 {
+			deadline := time.Now().Add(q.visibilityTimeout)
+			if err := q.backend.ZAdd(q.ctx, "inflight", taskID, float64(deadline.UnixMilli())); err != nil {
+				return nil, err
+			}
+			return nil, ErrTaskLeased
+		}
+	}
+
+	data, err := q.backend.Get(q.ctx, "inflight:"+taskID)
+	if err == ErrNil {
+		return nil, ErrTaskNotInFlight
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := q.backend.Del(q.ctx, "inflight:"+taskID); err != nil {
+		return nil, err
+	}
+	if err := q.ReleaseLease(taskID); err != nil {
+		return nil, err
+	}
+
+	return q.decode(data)
+}
+
+// Ack marks an in-flight task as completed. It is the manual counterpart of
+// a worker finishing a task, for callers processing tasks outside a worker.
+func (q *Queue) Ack(taskID string) error {
+	t, err := q.takeInFlight(taskID, false)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	durationMs := 0
+	if t.StartedAt != nil {
+		durationMs = int(now.Sub(*t.StartedAt).Milliseconds())
+	}
+
+	t.Status = task.CompletedStatus
+	t.CompletedAt = &now
+	if err := q.UpdateTask(t); err != nil {
+		return err
+	}
+
+	return q.CompleteTask(t, durationMs)
+}
+
+// Nack gives up on an in-flight task. With requeue set, the task is put back
+// in the queue and its retry count incremented, unless it has no retries
+// left; otherwise it is moved to the dead letter queue.
+func (q *Queue) Nack(taskID string, requeue bool) error {
+	t, err := q.takeInFlight(taskID, false)
+	if err != nil {
+		return err
+	}
+
+	durationMs := 0
+	if t.StartedAt != nil {
+		durationMs = int(time.Since(*t.StartedAt).Milliseconds())
+	}
+
+	const reason = "negatively acknowledged"
+	t.RetryCount++
+	q.RecordFailure(t, reason)
+	q.PublishEvent(events.Failed, t, reason)
+
+	if requeue && t.RetryCount < t.MaxRetries {
+		t.Status = task.PendingStatus
+		t.StartedAt = nil
+		if err := q.Enqueue(t); err != nil {
+			return err
+		}
+		if err := q.IncrementRetryCount(t.ID); err != nil {
+			log.Printf("Warning: failed to increment retry count: %v", err)
+		}
+
+		return q.FailTask(t, reason, durationMs)
+	}
+
+	if err := q.FailTask(t, reason, durationMs); err != nil {
+		log.Printf("Warning: failed to record task failure: %v", err)
+	}
+
+	return q.MoveToDeadLetter(t, reason)
+}
+
+// SetMaxLeasesPerWorker makes Claim reject callers already holding n
+// unreleased tasks with ErrLeaseLimitReached. Zero disables the limit. A
+// worker process runs one task at a time, so the limit only binds when
+// several processes share a worker ID or when Claim is called outside a
+// worker.
+func (q *Queue) SetMaxLeasesPerWorker(n int) {
+	q.maxLeases = n
+}
+
+// SetMaxQueueDepth makes Enqueue reject new tasks with ErrQueueFull once n
+// tasks are pending. Zero disables the limit.
+func (q *Queue) SetMaxQueueDepth(n int) {
+	q.maxDepth = n
+}
+
+// SetMaxPayloadSize makes Enqueue reject tasks whose JSON-encoded payload is
+// larger than n bytes with ErrPayloadTooLarge. Zero disables the limit.
+func (q *Queue) SetMaxPayloadSize(n int) {
+	q.maxPayload = n
+}
+
+// SetAggregateMetrics makes RefreshMetrics feed the task gauge from the
+// per-status counters with the type label set to AllTypesLabel, instead of
+// scanning every task to break it down by type.
+func (q *Queue) SetAggregateMetrics(enabled bool) {
+	q.aggregate = enabled
+}
+
+// checkPayload rejects payloads using the field encrypted payloads are
+// stored under, then measures the plaintext payload, so the size limit does
+// not depend on whether payload encryption is enabled.
+func (q *Queue) checkPayload(t *task.Task) error {
+	if encryption.HasReservedField(t.Payload) {
+		return ErrReservedPayload
+	}
+	if q.maxPayload <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(t.Payload)
+	if err != nil {
+		return err
+	}
+	if len(data) > q.maxPayload {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrPayloadTooLarge, len(data), q.maxPayload)
+	}
+
+	return nil
+}
+
+// checkCapacity reads the pending status counter rather than walking the
+// queue, so the check stays cheap on every enqueue. Retries and deferrals
+// bypass it: the task was already admitted and rejecting it would drop it.
+func (q *Queue) checkCapacity(t *task.Task) error {
+	if q.maxDepth <= 0 || t.RetryCount > 0 {
+		return nil
+	}
+
+	pending, err := q.counter("stats:" + string(task.PendingStatus))
+	if err != nil {
+		return err
+	}
+	if pending >= int64(q.maxDepth) {
+		return ErrQueueFull
+	}
+
+	return nil
+}
+
+// Claim dequeues a task on behalf of workerID and records the lease. The
+// lease counter is reserved with INCR before dequeuing and handed back when
+// the limit is exceeded or the queue is empty, so concurrent claims never
+// push a worker past its limit.
+func (q *Queue) Claim(workerID string) (*task.Task, error) {
+	key := "leases:" + workerID
+	leases, err := q.backend.Incr(q.ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// A fresh counter gets an expiry so it cannot outlive a worker that never
+	// heartbeats; heartbeats shorten it to the registry TTL afterwards.
+	if leases == 1 {
+		if err := q.backend.Expire(q.ctx, key, DefaultVisibilityTimeout); err != nil {
+			return nil, err
+		}
+	}
+	if q.maxLeases > 0 && leases > int64(q.maxLeases) {
+		if err := q.returnLease(workerID); err != nil {
+			return nil, err
+		}
+		return nil, ErrLeaseLimitReached
+	}
+
+	t, err := q.dequeue(workerID)
+	if err != nil || t == nil {
+		if releaseErr := q.returnLease(workerID); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (q *Queue) ReleaseLease(taskID string) error {
+	workerID, err := q.backend.Get(q.ctx, "lease:"+taskID)
+	if err == ErrNil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.backend.Del(q.ctx, "lease:"+taskID); err != nil {
+		return err
+	}
+
+	return q.returnLease(workerID)
+}
+
+// returnLease decrements a worker's lease counter, dropping the key once it
+// reaches zero. The counter may already have expired, in which case DECR
+// recreates it below zero and it is dropped as well.
+func (q *Queue) returnLease(workerID string) error {
+	leases, err := q.backend.Decr(q.ctx, "leases:"+workerID)
+	if err != nil {
+		return err
+	}
+	if leases <= 0 {
+		_, err := q.backend.Del(q.ctx, "leases:"+workerID)
+		return err
+	}
+
+	return nil
+}
+
+func (q *Queue) LeaseCount(workerID string) (int, error) {
+	leases, err := q.counter("leases:" + workerID)
+	return int(leases), err
+}
+
+func (q *Queue) CompleteTask(t *task.Task, durationMs int) error {
+	duration := time.Duration(durationMs) * time.Millisecond
+	metrics.RecordTaskCompleted(t.Type, duration)
+	q.PublishEvent(events.Completed, t, "")
+
+	if q.repo != nil {
+		return q.repo.CompleteTask(q.ctx, t.ID, durationMs)
+	}
+
+	return nil
+}
+
+// Transfer moves a pending task to dest. The task's queue position is taken
+// first, so a concurrent Dequeue cannot also pick it up, and the source copy
+// is removed before dest stores its own: when both queues share a backend,
+// that copy is the same key. If dest fails, the task goes back to the source.
+func (q *Queue) Transfer(taskID string, dest *Queue) error {
+	if dest == q {
+		return errors.New("cannot transfer a task to its own queue")
+	}
+
+	data, err := q.backend.Get(q.ctx, "task:"+taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	t, err := q.decode(data)
+	if err != nil {
+		return err
+	}
+
+	if t.Status != task.PendingStatus {
+		return fmt.Errorf("cannot transfer task with status: %s", t.Status)
+	}
+
+	taken, err := q.takeWaiting(taskID)
+	if err != nil {
+		return err
+	}
+	if !taken {
+		return fmt.Errorf("task %s is not waiting in the queue", taskID)
+	}
+
+	if _, err := q.backend.Del(q.ctx, "task:"+taskID); err != nil {
+		return err
+	}
+	if err := q.backend.SRem(q.ctx, "tasks:index", taskID); err != nil {
+		return err
+	}
+	if err := q.untrackStatus(taskID); err != nil {
+		return err
+	}
+
+	if err := dest.push(t); err != nil {
+		if restoreErr := q.push(t); restoreErr != nil {
+			log.Printf("Warning: failed to restore task %s after a failed transfer: %v", taskID, restoreErr)
+		}
+		return fmt.Errorf("failed to enqueue task in destination: %w", err)
+	}
+
+	return nil
+}
+
+// takeWaiting removes a task from its place in the queue or the scheduled
+// set, reporting false if it was in neither, e.g. because a worker dequeued
+// it in the meantime.
+func (q *Queue) takeWaiting(taskID string) (bool, error) {
+	itemKey, err := q.findItemKey(taskID)
+	if err != nil {
+		return false, err
+	}
+	if itemKey != "" {
+		removed, err := q.dropItem("queue:pos:", itemKey, taskID)
+		if err != nil || removed {
+			return removed, err
+		}
+	}
+
+	return q.backend.ZRem(q.ctx, "scheduled", taskID)
+}
+
+func (q *Queue) DeleteTask(taskID string) error {
+	keys := []string{
+		"task:" + taskID,
+		"dlq:task:" + taskID,
+		"inflight:" + taskID,
+		"queue:pos:" + taskID,
+		"dlq:pos:" + taskID,
+	}
+
+	itemKey, err := q.findItemKey(taskID)
+	if err != nil {
+		return err
+	}
+	if itemKey != "" {
+		keys = append(keys, itemKey)
+	}
+
+	dlqItemKey, err := q.findDeadLetterItemKey(taskID)
+	if err != nil {
+		return err
+	}
+	if dlqItemKey != "" {
+		keys = append(keys, dlqItemKey)
+	}
+
+	if err := q.ReleaseLease(taskID); err != nil {
+		return err
+	}
+
+	deleted, err := q.backend.Del(q.ctx, keys...)
+	if err != nil {
+		return err
+	}
+
+	// Index and status entries can outlive the record, e.g. after a crash
+	// between two writes, so they are cleaned up even if nothing was deleted.
+	if err := q.backend.SRem(q.ctx, "tasks:index", taskID); err != nil {
+		return err
+	}
+	if err := q.backend.SRem(q.ctx, "dlq:index", taskID); err != nil {
+		return err
+	}
+	inFlight, err := q.backend.ZRem(q.ctx, "inflight", taskID)
+	if err != nil {
+		return err
+	}
+	scheduled, err := q.backend.ZRem(q.ctx, "scheduled", taskID)
+	if err != nil {
+		return err
+	}
+	if err := q.untrackStatus(taskID); err != nil {
+		return err
+	}
+
+	if deleted == 0 && !inFlight && !scheduled {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
+func (q *Queue) findItemKey(taskID string) (string, error) {
+	return q.lookupItem("queue:pos:", taskID)
+}
+
+func (q *Queue) findDeadLetterItemKey(taskID string) (string, error) {
+	return q.lookupItem("dlq:pos:", taskID)
+}
+
+// lookupItem follows a task's position key to its slot, returning "" if the
+// slot has since been consumed or reused.
+func (q *Queue) lookupItem(posPrefix, taskID string) (string, error) {
+	itemKey, err := q.backend.Get(q.ctx, posPrefix+taskID)
+	if err == ErrNil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	id, err := q.backend.Get(q.ctx, itemKey)
+	if err == ErrNil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if id != taskID {
+		return "", nil
+	}
+
+	return itemKey, nil
+}
+
+// Peek returns up to n tasks in the order Dequeue would return them, skipping
+// removed, cancelled and not yet due entries the same way. Due scheduled
+// tasks are moved into the queue first, as Dequeue would; nothing else
+// changes.
+func (q *Queue) Peek(n int) ([]*task.Task, error) {
+	if err := q.promoteScheduled(); err != nil {
+		return nil, err
+	}
+
+	head, err := q.counter("queue:head")
+	if err != nil {
+		return nil, err
+	}
+	tail, err := q.counter("queue:tail")
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*task.Task, 0, n)
+	for seq := head + 1; seq <= tail && len(tasks) < n; seq++ {
+		taskID, err := q.backend.Get(q.ctx, fmt.Sprintf("queue:item:%d", seq))
+		if err == ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := q.backend.Get(q.ctx, "task:"+taskID)
+		if err == ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := q.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		if t.Status == task.CancelledStatus || t.ScheduledAt.After(time.Now()) || t.Expired(time.Now()) {
+			continue
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// Depth returns the number of tasks waiting in the pending queue. Items removed
+// by DeleteTask leave gaps between head and tail, so only live items count.
+func (q *Queue) Depth() (int, error) {
+	head, err := q.counter("queue:head")
+	if err != nil {
+		return 0, err
+	}
+	tail, err := q.counter("queue:tail")
+	if err != nil {
+		return 0, err
+	}
+
+	depth := 0
+	for start := head + 1; start <= tail; start += 100 {
+		end := min(start+99, tail)
+		keys := make([]string, 0, end-start+1)
+		for seq := start; seq <= end; seq++ {
+			keys = append(keys, fmt.Sprintf("queue:item:%d", seq))
+		}
+
+		n, err := q.backend.Exists(q.ctx, keys...)
+		if err != nil {
+			return 0, err
+		}
+		depth += int(n)
+	}
+
+	return depth, nil
+}
+
+var countedStatuses = []task.TaskStatus{
+	task.PendingStatus,
+	task.RunningStatus,
+	task.CompletedStatus,
+	task.FailedStatus,
+	task.CancelledStatus,
+	task.DeadLetterStatus,
+}
+
+// trackStatus moves a task's contribution to the stats:<status> counters from
+// the status it was last counted under, kept in status:<id>, to status.
+func (q *Queue) trackStatus(taskID string, status task.TaskStatus) error {
+	return trackStatus(q.ctx, q.backend, taskID, status)
+}
+
+func trackStatus(ctx context.Context, b Backend, taskID string, status task.TaskStatus) error {
+	previous, err := b.GetSet(ctx, "status:"+taskID, string(status))
+	if err != nil && err != ErrNil {
+		return err
+	}
+	if previous == string(status) {
+		return nil
+	}
+
+	if _, err := b.Incr(ctx, "stats:"+string(status)); err != nil {
+		return err
+	}
+	if previous != "" {
+		_, err := b.Decr(ctx, "stats:"+previous)
+		return err
+	}
+
+	return nil
+}
+
+func (q *Queue) untrackStatus(taskID string) error {
+	previous, err := q.backend.Get(q.ctx, "status:"+taskID)
+	if err == ErrNil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	deleted, err := q.backend.Del(q.ctx, "status:"+taskID)
+	if err != nil || deleted == 0 {
+		return err
+	}
+
+	_, err = q.backend.Decr(q.ctx, "stats:"+previous)
+	return err
+}
+
+// StatusCounts returns the number of tasks currently in each status, read from
+// counters maintained on every transition instead of scanning all tasks.
+// Statuses without tasks are omitted.
+func (q *Queue) StatusCounts() (map[task.TaskStatus]int, error) {
+	counts := make(map[task.TaskStatus]int)
+	for _, status := range countedStatuses {
+		n, err := q.counter("stats:" + string(status))
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			counts[status] = int(n)
+		}
+	}
+
+	return counts, nil
+}
+
+func (q *Queue) counter(key string) (int64, error) {
+	value, err := q.backend.Get(q.ctx, key)
+	if err == ErrNil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (q *Queue) CancelTask(taskID string) error {
+	data, err := q.backend.Get(q.ctx, "task:"+taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	t, err := q.decode(data)
+	if err != nil {
+		return err
+	}
+
+	if t.Status != task.PendingStatus && t.Status != task.RunningStatus {
+		return fmt.Errorf("cannot cancel task with status: %s", t.Status)
+	}
+
+	// A pending task has no worker to notice the cancellation, so once it is
+	// taken off the queue DeleteTask clears every reference to it and only
+	// the cancelled record is written back. If a worker dequeued it in the
+	// meantime, it is cancelled like a running task.
+	waiting := false
+	if t.Status == task.PendingStatus {
+		waiting, err = q.takeWaiting(taskID)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.Status = task.CancelledStatus
+	now := time.Now()
+	t.CompletedAt = &now
+
+	if q.repo != nil {
+		if err := q.repo.UpdateTaskStatus(q.ctx, t.ID, task.CancelledStatus, "cancelled by user"); err != nil {
+			log.Printf("Warning: failed to update task status in database: %v", err)
+		}
+	}
+
+	if waiting {
+		if err := q.DeleteTask(taskID); err != nil {
+			return err
+		}
+		if err := q.store(t); err != nil {
+			return err
+		}
+		metrics.RecordTaskCancelled(t.Type)
+		return nil
+	}
+
+	updatedData, err := q.encode(t)
+	if err != nil {
+		return err
+	}
+
+	if err := q.backend.Set(q.ctx, "task:"+taskID, updatedData, 0); err != nil {
+		return err
+	}
+	if err := q.trackStatus(taskID, task.CancelledStatus); err != nil {
 		return err
 	}
 
@@ -201,186 +1348,345 @@ func (q *Queue) CancelTask(taskID string) error {
 }
 
 func (q *Queue) IsCancelled(taskID string) (bool, error) {
-	data, err := q.client.Get(q.ctx, "task:"+taskID).Result()
+	data, err := q.backend.Get(q.ctx, "task:"+taskID)
 	if err != nil {
 		return false, err
 	}
 
-	t, err := task.TaskFromJSON(data)
+	t, err := q.decode(data)
 	if err != nil {
 		return false, err
 	}
 
-	return t.Status == task.CancelledStatus, nil
+	return t.Status == task.CancelledStatus, nil
+}
+
+func (q *Queue) FailTask(t *task.Task, reason string, durationMs int) error {
+	duration := time.Duration(durationMs) * time.Millisecond
+	metrics.RecordTaskFailed(t.Type, duration)
+
+	if q.repo != nil {
+		return q.repo.FailTask(q.ctx, t.ID, reason, q.failures.Classify(reason), durationMs)
+	}
+
+	return nil
+}
+
+func (q *Queue) UpdateTask(task *task.Task) error {
+	data, err := q.encode(task)
+	if err != nil {
+		return err
+	}
+
+	if q.repo != nil {
+		if err := q.repo.SaveTask(q.ctx, task); err != nil {
+			log.Printf("Warning: failed to update task in database: %v", err)
+		}
+	}
+
+	return q.storeTask(task.ID, data, task.Status)
+}
+
+// StartTask records that workerID began running t. It stands in for an
+// UpdateTask followed by a LogExecution of the running attempt, with one
+// backend round trip and one repository call.
+func (q *Queue) StartTask(t *task.Task, workerID string) error {
+	data, err := q.encode(t)
+	if err != nil {
+		return err
+	}
+
+	if q.repo != nil {
+		if err := q.repo.StartTask(q.ctx, t.ID, t.RetryCount+1, workerID); err != nil {
+			log.Printf("Warning: failed to record task start in database: %v", err)
+		}
+	}
+
+	return q.storeTask(t.ID, data, t.Status)
+}
+
+// taskStorer is implemented by backends that can write a task, index it and
+// move its status counters in fewer round trips than one per command.
+type taskStorer interface {
+	storeTask(ctx context.Context, taskID, data string, status task.TaskStatus) error
+}
+
+// storeTask writes the task, indexes it and moves its status counters.
+func (q *Queue) storeTask(taskID, data string, status task.TaskStatus) error {
+	if ts, ok := q.backend.(taskStorer); ok {
+		return ts.storeTask(q.ctx, taskID, data, status)
+	}
+
+	return storeTaskSequentially(q.ctx, q.backend, taskID, data, status)
+}
+
+func storeTaskSequentially(ctx context.Context, b Backend, taskID, data string, status task.TaskStatus) error {
+	if err := b.Set(ctx, "task:"+taskID, data, 0); err != nil {
+		return err
+	}
+	if err := b.SAdd(ctx, "tasks:index", taskID); err != nil {
+		return err
+	}
+
+	return trackStatus(ctx, b, taskID, status)
+}
+
+// GetTask returns a task from the queue, including one that has been moved
+// to the dead letter queue.
+func (q *Queue) GetTask(taskID string) (*task.Task, error) {
+	data, err := q.backend.Get(
+		q.ctx,
+		"task:"+taskID,
+	)
+	if err == ErrNil {
+		data, err = q.backend.Get(q.ctx, "dlq:task:"+taskID)
+	}
+	if err == ErrNil {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return q.decode(data)
+}
+
+// Requeue enqueues a fresh copy of a finished or dead-lettered task under a
+// new ID so it can be replayed. The original task and its history are left
+// untouched.
+func (q *Queue) Requeue(taskID string) (*task.Task, error) {
+	inFlight, err := q.backend.Exists(q.ctx, "inflight:"+taskID)
+	if err != nil {
+		return nil, err
+	}
+	if inFlight > 0 {
+		return nil, ErrTaskNotFinished
+	}
+
+	original, err := q.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch original.Status {
+	case task.CompletedStatus, task.FailedStatus, task.CancelledStatus, task.DeadLetterStatus:
+	default:
+		return nil, ErrTaskNotFinished
+	}
+
+	t := task.NewTask(original.Type, original.Payload, original.Priority)
+	t.MaxRetries = original.MaxRetries
+	t.Tags = original.Tags
+	t.ExpectedDurationMs = original.ExpectedDurationMs
+	t.CorrelationID = original.CorrelationID
+	t.CallbackURL = original.CallbackURL
+	t.RetryDelaySeconds = original.RetryDelaySeconds
+	t.DeadLetter = original.DeadLetter
+
+	if err := q.Enqueue(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (q *Queue) GetAllTasks() ([]*task.Task, error) {
+	return q.indexedTasks("tasks:index", "task:")
+}
+
+// indexedTasks loads every task listed in the index set, dropping IDs whose
+// task key no longer exists.
+func (q *Queue) indexedTasks(index, prefix string) ([]*task.Task, error) {
+	ids, err := q.backend.SMembers(q.ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*task.Task
+	for _, id := range ids {
+		data, err := q.backend.Get(q.ctx, prefix+id)
+		if err == ErrNil {
+			q.backend.SRem(q.ctx, index, id)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		t, err := q.decode(data)
+		if err != nil {
+			continue
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
 }
 
-func (q *Queue) FailTask(t *task.Task, reason string, durationMs int) error {
-	duration := time.Duration(durationMs) * time.Millisecond
-	metrics.RecordTaskFailed(t.Type, duration)
-
-	if q.repo != nil {
-		return q.repo.FailTask(q.ctx, t.ID, reason, durationMs)
+// RebuildIndexes adds every stored task and dead letter task to tasks:index
+// and dlq:index. It scans the whole keyspace and is meant to run once at
+// startup, for data written before the indexes existed.
+func (q *Queue) RebuildIndexes() error {
+	for prefix, index := range map[string]string{"task:": "tasks:index", "dlq:task:": "dlq:index"} {
+		keys, err := q.backend.Scan(q.ctx, prefix+"*")
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := q.backend.SAdd(q.ctx, index, strings.TrimPrefix(key, prefix)); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func (q *Queue) UpdateTask(task *task.Task) error {
-	data, err := task.ToJSON()
+func (q *Queue) GetTasksByTag(tag string) ([]*task.Task, error) {
+	tasks, err := q.GetAllTasks()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if q.repo != nil {
-		if err := q.repo.SaveTask(q.ctx, task); err != nil {
-			log.Printf("Warning: failed to update task in database: %v", err)
+	var filtered []*task.Task
+	for _, t := range tasks {
+		if t.HasTag(tag) {
+			filtered = append(filtered, t)
 		}
 	}
 
-	return q.client.Set(
-		q.ctx,
-		"task:"+task.ID,
-		data,
-		0,
-	).Err()
+	return filtered, nil
 }
 
-func (q *Queue) GetTask(taskID string) (*task.Task, error) {
-	data, err := q.client.Get(
-		q.ctx,
-		"task:"+taskID,
-	).Result()
-	if err != nil {
-		return nil, err
+func (q *Queue) LookupStatus(taskID string) (task.TaskStatus, bool) {
+	if t, err := q.GetTask(taskID); err == nil {
+		return t.Status, true
 	}
 
-	return task.TaskFromJSON(data)
-}
-
-func (q *Queue) GetAllTasks() ([]*task.Task, error) {
-	var tasks []*task.Task
+	if q.repo != nil {
+		if t, err := q.repo.GetTask(q.ctx, taskID); err == nil {
+			return t.Status, true
+		}
+	}
 
-	iter := q.client.Scan(q.ctx, 0, "task:*", 100).Iterator()
-	for iter.Next(q.ctx) {
-		key := iter.Val()
+	return "", false
+}
 
-		data, err := q.client.Get(q.ctx, key).Result()
-		if err != nil {
-			continue
+// DependenciesReady reports whether every task t depends on has completed. A
+// dependency that failed or can no longer be found is an error, since waiting
+// on it would defer t forever.
+func (q *Queue) DependenciesReady(t *task.Task) (bool, error) {
+	for _, depID := range t.DependsOn {
+		status, found := q.LookupStatus(depID)
+		if !found {
+			return false, fmt.Errorf("dependency %s not found", depID)
 		}
 
-		task, err := task.TaskFromJSON(data)
-		if err != nil {
+		switch status {
+		case task.CompletedStatus:
 			continue
+		case task.FailedStatus, task.DeadLetterStatus, task.CancelledStatus:
+			return false, fmt.Errorf("dependency %s ended with status %s", depID, status)
+		default:
+			return false, nil
 		}
-
-		tasks = append(tasks, task)
-	}
-
-	if err := iter.Err(); err != nil {
-		return nil, err
 	}
 
-	return tasks, nil
+	return true, nil
 }
 
 func (q *Queue) MoveToDeadLetter(t *task.Task, reason string) error {
 	t.FailureReason = reason
+	t.FailureCategory = q.failures.Classify(reason)
 	now := time.Now()
 	t.MoveToDLQAt = &now
 	t.Status = task.DeadLetterStatus
 
 	if q.repo != nil {
-		if err := q.repo.MoveTaskToDLQ(q.ctx, t.ID, reason); err != nil {
+		if err := q.repo.MoveTaskToDLQ(q.ctx, t.ID, reason, t.FailureCategory); err != nil {
 			log.Printf("Warning: failed to move task to DLQ in database: %v", err)
 		}
 	}
 
-	data, err := t.ToJSON()
+	data, err := q.encode(t)
 	if err != nil {
 		return err
 	}
 
-	seq, err := q.client.Incr(q.ctx, "dlq:tail").Result()
+	seq, err := q.backend.Incr(q.ctx, "dlq:tail")
 	if err != nil {
 		return err
 	}
 
-	if err := q.client.Set(
-		q.ctx,
-		fmt.Sprintf("dlq:item:%d", seq),
-		t.ID,
-		0,
-	).Err(); err != nil {
+	dlqItemKey := fmt.Sprintf("dlq:item:%d", seq)
+	if err := q.backend.Set(q.ctx, dlqItemKey, t.ID, 0); err != nil {
+		return err
+	}
+	if err := q.backend.Set(q.ctx, "dlq:pos:"+t.ID, dlqItemKey, 0); err != nil {
 		return err
 	}
 
-	if err := q.client.Set(
+	if err := q.backend.Set(
 		q.ctx,
 		"dlq:task:"+t.ID,
 		data,
 		0,
-	).Err(); err != nil {
+	); err != nil {
+		return err
+	}
+	if err := q.backend.SAdd(q.ctx, "dlq:index", t.ID); err != nil {
+		return err
+	}
+	if err := q.trackStatus(t.ID, task.DeadLetterStatus); err != nil {
+		return err
+	}
+
+	// The DLQ copy replaces the active entry, so the task is not counted in
+	// both places.
+	if _, err := q.backend.Del(q.ctx, "task:"+t.ID); err != nil {
+		return err
+	}
+	if err := q.backend.SRem(q.ctx, "tasks:index", t.ID); err != nil {
 		return err
 	}
 
 	metrics.RecordTaskDeadLettered(t.Type)
+	q.PublishEvent(events.DeadLettered, t, reason)
 
 	return nil
 }
 
 func (q *Queue) GetDeadLetterTasks() ([]*task.Task, error) {
-	var tasks []*task.Task
-
-	iter := q.client.Scan(q.ctx, 0, "dlq:task:*", 100).Iterator()
-	for iter.Next(q.ctx) {
-		key := iter.Val()
-
-		data, err := q.client.Get(q.ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		t, err := task.TaskFromJSON(data)
-		if err != nil {
-			continue
-		}
-
-		tasks = append(tasks, t)
-	}
-
-	if err := iter.Err(); err != nil {
-		return nil, err
-	}
-
-	return tasks, nil
+	return q.indexedTasks("dlq:index", "dlq:task:")
 }
 
 func (q *Queue) GetDeadLetterTask(taskID string) (*task.Task, error) {
-	data, err := q.client.Get(
+	data, err := q.backend.Get(
 		q.ctx,
 		"dlq:task:"+taskID,
-	).Result()
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return task.TaskFromJSON(data)
+	return q.decode(data)
 }
 
 func (q *Queue) RetryDeadLetterTask(taskID string) error {
-	data, err := q.client.Get(q.ctx, "dlq:task:"+taskID).Result()
+	data, err := q.backend.Get(q.ctx, "dlq:task:"+taskID)
 	if err != nil {
 		return err
 	}
 
-	t, err := task.TaskFromJSON(data)
+	t, err := q.decode(data)
 	if err != nil {
 		return err
 	}
 
 	t.RetryCount = 0
 	t.FailureReason = ""
+	t.FailureCategory = ""
 	t.MoveToDLQAt = nil
 	t.ScheduledAt = time.Now()
 	t.Status = task.PendingStatus
@@ -389,32 +1695,153 @@ func (q *Queue) RetryDeadLetterTask(taskID string) error {
 		return err
 	}
 
-	q.client.Del(q.ctx, "dlq:task:"+taskID)
+	if itemKey, err := q.findDeadLetterItemKey(taskID); err == nil && itemKey != "" {
+		q.dropItem("dlq:pos:", itemKey, taskID)
+	}
+	q.backend.Del(q.ctx, "dlq:task:"+taskID)
+	q.backend.SRem(q.ctx, "dlq:index", taskID)
 	return nil
 }
 
 func (q *Queue) PurgeDeadLetterTask(taskID string) error {
-	return q.client.Del(
-		q.ctx,
-		"dlq:task:"+taskID,
-	).Err()
+	if err := q.DeleteTask(taskID); err != nil && !errors.Is(err, ErrTaskNotFound) {
+		return err
+	}
+
+	return nil
 }
 
 func (q *Queue) GetDeadLetterStats() (map[string]any, error) {
-	var count int
+	count, err := q.backend.SCard(q.ctx, "dlq:index")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"total_tasks": int(count),
+	}, nil
+}
+
+func (q *Queue) SaveRecurringTask(rt *task.RecurringTask) error {
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+
+	return q.backend.Set(q.ctx, "schedule:"+rt.ID, string(data), 0)
+}
+
+// UpdateRecurringTask writes rt back only if the schedule still exists, so
+// that a run finishing after the schedule was deleted does not recreate it.
+func (q *Queue) UpdateRecurringTask(rt *task.RecurringTask) (bool, error) {
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return false, err
+	}
+
+	return q.backend.SetXX(q.ctx, "schedule:"+rt.ID, string(data))
+}
 
-	iter := q.client.Scan(q.ctx, 0, "dlq:task:*", 100).Iterator()
-	for iter.Next(q.ctx) {
-		count++
+func (q *Queue) GetRecurringTask(id string) (*task.RecurringTask, error) {
+	data, err := q.backend.Get(q.ctx, "schedule:"+id)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := iter.Err(); err != nil {
+	var rt task.RecurringTask
+	if err := json.Unmarshal([]byte(data), &rt); err != nil {
 		return nil, err
 	}
 
-	return map[string]any{
-		"total_tasks": count,
-	}, nil
+	return &rt, nil
+}
+
+func (q *Queue) GetRecurringTasks() ([]*task.RecurringTask, error) {
+	var schedules []*task.RecurringTask
+
+	keys, err := q.backend.Scan(q.ctx, "schedule:*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		data, err := q.backend.Get(q.ctx, key)
+		if err != nil {
+			continue
+		}
+
+		var rt task.RecurringTask
+		if err := json.Unmarshal([]byte(data), &rt); err != nil {
+			continue
+		}
+
+		schedules = append(schedules, &rt)
+	}
+
+	return schedules, nil
+}
+
+// scheduleRunTTL keeps a claimed schedule slot long after the claiming
+// scheduler has recorded the run, by which time the slot is no longer due.
+const scheduleRunTTL = 24 * time.Hour
+
+// ClaimScheduleRun reports whether the caller is the first to fire the
+// schedule for the slot due at fireAt, so that schedulers sharing a backend
+// enqueue each slot once.
+func (q *Queue) ClaimScheduleRun(scheduleID string, fireAt time.Time) (bool, error) {
+	return q.backend.SetNX(q.ctx, scheduleRunKey(scheduleID, fireAt), "1", scheduleRunTTL)
+}
+
+// ReleaseScheduleRun gives up a claimed slot that could not be enqueued so
+// the next scheduler tick can retry it.
+func (q *Queue) ReleaseScheduleRun(scheduleID string, fireAt time.Time) error {
+	_, err := q.backend.Del(q.ctx, scheduleRunKey(scheduleID, fireAt))
+	return err
+}
+
+func scheduleRunKey(scheduleID string, fireAt time.Time) string {
+	return fmt.Sprintf("schedulerun:%s:%d", scheduleID, fireAt.UnixMilli())
+}
+
+func (q *Queue) DeleteRecurringTask(id string) (bool, error) {
+	deleted, err := q.backend.Del(q.ctx, "schedule:"+id)
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// ReportInfo describes a report file written by a generate_report task. ID
+// is the ID of that task.
+type ReportInfo struct {
+	ID          string    `json:"id"`
+	Path        string    `json:"path"`
+	Format      string    `json:"format"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+func (q *Queue) SaveReportInfo(info ReportInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return q.backend.Set(q.ctx, "report:"+info.ID, string(data), 0)
+}
+
+func (q *Queue) GetReportInfo(id string) (*ReportInfo, error) {
+	data, err := q.backend.Get(q.ctx, "report:"+id)
+	if err != nil {
+		return nil, err
+	}
+
+	var info ReportInfo
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
 }
 
 func (q *Queue) IncrementRetryCount(taskID string) error {
@@ -433,21 +1860,205 @@ func (q *Queue) LogExecution(taskID string, attemptNumber int, status string, du
 	return nil
 }
 
+// RegisterTaskTypes records task types a worker has a handler for. Types stay
+// registered after the worker stops so tasks can still be queued for the
+// next one.
+func (q *Queue) RegisterTaskTypes(types ...string) error {
+	for _, taskType := range types {
+		if err := q.backend.SAdd(q.ctx, "tasktypes:index", taskType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *Queue) IsKnownTaskType(taskType string) (bool, error) {
+	return q.backend.SIsMember(q.ctx, "tasktypes:index", taskType)
+}
+
+func (q *Queue) KnownTaskTypes() ([]string, error) {
+	types, err := q.backend.SMembers(q.ctx, "tasktypes:index")
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(types)
+
+	return types, nil
+}
+
+// WorkerInfo is the heartbeat a worker publishes while it is running.
+type WorkerInfo struct {
+	ID          string    `json:"id"`
+	LastSeen    time.Time `json:"last_seen"`
+	CurrentTask string    `json:"current_task,omitempty"`
+}
+
+// Heartbeat records that a worker is alive. The worker is listed by
+// ActiveWorkers until ttl passes without another heartbeat.
+func (q *Queue) Heartbeat(info WorkerInfo, ttl time.Duration) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	if err := q.backend.Set(q.ctx, "worker:"+info.ID, string(data), ttl); err != nil {
+		return err
+	}
+	// A worker that stops heartbeating loses its lease counter along with
+	// its registry entry, so a restart under the same ID is not locked out.
+	if err := q.backend.Expire(q.ctx, "leases:"+info.ID, ttl); err != nil {
+		return err
+	}
+
+	return q.backend.SAdd(q.ctx, "workers:index", info.ID)
+}
+
+func (q *Queue) RemoveWorker(workerID string) error {
+	if _, err := q.backend.Del(q.ctx, "worker:"+workerID); err != nil {
+		return err
+	}
+
+	return q.backend.SRem(q.ctx, "workers:index", workerID)
+}
+
+func (q *Queue) ActiveWorkers() ([]WorkerInfo, error) {
+	ids, err := q.backend.SMembers(q.ctx, "workers:index")
+	if err != nil {
+		return nil, err
+	}
+
+	workers := []WorkerInfo{}
+	for _, id := range ids {
+		data, err := q.backend.Get(q.ctx, "worker:"+id)
+		if err == ErrNil {
+			q.backend.SRem(q.ctx, "workers:index", id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			continue
+		}
+
+		workers = append(workers, info)
+	}
+
+	slices.SortFunc(workers, func(a, b WorkerInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return workers, nil
+}
+
 func (q *Queue) GetRepository() repository.TaskRepository {
 	return q.repo
 }
 
+// Close waits for pending background writes, then closes the backend and the
+// repository. Only the first call closes anything; later calls return nil.
 func (q *Queue) Close() error {
-	return q.client.Close()
+	var err error
+	q.closeOnce.Do(func() {
+		q.pending.Wait()
+
+		err = q.backend.Close()
+		if q.repo != nil {
+			err = errors.Join(err, q.repo.Close())
+		}
+	})
+
+	return err
+}
+
+type MetricsSnapshot struct {
+	QueueDepth           int                                `json:"queue_depth"`
+	DeadLetterQueueDepth int                                `json:"dead_letter_queue_depth"`
+	DelayedTasks         int                                `json:"delayed_tasks"`
+	TasksByStatus        map[task.TaskStatus]map[string]int `json:"tasks_by_status"`
 }
 
 func (q *Queue) UpdateMetrics() error {
-	tasks, err := q.GetAllTasks()
+	_, err := q.RefreshMetrics()
+	return err
+}
+
+// DelayedCount returns the number of tasks parked in the scheduled set that
+// are not due yet. Due tasks still in the set are promoted on the next
+// dequeue and do not count.
+func (q *Queue) DelayedCount() (int, error) {
+	all, err := q.backend.ZRangeByScore(q.ctx, "scheduled", math.Inf(1))
 	if err != nil {
-		return err
+		return 0, err
+	}
+	due, err := q.backend.ZRangeByScore(q.ctx, "scheduled", float64(time.Now().UnixMilli()))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(all) - len(due), nil
+}
+
+// AllTypesLabel is the task type reported by RefreshMetrics when aggregate
+// metrics are on.
+const AllTypesLabel = "all"
+
+func (q *Queue) RefreshMetrics() (*MetricsSnapshot, error) {
+	depth, err := q.backend.SCard(q.ctx, "tasks:index")
+	if err != nil {
+		return nil, err
+	}
+
+	tasksByStatus, err := q.tasksByStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	delayed, err := q.DelayedCount()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.UpdateTaskGauges(tasksByStatus)
+	metrics.UpdateQueueDepth(int(depth))
+	metrics.UpdateDelayedTasks(delayed)
+
+	snapshot := &MetricsSnapshot{
+		QueueDepth:    int(depth),
+		DelayedTasks:  delayed,
+		TasksByStatus: tasksByStatus,
+	}
+
+	dlqDepth, err := q.backend.SCard(q.ctx, "dlq:index")
+	if err == nil {
+		metrics.UpdateDeadLetterQueueDepth(int(dlqDepth))
+		snapshot.DeadLetterQueueDepth = int(dlqDepth)
 	}
 
+	return snapshot, nil
+}
+
+func (q *Queue) tasksByStatus() (map[task.TaskStatus]map[string]int, error) {
 	tasksByStatus := make(map[task.TaskStatus]map[string]int)
+
+	if q.aggregate {
+		counts, err := q.StatusCounts()
+		if err != nil {
+			return nil, err
+		}
+		for status, n := range counts {
+			tasksByStatus[status] = map[string]int{AllTypesLabel: n}
+		}
+		return tasksByStatus, nil
+	}
+
+	tasks, err := q.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
 	for _, t := range tasks {
 		if tasksByStatus[t.Status] == nil {
 			tasksByStatus[t.Status] = make(map[string]int)
@@ -455,13 +2066,5 @@ func (q *Queue) UpdateMetrics() error {
 		tasksByStatus[t.Status][t.Type]++
 	}
 
-	metrics.UpdateTaskGauges(tasksByStatus)
-	metrics.UpdateQueueDepth(len(tasks))
-
-	dlqTasks, err := q.GetDeadLetterTasks()
-	if err == nil {
-		metrics.UpdateDeadLetterQueueDepth(len(dlqTasks))
-	}
-
-	return nil
+	return tasksByStatus, nil
 }
@@ -1,12 +1,20 @@
 package queue
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/encryption"
+	"github.com/nadmax/nexq/internal/metrics"
 	"github.com/nadmax/nexq/internal/repository/mocks"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,7 +46,7 @@ func TestNewQueue(t *testing.T) {
 	defer func() { _ = q.Close() }()
 
 	assert.NotNil(t, q)
-	assert.NotNil(t, q.client)
+	assert.NotNil(t, q.backend)
 }
 
 func TestNewQueue_InvalidAddress(t *testing.T) {
@@ -57,6 +65,60 @@ func TestEnqueue(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestEnqueue_MaxQueueDepth(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxQueueDepth(2)
+
+	require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	require.NoError(t, q.EnqueueWithMode(task.NewTask("test_task", nil, task.MediumPriority), FastEnqueue))
+
+	rejected := task.NewTask("test_task", nil, task.MediumPriority)
+	assert.ErrorIs(t, q.Enqueue(rejected), ErrQueueFull)
+	assert.ErrorIs(t, q.EnqueueWithMode(rejected, DurableEnqueue), ErrQueueFull)
+	_, err := q.GetTask(rejected.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	depth, err := q.Depth()
+	require.NoError(t, err)
+	assert.Equal(t, 2, depth)
+
+	retried := task.NewTask("test_task", nil, task.MediumPriority)
+	retried.RetryCount = 1
+	assert.NoError(t, q.Enqueue(retried), "retries bypass the limit")
+
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+	assert.NoError(t, q.Enqueue(rejected), "dequeuing frees a slot")
+}
+
+func TestEnqueue_MaxPayloadSize(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	payload := map[string]any{"data": "abcd"}
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	q.SetMaxPayloadSize(len(data))
+	assert.NoError(t, q.Enqueue(task.NewTask("test_task", payload, task.MediumPriority)), "a payload exactly at the limit is accepted")
+
+	q.SetMaxPayloadSize(len(data) - 1)
+	oversized := task.NewTask("test_task", payload, task.MediumPriority)
+	assert.ErrorIs(t, q.Enqueue(oversized), ErrPayloadTooLarge)
+	assert.ErrorIs(t, q.EnqueueWithMode(oversized, FastEnqueue), ErrPayloadTooLarge)
+	_, err = q.GetTask(oversized.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	q.SetMaxPayloadSize(0)
+	assert.NoError(t, q.Enqueue(oversized), "zero disables the limit")
+}
+
 func TestEnqueueWithRepository(t *testing.T) {
 	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
 	defer mr.Close()
@@ -92,6 +154,445 @@ func TestEnqueueAndDequeue(t *testing.T) {
 	assert.Equal(t, original.Status, dequeued.Status)
 }
 
+func TestEnqueueWithPayloadCipher(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	c, err := encryption.NewPayloadCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	q.SetPayloadCipher(c)
+
+	payload := map[string]any{"to": "user@example.com"}
+	original := task.NewTask("send_email", payload, task.MediumPriority)
+	require.NoError(t, q.Enqueue(original))
+
+	stored, err := mr.Get("task:" + original.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored, "user@example.com")
+	assert.Contains(t, stored, "_encrypted")
+	assert.Equal(t, payload, original.Payload)
+
+	fetched, err := q.GetTask(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, payload, fetched.Payload)
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, payload, dequeued.Payload)
+}
+
+func TestEnqueueIdempotent(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("test_task", nil, task.MediumPriority)
+	got, created, err := q.EnqueueIdempotent(first, "key-1", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, first.ID, got.ID)
+
+	second := task.NewTask("test_task", nil, task.MediumPriority)
+	got, created, err = q.EnqueueIdempotent(second, "key-1", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, got.ID)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+}
+
+func TestEnqueueIdempotent_ReplayAfterOriginalMoved(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	dequeued := task.NewTask("test_task", nil, task.MediumPriority)
+	_, _, err := q.EnqueueIdempotent(dequeued, "in-flight", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+
+	got, created, err := q.EnqueueIdempotent(task.NewTask("test_task", nil, task.MediumPriority), "in-flight", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, dequeued.ID, got.ID)
+
+	deadLettered := task.NewTask("test_task", nil, task.MediumPriority)
+	_, _, err = q.EnqueueIdempotent(deadLettered, "dead-lettered", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	require.NoError(t, q.MoveToDeadLetter(deadLettered, "boom"))
+
+	got, created, err = q.EnqueueIdempotent(task.NewTask("test_task", nil, task.MediumPriority), "dead-lettered", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, deadLettered.ID, got.ID)
+
+	deleted := task.NewTask("test_task", nil, task.MediumPriority)
+	_, _, err = q.EnqueueIdempotent(deleted, "deleted", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	require.NoError(t, q.DeleteTask(deleted.ID))
+
+	replacement := task.NewTask("test_task", nil, task.MediumPriority)
+	got, created, err = q.EnqueueIdempotent(replacement, "deleted", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, replacement.ID, got.ID)
+}
+
+func TestEnqueueIdempotent_KeyExpires(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("test_task", nil, task.MediumPriority)
+	_, created, err := q.EnqueueIdempotent(first, "key-1", time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	mr.FastForward(2 * time.Minute)
+
+	second := task.NewTask("test_task", nil, task.MediumPriority)
+	got, created, err := q.EnqueueIdempotent(second, "key-1", time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, second.ID, got.ID)
+}
+
+func TestEnqueueDeduplicated(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("invalidate_cache", map[string]any{"key": "users", "shard": 1}, task.MediumPriority)
+	got, created, err := q.EnqueueDeduplicated(first, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, first.ID, got.ID)
+
+	duplicate := task.NewTask("invalidate_cache", map[string]any{"shard": 1, "key": "users"}, task.MediumPriority)
+	got, created, err = q.EnqueueDeduplicated(duplicate, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created, "a duplicate within the window is dropped")
+	assert.Equal(t, first.ID, got.ID)
+
+	otherPayload := task.NewTask("invalidate_cache", map[string]any{"key": "orders", "shard": 1}, task.MediumPriority)
+	_, created, err = q.EnqueueDeduplicated(otherPayload, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	otherType := task.NewTask("warm_cache", map[string]any{"key": "users", "shard": 1}, task.MediumPriority)
+	_, created, err = q.EnqueueDeduplicated(otherType, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	mr.FastForward(2 * time.Minute)
+
+	late := task.NewTask("invalidate_cache", map[string]any{"key": "users", "shard": 1}, task.MediumPriority)
+	got, created, err = q.EnqueueDeduplicated(late, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created, "a duplicate after the window is accepted")
+	assert.Equal(t, late.ID, got.ID)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 4)
+}
+
+func TestEnqueueDeduplicated_DeadLetteredOriginal(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("invalidate_cache", map[string]any{"key": "users"}, task.MediumPriority)
+	_, created, err := q.EnqueueDeduplicated(first, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.NoError(t, q.MoveToDeadLetter(first, "boom"))
+
+	duplicate := task.NewTask("invalidate_cache", map[string]any{"key": "users"}, task.MediumPriority)
+	got, created, err := q.EnqueueDeduplicated(duplicate, time.Minute, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, got.ID)
+	assert.Equal(t, task.DeadLetterStatus, got.Status)
+}
+
+func TestEnqueueDeduplicated_OriginalElsewhere(t *testing.T) {
+	payload := map[string]any{"key": "users"}
+
+	t.Run("in flight", func(t *testing.T) {
+		q, mr := setupTestQueue(t)
+		defer mr.Close()
+		defer func() { _ = q.Close() }()
+
+		first := task.NewTask("invalidate_cache", payload, task.MediumPriority)
+		_, _, err := q.EnqueueDeduplicated(first, time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		_, err = q.Dequeue()
+		require.NoError(t, err)
+
+		got, created, err := q.EnqueueDeduplicated(task.NewTask("invalidate_cache", payload, task.MediumPriority), time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, first.ID, got.ID)
+	})
+
+	t.Run("only in history", func(t *testing.T) {
+		q, _, mr := setupTestQueueWithMockRepo(t)
+		defer mr.Close()
+		defer func() { _ = q.Close() }()
+
+		first := task.NewTask("invalidate_cache", payload, task.MediumPriority)
+		_, _, err := q.EnqueueDeduplicated(first, time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		mr.Del("task:" + first.ID)
+
+		got, created, err := q.EnqueueDeduplicated(task.NewTask("invalidate_cache", payload, task.MediumPriority), time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, first.ID, got.ID)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		q, mr := setupTestQueue(t)
+		defer mr.Close()
+		defer func() { _ = q.Close() }()
+
+		first := task.NewTask("invalidate_cache", payload, task.MediumPriority)
+		_, _, err := q.EnqueueDeduplicated(first, time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		require.NoError(t, q.DeleteTask(first.ID))
+
+		replacement := task.NewTask("invalidate_cache", payload, task.MediumPriority)
+		got, created, err := q.EnqueueDeduplicated(replacement, time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		assert.True(t, created, "a stale mapping does not block the new task")
+		assert.Equal(t, replacement.ID, got.ID)
+
+		_, created, err = q.EnqueueDeduplicated(task.NewTask("invalidate_cache", payload, task.MediumPriority), time.Minute, DurableEnqueue)
+		require.NoError(t, err)
+		assert.False(t, created, "the replacement now holds the window")
+	})
+}
+
+func TestEnqueueWithID(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	custom := task.NewTask("test_task", nil, task.MediumPriority)
+	custom.ID = "order-42"
+	require.NoError(t, q.EnqueueWithID(custom, DurableEnqueue))
+
+	stored, err := q.GetTask("order-42")
+	require.NoError(t, err)
+	assert.Equal(t, custom.ID, stored.ID)
+
+	duplicate := task.NewTask("test_task", nil, task.MediumPriority)
+	duplicate.ID = "order-42"
+	assert.ErrorIs(t, q.EnqueueWithID(duplicate, DurableEnqueue), ErrTaskIDExists)
+
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+	assert.ErrorIs(t, q.EnqueueWithID(duplicate, DurableEnqueue), ErrTaskIDExists, "in-flight tasks keep their ID")
+
+	generated := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(generated))
+	clash := task.NewTask("test_task", nil, task.MediumPriority)
+	clash.ID = generated.ID
+	assert.ErrorIs(t, q.EnqueueWithID(clash, DurableEnqueue), ErrTaskIDExists)
+}
+
+func TestEnqueueWithID_TakenInHistory(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	old := task.NewTask("test_task", nil, task.MediumPriority)
+	old.ID = "order-7"
+	old.Status = task.CompletedStatus
+	require.NoError(t, mockRepo.SaveTask(context.Background(), old))
+
+	reused := task.NewTask("test_task", nil, task.MediumPriority)
+	reused.ID = "order-7"
+	assert.ErrorIs(t, q.EnqueueWithID(reused, DurableEnqueue), ErrTaskIDExists)
+
+	mockRepo.GetTaskError = errors.New("connection refused")
+	fresh := task.NewTask("test_task", nil, task.MediumPriority)
+	fresh.ID = "order-8"
+	err := q.EnqueueWithID(fresh, DurableEnqueue)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTaskIDExists)
+	assert.False(t, mr.Exists("task:order-8"))
+}
+
+func TestEnqueueWithID_ReleasesReservationOnFailure(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxPayloadSize(1)
+	custom := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	custom.ID = "order-42"
+	assert.ErrorIs(t, q.EnqueueWithID(custom, DurableEnqueue), ErrPayloadTooLarge)
+
+	q.SetMaxPayloadSize(0)
+	assert.NoError(t, q.EnqueueWithID(custom, DurableEnqueue))
+}
+
+func TestEnqueueIdempotentWithID_Replay(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	first := task.NewTask("test_task", nil, task.MediumPriority)
+	first.ID = "order-42"
+	_, created, err := q.EnqueueIdempotentWithID(first, "key-1", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	replay := task.NewTask("test_task", nil, task.MediumPriority)
+	replay.ID = "order-42"
+	got, created, err := q.EnqueueIdempotentWithID(replay, "key-1", time.Hour, DurableEnqueue)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "order-42", got.ID)
+
+	_, _, err = q.EnqueueIdempotentWithID(replay, "key-2", time.Hour, DurableEnqueue)
+	assert.ErrorIs(t, err, ErrTaskIDExists)
+}
+
+func TestParseEnqueueMode(t *testing.T) {
+	mode, err := ParseEnqueueMode("")
+	require.NoError(t, err)
+	assert.Equal(t, DurableEnqueue, mode)
+
+	mode, err = ParseEnqueueMode("fast")
+	require.NoError(t, err)
+	assert.Equal(t, FastEnqueue, mode)
+
+	_, err = ParseEnqueueMode("eventually")
+	assert.Error(t, err)
+}
+
+func TestEnqueueWithMode_DurableWaitsForPersistence(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	gate := make(chan struct{})
+	mockRepo.SaveTaskGate = gate
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	done := make(chan error, 1)
+	go func() { done <- q.EnqueueWithMode(tsk, DurableEnqueue) }()
+
+	select {
+	case <-done:
+		t.Fatal("durable enqueue returned before the task was persisted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err := q.GetTask(tsk.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	close(gate)
+	require.NoError(t, <-done)
+
+	assert.Contains(t, mockRepo.Tasks, tsk.ID)
+	_, err = q.GetTask(tsk.ID)
+	assert.NoError(t, err)
+}
+
+func TestEnqueueWithMode_DurableFailsWhenNotPersisted(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.SaveTaskError = errors.New("database unavailable")
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	err := q.EnqueueWithMode(tsk, DurableEnqueue)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database unavailable")
+
+	_, err = q.GetTask(tsk.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestEnqueueWithMode_FastDoesNotWait(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+
+	gate := make(chan struct{})
+	mockRepo.SaveTaskGate = gate
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	done := make(chan error, 1)
+	go func() { done <- q.EnqueueWithMode(tsk, FastEnqueue) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("fast enqueue waited for persistence")
+	}
+
+	stored, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, stored.Status)
+
+	close(gate)
+	require.NoError(t, q.Close())
+	assert.Contains(t, mockRepo.Tasks, tsk.ID)
+}
+
+func TestEnqueueWithMode_FastQueuesAfterPersistence(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	gate := make(chan struct{})
+	mockRepo.SaveTaskGate = gate
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.EnqueueWithMode(tsk, FastEnqueue))
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, dequeued, "the task is not dequeued before its history row exists")
+
+	close(gate)
+	q.pending.Wait()
+
+	dequeued, err = q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, tsk.ID, dequeued.ID)
+
+	status, found := mockRepo.GetTaskStatus(tsk.ID)
+	require.True(t, found)
+	assert.Equal(t, task.RunningStatus, status)
+}
+
+func TestEnqueueWithMode_FastIgnoresPersistenceErrors(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	mockRepo.SaveTaskError = errors.New("database unavailable")
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.EnqueueWithMode(tsk, FastEnqueue))
+
+	_, err := q.GetTask(tsk.ID)
+	assert.NoError(t, err)
+}
+
 func TestDequeue_EmptyQueue(t *testing.T) {
 	q, mr := setupTestQueue(t)
 	defer mr.Close()
@@ -173,7 +674,41 @@ func TestScheduledTasks(t *testing.T) {
 
 	dequeued2, err := q.Dequeue()
 	assert.NoError(t, err)
-	assert.NotNil(t, dequeued2)
+	assert.Nil(t, dequeued2, "a task is not dequeued before it is due")
+
+	peeked, err := q.Peek(10)
+	require.NoError(t, err)
+	assert.Empty(t, peeked)
+}
+
+func TestScheduledTasks_BecomeDue(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("soon", nil, task.MediumPriority)
+	tsk.ScheduledAt = time.Now().Add(100 * time.Millisecond)
+	require.NoError(t, q.Enqueue(tsk))
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, dequeued)
+
+	time.Sleep(100 * time.Millisecond)
+
+	peeked, err := q.Peek(10)
+	require.NoError(t, err)
+	require.Len(t, peeked, 1)
+	assert.Equal(t, tsk.ID, peeked[0].ID)
+
+	dequeued, err = q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, tsk.ID, dequeued.ID)
+
+	dequeued, err = q.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, dequeued, "a promoted task is queued once")
 }
 
 func TestUpdateTask(t *testing.T) {
@@ -217,7 +752,18 @@ func TestGetTask_NotFound(t *testing.T) {
 
 	_, err := q.GetTask("non-existent-id")
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestGetTask_ConnectionError(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer func() { _ = q.Close() }()
+
+	mr.Close()
+
+	_, err := q.GetTask("some-id")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTaskNotFound)
 }
 
 func TestGetAllTasks(t *testing.T) {
@@ -253,7 +799,47 @@ func TestGetAllTasks_Empty(t *testing.T) {
 	assert.Len(t, tasks, 0)
 }
 
-func TestClose(t *testing.T) {
+func TestEnqueueWithTags(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test", nil, task.MediumPriority)
+	tsk.Tags = []string{"tenant-a", "batch-1"}
+	err := q.Enqueue(tsk)
+	require.NoError(t, err)
+
+	retrieved, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant-a", "batch-1"}, retrieved.Tags)
+}
+
+func TestGetTasksByTag(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	task1 := task.NewTask("task1", nil, task.MediumPriority)
+	task1.Tags = []string{"tenant-a"}
+	task2 := task.NewTask("task2", nil, task.MediumPriority)
+	task2.Tags = []string{"tenant-b"}
+	task3 := task.NewTask("task3", nil, task.MediumPriority)
+
+	require.NoError(t, q.Enqueue(task1))
+	require.NoError(t, q.Enqueue(task2))
+	require.NoError(t, q.Enqueue(task3))
+
+	tasks, err := q.GetTasksByTag("tenant-a")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task1.ID, tasks[0].ID)
+
+	tasks, err = q.GetTasksByTag("missing")
+	require.NoError(t, err)
+	assert.Len(t, tasks, 0)
+}
+
+func TestClose(t *testing.T) {
 	q, mr := setupTestQueue(t)
 	defer mr.Close()
 
@@ -261,6 +847,26 @@ func TestClose(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestClose_Idempotent(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+
+	require.NoError(t, q.Close())
+	assert.NoError(t, q.Close(), "a second Close must be a no-op")
+	assert.Equal(t, 1, mockRepo.CloseCalls)
+}
+
+func TestClose_ReturnsRepositoryError(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+
+	mockRepo.CloseError = errors.New("connection reset")
+
+	err := q.Close()
+	assert.ErrorIs(t, err, mockRepo.CloseError)
+	assert.NoError(t, q.Close())
+}
+
 func TestCompleteTaskWithRepository(t *testing.T) {
 	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
 	defer mr.Close()
@@ -300,6 +906,7 @@ func TestFailTaskWithRepository(t *testing.T) {
 	failCall := mockRepo.FailTaskCalls[0]
 	assert.Equal(t, tsk.ID, failCall.TaskID)
 	assert.Equal(t, reason, failCall.Reason)
+	assert.Equal(t, task.TimeoutFailure, failCall.Category)
 	assert.Equal(t, durationMs, failCall.DurationMs)
 }
 
@@ -321,6 +928,68 @@ func TestMoveToDeadLetterWithRepository(t *testing.T) {
 	dlqCall := mockRepo.MoveTaskToDLQCalls[0]
 	assert.Equal(t, tsk.ID, dlqCall.TaskID)
 	assert.Equal(t, reason, dlqCall.Reason)
+	assert.Equal(t, tsk.FailureCategory, dlqCall.Category)
+}
+
+func TestMoveToDeadLetter_RemovesActiveTask(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	metrics.TasksDeadLettered.Reset()
+
+	tsk := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	kept := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(kept))
+
+	require.NoError(t, q.MoveToDeadLetter(tsk, "Max retries exceeded"))
+
+	active, err := q.GetAllTasks()
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, kept.ID, active[0].ID)
+
+	dead, err := q.GetDeadLetterTasks()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, tsk.ID, dead[0].ID)
+
+	fromDLQ, err := q.GetTask(tsk.ID)
+	require.NoError(t, err, "GetTask falls back to the DLQ copy")
+	assert.Equal(t, task.DeadLetterStatus, fromDLQ.Status)
+	status, found := q.LookupStatus(tsk.ID)
+	assert.True(t, found)
+	assert.Equal(t, task.DeadLetterStatus, status)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TasksDeadLettered.WithLabelValues("test_task")))
+}
+
+func TestMoveToDeadLetter_FailureCategory(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.MoveToDeadLetter(tsk, "dial tcp: connection refused"))
+
+	dlqTask, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.ConnectionFailure, dlqTask.FailureCategory)
+
+	classifier, err := task.ParseFailureClassifier("quota=rate limit")
+	require.NoError(t, err)
+	q.SetFailureClassifier(classifier)
+
+	other := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.MoveToDeadLetter(other, "rate limit exceeded"))
+	dlqTask, err = q.GetDeadLetterTask(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.FailureCategory("quota"), dlqTask.FailureCategory)
+
+	require.NoError(t, q.RetryDeadLetterTask(other.ID))
+	retried, err := q.GetTask(other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, retried.FailureCategory)
 }
 
 func TestIncrementRetryCountWithRepository(t *testing.T) {
@@ -481,7 +1150,7 @@ func TestCancelTask_CompletedTask(t *testing.T) {
 
 	tsk := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
 	tsk.Status = task.CompletedStatus
-	err := q.Enqueue(tsk)
+	err := q.UpdateTask(tsk)
 	require.NoError(t, err)
 
 	err = q.CancelTask(tsk.ID)
@@ -735,6 +1404,26 @@ func TestUpdateMetrics(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestRefreshMetrics_AggregateTypes(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	require.NoError(t, q.Enqueue(task.NewTask("send_email", nil, task.MediumPriority)))
+	require.NoError(t, q.Enqueue(task.NewTask("send_email", nil, task.MediumPriority)))
+	require.NoError(t, q.Enqueue(task.NewTask("resize_image", nil, task.MediumPriority)))
+
+	snapshot, err := q.RefreshMetrics()
+	require.NoError(t, err)
+	assert.Equal(t, 3, snapshot.QueueDepth)
+	assert.Equal(t, map[string]int{"send_email": 2, "resize_image": 1}, snapshot.TasksByStatus[task.PendingStatus])
+
+	q.SetAggregateMetrics(true)
+	snapshot, err = q.RefreshMetrics()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{AllTypesLabel: 3}, snapshot.TasksByStatus[task.PendingStatus])
+}
+
 func TestUpdateMetrics_EmptyQueue(t *testing.T) {
 	q, mr := setupTestQueue(t)
 	defer mr.Close()
@@ -744,54 +1433,1185 @@ func TestUpdateMetrics_EmptyQueue(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestDeadLetterWorkflow(t *testing.T) {
+func TestDepth(t *testing.T) {
 	q, mr := setupTestQueue(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("workflow_task", map[string]any{"data": "test"}, task.HighPriority)
-	err := q.Enqueue(tsk)
+	depth, err := q.Depth()
 	require.NoError(t, err)
+	assert.Equal(t, 0, depth)
 
-	err = q.MoveToDeadLetter(tsk, "max retries exceeded")
+	var tasks []*task.Task
+	for range 150 {
+		tsk := task.NewTask("depth_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+		tasks = append(tasks, tsk)
+	}
+
+	depth, err = q.Depth()
 	require.NoError(t, err)
+	assert.Equal(t, 150, depth)
 
-	dlqTasks, err := q.GetDeadLetterTasks()
+	require.NoError(t, q.DeleteTask(tasks[120].ID))
+	_, err = q.Dequeue()
 	require.NoError(t, err)
-	assert.Len(t, dlqTasks, 1)
 
-	stats, err := q.GetDeadLetterStats()
+	depth, err = q.Depth()
 	require.NoError(t, err)
-	assert.Equal(t, 1, stats["total_tasks"])
+	assert.Equal(t, 148, depth)
+}
 
-	err = q.RetryDeadLetterTask(tsk.ID)
+func TestPeek(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tasks, err := q.Peek(10)
 	require.NoError(t, err)
+	assert.Empty(t, tasks)
 
-	dlqTasks, err = q.GetDeadLetterTasks()
+	var enqueued []*task.Task
+	for range 5 {
+		tsk := task.NewTask("peek_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+		enqueued = append(enqueued, tsk)
+	}
+
+	_, err = q.Dequeue()
 	require.NoError(t, err)
-	assert.Len(t, dlqTasks, 0)
+	require.NoError(t, q.DeleteTask(enqueued[1].ID))
+	require.NoError(t, q.CancelTask(enqueued[2].ID))
 
-	retrieved, err := q.GetTask(tsk.ID)
+	head, _ := mr.Get("queue:head")
+	tail, _ := mr.Get("queue:tail")
+
+	tasks, err = q.Peek(10)
 	require.NoError(t, err)
-	assert.Equal(t, task.PendingStatus, retrieved.Status)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, enqueued[3].ID, tasks[0].ID)
+	assert.Equal(t, enqueued[4].ID, tasks[1].ID)
+
+	tasks, err = q.Peek(1)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, enqueued[3].ID, tasks[0].ID)
+
+	headAfter, _ := mr.Get("queue:head")
+	tailAfter, _ := mr.Get("queue:tail")
+	assert.Equal(t, head, headAfter, "peek must not move the head")
+	assert.Equal(t, tail, tailAfter, "peek must not move the tail")
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, enqueued[3].ID, dequeued.ID, "dequeue should return the first peeked task")
 }
 
-func TestCancelAndRetryWorkflow(t *testing.T) {
+func TestStatusCounts_Transitions(t *testing.T) {
 	q, mr := setupTestQueue(t)
 	defer mr.Close()
 	defer func() { _ = q.Close() }()
 
-	tsk := task.NewTask("cancel_test", map[string]any{}, task.MediumPriority)
-	err := q.Enqueue(tsk)
+	assertCounts := func(expected map[task.TaskStatus]int) {
+		t.Helper()
+		counts, err := q.StatusCounts()
+		require.NoError(t, err)
+		assert.Equal(t, expected, counts)
+	}
+
+	assertCounts(map[task.TaskStatus]int{})
+
+	first := task.NewTask("counted", nil, task.MediumPriority)
+	second := task.NewTask("counted", nil, task.MediumPriority)
+	third := task.NewTask("counted", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(first))
+	require.NoError(t, q.Enqueue(second))
+	require.NoError(t, q.Enqueue(third))
+	assertCounts(map[task.TaskStatus]int{task.PendingStatus: 3})
+
+	dequeued, err := q.Dequeue()
 	require.NoError(t, err)
+	require.Equal(t, first.ID, dequeued.ID)
+	assertCounts(map[task.TaskStatus]int{task.PendingStatus: 2, task.RunningStatus: 1})
+
+	dequeued.Status = task.RunningStatus
+	require.NoError(t, q.UpdateTask(dequeued))
+	assertCounts(map[task.TaskStatus]int{task.PendingStatus: 2, task.RunningStatus: 1})
+
+	dequeued.Status = task.CompletedStatus
+	require.NoError(t, q.UpdateTask(dequeued))
+	assertCounts(map[task.TaskStatus]int{task.PendingStatus: 2, task.CompletedStatus: 1})
+
+	require.NoError(t, q.CancelTask(second.ID))
+	assertCounts(map[task.TaskStatus]int{
+		task.PendingStatus:   1,
+		task.CompletedStatus: 1,
+		task.CancelledStatus: 1,
+	})
+
+	require.NoError(t, q.MoveToDeadLetter(third, "boom"))
+	assertCounts(map[task.TaskStatus]int{
+		task.CompletedStatus:  1,
+		task.CancelledStatus:  1,
+		task.DeadLetterStatus: 1,
+	})
+
+	require.NoError(t, q.RetryDeadLetterTask(third.ID))
+	assertCounts(map[task.TaskStatus]int{
+		task.PendingStatus:   1,
+		task.CompletedStatus: 1,
+		task.CancelledStatus: 1,
+	})
+
+	require.NoError(t, q.DeleteTask(third.ID))
+	require.NoError(t, q.DeleteTask(second.ID))
+	assertCounts(map[task.TaskStatus]int{task.CompletedStatus: 1})
+}
 
-	err = q.CancelTask(tsk.ID)
+func TestStatusCounts_Transfer(t *testing.T) {
+	src, srcMr := setupTestQueue(t)
+	defer srcMr.Close()
+	defer func() { _ = src.Close() }()
+
+	dest, destMr := setupTestQueue(t)
+	defer destMr.Close()
+	defer func() { _ = dest.Close() }()
+
+	tsk := task.NewTask("counted", nil, task.MediumPriority)
+	require.NoError(t, src.Enqueue(tsk))
+	require.NoError(t, src.Transfer(tsk.ID, dest))
+
+	srcCounts, err := src.StatusCounts()
 	require.NoError(t, err)
+	assert.Empty(t, srcCounts)
 
-	cancelled, err := q.IsCancelled(tsk.ID)
+	destCounts, err := dest.StatusCounts()
 	require.NoError(t, err)
-	assert.True(t, cancelled)
+	assert.Equal(t, map[task.TaskStatus]int{task.PendingStatus: 1}, destCounts)
+}
 
-	err = q.CancelTask(tsk.ID)
-	assert.Error(t, err)
+func TestReclaimExpired(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetVisibilityTimeout(time.Millisecond)
+
+	dropped := task.NewTask("test_task", nil, task.MediumPriority)
+	settled := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dropped))
+	require.NoError(t, q.Enqueue(settled))
+
+	claimed, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	require.Equal(t, dropped.ID, claimed.ID)
+
+	done, err := q.Dequeue()
+	require.NoError(t, err)
+	require.Equal(t, settled.ID, done.ID)
+	require.NoError(t, q.Settle(done.ID))
+
+	depth, err := q.Depth()
+	require.NoError(t, err)
+	assert.Equal(t, 0, depth)
+
+	time.Sleep(5 * time.Millisecond)
+
+	reclaimed, err := q.ReclaimExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 1, reclaimed)
+
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, leases)
+
+	counts, err := q.StatusCounts()
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts[task.PendingStatus])
+
+	requeued, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, requeued)
+	assert.Equal(t, dropped.ID, requeued.ID)
+}
+
+func TestReclaimExpired_WithinTimeout(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	reclaimed, err := q.ReclaimExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+}
+
+func TestAck(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	require.NoError(t, q.Ack(tsk.ID))
+
+	acked, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, acked.Status)
+	assert.NotNil(t, acked.CompletedAt)
+
+	reclaimed, err := q.ReclaimExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+
+	assert.ErrorIs(t, q.Ack(tsk.ID), ErrTaskNotInFlight, "a task can only be acked once")
+}
+
+func TestAck_LeasedTask(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Claim("worker-1")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, q.Ack(tsk.ID), ErrTaskLeased)
+	assert.ErrorIs(t, q.Nack(tsk.ID, true), ErrTaskLeased)
+
+	inFlight, err := mr.ZMembers("inflight")
+	require.NoError(t, err)
+	assert.Equal(t, []string{tsk.ID}, inFlight, "the worker keeps its task")
+	assert.True(t, mr.Exists("inflight:"+tsk.ID))
+
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, leases)
+}
+
+func TestRequeue_Completed(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	retryDelay := 30
+	original := task.NewTask("test_task", map[string]any{"key": "value"}, task.HighPriority)
+	original.Tags = []string{"replay"}
+	original.RetryDelaySeconds = &retryDelay
+	require.NoError(t, q.Enqueue(original))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NoError(t, q.Ack(original.ID))
+
+	requeued, err := q.Requeue(original.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, requeued.ID)
+	assert.Equal(t, task.PendingStatus, requeued.Status)
+	assert.Equal(t, 0, requeued.RetryCount)
+	assert.Nil(t, requeued.StartedAt)
+	assert.Nil(t, requeued.CompletedAt)
+	assert.Equal(t, original.Type, requeued.Type)
+	assert.Equal(t, original.Payload, requeued.Payload)
+	assert.Equal(t, original.Priority, requeued.Priority)
+	assert.Equal(t, original.Tags, requeued.Tags)
+	assert.Equal(t, original.RetryDelaySeconds, requeued.RetryDelaySeconds)
+
+	kept, err := q.GetTask(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, kept.Status)
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, requeued.ID, dequeued.ID)
+}
+
+func TestRequeue_Failed(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	failed := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(failed))
+	failed.Status = task.FailedStatus
+	failed.RetryCount = 3
+	failed.Error = "boom"
+	require.NoError(t, q.UpdateTask(failed))
+
+	requeued, err := q.Requeue(failed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, requeued.RetryCount)
+	assert.Empty(t, requeued.Error)
+
+	dropped := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(dropped))
+	for {
+		claimed, err := q.Dequeue()
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		if claimed.ID == dropped.ID {
+			break
+		}
+	}
+	require.NoError(t, q.Nack(dropped.ID, false))
+
+	_, err = q.Requeue(dropped.ID)
+	require.NoError(t, err, "dead-lettered tasks can be requeued")
+}
+
+func TestRequeue_NotFinished(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	pending := task.NewTask("test_task", nil, task.MediumPriority)
+	running := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(running))
+	require.NoError(t, q.Enqueue(pending))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	_, err = q.Requeue(pending.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFinished)
+	_, err = q.Requeue(running.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFinished)
+	_, err = q.Requeue("missing")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestNack_Requeue(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	require.NoError(t, q.Nack(tsk.ID, true))
+
+	requeued, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, requeued)
+	assert.Equal(t, tsk.ID, requeued.ID)
+	assert.Equal(t, 1, requeued.RetryCount)
+	assert.Nil(t, requeued.StartedAt)
+
+	dlq, err := q.GetDeadLetterTasks()
+	require.NoError(t, err)
+	assert.Empty(t, dlq)
+}
+
+func TestNack_Drop(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	require.NoError(t, q.Nack(tsk.ID, false))
+
+	next, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, next)
+
+	dropped, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.DeadLetterStatus, dropped.Status)
+	assert.Equal(t, "negatively acknowledged", dropped.FailureReason)
+}
+
+func TestNack_RequeueWithoutRetriesLeft(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 1
+	require.NoError(t, q.Enqueue(tsk))
+	_, err := q.Dequeue()
+	require.NoError(t, err)
+
+	require.NoError(t, q.Nack(tsk.ID, true))
+
+	_, err = q.GetDeadLetterTask(tsk.ID)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, q.Nack(tsk.ID, true), ErrTaskNotInFlight)
+}
+
+func TestTaskTypes(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	require.NoError(t, q.RegisterTaskTypes("send_email", "generate_report"))
+	require.NoError(t, q.RegisterTaskTypes("send_email"))
+
+	known, err := q.IsKnownTaskType("send_email")
+	require.NoError(t, err)
+	assert.True(t, known)
+
+	known, err = q.IsKnownTaskType("fly_to_mars")
+	require.NoError(t, err)
+	assert.False(t, known)
+
+	types, err := q.KnownTaskTypes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"generate_report", "send_email"}, types)
+}
+
+func TestActiveWorkers(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	now := time.Now()
+	require.NoError(t, q.Heartbeat(WorkerInfo{ID: "worker-b", LastSeen: now}, time.Minute))
+	require.NoError(t, q.Heartbeat(WorkerInfo{ID: "worker-a", LastSeen: now, CurrentTask: "task-1"}, time.Minute))
+	require.NoError(t, q.Heartbeat(WorkerInfo{ID: "worker-expired", LastSeen: now}, time.Second))
+
+	mr.FastForward(2 * time.Second)
+
+	workers, err := q.ActiveWorkers()
+	require.NoError(t, err)
+	require.Len(t, workers, 2)
+	assert.Equal(t, "worker-a", workers[0].ID)
+	assert.Equal(t, "task-1", workers[0].CurrentTask)
+	assert.Equal(t, "worker-b", workers[1].ID)
+	assert.Empty(t, workers[1].CurrentTask)
+
+	isMember, err := q.backend.SIsMember(q.ctx, "workers:index", "worker-expired")
+	require.NoError(t, err)
+	assert.False(t, isMember, "expired workers should be dropped from the index")
+
+	require.NoError(t, q.RemoveWorker("worker-b"))
+	workers, err = q.ActiveWorkers()
+	require.NoError(t, err)
+	require.Len(t, workers, 1)
+	assert.Equal(t, "worker-a", workers[0].ID)
+}
+
+func TestIndexes_StayInSync(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	members := func(key string) []string {
+		t.Helper()
+		if !mr.Exists(key) {
+			return nil
+		}
+		ids, err := mr.Members(key)
+		require.NoError(t, err)
+		return ids
+	}
+
+	first := task.NewTask("indexed", nil, task.MediumPriority)
+	second := task.NewTask("indexed", nil, task.MediumPriority)
+	third := task.NewTask("indexed", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(first))
+	require.NoError(t, q.Enqueue(second))
+	require.NoError(t, q.Enqueue(third))
+	assert.ElementsMatch(t, []string{first.ID, second.ID, third.ID}, members("tasks:index"))
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{second.ID, third.ID}, members("tasks:index"))
+
+	dequeued.Status = task.CompletedStatus
+	require.NoError(t, q.UpdateTask(dequeued))
+	assert.ElementsMatch(t, []string{first.ID, second.ID, third.ID}, members("tasks:index"))
+
+	require.NoError(t, q.MoveToDeadLetter(second, "boom"))
+	assert.Equal(t, []string{second.ID}, members("dlq:index"))
+
+	stats, err := q.GetDeadLetterStats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats["total_tasks"])
+
+	require.NoError(t, q.PurgeDeadLetterTask(second.ID))
+	assert.Empty(t, members("dlq:index"))
+	assert.ElementsMatch(t, []string{first.ID, third.ID}, members("tasks:index"))
+
+	require.NoError(t, q.MoveToDeadLetter(third, "boom"))
+	require.NoError(t, q.RetryDeadLetterTask(third.ID))
+	assert.Empty(t, members("dlq:index"))
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+}
+
+func TestIndexes_DropStaleIDs(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("indexed", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	mr.Del("task:" + tsk.ID)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+	assert.False(t, mr.Exists("tasks:index"))
+}
+
+func TestRebuildIndexes(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	pending := task.NewTask("indexed", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(pending))
+	dead := task.NewTask("indexed", nil, task.MediumPriority)
+	require.NoError(t, q.MoveToDeadLetter(dead, "boom"))
+
+	mr.Del("tasks:index")
+	mr.Del("dlq:index")
+
+	require.NoError(t, q.RebuildIndexes())
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, pending.ID, tasks[0].ID)
+
+	dlqTasks, err := q.GetDeadLetterTasks()
+	require.NoError(t, err)
+	require.Len(t, dlqTasks, 1)
+	assert.Equal(t, dead.ID, dlqTasks[0].ID)
+}
+
+func TestRefreshMetrics_DelayedTasks(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	ready := task.NewTask("ready_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(ready))
+
+	delayed := task.NewTask("delayed_task", map[string]any{}, task.MediumPriority)
+	require.NoError(t, q.Enqueue(delayed))
+	require.NoError(t, q.Defer(delayed, time.Hour))
+
+	// Due but not promoted yet: it is about to run, not delayed.
+	due := task.NewTask("due_task", map[string]any{}, task.MediumPriority)
+	due.ScheduledAt = time.Now().Add(-time.Second)
+	require.NoError(t, q.UpdateTask(due))
+	_, err := mr.ZAdd("scheduled", float64(due.ScheduledAt.UnixMilli()), due.ID)
+	require.NoError(t, err)
+
+	snapshot, err := q.RefreshMetrics()
+	require.NoError(t, err)
+	assert.Equal(t, 1, snapshot.DelayedTasks)
+
+	metric := &dto.Metric{}
+	require.NoError(t, metrics.DelayedTasks.Write(metric))
+	assert.Equal(t, 1.0, metric.Gauge.GetValue())
+}
+
+func TestDequeue_DropsExpiredTask(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	before := testutil.ToFloat64(metrics.TasksExpired.WithLabelValues("stale_task"))
+
+	stale := task.NewTask("stale_task", nil, task.MediumPriority)
+	expiresAt := time.Now().Add(-time.Second)
+	stale.ExpiresAt = &expiresAt
+	require.NoError(t, q.Enqueue(stale))
+	fresh := task.NewTask("fresh_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(fresh))
+
+	peeked, err := q.Peek(10)
+	require.NoError(t, err)
+	require.Len(t, peeked, 1)
+	assert.Equal(t, fresh.ID, peeked[0].ID)
+
+	next, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, fresh.ID, next.ID, "the expired task is skipped")
+
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.TasksExpired.WithLabelValues("stale_task")))
+	assert.False(t, mr.Exists("task:"+stale.ID))
+	status, _ := mockRepo.GetTaskStatus(stale.ID)
+	assert.Equal(t, task.FailedStatus, status)
+}
+
+func TestDeadLetterWorkflow(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("workflow_task", map[string]any{"data": "test"}, task.HighPriority)
+	err := q.Enqueue(tsk)
+	require.NoError(t, err)
+
+	err = q.MoveToDeadLetter(tsk, "max retries exceeded")
+	require.NoError(t, err)
+
+	dlqTasks, err := q.GetDeadLetterTasks()
+	require.NoError(t, err)
+	assert.Len(t, dlqTasks, 1)
+
+	stats, err := q.GetDeadLetterStats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats["total_tasks"])
+
+	err = q.RetryDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+
+	dlqTasks, err = q.GetDeadLetterTasks()
+	require.NoError(t, err)
+	assert.Len(t, dlqTasks, 0)
+
+	retrieved, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, retrieved.Status)
+}
+
+func TestCancelAndRetryWorkflow(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("cancel_test", map[string]any{}, task.MediumPriority)
+	err := q.Enqueue(tsk)
+	require.NoError(t, err)
+
+	err = q.CancelTask(tsk.ID)
+	require.NoError(t, err)
+
+	cancelled, err := q.IsCancelled(tsk.ID)
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	err = q.CancelTask(tsk.ID)
+	assert.Error(t, err)
+}
+
+func TestRecurringTaskStorage(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("0 * * * *", "cleanup", nil, task.LowPriority)
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	stored, err := q.GetRecurringTask(rt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "0 * * * *", stored.Cron)
+
+	schedules, err := q.GetRecurringTasks()
+	require.NoError(t, err)
+	assert.Len(t, schedules, 1)
+
+	deleted, err := q.DeleteRecurringTask(rt.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = q.DeleteRecurringTask(rt.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	schedules, err = q.GetRecurringTasks()
+	require.NoError(t, err)
+	assert.Len(t, schedules, 0)
+}
+
+func TestDependenciesReady(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	parent := task.NewTask("parent", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(parent))
+
+	child := task.NewTask("child", nil, task.MediumPriority)
+	child.DependsOn = []string{parent.ID}
+
+	ready, err := q.DependenciesReady(child)
+	require.NoError(t, err)
+	assert.False(t, ready)
+
+	parent.Status = task.CompletedStatus
+	require.NoError(t, q.UpdateTask(parent))
+
+	ready, err = q.DependenciesReady(child)
+	require.NoError(t, err)
+	assert.True(t, ready)
+
+	parent.Status = task.FailedStatus
+	require.NoError(t, q.UpdateTask(parent))
+
+	ready, err = q.DependenciesReady(child)
+	assert.Error(t, err)
+	assert.False(t, ready)
+
+	orphan := task.NewTask("child", nil, task.MediumPriority)
+	orphan.DependsOn = []string{"missing"}
+
+	ready, err = q.DependenciesReady(orphan)
+	assert.ErrorContains(t, err, "dependency missing not found")
+	assert.False(t, ready)
+}
+
+func TestDefer(t *testing.T) {
+	q, mockRepo, mr := setupTestQueueWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+
+	before := time.Now()
+	require.NoError(t, q.Defer(dequeued, 100*time.Millisecond))
+	assert.True(t, dequeued.ScheduledAt.After(before.Add(90*time.Millisecond)))
+
+	status, _ := mockRepo.GetTaskStatus(tsk.ID)
+	assert.Equal(t, task.PendingStatus, status)
+
+	again, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, again, "a deferred task is not dequeued before its delay")
+
+	time.Sleep(100 * time.Millisecond)
+
+	again, err = q.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, again)
+	assert.Equal(t, tsk.ID, again.ID)
+	assert.Equal(t, 1, mockRepo.GetSaveTaskCallCount())
+}
+
+func TestClaim_LeaseLimit(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxLeasesPerWorker(2)
+
+	for range 4 {
+		require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	}
+
+	first, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, first)
+
+	second, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, second)
+
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, leases)
+
+	_, err = q.Claim("worker-1")
+	assert.ErrorIs(t, err, ErrLeaseLimitReached)
+
+	other, err := q.Claim("worker-2")
+	require.NoError(t, err)
+	assert.NotNil(t, other)
+
+	require.NoError(t, q.ReleaseLease(first.ID))
+
+	third, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	assert.NotNil(t, third)
+}
+
+func TestClaim_LeaseLimitConcurrent(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxLeasesPerWorker(3)
+
+	for range 10 {
+		require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed int
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tsk, err := q.Claim("worker-1")
+			if err != nil {
+				assert.ErrorIs(t, err, ErrLeaseLimitReached)
+				return
+			}
+			if tsk != nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, claimed)
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, leases)
+}
+
+func TestClaim_EmptyQueueReturnsLease(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxLeasesPerWorker(1)
+
+	tsk, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, tsk)
+
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, leases)
+}
+
+func TestClaim_LeasesExpireWithHeartbeat(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxLeasesPerWorker(1)
+	require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+
+	claimed, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	require.NoError(t, q.Heartbeat(WorkerInfo{ID: "worker-1"}, 15*time.Second))
+
+	_, err = q.Claim("worker-1")
+	assert.ErrorIs(t, err, ErrLeaseLimitReached)
+
+	mr.FastForward(16 * time.Second)
+
+	next, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	assert.NotNil(t, next)
+}
+
+func TestReleaseLease(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+
+	claimed, err := q.Claim("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	require.NoError(t, q.ReleaseLease(claimed.ID))
+	require.NoError(t, q.ReleaseLease(claimed.ID))
+
+	leases, err := q.LeaseCount("worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, leases)
+	assert.False(t, mr.Exists("lease:"+claimed.ID))
+}
+
+func TestClaim_UnlimitedByDefault(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	for range 3 {
+		require.NoError(t, q.Enqueue(task.NewTask("test_task", nil, task.MediumPriority)))
+	}
+
+	for range 3 {
+		claimed, err := q.Claim("worker-1")
+		require.NoError(t, err)
+		assert.NotNil(t, claimed)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	src, srcMr := setupTestQueue(t)
+	defer srcMr.Close()
+	defer func() { _ = src.Close() }()
+
+	dest, destMr := setupTestQueue(t)
+	defer destMr.Close()
+	defer func() { _ = dest.Close() }()
+
+	staying := task.NewTask("test_task", nil, task.MediumPriority)
+	moving := task.NewTask("test_task", map[string]any{"key": "value"}, task.HighPriority)
+	require.NoError(t, src.Enqueue(staying))
+	require.NoError(t, src.Enqueue(moving))
+
+	require.NoError(t, src.Transfer(moving.ID, dest))
+
+	_, err := src.GetTask(moving.ID)
+	assert.Error(t, err)
+
+	transferred, err := dest.GetTask(moving.ID)
+	require.NoError(t, err)
+	assert.Equal(t, moving.Payload, transferred.Payload)
+	assert.Equal(t, task.HighPriority, transferred.Priority)
+
+	dequeued, err := dest.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, moving.ID, dequeued.ID)
+
+	dequeued, err = src.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, staying.ID, dequeued.ID)
+
+	dequeued, err = src.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, dequeued)
+}
+
+func TestTransfer_SharedBackend(t *testing.T) {
+	src, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = src.Close() }()
+
+	dest, err := NewQueue(mr.Addr(), nil)
+	require.NoError(t, err)
+	defer func() { _ = dest.Close() }()
+
+	tsk := task.NewTask("test_task", map[string]any{"key": "value"}, task.MediumPriority)
+	require.NoError(t, src.Enqueue(tsk))
+
+	require.NoError(t, src.Transfer(tsk.ID, dest))
+
+	transferred, err := dest.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tsk.Payload, transferred.Payload)
+
+	counts, err := dest.StatusCounts()
+	require.NoError(t, err)
+	assert.Equal(t, map[task.TaskStatus]int{task.PendingStatus: 1}, counts)
+
+	dequeued, err := dest.Dequeue()
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+	assert.Equal(t, tsk.ID, dequeued.ID)
+
+	dequeued, err = src.Dequeue()
+	require.NoError(t, err)
+	assert.Nil(t, dequeued, "the task is queued once")
+}
+
+func TestTransfer_ScheduledTask(t *testing.T) {
+	src, srcMr := setupTestQueue(t)
+	defer srcMr.Close()
+	defer func() { _ = src.Close() }()
+
+	dest, destMr := setupTestQueue(t)
+	defer destMr.Close()
+	defer func() { _ = dest.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.ScheduledAt = time.Now().Add(time.Hour)
+	require.NoError(t, src.Enqueue(tsk))
+
+	require.NoError(t, src.Transfer(tsk.ID, dest))
+
+	assert.False(t, srcMr.Exists("task:"+tsk.ID))
+	members, err := destMr.ZMembers("scheduled")
+	require.NoError(t, err)
+	assert.Equal(t, []string{tsk.ID}, members)
+}
+
+func TestDeleteTask(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	t.Run("pending task", func(t *testing.T) {
+		doomed := task.NewTask("test_task", nil, task.MediumPriority)
+		kept := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(doomed))
+		require.NoError(t, q.Enqueue(kept))
+
+		itemKey, err := q.findItemKey(doomed.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, itemKey)
+
+		require.NoError(t, q.DeleteTask(doomed.ID))
+
+		_, err = q.GetTask(doomed.ID)
+		assert.ErrorIs(t, err, ErrTaskNotFound)
+		assert.False(t, mr.Exists(itemKey))
+
+		tasks, err := q.GetAllTasks()
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, kept.ID, tasks[0].ID)
+
+		next, err := q.Dequeue()
+		require.NoError(t, err)
+		require.NotNil(t, next)
+		assert.Equal(t, kept.ID, next.ID)
+	})
+
+	t.Run("leased task", func(t *testing.T) {
+		tsk := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+
+		claimed, err := q.Claim("worker-1")
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		require.NoError(t, q.UpdateTask(claimed))
+
+		require.NoError(t, q.DeleteTask(tsk.ID))
+
+		assert.False(t, mr.Exists("lease:"+tsk.ID))
+		leases, err := q.LeaseCount("worker-1")
+		require.NoError(t, err)
+		assert.Equal(t, 0, leases)
+	})
+
+	t.Run("dead letter task", func(t *testing.T) {
+		tsk := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, q.MoveToDeadLetter(tsk, "failed"))
+
+		itemKey, err := q.findDeadLetterItemKey(tsk.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, itemKey)
+
+		require.NoError(t, q.DeleteTask(tsk.ID))
+
+		_, err = q.GetDeadLetterTask(tsk.ID)
+		assert.Error(t, err)
+		assert.False(t, mr.Exists(itemKey))
+
+		dlq, err := q.GetDeadLetterTasks()
+		require.NoError(t, err)
+		assert.Empty(t, dlq)
+	})
+
+	t.Run("stale index and status entries", func(t *testing.T) {
+		tsk := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, q.Enqueue(tsk))
+		mr.Del("task:" + tsk.ID)
+		mr.Del("queue:pos:" + tsk.ID)
+
+		assert.ErrorIs(t, q.DeleteTask(tsk.ID), ErrTaskNotFound)
+
+		indexed, _ := mr.Members("tasks:index")
+		assert.NotContains(t, indexed, tsk.ID)
+		assert.False(t, mr.Exists("status:"+tsk.ID))
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		assert.ErrorIs(t, q.DeleteTask("non-existent-id"), ErrTaskNotFound)
+	})
+}
+
+func TestFindItemKey_FollowsPosition(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	itemKey, err := q.findItemKey(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "queue:item:1", itemKey)
+
+	_, err = q.Dequeue()
+	require.NoError(t, err)
+	assert.False(t, mr.Exists("queue:pos:"+tsk.ID), "dequeuing drops the position")
+
+	require.NoError(t, mr.Set("queue:pos:"+tsk.ID, "queue:item:1"))
+	itemKey, err = q.findItemKey(tsk.ID)
+	require.NoError(t, err)
+	assert.Empty(t, itemKey, "a position pointing at a consumed slot is ignored")
+}
+
+func TestCancelTask_RemovesQueueSlot(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	scheduled := task.NewTask("test_task", nil, task.MediumPriority)
+	scheduled.ScheduledAt = time.Now().Add(time.Hour)
+	require.NoError(t, q.Enqueue(scheduled))
+
+	require.NoError(t, q.CancelTask(tsk.ID))
+	require.NoError(t, q.CancelTask(scheduled.ID))
+
+	assert.False(t, mr.Exists("queue:item:1"))
+	assert.False(t, mr.Exists("queue:pos:"+tsk.ID))
+	scheduledIDs, _ := mr.ZMembers("scheduled")
+	assert.Empty(t, scheduledIDs)
+
+	depth, err := q.Depth()
+	require.NoError(t, err)
+	assert.Equal(t, 0, depth)
+
+	for _, id := range []string{tsk.ID, scheduled.ID} {
+		cancelled, err := q.GetTask(id)
+		require.NoError(t, err)
+		assert.Equal(t, task.CancelledStatus, cancelled.Status)
+	}
+}
+
+func TestTransfer_Errors(t *testing.T) {
+	src, srcMr := setupTestQueue(t)
+	defer srcMr.Close()
+	defer func() { _ = src.Close() }()
+
+	dest, destMr := setupTestQueue(t)
+	defer destMr.Close()
+	defer func() { _ = dest.Close() }()
+
+	t.Run("unknown task", func(t *testing.T) {
+		assert.Error(t, src.Transfer("missing", dest))
+	})
+
+	t.Run("same queue", func(t *testing.T) {
+		tsk := task.NewTask("test_task", nil, task.MediumPriority)
+		require.NoError(t, src.Enqueue(tsk))
+
+		assert.Error(t, src.Transfer(tsk.ID, src))
+	})
+
+	t.Run("task no longer pending", func(t *testing.T) {
+		tsk := task.NewTask("test_task", nil, task.MediumPriority)
+		tsk.Status = task.CompletedStatus
+		require.NoError(t, src.UpdateTask(tsk))
+
+		assert.Error(t, src.Transfer(tsk.ID, dest))
+
+		_, err := dest.GetTask(tsk.ID)
+		assert.Error(t, err)
+	})
 }
@@ -4,8 +4,12 @@ package mocks
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/nadmax/nexq/internal/repository"
 	"github.com/nadmax/nexq/internal/repository/models"
 	"github.com/nadmax/nexq/internal/task"
 )
@@ -20,11 +24,15 @@ type MockPostgresRepository struct {
 	MoveTaskToDLQCalls    []MoveTaskToDLQCall
 	IncrementRetryCalls   []string
 	LogExecutionCalls     []LogExecutionCall
+	StartTaskCalls        []LogExecutionCall
 	Tasks                 map[string]*task.Task
 	ExecutionLog          []LogExecutionCall
 	TaskStats             []models.TaskStats
 	RecentTasks           []models.RecentTask
+	DurationOutliers      []models.DurationOutlier
+	TaskStatsHours        []int
 	GetTaskError          error
+	GetDeadLetterError    error
 	SaveTaskError         error
 	CompleteTaskError     error
 	FailTaskError         error
@@ -35,6 +43,16 @@ type MockPostgresRepository struct {
 	GetRecentTasksError   error
 	GetTaskHistoryError   error
 	GetTasksByTypeError   error
+	GetTasksByTagError    error
+	GetOutliersError      error
+	SearchByErrorError    error
+	PruneCalls            []time.Duration
+	PruneResult           int64
+	PruneError            error
+	CloseCalls            int
+	CloseError            error
+	// SaveTaskGate, when set, blocks SaveTask until the channel is closed.
+	SaveTaskGate chan struct{}
 }
 
 type SaveTaskCall struct {
@@ -55,12 +73,14 @@ type CompleteTaskCall struct {
 type FailTaskCall struct {
 	TaskID     string
 	Reason     string
+	Category   task.FailureCategory
 	DurationMs int
 }
 
 type MoveTaskToDLQCall struct {
-	TaskID string
-	Reason string
+	TaskID   string
+	Reason   string
+	Category task.FailureCategory
 }
 
 type LogExecutionCall struct {
@@ -93,14 +113,40 @@ func (m *MockPostgresRepository) GetTask(ctx context.Context, taskID string) (*t
 
 	t, exists := m.Tasks[taskID]
 	if !exists {
-		return nil, fmt.Errorf("task not found: %s", taskID)
+		return nil, fmt.Errorf("%w: %s", repository.ErrTaskNotFound, taskID)
 	}
 
 	taskCopy := *t
 	return &taskCopy, nil
 }
 
+func (m *MockPostgresRepository) GetDeadLetterTasks(ctx context.Context, limit int) ([]*task.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.GetDeadLetterError != nil {
+		return nil, m.GetDeadLetterError
+	}
+
+	var tasks []*task.Task
+	for _, t := range m.Tasks {
+		if t.Status == task.DeadLetterStatus {
+			taskCopy := *t
+			tasks = append(tasks, &taskCopy)
+			if len(tasks) >= limit {
+				break
+			}
+		}
+	}
+
+	return tasks, nil
+}
+
 func (m *MockPostgresRepository) SaveTask(ctx context.Context, t *task.Task) error {
+	if m.SaveTaskGate != nil {
+		<-m.SaveTaskGate
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -152,13 +198,14 @@ func (m *MockPostgresRepository) CompleteTask(ctx context.Context, taskID string
 	return nil
 }
 
-func (m *MockPostgresRepository) FailTask(ctx context.Context, taskID string, reason string, durationMs int) error {
+func (m *MockPostgresRepository) FailTask(ctx context.Context, taskID string, reason string, category task.FailureCategory, durationMs int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.FailTaskCalls = append(m.FailTaskCalls, FailTaskCall{
 		TaskID:     taskID,
 		Reason:     reason,
+		Category:   category,
 		DurationMs: durationMs,
 	})
 
@@ -169,18 +216,20 @@ func (m *MockPostgresRepository) FailTask(ctx context.Context, taskID string, re
 	if t, exists := m.Tasks[taskID]; exists {
 		t.Status = task.FailedStatus
 		t.FailureReason = reason
+		t.FailureCategory = category
 	}
 
 	return nil
 }
 
-func (m *MockPostgresRepository) MoveTaskToDLQ(ctx context.Context, taskID string, reason string) error {
+func (m *MockPostgresRepository) MoveTaskToDLQ(ctx context.Context, taskID string, reason string, category task.FailureCategory) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.MoveTaskToDLQCalls = append(m.MoveTaskToDLQCalls, MoveTaskToDLQCall{
-		TaskID: taskID,
-		Reason: reason,
+		TaskID:   taskID,
+		Reason:   reason,
+		Category: category,
 	})
 
 	if m.MoveTaskToDLQError != nil {
@@ -190,6 +239,7 @@ func (m *MockPostgresRepository) MoveTaskToDLQ(ctx context.Context, taskID strin
 	if t, exists := m.Tasks[taskID]; exists {
 		t.Status = task.DeadLetterStatus
 		t.FailureReason = reason
+		t.FailureCategory = category
 	}
 
 	return nil
@@ -235,10 +285,39 @@ func (m *MockPostgresRepository) LogExecution(ctx context.Context, taskID string
 	return nil
 }
 
+// StartTask records the start in the execution log like LogExecution, so
+// tests reading the log see the same entries either way.
+func (m *MockPostgresRepository) StartTask(ctx context.Context, taskID string, attemptNumber int, workerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	call := LogExecutionCall{
+		TaskID:        taskID,
+		AttemptNumber: attemptNumber,
+		Status:        string(task.RunningStatus),
+		WorkerID:      workerID,
+	}
+
+	m.StartTaskCalls = append(m.StartTaskCalls, call)
+	m.ExecutionLog = append(m.ExecutionLog, call)
+
+	if m.LogExecutionError != nil {
+		return m.LogExecutionError
+	}
+
+	if t, exists := m.Tasks[taskID]; exists {
+		t.Status = task.RunningStatus
+	}
+
+	return nil
+}
+
 func (m *MockPostgresRepository) GetTaskStats(ctx context.Context, hours int) ([]models.TaskStats, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.TaskStatsHours = append(m.TaskStatsHours, hours)
+
 	if m.GetTaskStatsError != nil {
 		return nil, m.GetTaskStatsError
 	}
@@ -246,7 +325,22 @@ func (m *MockPostgresRepository) GetTaskStats(ctx context.Context, hours int) ([
 	return m.TaskStats, nil
 }
 
-func (m *MockPostgresRepository) GetRecentTasks(ctx context.Context, limit int) ([]models.RecentTask, error) {
+func (m *MockPostgresRepository) GetDurationOutliers(ctx context.Context, hours int, limit int) ([]models.DurationOutlier, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.GetOutliersError != nil {
+		return nil, m.GetOutliersError
+	}
+
+	if len(m.DurationOutliers) > limit {
+		return m.DurationOutliers[:limit], nil
+	}
+
+	return m.DurationOutliers, nil
+}
+
+func (m *MockPostgresRepository) GetRecentTasks(ctx context.Context, limit int, offset int) ([]models.RecentTask, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -254,14 +348,21 @@ func (m *MockPostgresRepository) GetRecentTasks(ctx context.Context, limit int)
 		return nil, m.GetRecentTasksError
 	}
 
-	if len(m.RecentTasks) > limit {
-		return m.RecentTasks[:limit], nil
+	return page(m.RecentTasks, limit, offset), nil
+}
+
+func (m *MockPostgresRepository) CountTasks(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.GetRecentTasksError != nil {
+		return 0, m.GetRecentTasksError
 	}
 
-	return m.RecentTasks, nil
+	return len(m.RecentTasks), nil
 }
 
-func (m *MockPostgresRepository) GetTasksByType(ctx context.Context, taskType string, limit int) ([]models.RecentTask, error) {
+func (m *MockPostgresRepository) GetTasksByType(ctx context.Context, taskType string, limit int, offset int) ([]models.RecentTask, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -269,10 +370,77 @@ func (m *MockPostgresRepository) GetTasksByType(ctx context.Context, taskType st
 		return nil, m.GetTasksByTypeError
 	}
 
+	return page(m.tasksOfType(taskType), limit, offset), nil
+}
+
+func (m *MockPostgresRepository) CountTasksByType(ctx context.Context, taskType string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.GetTasksByTypeError != nil {
+		return 0, m.GetTasksByTypeError
+	}
+
+	return len(m.tasksOfType(taskType)), nil
+}
+
+func (m *MockPostgresRepository) tasksOfType(taskType string) []models.RecentTask {
 	var filtered []models.RecentTask
 	for _, task := range m.RecentTasks {
 		if task.Type == taskType {
 			filtered = append(filtered, task)
+		}
+	}
+
+	return filtered
+}
+
+func page(tasks []models.RecentTask, limit int, offset int) []models.RecentTask {
+	if offset >= len(tasks) {
+		return nil
+	}
+
+	tasks = tasks[offset:]
+	if len(tasks) > limit {
+		return tasks[:limit]
+	}
+
+	return tasks
+}
+
+func (m *MockPostgresRepository) GetTasksByTag(ctx context.Context, tag string, limit int) ([]models.RecentTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.GetTasksByTagError != nil {
+		return nil, m.GetTasksByTagError
+	}
+
+	var filtered []models.RecentTask
+	for _, task := range m.RecentTasks {
+		if slices.Contains(task.Tags, tag) {
+			filtered = append(filtered, task)
+			if len(filtered) >= limit {
+				break
+			}
+		}
+	}
+
+	return filtered, nil
+}
+
+func (m *MockPostgresRepository) SearchTasksByError(ctx context.Context, substring string, limit int) ([]models.RecentTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SearchByErrorError != nil {
+		return nil, m.SearchByErrorError
+	}
+
+	var filtered []models.RecentTask
+	for _, task := range m.RecentTasks {
+		if task.FailureReason != "" && strings.Contains(strings.ToLower(task.FailureReason), strings.ToLower(substring)) {
+			filtered = append(filtered, task)
 			if len(filtered) >= limit {
 				break
 			}
@@ -308,8 +476,25 @@ func (m *MockPostgresRepository) GetTaskHistory(ctx context.Context, taskID stri
 	return history, nil
 }
 
+func (m *MockPostgresRepository) PruneTaskHistory(ctx context.Context, olderThan time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.PruneCalls = append(m.PruneCalls, olderThan)
+
+	if m.PruneError != nil {
+		return 0, m.PruneError
+	}
+
+	return m.PruneResult, nil
+}
+
 func (m *MockPostgresRepository) Close() error {
-	return nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CloseCalls++
+	return m.CloseError
 }
 
 func (m *MockPostgresRepository) GetSaveTaskCallCount() int {
@@ -340,6 +525,13 @@ func (m *MockPostgresRepository) GetLogExecutionCallCount() int {
 	return len(m.LogExecutionCalls)
 }
 
+func (m *MockPostgresRepository) GetStartTaskCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.StartTaskCalls)
+}
+
 func (m *MockPostgresRepository) WasTaskSaved(taskID string) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -405,6 +597,7 @@ func (m *MockPostgresRepository) Reset() {
 	m.MoveTaskToDLQCalls = nil
 	m.IncrementRetryCalls = nil
 	m.LogExecutionCalls = nil
+	m.StartTaskCalls = nil
 	m.Tasks = make(map[string]*task.Task)
 	m.ExecutionLog = nil
 }
@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nadmax/nexq/internal/repository"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockPostgresRepository_ImplementsTaskRepository(t *testing.T) {
+	var repo repository.TaskRepository = NewMockPostgresRepository()
+	assert.NotNil(t, repo)
+}
+
+func TestMockGetDeadLetterTasks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns only dead letter tasks", func(t *testing.T) {
+		repo := NewMockPostgresRepository()
+
+		dead := task.NewTask("email", nil, task.MediumPriority)
+		dead.Status = task.DeadLetterStatus
+		pending := task.NewTask("email", nil, task.MediumPriority)
+		require.NoError(t, repo.SaveTask(ctx, dead))
+		require.NoError(t, repo.SaveTask(ctx, pending))
+
+		tasks, err := repo.GetDeadLetterTasks(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, dead.ID, tasks[0].ID)
+	})
+
+	t.Run("returns configured error", func(t *testing.T) {
+		repo := NewMockPostgresRepository()
+		repo.GetDeadLetterError = errors.New("boom")
+
+		_, err := repo.GetDeadLetterTasks(ctx, 10)
+		assert.Error(t, err)
+	})
+}
@@ -22,4 +22,14 @@ type RecentTask struct {
 	DurationMs    *int       `json:"duration_ms,omitempty"`
 	RetryCount    int        `json:"retry_count"`
 	FailureReason string     `json:"failure_reason,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+}
+
+type DurationOutlier struct {
+	TaskID             string    `json:"task_id"`
+	Type               string    `json:"type"`
+	CreatedAt          time.Time `json:"created_at"`
+	ExpectedDurationMs int       `json:"expected_duration_ms"`
+	DurationMs         int       `json:"duration_ms"`
+	BreachMs           int       `json:"breach_ms"`
 }
@@ -5,20 +5,70 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/nadmax/nexq/internal/encryption"
+	"github.com/nadmax/nexq/internal/repository"
 	"github.com/nadmax/nexq/internal/repository/models"
 	"github.com/nadmax/nexq/internal/task"
 )
 
 type PostgresTaskRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher *encryption.PayloadCipher
+}
+
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+	}
+}
+
+func (o PoolOptions) Validate() error {
+	if o.MaxOpenConns <= 0 {
+		return fmt.Errorf("max open connections must be positive, got %d", o.MaxOpenConns)
+	}
+	if o.MaxIdleConns < 0 {
+		return fmt.Errorf("max idle connections must not be negative, got %d", o.MaxIdleConns)
+	}
+	if o.MaxIdleConns > o.MaxOpenConns {
+		return fmt.Errorf("max idle connections (%d) must not exceed max open connections (%d)", o.MaxIdleConns, o.MaxOpenConns)
+	}
+	if o.ConnMaxLifetime < 0 {
+		return fmt.Errorf("connection max lifetime must not be negative, got %s", o.ConnMaxLifetime)
+	}
+
+	return nil
+}
+
+func (o PoolOptions) apply(db *sql.DB) {
+	db.SetMaxOpenConns(o.MaxOpenConns)
+	db.SetMaxIdleConns(o.MaxIdleConns)
+	db.SetConnMaxLifetime(o.ConnMaxLifetime)
 }
 
 func NewPostgresTaskRepository(connectionString string) (*PostgresTaskRepository, error) {
+	return NewPostgresTaskRepositoryWithPool(connectionString, DefaultPoolOptions())
+}
+
+func NewPostgresTaskRepositoryWithPool(connectionString string, pool PoolOptions) (*PostgresTaskRepository, error) {
+	if err := pool.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pool options: %w", err)
+	}
+
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
@@ -28,31 +78,37 @@ func NewPostgresTaskRepository(connectionString string) (*PostgresTaskRepository
 		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	pool.apply(db)
 
 	return &PostgresTaskRepository{db: db}, nil
 }
 
-func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID string) (*task.Task, error) {
-	query := `
+func (r *PostgresTaskRepository) SetPayloadCipher(c *encryption.PayloadCipher) {
+	r.cipher = c
+}
+
+const selectTaskColumns = `
 		SELECT 
 			task_id, type, payload, priority, status, 
 			retry_count, failure_reason, created_at, 
 			scheduled_at, started_at, completed_at,
-			duration_ms, worker_id, moved_to_dlq_at
+			duration_ms, worker_id, moved_to_dlq_at,
+			tags, failure_category, expected_duration_ms
 		FROM task_history
-		WHERE task_id = $1
-	`
+`
 
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *PostgresTaskRepository) scanTask(row rowScanner) (*task.Task, error) {
 	var t task.Task
 	var payload []byte
 	var scheduledAt, startedAt, completedAt, movedToDLQAt sql.NullTime
-	var durationMs sql.NullInt64
-	var workerID, failureReason sql.NullString
+	var durationMs, expectedDurationMs sql.NullInt64
+	var workerID, failureReason, failureCategory sql.NullString
 
-	err := r.db.QueryRowContext(ctx, query, taskID).Scan(
+	err := row.Scan(
 		&t.ID,
 		&t.Type,
 		&payload,
@@ -67,6 +123,9 @@ func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID string) (*t
 		&durationMs,
 		&workerID,
 		&movedToDLQAt,
+		pq.Array(&t.Tags),
+		&failureCategory,
+		&expectedDurationMs,
 	)
 	if err != nil {
 		return nil, err
@@ -76,6 +135,14 @@ func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID string) (*t
 		return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
+	if r.cipher != nil {
+		decrypted, err := r.cipher.Decrypt(t.Payload)
+		if err != nil {
+			return nil, err
+		}
+		t.Payload = decrypted
+	}
+
 	if scheduledAt.Valid {
 		t.ScheduledAt = scheduledAt.Time
 	}
@@ -91,12 +158,71 @@ func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID string) (*t
 	if failureReason.Valid {
 		t.FailureReason = failureReason.String
 	}
+	if failureCategory.Valid {
+		t.FailureCategory = task.FailureCategory(failureCategory.String)
+	}
+	if expectedDurationMs.Valid {
+		expected := int(expectedDurationMs.Int64)
+		t.ExpectedDurationMs = &expected
+	}
 
 	return &t, nil
 }
 
+func (r *PostgresTaskRepository) GetTask(ctx context.Context, taskID string) (*task.Task, error) {
+	query := selectTaskColumns + `
+		WHERE task_id = $1
+	`
+
+	t, err := r.scanTask(r.db.QueryRowContext(ctx, query, taskID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrTaskNotFound
+	}
+
+	return t, err
+}
+
+func (r *PostgresTaskRepository) GetDeadLetterTasks(ctx context.Context, limit int) ([]*task.Task, error) {
+	query := selectTaskColumns + `
+		WHERE status = 'dead_letter'
+		ORDER BY moved_to_dlq_at DESC
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var tasks []*task.Task
+	for rows.Next() {
+		t, err := r.scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
+
 func (r *PostgresTaskRepository) SaveTask(ctx context.Context, t *task.Task) error {
-	payload, err := json.Marshal(t.Payload)
+	storedPayload := t.Payload
+	if r.cipher != nil {
+		encrypted, err := r.cipher.Encrypt(t.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt payload: %w", err)
+		}
+		storedPayload = encrypted
+	}
+
+	payload, err := json.Marshal(storedPayload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
@@ -104,13 +230,18 @@ func (r *PostgresTaskRepository) SaveTask(ctx context.Context, t *task.Task) err
 	query := `
 		INSERT INTO task_history (
 			task_id, type, payload, priority, status, 
-			retry_count, failure_reason, created_at, scheduled_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			retry_count, failure_reason, created_at, scheduled_at, tags,
+			expected_duration_ms, failure_category, max_retries
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13)
 		ON CONFLICT (task_id) DO UPDATE SET
 			status = EXCLUDED.status,
 			retry_count = EXCLUDED.retry_count,
 			failure_reason = EXCLUDED.failure_reason,
-			scheduled_at = EXCLUDED.scheduled_at
+			failure_category = EXCLUDED.failure_category,
+			scheduled_at = EXCLUDED.scheduled_at,
+			tags = EXCLUDED.tags,
+			expected_duration_ms = EXCLUDED.expected_duration_ms,
+			max_retries = EXCLUDED.max_retries
 	`
 
 	var scheduledAt any
@@ -132,6 +263,10 @@ func (r *PostgresTaskRepository) SaveTask(ctx context.Context, t *task.Task) err
 		t.FailureReason,
 		t.CreatedAt,
 		scheduledAt,
+		pq.Array(t.Tags),
+		t.ExpectedDurationMs,
+		string(t.FailureCategory),
+		t.MaxRetries,
 	)
 
 	return err
@@ -164,30 +299,32 @@ func (r *PostgresTaskRepository) CompleteTask(ctx context.Context, taskID string
 	return err
 }
 
-func (r *PostgresTaskRepository) FailTask(ctx context.Context, taskID string, reason string, durationMs int) error {
+func (r *PostgresTaskRepository) FailTask(ctx context.Context, taskID string, reason string, category task.FailureCategory, durationMs int) error {
 	query := `
 		UPDATE task_history 
 		SET status = 'failed',
 		    completed_at = NOW(),
 		    failure_reason = $1,
-		    duration_ms = $2,
+		    failure_category = NULLIF($2, ''),
+		    duration_ms = $3,
 		    last_error = $1
-		WHERE task_id = $3
+		WHERE task_id = $4
 	`
-	_, err := r.db.ExecContext(ctx, query, reason, durationMs, taskID)
+	_, err := r.db.ExecContext(ctx, query, reason, string(category), durationMs, taskID)
 
 	return err
 }
 
-func (r *PostgresTaskRepository) MoveTaskToDLQ(ctx context.Context, taskID string, reason string) error {
+func (r *PostgresTaskRepository) MoveTaskToDLQ(ctx context.Context, taskID string, reason string, category task.FailureCategory) error {
 	query := `
 		UPDATE task_history 
 		SET status = 'dead_letter',
 		    failure_reason = $1,
+		    failure_category = NULLIF($2, ''),
 		    moved_to_dlq_at = NOW()
-		WHERE task_id = $2
+		WHERE task_id = $3
 	`
-	_, err := r.db.ExecContext(ctx, query, reason, taskID)
+	_, err := r.db.ExecContext(ctx, query, reason, string(category), taskID)
 
 	return err
 }
@@ -239,6 +376,24 @@ func (r *PostgresTaskRepository) LogExecution(ctx context.Context, taskID string
 	return err
 }
 
+func (r *PostgresTaskRepository) StartTask(ctx context.Context, taskID string, attemptNumber int, workerID string) error {
+	query := `
+		WITH started AS (
+			UPDATE task_history
+			SET status = 'running',
+			    started_at = NOW(),
+			    worker_id = $3
+			WHERE task_id = $1
+		)
+		INSERT INTO task_execution_log (
+			task_id, attempt_number, status, completed_at, worker_id
+		) VALUES ($1, $2, 'running', NOW(), $3)
+	`
+	_, err := r.db.ExecContext(ctx, query, taskID, attemptNumber, workerID)
+
+	return err
+}
+
 func (r *PostgresTaskRepository) GetTaskStats(ctx context.Context, hours int) ([]models.TaskStats, error) {
 	query := `
 		SELECT 
@@ -284,16 +439,59 @@ func (r *PostgresTaskRepository) GetTaskStats(ctx context.Context, hours int) ([
 	return stats, rows.Err()
 }
 
-func (r *PostgresTaskRepository) GetRecentTasks(ctx context.Context, limit int) ([]models.RecentTask, error) {
+func (r *PostgresTaskRepository) GetDurationOutliers(ctx context.Context, hours int, limit int) ([]models.DurationOutlier, error) {
+	query := `
+		SELECT 
+			task_id, type, created_at, expected_duration_ms, duration_ms,
+			duration_ms - expected_duration_ms AS breach_ms
+		FROM task_history
+		WHERE created_at > NOW() - INTERVAL '1 hour' * $1
+			AND expected_duration_ms IS NOT NULL
+			AND duration_ms > expected_duration_ms
+		ORDER BY breach_ms DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, hours, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var outliers []models.DurationOutlier
+	for rows.Next() {
+		var o models.DurationOutlier
+		if err := rows.Scan(
+			&o.TaskID,
+			&o.Type,
+			&o.CreatedAt,
+			&o.ExpectedDurationMs,
+			&o.DurationMs,
+			&o.BreachMs,
+		); err != nil {
+			return nil, err
+		}
+
+		outliers = append(outliers, o)
+	}
+
+	return outliers, rows.Err()
+}
+
+func (r *PostgresTaskRepository) GetRecentTasks(ctx context.Context, limit int, offset int) ([]models.RecentTask, error) {
 	query := `
 		SELECT 
 			task_id, type, status, created_at, completed_at,
 			duration_ms, retry_count, COALESCE(failure_reason, '')
 		FROM task_history
 		ORDER BY created_at DESC
-		LIMIT $1
+		LIMIT $1 OFFSET $2
 	`
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -326,7 +524,14 @@ func (r *PostgresTaskRepository) GetRecentTasks(ctx context.Context, limit int)
 	return tasks, rows.Err()
 }
 
-func (r *PostgresTaskRepository) GetTasksByType(ctx context.Context, taskType string, limit int) ([]models.RecentTask, error) {
+func (r *PostgresTaskRepository) CountTasks(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_history`).Scan(&count)
+
+	return count, err
+}
+
+func (r *PostgresTaskRepository) GetTasksByType(ctx context.Context, taskType string, limit int, offset int) ([]models.RecentTask, error) {
 	query := `
 		SELECT 
 			task_id, type, status, created_at, completed_at,
@@ -334,9 +539,105 @@ func (r *PostgresTaskRepository) GetTasksByType(ctx context.Context, taskType st
 		FROM task_history
 		WHERE type = $1
 		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, taskType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var tasks []models.RecentTask
+	for rows.Next() {
+		var t models.RecentTask
+		if err := rows.Scan(
+			&t.TaskID,
+			&t.Type,
+			&t.Status,
+			&t.CreatedAt,
+			&t.CompletedAt,
+			&t.DurationMs,
+			&t.RetryCount,
+			&t.FailureReason,
+		); err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (r *PostgresTaskRepository) CountTasksByType(ctx context.Context, taskType string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_history WHERE type = $1`, taskType).Scan(&count)
+
+	return count, err
+}
+
+func (r *PostgresTaskRepository) GetTasksByTag(ctx context.Context, tag string, limit int) ([]models.RecentTask, error) {
+	query := `
+		SELECT 
+			task_id, type, status, created_at, completed_at,
+			duration_ms, retry_count, COALESCE(failure_reason, ''), tags
+		FROM task_history
+		WHERE $1 = ANY(tags)
+		ORDER BY created_at DESC
 		LIMIT $2
 	`
-	rows, err := r.db.QueryContext(ctx, query, taskType, limit)
+	rows, err := r.db.QueryContext(ctx, query, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var tasks []models.RecentTask
+	for rows.Next() {
+		var t models.RecentTask
+		if err := rows.Scan(
+			&t.TaskID,
+			&t.Type,
+			&t.Status,
+			&t.CreatedAt,
+			&t.CompletedAt,
+			&t.DurationMs,
+			&t.RetryCount,
+			&t.FailureReason,
+			pq.Array(&t.Tags),
+		); err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *PostgresTaskRepository) SearchTasksByError(ctx context.Context, substring string, limit int) ([]models.RecentTask, error) {
+	query := `
+		SELECT 
+			task_id, type, status, created_at, completed_at,
+			duration_ms, retry_count, COALESCE(failure_reason, '')
+		FROM task_history
+		WHERE failure_reason ILIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, likeEscaper.Replace(substring), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -434,6 +735,23 @@ func (r *PostgresTaskRepository) GetTaskHistory(ctx context.Context, taskID stri
 	return history, rows.Err()
 }
 
+func (r *PostgresTaskRepository) PruneTaskHistory(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+		DELETE FROM task_history
+		WHERE created_at < $1
+		AND (
+			status IN ('completed', 'cancelled', 'dead_letter')
+			OR (status = 'failed' AND retry_count >= max_retries)
+		)
+	`
+	result, err := r.db.ExecContext(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 func (r *PostgresTaskRepository) DB() *sql.DB {
 	return r.db
 }
@@ -1,13 +1,18 @@
 package postgres
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nadmax/nexq/internal/encryption"
+	"github.com/nadmax/nexq/internal/repository"
 	"github.com/nadmax/nexq/internal/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,6 +26,11 @@ func setupMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *PostgresTaskRepositor
 	return db, mock, repo
 }
 
+func TestPostgresTaskRepository_ImplementsTaskRepository(t *testing.T) {
+	var repo repository.TaskRepository = &PostgresTaskRepository{}
+	assert.NotNil(t, repo)
+}
+
 func TestNewPostgresTaskRepository(t *testing.T) {
 	t.Run("successful connection", func(t *testing.T) {
 		t.Skip("Integration test - requires real database")
@@ -32,6 +42,41 @@ func TestNewPostgresTaskRepository(t *testing.T) {
 	})
 }
 
+func TestPoolOptions(t *testing.T) {
+	t.Run("applies custom settings", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		opts := PoolOptions{MaxOpenConns: 50, MaxIdleConns: 10, ConnMaxLifetime: time.Minute}
+		require.NoError(t, opts.Validate())
+		opts.apply(db)
+
+		assert.Equal(t, 50, db.Stats().MaxOpenConnections)
+	})
+
+	t.Run("defaults match previous settings", func(t *testing.T) {
+		opts := DefaultPoolOptions()
+		assert.Equal(t, 25, opts.MaxOpenConns)
+		assert.Equal(t, 5, opts.MaxIdleConns)
+		assert.Equal(t, 5*time.Minute, opts.ConnMaxLifetime)
+		assert.NoError(t, opts.Validate())
+	})
+
+	t.Run("rejects idle above open", func(t *testing.T) {
+		opts := PoolOptions{MaxOpenConns: 5, MaxIdleConns: 10}
+		assert.Error(t, opts.Validate())
+
+		_, err := NewPostgresTaskRepositoryWithPool("postgres://localhost/nexq", opts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid pool options")
+	})
+
+	t.Run("rejects non-positive max open", func(t *testing.T) {
+		assert.Error(t, PoolOptions{MaxOpenConns: 0}.Validate())
+	})
+}
+
 func TestGetTask(t *testing.T) {
 	db, mock, repo := setupMockDB(t)
 	defer func() { _ = db.Close() }()
@@ -51,11 +96,13 @@ func TestGetTask(t *testing.T) {
 			"retry_count", "failure_reason", "created_at",
 			"scheduled_at", "started_at", "completed_at",
 			"duration_ms", "worker_id", "moved_to_dlq_at",
+			"tags", "failure_category", "expected_duration_ms",
 		}).AddRow(
 			taskID, "email", payloadBytes, 5, "completed",
 			0, nil, now,
 			now, startedAt, completedAt,
 			5000, "worker-1", nil,
+			"{urgent,billing}", "timeout", 4000,
 		)
 
 		mock.ExpectQuery("SELECT.*FROM task_history WHERE task_id").
@@ -69,6 +116,10 @@ func TestGetTask(t *testing.T) {
 		assert.Equal(t, task.TaskStatus("completed"), result.Status)
 		assert.NotNil(t, result.StartedAt)
 		assert.NotNil(t, result.CompletedAt)
+		assert.Equal(t, []string{"urgent", "billing"}, result.Tags)
+		assert.Equal(t, task.TimeoutFailure, result.FailureCategory)
+		require.NotNil(t, result.ExpectedDurationMs)
+		assert.Equal(t, 4000, *result.ExpectedDurationMs)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -78,7 +129,18 @@ func TestGetTask(t *testing.T) {
 			WillReturnError(sql.ErrNoRows)
 
 		_, err := repo.GetTask(ctx, "nonexistent")
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, repository.ErrTaskNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE task_id").
+			WithArgs(taskID).
+			WillReturnError(errors.New("connection refused"))
+
+		_, err := repo.GetTask(ctx, taskID)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, repository.ErrTaskNotFound)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -88,11 +150,13 @@ func TestGetTask(t *testing.T) {
 			"retry_count", "failure_reason", "created_at",
 			"scheduled_at", "started_at", "completed_at",
 			"duration_ms", "worker_id", "moved_to_dlq_at",
+			"tags", "failure_category", "expected_duration_ms",
 		}).AddRow(
 			taskID, "email", []byte("invalid json"), 5, "completed",
 			0, nil, now,
 			now, nil, nil,
 			nil, nil, nil,
+			nil, nil, nil,
 		)
 
 		mock.ExpectQuery("SELECT.*FROM task_history WHERE task_id").
@@ -106,6 +170,129 @@ func TestGetTask(t *testing.T) {
 	})
 }
 
+func TestGetDeadLetterTasks(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	now := time.Now()
+	columns := []string{
+		"task_id", "type", "payload", "priority", "status",
+		"retry_count", "failure_reason", "created_at",
+		"scheduled_at", "started_at", "completed_at",
+		"duration_ms", "worker_id", "moved_to_dlq_at",
+		"tags", "failure_category", "expected_duration_ms",
+	}
+
+	t.Run("successful retrieval", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(
+				"task-1", "email", []byte(`{"to":"a@example.com"}`), 1, "dead_letter",
+				3, "smtp timeout", now,
+				now, now, nil,
+				nil, "worker-1", now,
+				nil, nil, nil,
+			).
+			AddRow(
+				"task-2", "report", []byte(`{}`), 2, "dead_letter",
+				3, "disk full", now,
+				now, now, nil,
+				nil, "worker-2", now,
+				nil, nil, nil,
+			)
+
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE status = 'dead_letter'").
+			WithArgs(10).
+			WillReturnRows(rows)
+
+		tasks, err := repo.GetDeadLetterTasks(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+		assert.Equal(t, "task-1", tasks[0].ID)
+		assert.Equal(t, "smtp timeout", tasks[0].FailureReason)
+		assert.NotNil(t, tasks[0].MoveToDLQAt)
+		assert.Equal(t, "task-2", tasks[1].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE status = 'dead_letter'").
+			WithArgs(10).
+			WillReturnError(sql.ErrConnDone)
+
+		_, err := repo.GetDeadLetterTasks(ctx, 10)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+type ciphertextArg struct {
+	plaintext string
+}
+
+func (a ciphertextArg) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	return ok && bytes.Contains(b, []byte("_encrypted")) && !bytes.Contains(b, []byte(a.plaintext))
+}
+
+func TestPayloadEncryption(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	c, err := encryption.NewPayloadCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	repo.SetPayloadCipher(c)
+
+	ctx := context.Background()
+	payload := map[string]any{"to": "user@example.com"}
+
+	t.Run("stores ciphertext", func(t *testing.T) {
+		tsk := task.NewTask("send_email", payload, task.MediumPriority)
+
+		mock.ExpectExec("INSERT INTO task_history").
+			WithArgs(
+				tsk.ID, tsk.Type, ciphertextArg{plaintext: "user@example.com"}, tsk.Priority, tsk.Status,
+				tsk.RetryCount, tsk.FailureReason, tsk.CreatedAt, tsk.ScheduledAt, sqlmock.AnyArg(),
+				nil, "", tsk.MaxRetries,
+			).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		require.NoError(t, repo.SaveTask(ctx, tsk))
+		assert.Equal(t, payload, tsk.Payload)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reads plaintext", func(t *testing.T) {
+		encrypted, err := c.Encrypt(payload)
+		require.NoError(t, err)
+		encryptedBytes, _ := json.Marshal(encrypted)
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{
+			"task_id", "type", "payload", "priority", "status",
+			"retry_count", "failure_reason", "created_at",
+			"scheduled_at", "started_at", "completed_at",
+			"duration_ms", "worker_id", "moved_to_dlq_at",
+			"tags", "failure_category", "expected_duration_ms",
+		}).AddRow(
+			"task-1", "send_email", encryptedBytes, 1, "pending",
+			0, nil, now,
+			now, nil, nil,
+			nil, nil, nil,
+			nil, nil, nil,
+		)
+
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE task_id").
+			WithArgs("task-1").
+			WillReturnRows(rows)
+
+		result, err := repo.GetTask(ctx, "task-1")
+		require.NoError(t, err)
+		assert.Equal(t, payload, result.Payload)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestSaveTask(t *testing.T) {
 	db, mock, repo := setupMockDB(t)
 	defer func() { _ = db.Close() }()
@@ -136,6 +323,10 @@ func TestSaveTask(t *testing.T) {
 				tsk.FailureReason,
 				tsk.CreatedAt,
 				tsk.ScheduledAt,
+				sqlmock.AnyArg(),
+				nil,
+				"",
+				tsk.MaxRetries,
 			).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
@@ -166,6 +357,10 @@ func TestSaveTask(t *testing.T) {
 				tsk.FailureReason,
 				tsk.CreatedAt,
 				nil,
+				sqlmock.AnyArg(),
+				nil,
+				"",
+				tsk.MaxRetries,
 			).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
@@ -198,6 +393,10 @@ func TestSaveTask(t *testing.T) {
 				tsk.FailureReason,
 				tsk.CreatedAt,
 				tsk.ScheduledAt,
+				sqlmock.AnyArg(),
+				nil,
+				"",
+				tsk.MaxRetries,
 			).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -260,10 +459,10 @@ func TestFailTask(t *testing.T) {
 	t.Run("task failure with reason", func(t *testing.T) {
 		reason := "connection timeout"
 		mock.ExpectExec("UPDATE task_history SET status = 'failed'").
-			WithArgs(reason, 3000, "task-123").
+			WithArgs(reason, "timeout", 3000, "task-123").
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
-		err := repo.FailTask(ctx, "task-123", reason, 3000)
+		err := repo.FailTask(ctx, "task-123", reason, task.TimeoutFailure, 3000)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -278,10 +477,10 @@ func TestMoveTaskToDLQ(t *testing.T) {
 	t.Run("move to dead letter queue", func(t *testing.T) {
 		reason := "max retries exceeded"
 		mock.ExpectExec("UPDATE task_history SET status = 'dead_letter'").
-			WithArgs(reason, "task-123").
+			WithArgs(reason, "unknown", "task-123").
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
-		err := repo.MoveTaskToDLQ(ctx, "task-123", reason)
+		err := repo.MoveTaskToDLQ(ctx, "task-123", reason, task.UnknownFailure)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -332,6 +531,19 @@ func TestLogExecution(t *testing.T) {
 	})
 }
 
+func TestStartTask(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectExec("WITH started AS \\(\\s*UPDATE task_history.*INSERT INTO task_execution_log").
+		WithArgs("task-123", 2, "worker-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.StartTask(context.Background(), "task-123", 2, "worker-1")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetTaskStats(t *testing.T) {
 	db, mock, repo := setupMockDB(t)
 	defer func() { _ = db.Close() }()
@@ -394,11 +606,11 @@ func TestGetRecentTasks(t *testing.T) {
 			AddRow("task-1", "email", "completed", now, completedAt, 5000, 0, "").
 			AddRow("task-2", "webhook", "failed", now, completedAt, 3000, 2, "timeout")
 
-		mock.ExpectQuery("SELECT.*FROM task_history ORDER BY created_at DESC").
-			WithArgs(10).
+		mock.ExpectQuery("SELECT.*FROM task_history ORDER BY created_at DESC LIMIT \\$1 OFFSET \\$2").
+			WithArgs(10, 0).
 			WillReturnRows(rows)
 
-		tasks, err := repo.GetRecentTasks(ctx, 10)
+		tasks, err := repo.GetRecentTasks(ctx, 10, 0)
 		require.NoError(t, err)
 		assert.Len(t, tasks, 2)
 		assert.Equal(t, "task-1", tasks[0].TaskID)
@@ -407,6 +619,50 @@ func TestGetRecentTasks(t *testing.T) {
 		assert.Equal(t, "timeout", tasks[1].FailureReason)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("with offset", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{
+			"task_id", "type", "status", "created_at", "completed_at",
+			"duration_ms", "retry_count", "failure_reason",
+		}).AddRow("task-41", "email", "completed", now, now, 5000, 0, "")
+
+		mock.ExpectQuery("SELECT.*FROM task_history ORDER BY created_at DESC LIMIT \\$1 OFFSET \\$2").
+			WithArgs(20, 40).
+			WillReturnRows(rows)
+
+		tasks, err := repo.GetRecentTasks(ctx, 20, 40)
+		require.NoError(t, err)
+		assert.Len(t, tasks, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCountTasks(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("all tasks", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM task_history$").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1234))
+
+		count, err := repo.CountTasks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1234, count)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("by type", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM task_history WHERE type = \\$1").
+			WithArgs("email").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+		count, err := repo.CountTasksByType(ctx, "email")
+		require.NoError(t, err)
+		assert.Equal(t, 42, count)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestGetTasksByType(t *testing.T) {
@@ -424,11 +680,11 @@ func TestGetTasksByType(t *testing.T) {
 			AddRow("task-1", "email", "completed", now, now, 5000, 0, "").
 			AddRow("task-2", "email", "failed", now, now, 3000, 1, "smtp error")
 
-		mock.ExpectQuery("SELECT.*FROM task_history WHERE type").
-			WithArgs("email", 50).
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE type.*LIMIT \\$2 OFFSET \\$3").
+			WithArgs("email", 50, 100).
 			WillReturnRows(rows)
 
-		tasks, err := repo.GetTasksByType(ctx, "email", 50)
+		tasks, err := repo.GetTasksByType(ctx, "email", 50, 100)
 		require.NoError(t, err)
 		assert.Len(t, tasks, 2)
 		assert.Equal(t, "email", tasks[0].Type)
@@ -437,6 +693,210 @@ func TestGetTasksByType(t *testing.T) {
 	})
 }
 
+func TestSearchTasksByError(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("matches failure reason substring", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{
+			"task_id", "type", "status", "created_at", "completed_at",
+			"duration_ms", "retry_count", "failure_reason",
+		}).AddRow("task-1", "webhook", "failed", now, now, 3000, 3, "dial tcp: i/o timeout")
+
+		mock.ExpectQuery(`SELECT.*FROM task_history WHERE failure_reason ILIKE '%' \|\| \$1 \|\| '%' ORDER BY created_at DESC LIMIT \$2`).
+			WithArgs("timeout", 25).
+			WillReturnRows(rows)
+
+		tasks, err := repo.SearchTasksByError(ctx, "timeout", 25)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "dial tcp: i/o timeout", tasks[0].FailureReason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("escapes LIKE wildcards", func(t *testing.T) {
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE failure_reason ILIKE").
+			WithArgs(`100\% of quota\_used \\ retry`, 10).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"task_id", "type", "status", "created_at", "completed_at",
+				"duration_ms", "retry_count", "failure_reason",
+			}))
+
+		tasks, err := repo.SearchTasksByError(ctx, `100% of quota_used \ retry`, 10)
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveTask_WithTags(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	tsk := &task.Task{
+		ID:        "task-tags",
+		Type:      "email",
+		Payload:   map[string]any{},
+		Status:    task.PendingStatus,
+		CreatedAt: now,
+		Tags:      []string{"tenant-a", "batch-42"},
+	}
+
+	mock.ExpectExec("INSERT INTO task_history").
+		WithArgs(
+			tsk.ID,
+			tsk.Type,
+			sqlmock.AnyArg(),
+			tsk.Priority,
+			tsk.Status,
+			tsk.RetryCount,
+			tsk.FailureReason,
+			tsk.CreatedAt,
+			nil,
+			"{\"tenant-a\",\"batch-42\"}",
+			nil,
+			"",
+			tsk.MaxRetries,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.SaveTask(context.Background(), tsk)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTasksByTag(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("get tasks by tag", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{
+			"task_id", "type", "status", "created_at", "completed_at",
+			"duration_ms", "retry_count", "failure_reason", "tags",
+		}).
+			AddRow("task-1", "email", "completed", now, now, 5000, 0, "", "{tenant-a,batch-42}").
+			AddRow("task-2", "webhook", "pending", now, nil, nil, 0, "", "{tenant-a}")
+
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE \\$1 = ANY\\(tags\\)").
+			WithArgs("tenant-a", 20).
+			WillReturnRows(rows)
+
+		tasks, err := repo.GetTasksByTag(ctx, "tenant-a", 20)
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+		assert.Equal(t, []string{"tenant-a", "batch-42"}, tasks[0].Tags)
+		assert.Equal(t, []string{"tenant-a"}, tasks[1].Tags)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE \\$1 = ANY\\(tags\\)").
+			WithArgs("tenant-b", 20).
+			WillReturnError(sql.ErrConnDone)
+
+		tasks, err := repo.GetTasksByTag(ctx, "tenant-b", 20)
+		assert.Error(t, err)
+		assert.Nil(t, tasks)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetDurationOutliers(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("ordered by breach magnitude", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{
+			"task_id", "type", "created_at", "expected_duration_ms", "duration_ms", "breach_ms",
+		}).
+			AddRow("task-1", "generate_report", now, 1000, 9000, 8000).
+			AddRow("task-2", "email", now, 200, 700, 500)
+
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE created_at.*expected_duration_ms IS NOT NULL.*ORDER BY breach_ms DESC LIMIT").
+			WithArgs(12, 100).
+			WillReturnRows(rows)
+
+		outliers, err := repo.GetDurationOutliers(ctx, 12, 100)
+		require.NoError(t, err)
+		require.Len(t, outliers, 2)
+		assert.Equal(t, "task-1", outliers[0].TaskID)
+		assert.Equal(t, 8000, outliers[0].BreachMs)
+		assert.Equal(t, 1000, outliers[0].ExpectedDurationMs)
+		assert.Equal(t, 9000, outliers[0].DurationMs)
+		assert.Equal(t, "task-2", outliers[1].TaskID)
+		assert.Greater(t, outliers[0].BreachMs, outliers[1].BreachMs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT.*FROM task_history WHERE created_at").
+			WithArgs(24, 100).
+			WillReturnError(sql.ErrConnDone)
+
+		outliers, err := repo.GetDurationOutliers(ctx, 24, 100)
+		assert.Error(t, err)
+		assert.Nil(t, outliers)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+type cutoffArg struct {
+	want time.Time
+}
+
+func (a cutoffArg) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	return ok && got.Sub(a.want).Abs() < 5*time.Second
+}
+
+func TestPruneTaskHistory(t *testing.T) {
+	db, mock, repo := setupMockDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("deletes terminal rows older than the cutoff", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM task_history WHERE created_at < \$1 AND \( status IN \('completed', 'cancelled', 'dead_letter'\)`).
+			WithArgs(cutoffArg{want: time.Now().Add(-30 * 24 * time.Hour)}).
+			WillReturnResult(sqlmock.NewResult(0, 42))
+
+		deleted, err := repo.PruneTaskHistory(ctx, 30*24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("keeps failed rows that are still retrying", func(t *testing.T) {
+		mock.ExpectExec(`OR \(status = 'failed' AND retry_count >= max_retries\) \)$`).
+			WithArgs(cutoffArg{want: time.Now().Add(-time.Hour)}).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		deleted, err := repo.PruneTaskHistory(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM task_history").
+			WillReturnError(errors.New("connection refused"))
+
+		_, err := repo.PruneTaskHistory(ctx, time.Hour)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestGetTaskHistory(t *testing.T) {
 	db, mock, repo := setupMockDB(t)
 	defer func() { _ = db.Close() }()
@@ -3,23 +3,37 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/nadmax/nexq/internal/repository/models"
 	"github.com/nadmax/nexq/internal/task"
 )
 
+var ErrTaskNotFound = errors.New("task not found")
+
 type TaskRepository interface {
 	GetTask(ctx context.Context, taskID string) (*task.Task, error)
+	GetDeadLetterTasks(ctx context.Context, limit int) ([]*task.Task, error)
 	SaveTask(ctx context.Context, t *task.Task) error
 	UpdateTaskStatus(ctx context.Context, taskID string, status task.TaskStatus, workerID string) error
 	CompleteTask(ctx context.Context, taskID string, durationMs int) error
-	FailTask(ctx context.Context, taskID string, reason string, durationMs int) error
-	MoveTaskToDLQ(ctx context.Context, taskID string, reason string) error
+	FailTask(ctx context.Context, taskID string, reason string, category task.FailureCategory, durationMs int) error
+	MoveTaskToDLQ(ctx context.Context, taskID string, reason string, category task.FailureCategory) error
 	IncrementRetryCount(ctx context.Context, taskID string) error
 	LogExecution(ctx context.Context, taskID string, attemptNumber int, status string, durationMs int, msgErr string, workerID string) error
+	// StartTask marks a task running on workerID and logs the start of the
+	// attempt, in a single round trip.
+	StartTask(ctx context.Context, taskID string, attemptNumber int, workerID string) error
 	GetTaskStats(ctx context.Context, hours int) ([]models.TaskStats, error)
-	GetRecentTasks(ctx context.Context, limit int) ([]models.RecentTask, error)
-	GetTasksByType(ctx context.Context, taskType string, limit int) ([]models.RecentTask, error)
+	GetDurationOutliers(ctx context.Context, hours int, limit int) ([]models.DurationOutlier, error)
+	GetRecentTasks(ctx context.Context, limit int, offset int) ([]models.RecentTask, error)
+	CountTasks(ctx context.Context) (int, error)
+	GetTasksByType(ctx context.Context, taskType string, limit int, offset int) ([]models.RecentTask, error)
+	CountTasksByType(ctx context.Context, taskType string) (int, error)
+	GetTasksByTag(ctx context.Context, tag string, limit int) ([]models.RecentTask, error)
+	SearchTasksByError(ctx context.Context, substring string, limit int) ([]models.RecentTask, error)
 	GetTaskHistory(ctx context.Context, taskID string) ([]map[string]any, error)
+	PruneTaskHistory(ctx context.Context, olderThan time.Duration) (int64, error)
 	Close() error
 }
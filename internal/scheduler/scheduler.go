@@ -0,0 +1,125 @@
+// Package scheduler enqueues recurring tasks according to their cron expressions.
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nadmax/nexq/internal/queue"
+	"github.com/robfig/cron/v3"
+)
+
+type Scheduler struct {
+	queue    *queue.Queue
+	now      func() time.Time
+	interval time.Duration
+	stop     chan bool
+}
+
+func NewScheduler(q *queue.Queue) *Scheduler {
+	return &Scheduler{
+		queue:    q,
+		now:      time.Now,
+		interval: time.Second,
+		stop:     make(chan bool),
+	}
+}
+
+func ParseCron(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	return schedule, nil
+}
+
+func (s *Scheduler) SetInterval(d time.Duration) {
+	s.interval = d
+}
+
+func (s *Scheduler) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+func (s *Scheduler) Start() {
+	log.Println("Scheduler started")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			log.Println("Scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunDue(); err != nil {
+				log.Printf("Scheduler: failed to run due schedules: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) Stop() {
+	s.stop <- true
+}
+
+func (s *Scheduler) RunDue() (int, error) {
+	schedules, err := s.queue.GetRecurringTasks()
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	enqueued := 0
+
+	for _, rt := range schedules {
+		schedule, err := ParseCron(rt.Cron)
+		if err != nil {
+			log.Printf("Scheduler: skipping schedule %s: %v", rt.ID, err)
+			continue
+		}
+
+		last := rt.CreatedAt
+		if rt.LastRunAt != nil {
+			last = *rt.LastRunAt
+		}
+
+		fireAt := schedule.Next(last)
+		if fireAt.After(now) {
+			continue
+		}
+
+		claimed, err := s.queue.ClaimScheduleRun(rt.ID, fireAt)
+		if err != nil {
+			log.Printf("Scheduler: failed to claim run of schedule %s: %v", rt.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		t := rt.NewTask()
+		if err := s.queue.Enqueue(t); err != nil {
+			log.Printf("Scheduler: failed to enqueue task for schedule %s: %v", rt.ID, err)
+			if err := s.queue.ReleaseScheduleRun(rt.ID, fireAt); err != nil {
+				log.Printf("Scheduler: failed to release run of schedule %s: %v", rt.ID, err)
+			}
+			continue
+		}
+
+		rt.LastRunAt = &now
+		if updated, err := s.queue.UpdateRecurringTask(rt); err != nil {
+			log.Printf("Scheduler: failed to update schedule %s: %v", rt.ID, err)
+		} else if !updated {
+			log.Printf("Scheduler: schedule %s was deleted while it ran", rt.ID)
+		}
+
+		log.Printf("Scheduler: enqueued task %s for schedule %s", t.ID, rt.ID)
+		enqueued++
+	}
+
+	return enqueued, nil
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/queue"
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func setupTestScheduler(t *testing.T) (*Scheduler, *queue.Queue, *fakeClock, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+
+	q, err := queue.NewQueue(mr.Addr(), nil)
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewScheduler(q)
+	s.SetClock(clock.Now)
+
+	return s, q, clock, mr
+}
+
+func TestParseCron(t *testing.T) {
+	_, err := ParseCron("*/5 * * * *")
+	assert.NoError(t, err)
+
+	_, err = ParseCron("@every 1m")
+	assert.NoError(t, err)
+
+	_, err = ParseCron("not a cron")
+	assert.Error(t, err)
+}
+
+func TestRunDue_EnqueuesOnSchedule(t *testing.T) {
+	s, q, clock, mr := setupTestScheduler(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("*/5 * * * *", "cleanup", map[string]any{"scope": "all"}, task.HighPriority)
+	rt.CreatedAt = clock.Now()
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	enqueued, err := s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 0, enqueued)
+
+	clock.Advance(5 * time.Minute)
+	enqueued, err = s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+
+	enqueued, err = s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 0, enqueued)
+
+	clock.Advance(5 * time.Minute)
+	enqueued, err = s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	for _, tsk := range tasks {
+		assert.Equal(t, "cleanup", tsk.Type)
+		assert.Equal(t, task.HighPriority, tsk.Priority)
+		assert.Equal(t, "all", tsk.Payload["scope"])
+	}
+
+	stored, err := q.GetRecurringTask(rt.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastRunAt)
+	assert.True(t, clock.Now().Equal(*stored.LastRunAt))
+}
+
+func TestRunDue_SlotClaimedElsewhere(t *testing.T) {
+	s, q, clock, mr := setupTestScheduler(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("*/5 * * * *", "cleanup", nil, task.LowPriority)
+	rt.CreatedAt = clock.Now()
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	// Another scheduler sharing the backend fires the slot first.
+	fireAt := clock.Now().Add(5 * time.Minute)
+	claimed, err := q.ClaimScheduleRun(rt.ID, fireAt)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	clock.Advance(5 * time.Minute)
+	enqueued, err := s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 0, enqueued)
+
+	tasks, err := q.GetAllTasks()
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func TestRunDue_ReleasesSlotWhenEnqueueFails(t *testing.T) {
+	s, q, clock, mr := setupTestScheduler(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("*/5 * * * *", "cleanup", nil, task.LowPriority)
+	rt.CreatedAt = clock.Now()
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	q.SetMaxQueueDepth(1)
+	require.NoError(t, q.Enqueue(task.NewTask("filler", nil, task.LowPriority)))
+
+	clock.Advance(5 * time.Minute)
+	enqueued, err := s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 0, enqueued)
+
+	q.SetMaxQueueDepth(0)
+	enqueued, err = s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+}
+
+func TestRunDue_DoesNotRecreateDeletedSchedule(t *testing.T) {
+	s, q, clock, mr := setupTestScheduler(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("*/5 * * * *", "cleanup", nil, task.LowPriority)
+	rt.CreatedAt = clock.Now()
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	// RunDue reads the clock after loading the schedules, so deleting the
+	// schedule there races the delete against the run.
+	clock.Advance(5 * time.Minute)
+	s.SetClock(func() time.Time {
+		deleted, err := q.DeleteRecurringTask(rt.ID)
+		require.NoError(t, err)
+		require.True(t, deleted)
+		return clock.Now()
+	})
+
+	enqueued, err := s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+
+	_, err = q.GetRecurringTask(rt.ID)
+	assert.ErrorIs(t, err, queue.ErrNil)
+}
+
+func TestRunDue_SkipsInvalidCron(t *testing.T) {
+	s, q, clock, mr := setupTestScheduler(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("bogus", "cleanup", nil, task.LowPriority)
+	rt.CreatedAt = clock.Now()
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	clock.Advance(time.Hour)
+	enqueued, err := s.RunDue()
+	require.NoError(t, err)
+	assert.Equal(t, 0, enqueued)
+}
+
+func TestStartAndStop(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	q, err := queue.NewQueue(mr.Addr(), nil)
+	require.NoError(t, err)
+	defer func() { _ = q.Close() }()
+
+	rt := task.NewRecurringTask("@every 1s", "heartbeat", nil, task.LowPriority)
+	rt.CreatedAt = time.Now().Add(-time.Minute)
+	require.NoError(t, q.SaveRecurringTask(rt))
+
+	s := NewScheduler(q)
+	s.SetInterval(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		tasks, err := q.GetAllTasks()
+		return err == nil && len(tasks) >= 1
+	}, time.Second, 10*time.Millisecond)
+
+	s.Stop()
+	<-done
+}
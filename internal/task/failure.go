@@ -0,0 +1,80 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FailureCategory groups failure reasons so they can be aggregated without
+// comparing raw error strings.
+type FailureCategory string
+
+const (
+	TimeoutFailure    FailureCategory = "timeout"
+	ConnectionFailure FailureCategory = "connection"
+	ValidationFailure FailureCategory = "validation"
+	UnknownFailure    FailureCategory = "unknown"
+)
+
+type failureRule struct {
+	category FailureCategory
+	patterns []string
+}
+
+// FailureClassifier maps failure reasons to categories by case-insensitive
+// substring match. Rules are tried in order and the first match wins.
+type FailureClassifier struct {
+	rules []failureRule
+}
+
+func DefaultFailureClassifier() *FailureClassifier {
+	return &FailureClassifier{rules: []failureRule{
+		{TimeoutFailure, []string{"timeout", "timed out", "deadline exceeded"}},
+		{ConnectionFailure, []string{"connection", "no such host", "broken pipe", "network is unreachable"}},
+		{ValidationFailure, []string{"invalid", "missing", "required", "validation", "unsupported", "must be"}},
+	}}
+}
+
+// ParseFailureClassifier reads rules of the form
+// "timeout=timed out|deadline;connection=refused", in match order. An empty
+// string yields the default classifier.
+func ParseFailureClassifier(s string) (*FailureClassifier, error) {
+	if s == "" {
+		return DefaultFailureClassifier(), nil
+	}
+
+	c := &FailureClassifier{}
+	for rule := range strings.SplitSeq(s, ";") {
+		category, patterns, ok := strings.Cut(rule, "=")
+		category = strings.TrimSpace(category)
+		if !ok || category == "" {
+			return nil, fmt.Errorf("invalid failure category rule %q (expected category=pattern|pattern)", rule)
+		}
+
+		r := failureRule{category: FailureCategory(category)}
+		for pattern := range strings.SplitSeq(patterns, "|") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				r.patterns = append(r.patterns, strings.ToLower(pattern))
+			}
+		}
+		if len(r.patterns) == 0 {
+			return nil, fmt.Errorf("failure category %q has no patterns", category)
+		}
+		c.rules = append(c.rules, r)
+	}
+
+	return c, nil
+}
+
+func (c *FailureClassifier) Classify(reason string) FailureCategory {
+	reason = strings.ToLower(reason)
+	for _, r := range c.rules {
+		for _, pattern := range r.patterns {
+			if strings.Contains(reason, pattern) {
+				return r.category
+			}
+		}
+	}
+
+	return UnknownFailure
+}
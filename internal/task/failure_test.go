@@ -0,0 +1,55 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureClassifier_Default(t *testing.T) {
+	c := DefaultFailureClassifier()
+
+	tests := []struct {
+		reason   string
+		expected FailureCategory
+	}{
+		{"context deadline exceeded", TimeoutFailure},
+		{"connection timeout", TimeoutFailure},
+		{"request Timed Out after 30s", TimeoutFailure},
+		{"dial tcp 10.0.0.1:5432: connect: connection refused", ConnectionFailure},
+		{"lookup smtp.example.com: no such host", ConnectionFailure},
+		{"write: broken pipe", ConnectionFailure},
+		{"missing 'to' field", ValidationFailure},
+		{"invalid report type: weekly", ValidationFailure},
+		{"unsupported format: xml", ValidationFailure},
+		{"negatively acknowledged", UnknownFailure},
+		{"", UnknownFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			assert.Equal(t, tt.expected, c.Classify(tt.reason))
+		})
+	}
+}
+
+func TestParseFailureClassifier(t *testing.T) {
+	c, err := ParseFailureClassifier("quota=rate limit|429; timeout = slow")
+	require.NoError(t, err)
+
+	assert.Equal(t, FailureCategory("quota"), c.Classify("HTTP 429 Too Many Requests"))
+	assert.Equal(t, TimeoutFailure, c.Classify("upstream was SLOW"))
+	assert.Equal(t, UnknownFailure, c.Classify("connection refused"), "custom rules replace the defaults")
+
+	c, err = ParseFailureClassifier("")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectionFailure, c.Classify("connection reset by peer"))
+}
+
+func TestParseFailureClassifier_Invalid(t *testing.T) {
+	for _, s := range []string{"timeout", "=slow", "timeout=", "timeout=slow;;"} {
+		_, err := ParseFailureClassifier(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -4,6 +4,8 @@ package task
 
 import (
 	"encoding/json"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,20 +15,44 @@ type (
 	TaskStatus   string
 	TaskPriority int
 	Task         struct {
-		ID            string         `json:"id"`
-		Type          string         `json:"type"`
-		Payload       map[string]any `json:"payload"`
-		Priority      TaskPriority   `json:"priority"`
-		Status        TaskStatus     `json:"status"`
-		RetryCount    int            `json:"retry_count"`
-		MaxRetries    int            `json:"max_retries"`
-		CreatedAt     time.Time      `json:"created_at"`
-		ScheduledAt   time.Time      `json:"scheduled_at"`
-		StartedAt     *time.Time     `json:"started_at,omitempty"`
-		CompletedAt   *time.Time     `json:"completed_at,omitempty"`
-		Error         string         `json:"error,omitempty"`
-		FailureReason string         `json:"failure_reason,omitempty"`
-		MoveToDLQAt   *time.Time     `json:"moved_to_dlq_at,omitempty"`
+		ID                 string          `json:"id"`
+		Type               string          `json:"type"`
+		Payload            map[string]any  `json:"payload"`
+		Priority           TaskPriority    `json:"priority"`
+		Status             TaskStatus      `json:"status"`
+		RetryCount         int             `json:"retry_count"`
+		MaxRetries         int             `json:"max_retries"`
+		CreatedAt          time.Time       `json:"created_at"`
+		ScheduledAt        time.Time       `json:"scheduled_at"`
+		StartedAt          *time.Time      `json:"started_at,omitempty"`
+		CompletedAt        *time.Time      `json:"completed_at,omitempty"`
+		Error              string          `json:"error,omitempty"`
+		FailureReason      string          `json:"failure_reason,omitempty"`
+		FailureCategory    FailureCategory `json:"failure_category,omitempty"`
+		MoveToDLQAt        *time.Time      `json:"moved_to_dlq_at,omitempty"`
+		Tags               []string        `json:"tags,omitempty"`
+		ExpectedDurationMs *int            `json:"expected_duration_ms,omitempty"`
+		DependsOn          []string        `json:"depends_on,omitempty"`
+		CorrelationID      string          `json:"correlation_id,omitempty"`
+		CallbackURL        string          `json:"callback_url,omitempty"`
+		RetryDelaySeconds  *int            `json:"retry_delay_seconds,omitempty"`
+		DeadLetter         *bool           `json:"dead_letter,omitempty"`
+		// ExpiresAt is when a task that has not started yet is dropped
+		// instead of run.
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		// Progress is handler-defined work done so far, such as the rows a
+		// running report has written.
+		Progress int `json:"progress,omitempty"`
+	}
+	RecurringTask struct {
+		ID        string         `json:"id"`
+		Cron      string         `json:"cron"`
+		Type      string         `json:"type"`
+		Payload   map[string]any `json:"payload"`
+		Priority  TaskPriority   `json:"priority"`
+		Tags      []string       `json:"tags,omitempty"`
+		CreatedAt time.Time      `json:"created_at"`
+		LastRunAt *time.Time     `json:"last_run_at,omitempty"`
 	}
 )
 
@@ -45,12 +71,23 @@ const (
 	HighPriority
 )
 
+var newID = func() string {
+	return uuid.New().String()
+}
+
+// SetIDGenerator replaces the UUID generator NewTask uses for task IDs, e.g.
+// with one producing time-sortable ULIDs. Call it during startup, before any
+// task is created.
+func SetIDGenerator(gen func() string) {
+	newID = gen
+}
+
 func NewTask(taskType string, payload map[string]any, priority TaskPriority) *Task {
 	return &Task{
-		ID:          uuid.New().String(),
+		ID:          newID(),
 		Type:        taskType,
 		Payload:     payload,
-		Priority:    priority,
+		Priority:    clampPriority(priority),
 		Status:      PendingStatus,
 		MaxRetries:  3,
 		RetryCount:  0,
@@ -69,7 +106,13 @@ func (t *Task) ToJSON() (string, error) {
 }
 
 func (t *Task) ShouldMoveToDeadLetter() bool {
-	return t.RetryCount >= t.MaxRetries && t.Status == FailedStatus
+	return t.DeadLetterEnabled() && t.RetryCount >= t.MaxRetries && t.Status == FailedStatus
+}
+
+// DeadLetterEnabled reports whether an exhausted task moves to the dead letter
+// queue rather than staying failed. Tasks that never set DeadLetter do.
+func (t *Task) DeadLetterEnabled() bool {
+	return t.DeadLetter == nil || *t.DeadLetter
 }
 
 func TaskFromJSON(data string) (*Task, error) {
@@ -82,6 +125,33 @@ func TaskFromJSON(data string) (*Task, error) {
 	return &t, nil
 }
 
+func (t *Task) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, tag)
+}
+
+// Expired reports whether the task's TTL has passed at now.
+func (t *Task) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(now)
+}
+
+func NewRecurringTask(cron string, taskType string, payload map[string]any, priority TaskPriority) *RecurringTask {
+	return &RecurringTask{
+		ID:        uuid.New().String(),
+		Cron:      cron,
+		Type:      taskType,
+		Payload:   payload,
+		Priority:  clampPriority(priority),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (rt *RecurringTask) NewTask() *Task {
+	t := NewTask(rt.Type, rt.Payload, rt.Priority)
+	t.Tags = rt.Tags
+
+	return t
+}
+
 func (p TaskPriority) String() string {
 	switch p {
 	case LowPriority:
@@ -94,3 +164,55 @@ func (p TaskPriority) String() string {
 		return "unknown"
 	}
 }
+
+// clampPriority coerces an out-of-range priority to the nearest valid one, so
+// a task never carries a priority the scheduler has no queue for.
+func clampPriority(p TaskPriority) TaskPriority {
+	return min(max(p, LowPriority), HighPriority)
+}
+
+// ParsePriority maps a priority name as returned by String back to its value.
+func ParsePriority(s string) (TaskPriority, error) {
+	switch s {
+	case "low":
+		return LowPriority, nil
+	case "medium":
+		return MediumPriority, nil
+	case "high":
+		return HighPriority, nil
+	default:
+		return 0, fmt.Errorf("unknown priority: %q (available: low, medium, high)", s)
+	}
+}
+
+// MarshalJSON encodes known priorities by name and anything else as its
+// number.
+func (p TaskPriority) MarshalJSON() ([]byte, error) {
+	if p < LowPriority || p > HighPriority {
+		return json.Marshal(int(p))
+	}
+
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON accepts a priority name as well as the numeric form older
+// clients and stored tasks use.
+func (p *TaskPriority) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		priority, err := ParsePriority(name)
+		if err != nil {
+			return err
+		}
+		*p = priority
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("priority must be a name or a number: %s", data)
+	}
+	*p = TaskPriority(n)
+
+	return nil
+}
@@ -1,10 +1,13 @@
 package task
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTask(t *testing.T) {
@@ -71,6 +74,36 @@ func TestTaskPriorities(t *testing.T) {
 	assert.Equal(t, TaskPriority(2), HighPriority)
 }
 
+func TestTaskPriorityJSON(t *testing.T) {
+	for _, priority := range []TaskPriority{LowPriority, MediumPriority, HighPriority} {
+		t.Run(priority.String(), func(t *testing.T) {
+			data, err := json.Marshal(priority)
+			require.NoError(t, err)
+			assert.Equal(t, `"`+priority.String()+`"`, string(data))
+
+			var restored TaskPriority
+			require.NoError(t, json.Unmarshal(data, &restored))
+			assert.Equal(t, priority, restored)
+		})
+	}
+}
+
+func TestTaskPriorityJSON_Numeric(t *testing.T) {
+	var priority TaskPriority
+	require.NoError(t, json.Unmarshal([]byte("2"), &priority))
+	assert.Equal(t, HighPriority, priority)
+
+	data, err := json.Marshal(TaskPriority(7))
+	require.NoError(t, err)
+	assert.Equal(t, "7", string(data))
+}
+
+func TestTaskPriorityJSON_Invalid(t *testing.T) {
+	var priority TaskPriority
+	assert.Error(t, json.Unmarshal([]byte(`"urgent"`), &priority))
+	assert.Error(t, json.Unmarshal([]byte(`true`), &priority))
+}
+
 func TestTaskJSONRoundTrip(t *testing.T) {
 	now := time.Now()
 	task := &Task{
@@ -102,12 +135,31 @@ func TestTaskJSONRoundTrip(t *testing.T) {
 	assert.Equal(t, task.Error, restored.Error)
 }
 
+func TestSetIDGenerator(t *testing.T) {
+	previous := newID
+	defer SetIDGenerator(previous)
+
+	n := 0
+	SetIDGenerator(func() string {
+		n++
+		return fmt.Sprintf("task-%03d", n)
+	})
+
+	assert.Equal(t, "task-001", NewTask("test", nil, MediumPriority).ID)
+	assert.Equal(t, "task-002", NewTask("test", nil, MediumPriority).ID)
+
+	SetIDGenerator(previous)
+	assert.Len(t, NewTask("test", nil, MediumPriority).ID, 36)
+}
+
 func TestTask_ShouldMoveToDeadLetter(t *testing.T) {
+	enabled, disabled := true, false
 	tests := []struct {
 		name       string
 		retryCount int
 		maxRetries int
 		status     TaskStatus
+		deadLetter *bool
 		expected   bool
 	}{
 		{
@@ -159,6 +211,22 @@ func TestTask_ShouldMoveToDeadLetter(t *testing.T) {
 			status:     FailedStatus,
 			expected:   false,
 		},
+		{
+			name:       "should move when dead lettering is explicitly enabled",
+			retryCount: 3,
+			maxRetries: 3,
+			status:     FailedStatus,
+			deadLetter: &enabled,
+			expected:   true,
+		},
+		{
+			name:       "should not move when dead lettering is disabled",
+			retryCount: 3,
+			maxRetries: 3,
+			status:     FailedStatus,
+			deadLetter: &disabled,
+			expected:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -167,6 +235,7 @@ func TestTask_ShouldMoveToDeadLetter(t *testing.T) {
 				RetryCount: tt.retryCount,
 				MaxRetries: tt.maxRetries,
 				Status:     tt.status,
+				DeadLetter: tt.deadLetter,
 			}
 
 			result := task.ShouldMoveToDeadLetter()
@@ -217,3 +286,40 @@ func TestTaskPriority_String(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTask_ClampsPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority TaskPriority
+		expected TaskPriority
+	}{
+		{"below minimum", TaskPriority(-3), LowPriority},
+		{"low", LowPriority, LowPriority},
+		{"medium", MediumPriority, MediumPriority},
+		{"high", HighPriority, HighPriority},
+		{"above maximum", TaskPriority(99), HighPriority},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NewTask("test", nil, tt.priority).Priority)
+			assert.Equal(t, tt.expected, NewRecurringTask("* * * * *", "test", nil, tt.priority).Priority)
+		})
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	tsk := NewTask("test", nil, MediumPriority)
+	assert.False(t, tsk.HasTag("tenant-a"))
+
+	tsk.Tags = []string{"tenant-a", "batch-1"}
+	assert.True(t, tsk.HasTag("tenant-a"))
+	assert.True(t, tsk.HasTag("batch-1"))
+	assert.False(t, tsk.HasTag("tenant-b"))
+}
+
+func TestTaskFromJSON_WithoutTags(t *testing.T) {
+	tsk, err := TaskFromJSON(`{"id":"task-1","type":"test","status":"pending"}`)
+	require.NoError(t, err)
+	assert.Nil(t, tsk.Tags)
+}
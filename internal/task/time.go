@@ -0,0 +1,142 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type TimeFormat string
+
+const (
+	RFC3339TimeFormat    TimeFormat = "rfc3339"
+	UnixMillisTimeFormat TimeFormat = "unix_ms"
+)
+
+func ParseTimeFormat(s string) (TimeFormat, error) {
+	switch TimeFormat(s) {
+	case "", RFC3339TimeFormat:
+		return RFC3339TimeFormat, nil
+	case UnixMillisTimeFormat:
+		return UnixMillisTimeFormat, nil
+	default:
+		return "", fmt.Errorf("unsupported time format: %s (available: rfc3339, unix_ms)", s)
+	}
+}
+
+// Timestamp is a time field that marshals as unix epoch milliseconds and
+// unmarshals from either epoch milliseconds or an RFC3339 string.
+type Timestamp time.Time
+
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, time.Time(ts).UnixMilli(), 10), nil
+}
+
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if ms, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+		*ts = Timestamp(time.UnixMilli(ms))
+		return nil
+	}
+
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+
+	*ts = Timestamp(t)
+	return nil
+}
+
+func timestampPtr(t *time.Time) *Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	ts := Timestamp(*t)
+	return &ts
+}
+
+func timePtr(ts *Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+
+	t := time.Time(*ts)
+	return &t
+}
+
+type taskAlias Task
+
+type taskWithTimestamps struct {
+	*taskAlias
+	CreatedAt   Timestamp  `json:"created_at"`
+	ScheduledAt Timestamp  `json:"scheduled_at"`
+	StartedAt   *Timestamp `json:"started_at,omitempty"`
+	CompletedAt *Timestamp `json:"completed_at,omitempty"`
+	MoveToDLQAt *Timestamp `json:"moved_to_dlq_at,omitempty"`
+	ExpiresAt   *Timestamp `json:"expires_at,omitempty"`
+}
+
+func (t *Task) UnmarshalJSON(data []byte) error {
+	aux := taskWithTimestamps{taskAlias: (*taskAlias)(t)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	t.CreatedAt = time.Time(aux.CreatedAt)
+	t.ScheduledAt = time.Time(aux.ScheduledAt)
+	t.StartedAt = timePtr(aux.StartedAt)
+	t.CompletedAt = timePtr(aux.CompletedAt)
+	t.MoveToDLQAt = timePtr(aux.MoveToDLQAt)
+	t.ExpiresAt = timePtr(aux.ExpiresAt)
+
+	return nil
+}
+
+func (t *Task) MarshalJSONWithTimeFormat(format TimeFormat) ([]byte, error) {
+	if format != UnixMillisTimeFormat {
+		return json.Marshal((*taskAlias)(t))
+	}
+
+	return json.Marshal(taskWithTimestamps{
+		taskAlias:   (*taskAlias)(t),
+		CreatedAt:   Timestamp(t.CreatedAt),
+		ScheduledAt: Timestamp(t.ScheduledAt),
+		StartedAt:   timestampPtr(t.StartedAt),
+		CompletedAt: timestampPtr(t.CompletedAt),
+		MoveToDLQAt: timestampPtr(t.MoveToDLQAt),
+		ExpiresAt:   timestampPtr(t.ExpiresAt),
+	})
+}
+
+// FormattedTask renders a task with the configured time format when encoded.
+type FormattedTask struct {
+	task   *Task
+	format TimeFormat
+}
+
+func WithTimeFormat(t *Task, format TimeFormat) FormattedTask {
+	return FormattedTask{task: t, format: format}
+}
+
+func WithTimeFormatAll(tasks []*Task, format TimeFormat) []FormattedTask {
+	if tasks == nil {
+		return nil
+	}
+
+	formatted := make([]FormattedTask, 0, len(tasks))
+	for _, t := range tasks {
+		formatted = append(formatted, WithTimeFormat(t, format))
+	}
+
+	return formatted
+}
+
+func (f FormattedTask) MarshalJSON() ([]byte, error) {
+	return f.task.MarshalJSONWithTimeFormat(f.format)
+}
@@ -0,0 +1,105 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    TimeFormat
+		expectError bool
+	}{
+		{input: "", expected: RFC3339TimeFormat},
+		{input: "rfc3339", expected: RFC3339TimeFormat},
+		{input: "unix_ms", expected: UnixMillisTimeFormat},
+		{input: "unix", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			format, err := ParseTimeFormat(tt.input)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestMarshalJSONWithTimeFormat_RFC3339(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tsk := &Task{ID: "task-1", Type: "test", CreatedAt: created, ScheduledAt: created}
+
+	data, err := tsk.MarshalJSONWithTimeFormat(RFC3339TimeFormat)
+	require.NoError(t, err)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "2024-01-02T03:04:05Z", raw["created_at"])
+	assert.NotContains(t, raw, "started_at")
+
+	decoded, err := TaskFromJSON(string(data))
+	require.NoError(t, err)
+	assert.True(t, created.Equal(decoded.CreatedAt))
+}
+
+func TestMarshalJSONWithTimeFormat_UnixMillis(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	started := created.Add(time.Second)
+	tsk := &Task{ID: "task-1", Type: "test", CreatedAt: created, ScheduledAt: created, StartedAt: &started}
+
+	data, err := tsk.MarshalJSONWithTimeFormat(UnixMillisTimeFormat)
+	require.NoError(t, err)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, float64(created.UnixMilli()), raw["created_at"])
+	assert.Equal(t, float64(started.UnixMilli()), raw["started_at"])
+	assert.NotContains(t, raw, "completed_at")
+	assert.Equal(t, "task-1", raw["id"])
+
+	decoded, err := TaskFromJSON(string(data))
+	require.NoError(t, err)
+	assert.True(t, created.Equal(decoded.CreatedAt))
+	require.NotNil(t, decoded.StartedAt)
+	assert.True(t, started.Equal(*decoded.StartedAt))
+	assert.Nil(t, decoded.CompletedAt)
+}
+
+func TestMarshalJSONWithTimeFormat_UnixMillisExpiresAt(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := created.Add(time.Hour)
+	tsk := &Task{ID: "task-1", Type: "test", CreatedAt: created, ScheduledAt: created, ExpiresAt: &expires}
+
+	data, err := tsk.MarshalJSONWithTimeFormat(UnixMillisTimeFormat)
+	require.NoError(t, err)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, float64(expires.UnixMilli()), raw["expires_at"])
+
+	decoded, err := TaskFromJSON(string(data))
+	require.NoError(t, err)
+	require.NotNil(t, decoded.ExpiresAt)
+	assert.True(t, expires.Equal(*decoded.ExpiresAt))
+}
+
+func TestWithTimeFormat(t *testing.T) {
+	created := time.UnixMilli(1700000000000)
+	tsk := &Task{ID: "task-1", CreatedAt: created}
+
+	data, err := json.Marshal(WithTimeFormatAll([]*Task{tsk}, UnixMillisTimeFormat))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"created_at":1700000000000`)
+
+	assert.Nil(t, WithTimeFormatAll(nil, UnixMillisTimeFormat))
+}
@@ -0,0 +1,56 @@
+// Package tracing sets up optional OpenTelemetry tracing exported over OTLP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/nadmax/nexq"
+
+// Attribute keys shared by the HTTP and worker spans. The correlation ID is
+// the request ID of the API call that created a task, so it ties a task's
+// processing span to the request span that enqueued it.
+const (
+	CorrelationIDKey = "nexq.correlation_id"
+	TaskIDKey        = "nexq.task.id"
+	TaskTypeKey      = "nexq.task.type"
+	TaskPriorityKey  = "nexq.task.priority"
+	TaskStatusKey    = "nexq.task.status"
+)
+
+// Tracer returns the tracer of the global provider, which stays a no-op
+// unless Setup installed an exporting one.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs a global tracer provider exporting spans over OTLP/HTTP. The
+// exporter is configured by the standard OTEL_EXPORTER_OTLP_* variables. The
+// returned function flushes pending spans and must be called on shutdown.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
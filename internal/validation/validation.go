@@ -0,0 +1,135 @@
+// Package validation checks task payloads against per-type rules before they are enqueued.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nadmax/nexq/internal/worker/handlers"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Func returns one FieldError per invalid payload field, or none when the
+// payload is acceptable.
+type Func func(payload map[string]any) []FieldError
+
+type Registry struct {
+	validators map[string]Func
+}
+
+func NewRegistry() *Registry {
+	return &Registry{validators: make(map[string]Func)}
+}
+
+// DefaultRegistry returns a registry with the built-in send_email and
+// generate_report validators.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("send_email", ValidateEmailPayload)
+	r.Register("generate_report", ValidateReportPayload)
+	return r
+}
+
+func (r *Registry) Register(taskType string, fn Func) {
+	r.validators[taskType] = fn
+}
+
+// Validate runs the validator registered for taskType. Types without a
+// validator accept any payload.
+func (r *Registry) Validate(taskType string, payload map[string]any) []FieldError {
+	fn, exists := r.validators[taskType]
+	if !exists {
+		return nil
+	}
+
+	return fn(payload)
+}
+
+func ValidateEmailPayload(payload map[string]any) []FieldError {
+	var errs []FieldError
+
+	if to, ok := requiredString(payload, "to", &errs); ok {
+		if _, err := mail.ParseAddress(to); err != nil {
+			errs = append(errs, FieldError{Field: "to", Message: "must be a valid email address"})
+		}
+	}
+	requiredString(payload, "subject", &errs)
+	requiredString(payload, "body", &errs)
+
+	return errs
+}
+
+func ValidateReportPayload(payload map[string]any) []FieldError {
+	var errs []FieldError
+
+	if reportType, ok := requiredString(payload, "report_type", &errs); ok {
+		oneOf(reportType, "report_type", handlers.ReportTypes(), &errs)
+	}
+	if format, ok := optionalString(payload, "format", &errs); ok {
+		oneOf(format, "format", handlers.ReportFormats(), &errs)
+	}
+	if destination, ok := optionalString(payload, "destination", &errs); ok {
+		oneOf(destination, "destination", []string{"local", "s3"}, &errs)
+		if destination == "s3" {
+			requiredString(payload, "bucket", &errs)
+		}
+	}
+	for _, field := range []string{"start_time", "end_time"} {
+		if value, ok := optionalString(payload, field, &errs); ok {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				errs = append(errs, FieldError{Field: field, Message: "must be an RFC3339 timestamp"})
+			}
+		}
+	}
+	if value, exists := payload["schedule_in"]; exists {
+		if seconds, ok := value.(float64); !ok || seconds < 0 || seconds != float64(int(seconds)) {
+			errs = append(errs, FieldError{Field: "schedule_in", Message: "must be a non-negative integer"})
+		}
+	}
+
+	return errs
+}
+
+func requiredString(payload map[string]any, field string, errs *[]FieldError) (string, bool) {
+	value, exists := payload[field]
+	if !exists {
+		*errs = append(*errs, FieldError{Field: field, Message: "is required"})
+		return "", false
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		*errs = append(*errs, FieldError{Field: field, Message: "must be a string"})
+		return "", false
+	}
+	if strings.TrimSpace(s) == "" {
+		*errs = append(*errs, FieldError{Field: field, Message: "must not be empty"})
+		return "", false
+	}
+
+	return s, true
+}
+
+func optionalString(payload map[string]any, field string, errs *[]FieldError) (string, bool) {
+	if _, exists := payload[field]; !exists {
+		return "", false
+	}
+
+	return requiredString(payload, field, errs)
+}
+
+func oneOf(value, field string, allowed []string, errs *[]FieldError) {
+	if !slices.Contains(allowed, value) {
+		*errs = append(*errs, FieldError{
+			Field:   field,
+			Message: fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")),
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fields(errs []FieldError) []string {
+	var names []string
+	for _, e := range errs {
+		names = append(names, e.Field)
+	}
+	return names
+}
+
+func TestValidateEmailPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  map[string]any
+		expected []string
+	}{
+		{
+			name: "valid",
+			payload: map[string]any{
+				"to":      "user@example.com",
+				"subject": "Welcome",
+				"body":    "Hello!",
+			},
+		},
+		{
+			name:     "missing fields",
+			payload:  map[string]any{},
+			expected: []string{"to", "subject", "body"},
+		},
+		{
+			name: "invalid values",
+			payload: map[string]any{
+				"to":      "not-an-address",
+				"subject": 42,
+				"body":    "  ",
+			},
+			expected: []string{"to", "subject", "body"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fields(ValidateEmailPayload(tt.payload)))
+		})
+	}
+}
+
+func TestValidateReportPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  map[string]any
+		expected []string
+	}{
+		{
+			name:    "valid minimal",
+			payload: map[string]any{"report_type": "task_summary"},
+		},
+		{
+			name: "valid full",
+			payload: map[string]any{
+				"report_type": "retry_analysis",
+				"format":      "json",
+				"destination": "s3",
+				"bucket":      "reports",
+				"start_time":  "2026-01-01T00:00:00Z",
+				"end_time":    "2026-01-02T00:00:00Z",
+				"schedule_in": float64(60),
+			},
+		},
+		{
+			name:     "missing report type",
+			payload:  map[string]any{},
+			expected: []string{"report_type"},
+		},
+		{
+			name: "invalid values",
+			payload: map[string]any{
+				"report_type": "unknown",
+				"format":      "xml",
+				"destination": "s3",
+				"start_time":  "yesterday",
+				"schedule_in": float64(-5),
+			},
+			expected: []string{"report_type", "format", "bucket", "start_time", "schedule_in"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fields(ValidateReportPayload(tt.payload)))
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.Empty(t, r.Validate("send_email", nil), "unregistered types accept any payload")
+
+	r.Register("custom", func(payload map[string]any) []FieldError {
+		return []FieldError{{Field: "id", Message: "is required"}}
+	})
+	assert.Equal(t, []string{"id"}, fields(r.Validate("custom", nil)))
+
+	defaults := DefaultRegistry()
+	assert.NotEmpty(t, defaults.Validate("send_email", nil))
+	assert.NotEmpty(t, defaults.Validate("generate_report", nil))
+}
@@ -0,0 +1,24 @@
+// Package version exposes build information injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/nadmax/nexq/internal/version.Version=v1.2.0"
+package version
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+}
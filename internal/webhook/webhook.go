@@ -0,0 +1,149 @@
+// Package webhook delivers JSON notifications to user-supplied HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+// ErrForbiddenAddress is returned when a webhook target resolves to a
+// loopback, private or link-local address that is not explicitly allowed.
+var ErrForbiddenAddress = errors.New("webhook address not allowed")
+
+var errRedirect = errors.New("webhook redirects are not followed")
+
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(timeout time.Duration) *Client {
+	return NewClientWithAllowlist(timeout, nil)
+}
+
+// NewClientWithAllowlist returns a client that refuses to connect to
+// loopback, private and link-local addresses, except those within allowed.
+// The check runs on the address actually dialed, so a hostname resolving to
+// an internal address is caught too. Redirects are never followed.
+func NewClientWithAllowlist(timeout time.Duration, allowed []*net.IPNet) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allowed)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect to the target on our behalf, out of reach of
+	// the address check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Client{http: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errRedirect
+		},
+	}}
+}
+
+func checkAddress(address string, allowed []*net.IPNet) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+
+	return nil
+}
+
+// ParseAllowedNetworks reads a comma-separated list of CIDR blocks, such as
+// "10.0.0.0/8,192.168.1.10/32". An empty string allows none.
+func ParseAllowedNetworks(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for cidr := range strings.SplitSeq(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// Post sends payload as JSON to target and fails on transport errors and
+// non-2xx responses.
+func (c *Client) Post(ctx context.Context, target string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with status %d", target, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ValidateURL accepts absolute http and https URLs. Where the URL may point
+// is enforced by the client when it connects, since a hostname can resolve to
+// a different address by then.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("must use http or https")
+	}
+	if u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+
+	return nil
+}
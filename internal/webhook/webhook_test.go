@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopbackClient is allowed to reach the httptest servers the tests run.
+func loopbackClient(t *testing.T, timeout time.Duration) *Client {
+	allowed, err := ParseAllowedNetworks("127.0.0.0/8,::1/128")
+	require.NoError(t, err)
+
+	return NewClientWithAllowlist(timeout, allowed)
+}
+
+func TestPost(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := loopbackClient(t, time.Second).Post(context.Background(), server.URL, map[string]string{"status": "completed"})
+	require.NoError(t, err)
+	assert.Equal(t, "completed", received["status"])
+}
+
+func TestPost_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := loopbackClient(t, time.Second).Post(context.Background(), server.URL, nil)
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestPost_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	err := loopbackClient(t, 20*time.Millisecond).Post(context.Background(), server.URL, nil)
+	assert.Error(t, err)
+}
+
+func TestPost_RejectsInternalAddresses(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	err := NewClient(time.Second).Post(context.Background(), server.URL, nil)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.False(t, hit)
+
+	for _, address := range []string{"10.1.2.3:80", "192.168.0.1:443", "169.254.169.254:80", "[::1]:80", "[fe80::1]:80", "0.0.0.0:80"} {
+		assert.ErrorIs(t, checkAddress(address, nil), ErrForbiddenAddress, address)
+	}
+	assert.NoError(t, checkAddress("93.184.216.34:443", nil))
+}
+
+func TestPost_DoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	err := loopbackClient(t, time.Second).Post(context.Background(), server.URL, nil)
+	assert.ErrorIs(t, err, errRedirect)
+	assert.False(t, redirected)
+}
+
+func TestParseAllowedNetworks(t *testing.T) {
+	networks, err := ParseAllowedNetworks(" 10.0.0.0/8, 192.168.1.10/32 ")
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	assert.True(t, networks[0].Contains(net.ParseIP("10.20.30.40")))
+	assert.NoError(t, checkAddress("10.20.30.40:80", networks))
+	assert.ErrorIs(t, checkAddress("192.168.1.11:80", networks), ErrForbiddenAddress)
+
+	networks, err = ParseAllowedNetworks("")
+	require.NoError(t, err)
+	assert.Empty(t, networks)
+
+	_, err = ParseAllowedNetworks("10.0.0.0")
+	assert.Error(t, err)
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://example.com/hook"))
+	assert.NoError(t, ValidateURL("http://localhost:8080/hook"))
+	assert.Error(t, ValidateURL("ftp://example.com/hook"))
+	assert.Error(t, ValidateURL("/hook"))
+	assert.Error(t, ValidateURL("https://"))
+}
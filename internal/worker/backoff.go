@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy returns how long to wait before retrying a task that has
+// failed attempt times.
+type BackoffStrategy interface {
+	Next(attempt int) time.Duration
+}
+
+type BackoffFunc func(attempt int) time.Duration
+
+func (f BackoffFunc) Next(attempt int) time.Duration {
+	return f(attempt)
+}
+
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+func (b ConstantBackoff) Next(attempt int) time.Duration {
+	return b.Delay
+}
+
+type LinearBackoff struct {
+	Step time.Duration
+}
+
+func (b LinearBackoff) Next(attempt int) time.Duration {
+	return time.Duration(max(attempt, 1)) * b.Step
+}
+
+// ExponentialBackoff doubles Base on every attempt, capped at Max when set.
+type ExponentialBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (b ExponentialBackoff) Next(attempt int) time.Duration {
+	limit := b.Max
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+
+	delay := b.Base
+	for i := 1; i < attempt && delay < limit; i++ {
+		if delay > limit/2 {
+			return limit
+		}
+		delay *= 2
+	}
+
+	return min(delay, limit)
+}
+
+// ExponentialJitterBackoff picks a random delay between zero and the
+// exponential delay for the attempt, spreading out retries of tasks that
+// failed together.
+type ExponentialJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (b ExponentialJitterBackoff) Next(attempt int) time.Duration {
+	ceiling := ExponentialBackoff(b).Next(attempt)
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+var defaultBackoff BackoffStrategy = LinearBackoff{Step: 10 * time.Second}
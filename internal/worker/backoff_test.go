@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := ConstantBackoff{Delay: 5 * time.Second}
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		assert.Equal(t, 5*time.Second, b.Next(attempt))
+	}
+}
+
+func TestLinearBackoff(t *testing.T) {
+	b := LinearBackoff{Step: 10 * time.Second}
+
+	assert.Equal(t, 10*time.Second, b.Next(0))
+	assert.Equal(t, 10*time.Second, b.Next(1))
+	assert.Equal(t, 20*time.Second, b.Next(2))
+	assert.Equal(t, 50*time.Second, b.Next(5))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Base: time.Second, Max: time.Minute}
+
+	assert.Equal(t, time.Second, b.Next(1))
+	assert.Equal(t, 2*time.Second, b.Next(2))
+	assert.Equal(t, 4*time.Second, b.Next(3))
+	assert.Equal(t, 32*time.Second, b.Next(6))
+	assert.Equal(t, time.Minute, b.Next(7))
+	assert.Equal(t, time.Minute, b.Next(1000))
+
+	uncapped := ExponentialBackoff{Base: time.Second}
+	assert.Equal(t, time.Duration(math.MaxInt64), uncapped.Next(1000))
+}
+
+func TestExponentialJitterBackoff(t *testing.T) {
+	b := ExponentialJitterBackoff{Base: time.Second, Max: time.Minute}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		ceiling := ExponentialBackoff(b).Next(attempt)
+		for range 100 {
+			delay := b.Next(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, ceiling)
+		}
+	}
+
+	seen := make(map[time.Duration]bool)
+	for range 20 {
+		seen[b.Next(6)] = true
+	}
+	assert.Greater(t, len(seen), 1, "jitter should vary the delay")
+}
+
+func TestWorkerUsesBackoffStrategy(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var attempts []int
+	w.SetBackoff(BackoffFunc(func(attempt int) time.Duration {
+		attempts = append(attempts, attempt)
+		return time.Hour
+	}))
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("task failed")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	require.NoError(t, q.Enqueue(tsk))
+
+	before := time.Now()
+	w.processNextTask()
+
+	assert.Equal(t, []int{1}, attempts)
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, before.Add(time.Hour), updated.ScheduledAt, 5*time.Second)
+
+	w.processNextTask()
+	assert.Equal(t, []int{1}, attempts, "the retry waits for its backoff")
+}
+
+func TestWorkerRetriesAfterBackoff(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	const backoff = 100 * time.Millisecond
+	w.SetBackoff(ConstantBackoff{Delay: backoff})
+
+	runs := 0
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		runs++
+		return errors.New("task failed")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+	w.processNextTask()
+	assert.Equal(t, 1, runs, "a retried task is not dequeued before its backoff")
+
+	time.Sleep(backoff)
+	w.processNextTask()
+	assert.Equal(t, 2, runs)
+}
+
+func TestWorkerRetryDelayOverridesBackoff(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.SetBackoff(BackoffFunc(func(attempt int) time.Duration {
+		return time.Hour
+	}))
+
+	runs := 0
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		runs++
+		return errors.New("rate limited")
+	})
+
+	retryDelay := 30
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	tsk.RetryDelaySeconds = &retryDelay
+	require.NoError(t, q.Enqueue(tsk))
+
+	before := time.Now()
+	w.processNextTask()
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, updated.Status)
+	assert.WithinDuration(t, before.Add(30*time.Second), updated.ScheduledAt, 5*time.Second)
+
+	w.processNextTask()
+	assert.Equal(t, 1, runs, "the retry waits for retry_delay_seconds")
+
+	waiting, err := q.Peek(10)
+	require.NoError(t, err)
+	assert.Empty(t, waiting)
+}
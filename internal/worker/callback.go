@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/webhook"
+)
+
+// CallbackPayload is POSTed to a task's callback_url once it reaches a
+// terminal state.
+type CallbackPayload struct {
+	TaskID      string          `json:"task_id"`
+	Type        string          `json:"type"`
+	Status      task.TaskStatus `json:"status"`
+	RetryCount  int             `json:"retry_count"`
+	Error       string          `json:"error,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// notifyCallback delivers the task's final status to its callback URL in the
+// background. Delivery is best-effort: failures are logged and never change
+// the outcome of the task.
+func (w *Worker) notifyCallback(t *task.Task) {
+	if t.CallbackURL == "" {
+		return
+	}
+
+	target := t.CallbackURL
+	payload := CallbackPayload{
+		TaskID:      t.ID,
+		Type:        t.Type,
+		Status:      t.Status,
+		RetryCount:  t.RetryCount,
+		Error:       t.Error,
+		CompletedAt: t.CompletedAt,
+	}
+	logger := w.taskLogger(t)
+
+	w.callbacks.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhook.DefaultTimeout)
+		defer cancel()
+
+		if err := w.webhooks.Post(ctx, target, payload); err != nil {
+			logger.Warn("failed to deliver task callback", "status", payload.Status, "error", err)
+		}
+	})
+}
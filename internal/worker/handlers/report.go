@@ -4,97 +4,208 @@
 package handlers
 
 import (
+	"compress/gzip"
 	"context"
 	"database/sql"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/worker"
 )
 
 type ReportPayload struct {
-	ReportType string `json:"report_type"`
-	StartTime  string `json:"start_time"`
-	EndTime    string `json:"end_time"`
-	Format     string `json:"format"`
-	OutputPath string `json:"output_path"`
-	ScheduleIn int    `json:"schedule_in"`
+	ReportType  string `json:"report_type"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	Format      string `json:"format"`
+	OutputPath  string `json:"output_path"`
+	ScheduleIn  int    `json:"schedule_in"`
+	Destination string `json:"destination"`
+	Bucket      string `json:"bucket"`
+	// Compress gzips the report and appends .gz to its filename.
+	Compress bool `json:"compress"`
+	// CSVDelimiter is the single character separating CSV fields.
+	CSVDelimiter string `json:"csv_delimiter"`
+	// JSONStyle is "envelope" for {generated_at, data, total_rows} or
+	// "array" for a bare array of rows.
+	JSONStyle string `json:"json_style"`
+	// FilenameTemplate names the report file, replacing {type}, {timestamp}
+	// and {format}. It must not contain path separators or "..".
+	FilenameTemplate string `json:"filename_template"`
+	// Timezone is the IANA zone hourly buckets and timestamps are reported
+	// in; empty means the database's zone.
+	Timezone string `json:"timezone"`
 }
 
+const defaultFilenameTemplate = "nexq_{type}_{timestamp}.{format}"
+
+// ReportStore records where locally saved reports are so the API can serve
+// them for download.
+type ReportStore interface {
+	SaveReportInfo(info queue.ReportInfo) error
+}
+
+// ReportGenerator runs generate_report tasks. A worker processes one task at
+// a time, so each worker process runs at most one report query; scale report
+// throughput, and the load it puts on PostgreSQL, with the number of workers.
 type ReportGenerator struct {
-	db *sql.DB
+	db              *sql.DB
+	uploader        ReportUploader
+	store           ReportStore
+	updater         TaskUpdater
+	maxRowsInMemory int
+	// progressInterval is the minimum time between progress updates of a
+	// running report.
+	progressInterval time.Duration
 }
 
 func NewReportGenerator(db *sql.DB) *ReportGenerator {
-	return &ReportGenerator{db: db}
+	return &ReportGenerator{
+		db:               db,
+		maxRowsInMemory:  defaultMaxRowsInMemory,
+		progressInterval: defaultProgressInterval,
+	}
+}
+
+func (rg *ReportGenerator) SetUploader(u ReportUploader) {
+	rg.uploader = u
+}
+
+func (rg *ReportGenerator) SetReportStore(s ReportStore) {
+	rg.store = s
+}
+
+// SetTaskUpdater enables periodic progress updates on the task of a running
+// report.
+func (rg *ReportGenerator) SetTaskUpdater(u TaskUpdater) {
+	rg.updater = u
+}
+
+func (rg *ReportGenerator) SetMaxRowsInMemory(n int) {
+	rg.maxRowsInMemory = n
 }
 
 func (rg *ReportGenerator) GenerateReportHandler(ctx context.Context, t *task.Task) error {
-	payload, err := parsePayload(t.Payload)
+	payload, err := ParsePayload(t.Payload)
 	if err != nil {
-		return fmt.Errorf("invalid payload: %w", err)
+		return worker.Permanent(fmt.Errorf("invalid payload: %w", err))
 	}
 
+	logger := logging.Logger().With("task_id", t.ID, "type", t.Type)
+
 	if payload.ScheduleIn > 0 {
-		log.Printf("[Task %s] Delaying report generation by %d seconds", t.ID, payload.ScheduleIn)
+		logger.Info("delaying report generation", "delay", time.Duration(payload.ScheduleIn)*time.Second)
 
 		select {
 		case <-time.After(time.Duration(payload.ScheduleIn) * time.Second):
 		case <-ctx.Done():
-			log.Printf("[Task %s] Task cancelled during delay", t.ID)
+			logger.Info("task cancelled during delay", "status", task.CancelledStatus)
 			return ctx.Err()
 		}
 	}
 
 	startTime, endTime, err := parseTimeRange(payload)
 	if err != nil {
-		return fmt.Errorf("invalid time range: %w", err)
-	}
-
-	log.Printf("[Task %s] Generating %s report (format: %s, period: %s to %s)",
-		t.ID, payload.ReportType, payload.Format, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
-
-	var data [][]string
-	switch payload.ReportType {
-	case "task_summary":
-		data, err = rg.generateTaskSummary(ctx, startTime, endTime)
-	case "worker_performance":
-		data, err = rg.generateWorkerPerformance(ctx, startTime, endTime)
-	case "failure_analysis":
-		data, err = rg.generateFailureAnalysis(ctx, startTime, endTime)
-	case "hourly_breakdown":
-		data, err = rg.generateHourlyBreakdown(ctx, startTime, endTime)
-	case "retry_analysis":
-		data, err = rg.generateRetryAnalysis(ctx, startTime, endTime)
-	default:
-		return fmt.Errorf("unsupported report type: %s (available: task_summary, worker_performance, failure_analysis, hourly_breakdown, retry_analysis)", payload.ReportType)
+		return worker.Permanent(fmt.Errorf("invalid time range: %w", err))
+	}
+	window := reportWindow{start: startTime, end: endTime}
+	if payload.Timezone != "" {
+		if window.location, err = time.LoadLocation(payload.Timezone); err != nil {
+			return worker.Permanent(fmt.Errorf("invalid timezone: %w", err))
+		}
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed to generate report: %w", err)
+	if err := rg.checkDestination(payload); err != nil {
+		return err
+	}
+
+	logger.Info("generating report",
+		"report_type", payload.ReportType,
+		"format", payload.Format,
+		"start_time", startTime.Format(time.RFC3339),
+		"end_time", endTime.Format(time.RFC3339))
+
+	definition, ok := reportDefinitions[payload.ReportType]
+	if !ok {
+		return worker.Permanent(unsupportedReportTypeError(payload.ReportType))
 	}
 
-	if ctx.Err() != nil {
-		log.Printf("[Task %s] Task cancelled after data generation", t.ID)
-		return ctx.Err()
+	render := func(w reportWriter) (int, error) {
+		progress := &progressReportWriter{
+			reportWriter: w,
+			ctx:          ctx,
+			t:            t,
+			updater:      rg.updater,
+			logger:       logger,
+			interval:     rg.progressInterval,
+		}
+		return definition.generate(rg, ctx, progress, window)
 	}
 
-	outputFile, err := saveReport(payload, data)
+	var location string
+	var rowCount int
+	if payload.Destination == "s3" {
+		location, rowCount, err = rg.uploadReport(ctx, payload, render)
+	} else {
+		location, rowCount, err = saveReport(payload, render)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to save report: %w", err)
+		if ctx.Err() != nil {
+			logger.Info("task cancelled during report generation", "status", task.CancelledStatus)
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("failed to generate report: %w", err)
 	}
 
-	log.Printf("[Task %s] Report generated successfully: %s (%d rows)", t.ID, outputFile, len(data)-1)
+	if payload.Destination == "local" && rg.store != nil {
+		format := payload.Format
+		if payload.Compress {
+			format = "gz"
+		}
+		info := queue.ReportInfo{ID: t.ID, Path: location, Format: format, GeneratedAt: time.Now()}
+		if err := rg.store.SaveReportInfo(info); err != nil {
+			logger.Warn("failed to record report for download", "location", location, "error", err)
+		}
+	}
+
+	t.Progress = rowCount
+	logger.Info("report generated", "location", location, "rows", rowCount)
 	return nil
 }
 
-func parsePayload(payload map[string]any) (*ReportPayload, error) {
+// ReportContentType returns the MIME type of reports in the given format.
+func ReportContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "json":
+		return "application/json"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case "gz":
+		return "application/gzip"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func unsupportedReportTypeError(reportType string) error {
+	return fmt.Errorf("unsupported report type: %s (available: %s)", reportType, strings.Join(ReportTypes(), ", "))
+}
+
+func ParsePayload(payload map[string]any) (*ReportPayload, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
@@ -108,16 +219,78 @@ func parsePayload(payload map[string]any) (*ReportPayload, error) {
 	if rp.ReportType == "" {
 		return nil, errors.New("missing required field: report_type")
 	}
+	if _, ok := reportDefinitions[rp.ReportType]; !ok {
+		return nil, unsupportedReportTypeError(rp.ReportType)
+	}
 	if rp.OutputPath == "" {
 		rp.OutputPath = "./reports"
 	}
 	if rp.Format == "" {
 		rp.Format = "csv"
 	}
+	if rp.CSVDelimiter == "" {
+		rp.CSVDelimiter = ","
+	}
+	if err := validateCSVDelimiter(rp.CSVDelimiter); err != nil {
+		return nil, err
+	}
+	if rp.JSONStyle == "" {
+		rp.JSONStyle = jsonEnvelopeStyle
+	}
+	if rp.Timezone != "" {
+		if _, err := time.LoadLocation(rp.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", rp.Timezone, err)
+		}
+	}
+	if rp.FilenameTemplate == "" {
+		rp.FilenameTemplate = defaultFilenameTemplate
+	}
+	if err := validateFilenameTemplate(rp.FilenameTemplate); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(jsonStyles, rp.JSONStyle) {
+		return nil, fmt.Errorf("unsupported json_style: %s (available: %s)", rp.JSONStyle, strings.Join(jsonStyles, ", "))
+	}
+	switch rp.Destination {
+	case "":
+		rp.Destination = "local"
+	case "local":
+	case "s3":
+		if rp.Bucket == "" {
+			return nil, errors.New("missing required field for s3 destination: bucket")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported destination: %s (available: local, s3)", rp.Destination)
+	}
 
 	return &rp, nil
 }
 
+// reportWindow is the time range a report covers and the zone its hours and
+// timestamps are reported in; a nil location leaves them in the database's
+// zone.
+type reportWindow struct {
+	start, end time.Time
+	location   *time.Location
+}
+
+// timezone is the zone name passed to queries, empty for the database's.
+func (w reportWindow) timezone() string {
+	if w.location == nil {
+		return ""
+	}
+
+	return w.location.String()
+}
+
+func (w reportWindow) local(t time.Time) time.Time {
+	if w.location == nil {
+		return t
+	}
+
+	return t.In(w.location)
+}
+
 func parseTimeRange(payload *ReportPayload) (time.Time, time.Time, error) {
 	var startTime, endTime time.Time
 	var err error
@@ -143,14 +316,14 @@ func parseTimeRange(payload *ReportPayload) (time.Time, time.Time, error) {
 	return startTime, endTime, nil
 }
 
-func (rg *ReportGenerator) generateTaskSummary(ctx context.Context, startTime, endTime time.Time) ([][]string, error) {
+func (rg *ReportGenerator) generateTaskSummary(ctx context.Context, w reportWriter, window reportWindow) (int, error) {
 	query := `
 		SELECT 
 			type,
 			COUNT(*) as total_tasks,
 			COUNT(*) FILTER (WHERE status = 'completed') as completed,
 			COUNT(*) FILTER (WHERE status = 'failed') as failed,
-			COUNT(*) FILTER (WHERE status = 'moved_to_dlq') as moved_to_dlq,
+			COUNT(*) FILTER (WHERE status = 'dead_letter') as moved_to_dlq,
 			AVG(retry_count) as avg_retries,
 			AVG(duration_ms) FILTER (WHERE duration_ms IS NOT NULL) as avg_duration_ms,
 			MAX(duration_ms) as max_duration_ms,
@@ -162,20 +335,21 @@ func (rg *ReportGenerator) generateTaskSummary(ctx context.Context, startTime, e
 		ORDER BY total_tasks DESC
 	`
 
-	rows, err := rg.db.QueryContext(ctx, query, startTime, endTime)
+	rows, err := rg.db.QueryContext(ctx, query, window.start, window.end)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("failed to close rows: %v", closeErr)
+			logging.Logger().Warn("failed to close rows", "error", closeErr)
 		}
 	}()
 
-	data := [][]string{
-		{"Task Type", "Total", "Completed", "Failed", "DLQ", "Avg Retries", "Avg Duration (ms)", "Max Duration (ms)", "Min Duration (ms)", "Success Rate (%)"},
+	if err := w.Write([]string{"Task Type", "Total", "Completed", "Failed", "DLQ", "Avg Retries", "Avg Duration (ms)", "Max Duration (ms)", "Min Duration (ms)", "Success Rate (%)"}); err != nil {
+		return 0, err
 	}
 
+	count := 0
 	for rows.Next() {
 		var taskType string
 		var total, completed, failed, dlq int
@@ -184,10 +358,10 @@ func (rg *ReportGenerator) generateTaskSummary(ctx context.Context, startTime, e
 
 		err := rows.Scan(&taskType, &total, &completed, &failed, &dlq, &avgRetries, &avgDuration, &maxDuration, &minDuration, &successRate)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			return count, fmt.Errorf("scan failed: %w", err)
 		}
 
-		data = append(data, []string{
+		if err := w.Write([]string{
 			taskType,
 			fmt.Sprintf("%d", total),
 			fmt.Sprintf("%d", completed),
@@ -198,13 +372,16 @@ func (rg *ReportGenerator) generateTaskSummary(ctx context.Context, startTime, e
 			formatInt64(maxDuration),
 			formatInt64(minDuration),
 			formatFloat(successRate, 2),
-		})
+		}); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return data, rows.Err()
+	return count, rows.Err()
 }
 
-func (rg *ReportGenerator) generateWorkerPerformance(ctx context.Context, startTime, endTime time.Time) ([][]string, error) {
+func (rg *ReportGenerator) generateWorkerPerformance(ctx context.Context, w reportWriter, window reportWindow) (int, error) {
 	query := `
 		SELECT 
 			COALESCE(worker_id, 'unknown') as worker_id,
@@ -221,20 +398,21 @@ func (rg *ReportGenerator) generateWorkerPerformance(ctx context.Context, startT
 		ORDER BY tasks_processed DESC
 	`
 
-	rows, err := rg.db.QueryContext(ctx, query, startTime, endTime)
+	rows, err := rg.db.QueryContext(ctx, query, window.start, window.end)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("failed to close rows: %v", closeErr)
+			logging.Logger().Warn("failed to close rows", "error", closeErr)
 		}
 	}()
 
-	data := [][]string{
-		{"Worker ID", "Tasks Processed", "Completed", "Failed", "Avg Duration (ms)", "Max Duration (ms)", "Success Rate (%)"},
+	if err := w.Write([]string{"Worker ID", "Tasks Processed", "Completed", "Failed", "Avg Duration (ms)", "Max Duration (ms)", "Success Rate (%)"}); err != nil {
+		return 0, err
 	}
 
+	count := 0
 	for rows.Next() {
 		var workerID string
 		var tasksProcessed, completed, failed int
@@ -243,10 +421,10 @@ func (rg *ReportGenerator) generateWorkerPerformance(ctx context.Context, startT
 
 		err := rows.Scan(&workerID, &tasksProcessed, &completed, &failed, &avgDuration, &maxDuration, &successRate)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			return count, fmt.Errorf("scan failed: %w", err)
 		}
 
-		data = append(data, []string{
+		if err := w.Write([]string{
 			workerID,
 			fmt.Sprintf("%d", tasksProcessed),
 			fmt.Sprintf("%d", completed),
@@ -254,93 +432,103 @@ func (rg *ReportGenerator) generateWorkerPerformance(ctx context.Context, startT
 			formatFloat(avgDuration, 0),
 			formatInt64(maxDuration),
 			formatFloat(successRate, 2),
-		})
+		}); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return data, rows.Err()
+	return count, rows.Err()
 }
 
-func (rg *ReportGenerator) generateFailureAnalysis(ctx context.Context, startTime, endTime time.Time) ([][]string, error) {
+func (rg *ReportGenerator) generateFailureAnalysis(ctx context.Context, w reportWriter, window reportWindow) (int, error) {
 	query := `
 		SELECT 
 			type,
+			COALESCE(failure_category, 'unknown') as category,
 			LEFT(COALESCE(failure_reason, last_error, 'unknown'), 100) as error_type,
 			COUNT(*) as occurrences,
 			MAX(created_at) as last_occurrence,
 			AVG(retry_count) as avg_retry_count
 		FROM task_history
 		WHERE created_at BETWEEN $1 AND $2
-			AND status IN ('failed', 'moved_to_dlq')
-		GROUP BY type, LEFT(COALESCE(failure_reason, last_error, 'unknown'), 100)
+			AND status IN ('failed', 'dead_letter')
+		GROUP BY type, COALESCE(failure_category, 'unknown'), LEFT(COALESCE(failure_reason, last_error, 'unknown'), 100)
 		ORDER BY occurrences DESC
 		LIMIT 50
 	`
 
-	rows, err := rg.db.QueryContext(ctx, query, startTime, endTime)
+	rows, err := rg.db.QueryContext(ctx, query, window.start, window.end)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("failed to close rows: %v", closeErr)
+			logging.Logger().Warn("failed to close rows", "error", closeErr)
 		}
 	}()
 
-	data := [][]string{
-		{"Task Type", "Error", "Occurrences", "Last Occurrence", "Avg Retry Count"},
+	if err := w.Write([]string{"Task Type", "Category", "Error", "Occurrences", "Last Occurrence", "Avg Retry Count"}); err != nil {
+		return 0, err
 	}
 
+	count := 0
 	for rows.Next() {
-		var taskType, errorType string
+		var taskType, category, errorType string
 		var occurrences int
 		var lastOccurrence time.Time
 		var avgRetryCount sql.NullFloat64
 
-		err := rows.Scan(&taskType, &errorType, &occurrences, &lastOccurrence, &avgRetryCount)
+		err := rows.Scan(&taskType, &category, &errorType, &occurrences, &lastOccurrence, &avgRetryCount)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			return count, fmt.Errorf("scan failed: %w", err)
 		}
 
-		data = append(data, []string{
+		if err := w.Write([]string{
 			taskType,
+			category,
 			errorType,
 			fmt.Sprintf("%d", occurrences),
-			lastOccurrence.Format("2006-01-02 15:04:05"),
+			window.local(lastOccurrence).Format("2006-01-02 15:04:05"),
 			formatFloat(avgRetryCount, 2),
-		})
+		}); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return data, rows.Err()
+	return count, rows.Err()
 }
 
-func (rg *ReportGenerator) generateHourlyBreakdown(ctx context.Context, startTime, endTime time.Time) ([][]string, error) {
+func (rg *ReportGenerator) generateHourlyBreakdown(ctx context.Context, w reportWriter, window reportWindow) (int, error) {
 	query := `
 		SELECT 
-			DATE_TRUNC('hour', created_at) as hour,
+			DATE_TRUNC('hour', created_at AT TIME ZONE COALESCE(NULLIF($3, ''), current_setting('TimeZone'))) as hour,
 			COUNT(*) as total_tasks,
 			COUNT(*) FILTER (WHERE status = 'completed') as completed,
 			COUNT(*) FILTER (WHERE status = 'failed') as failed,
 			AVG(duration_ms) FILTER (WHERE duration_ms IS NOT NULL) as avg_duration_ms
 		FROM task_history
 		WHERE created_at BETWEEN $1 AND $2
-		GROUP BY DATE_TRUNC('hour', created_at)
+		GROUP BY 1
 		ORDER BY hour DESC
 	`
 
-	rows, err := rg.db.QueryContext(ctx, query, startTime, endTime)
+	rows, err := rg.db.QueryContext(ctx, query, window.start, window.end, window.timezone())
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("failed to close rows: %v", closeErr)
+			logging.Logger().Warn("failed to close rows", "error", closeErr)
 		}
 	}()
 
-	data := [][]string{
-		{"Hour", "Total Tasks", "Completed", "Failed", "Avg Duration (ms)"},
+	if err := w.Write([]string{"Hour", "Total Tasks", "Completed", "Failed", "Avg Duration (ms)"}); err != nil {
+		return 0, err
 	}
 
+	count := 0
 	for rows.Next() {
 		var hour time.Time
 		var total, completed, failed int
@@ -348,22 +536,25 @@ func (rg *ReportGenerator) generateHourlyBreakdown(ctx context.Context, startTim
 
 		err := rows.Scan(&hour, &total, &completed, &failed, &avgDuration)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			return count, fmt.Errorf("scan failed: %w", err)
 		}
 
-		data = append(data, []string{
+		if err := w.Write([]string{
 			hour.Format("2006-01-02 15:00"),
 			fmt.Sprintf("%d", total),
 			fmt.Sprintf("%d", completed),
 			fmt.Sprintf("%d", failed),
 			formatFloat(avgDuration, 0),
-		})
+		}); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return data, rows.Err()
+	return count, rows.Err()
 }
 
-func (rg *ReportGenerator) generateRetryAnalysis(ctx context.Context, startTime, endTime time.Time) ([][]string, error) {
+func (rg *ReportGenerator) generateRetryAnalysis(ctx context.Context, w reportWriter, window reportWindow) (int, error) {
 	query := `
 		SELECT 
 			type,
@@ -371,7 +562,7 @@ func (rg *ReportGenerator) generateRetryAnalysis(ctx context.Context, startTime,
 			COUNT(*) as task_count,
 			COUNT(*) FILTER (WHERE status = 'completed') as eventually_succeeded,
 			COUNT(*) FILTER (WHERE status = 'failed') as failed,
-			COUNT(*) FILTER (WHERE status = 'moved_to_dlq') as moved_to_dlq
+			COUNT(*) FILTER (WHERE status = 'dead_letter') as moved_to_dlq
 		FROM task_history
 		WHERE created_at BETWEEN $1 AND $2
 			AND retry_count > 0
@@ -379,40 +570,44 @@ func (rg *ReportGenerator) generateRetryAnalysis(ctx context.Context, startTime,
 		ORDER BY type, retry_count
 	`
 
-	rows, err := rg.db.QueryContext(ctx, query, startTime, endTime)
+	rows, err := rg.db.QueryContext(ctx, query, window.start, window.end)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("failed to close rows: %v", closeErr)
+			logging.Logger().Warn("failed to close rows", "error", closeErr)
 		}
 	}()
 
-	data := [][]string{
-		{"Task Type", "Retry Count", "Total", "Eventually Succeeded", "Failed", "Moved to DLQ"},
+	if err := w.Write([]string{"Task Type", "Retry Count", "Total", "Eventually Succeeded", "Failed", "Moved to DLQ"}); err != nil {
+		return 0, err
 	}
 
+	count := 0
 	for rows.Next() {
 		var taskType string
 		var retryCount, taskCount, succeeded, failed, dlq int
 
 		err := rows.Scan(&taskType, &retryCount, &taskCount, &succeeded, &failed, &dlq)
 		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			return count, fmt.Errorf("scan failed: %w", err)
 		}
 
-		data = append(data, []string{
+		if err := w.Write([]string{
 			taskType,
 			fmt.Sprintf("%d", retryCount),
 			fmt.Sprintf("%d", taskCount),
 			fmt.Sprintf("%d", succeeded),
 			fmt.Sprintf("%d", failed),
 			fmt.Sprintf("%d", dlq),
-		})
+		}); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return data, rows.Err()
+	return count, rows.Err()
 }
 
 func formatFloat(val sql.NullFloat64, precision int) string {
@@ -429,77 +624,147 @@ func formatInt64(val sql.NullInt64) string {
 	return fmt.Sprintf("%d", val.Int64)
 }
 
-func saveReport(payload *ReportPayload, data [][]string) (string, error) {
+func (rg *ReportGenerator) checkDestination(payload *ReportPayload) error {
+	if payload.Destination == "s3" {
+		if rg.uploader == nil {
+			return worker.Permanent(errors.New("s3 destination is not configured"))
+		}
+		return nil
+	}
+
 	if err := os.MkdirAll(payload.OutputPath, 0755); err != nil {
-		return "", err
+		return fmt.Errorf("output path not writable: %w", err)
 	}
 
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("nexq_%s_%s.%s", payload.ReportType, timestamp, payload.Format)
-	fullPath := filepath.Join(payload.OutputPath, filename)
+	probe, err := os.CreateTemp(payload.OutputPath, ".nexq-probe-*")
+	if err != nil {
+		return fmt.Errorf("output path not writable: %w", err)
+	}
 
-	switch payload.Format {
-	case "csv":
-		return fullPath, saveAsCSV(fullPath, data)
-	case "json":
-		return fullPath, saveAsJSON(fullPath, data)
-	default:
-		return "", fmt.Errorf("unsupported format: %s", payload.Format)
+	if err := probe.Close(); err != nil {
+		logging.Logger().Warn("failed to close probe file", "error", err)
+	}
+	if err := os.Remove(probe.Name()); err != nil {
+		logging.Logger().Warn("failed to remove probe file", "path", probe.Name(), "error", err)
 	}
+
+	return nil
 }
 
-func saveAsCSV(path string, data [][]string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+// validateFilenameTemplate keeps a report inside its output path: the
+// placeholders expand to safe values, so only the template itself can carry
+// a path separator or a parent reference.
+func validateFilenameTemplate(template string) error {
+	if strings.ContainsAny(template, `/\`) || strings.Contains(template, "..") || template == "." {
+		return fmt.Errorf("invalid filename_template %q: must not contain '/', '\\' or '..'", template)
+	}
+
+	return nil
+}
+
+func reportFilename(payload *ReportPayload) string {
+	template := payload.FilenameTemplate
+	if template == "" {
+		template = defaultFilenameTemplate
 	}
-	defer func() {
-		if fileErr := file.Close(); err != nil {
-			log.Printf("failed to close file: %v", fileErr)
-		}
-	}()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	filename := strings.NewReplacer(
+		"{type}", payload.ReportType,
+		"{timestamp}", time.Now().Format("20060102_150405"),
+		"{format}", payload.Format,
+	).Replace(template)
+	if payload.Compress {
+		filename += ".gz"
+	}
 
-	return writer.WriteAll(data)
+	return filename
 }
 
-func saveAsJSON(path string, data [][]string) error {
-	file, err := os.Create(path)
+func renderReport(w io.Writer, payload *ReportPayload, generate func(reportWriter) (int, error)) (int, error) {
+	var gz *gzip.Writer
+	if payload.Compress {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	rw, err := newReportWriter(payload, w)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer func() {
-		if fileErr := file.Close(); err != nil {
-			log.Printf("failed to close file: %v", fileErr)
+
+	rowCount, err := generate(rw)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := rw.Close(); err != nil {
+		return 0, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return 0, err
 		}
-	}()
+	}
 
-	if len(data) < 2 {
-		return errors.New("insufficient data for JSON export")
+	return rowCount, nil
+}
+
+func saveReport(payload *ReportPayload, generate func(reportWriter) (int, error)) (string, int, error) {
+	if err := os.MkdirAll(payload.OutputPath, 0755); err != nil {
+		return "", 0, err
 	}
 
-	headers := data[0]
-	rows := data[1:]
+	fullPath := filepath.Join(payload.OutputPath, reportFilename(payload))
+
+	file, err := os.Create(fullPath)
+	if err != nil {
+		return "", 0, err
+	}
 
-	var records []map[string]string
-	for _, row := range rows {
-		record := make(map[string]string)
-		for i, header := range headers {
-			if i < len(row) {
-				record[header] = row[i]
-			}
+	// A failed close can mean buffered data never reached the disk, so it
+	// fails the report like any other write error.
+	rowCount, err := renderReport(file, payload, generate)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close report file: %w", closeErr)
+	}
+	if err != nil {
+		if removeErr := os.Remove(fullPath); removeErr != nil {
+			logging.Logger().Warn("failed to remove incomplete report", "path", fullPath, "error", removeErr)
 		}
+		return "", 0, err
+	}
+
+	return fullPath, rowCount, nil
+}
 
-		records = append(records, record)
+func (rg *ReportGenerator) uploadReport(ctx context.Context, payload *ReportPayload, generate func(reportWriter) (int, error)) (string, int, error) {
+	if rg.uploader == nil {
+		return "", 0, errors.New("s3 destination is not configured")
 	}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(map[string]any{
-		"generated_at": time.Now().Format(time.RFC3339),
-		"data":         records,
-		"total_rows":   len(records),
+	buf := newSpillBuffer(rg.maxRowsInMemory)
+	defer func() {
+		if err := buf.Close(); err != nil {
+			logging.Logger().Warn("failed to close spilled report", "error", err)
+		}
+	}()
+
+	rowCount, err := renderReport(buf, payload, func(w reportWriter) (int, error) {
+		return generate(&spillingReportWriter{reportWriter: w, buf: buf})
 	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	body, err := buf.Reader()
+	if err != nil {
+		return "", 0, err
+	}
+
+	key := path.Join(payload.OutputPath, reportFilename(payload))
+	if err := rg.uploader.Upload(ctx, payload.Bucket, key, body); err != nil {
+		return "", 0, fmt.Errorf("failed to upload report: %w", err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", payload.Bucket, key), rowCount, nil
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nadmax/nexq/internal/task"
+)
+
+const defaultProgressInterval = 5 * time.Second
+
+// TaskUpdater persists a task, letting the report generator publish the
+// progress of a running report.
+type TaskUpdater interface {
+	UpdateTask(t *task.Task) error
+}
+
+// progressReportWriter counts the rows written after the header and, at most
+// once per interval, records the count as the task's progress.
+type progressReportWriter struct {
+	reportWriter
+	ctx      context.Context
+	t        *task.Task
+	updater  TaskUpdater
+	logger   *slog.Logger
+	interval time.Duration
+
+	headerWritten bool
+	rows          int
+	lastUpdate    time.Time
+}
+
+func (pw *progressReportWriter) Write(record []string) error {
+	if err := pw.reportWriter.Write(record); err != nil {
+		return err
+	}
+
+	if !pw.headerWritten {
+		pw.headerWritten = true
+		pw.lastUpdate = time.Now()
+		return nil
+	}
+
+	pw.rows++
+	if time.Since(pw.lastUpdate) >= pw.interval {
+		pw.lastUpdate = time.Now()
+		pw.report()
+	}
+
+	return nil
+}
+
+// report stores the current row count on the task. It is skipped once the
+// task's context is done so a late update cannot overwrite a cancellation.
+func (pw *progressReportWriter) report() {
+	if pw.ctx.Err() != nil {
+		return
+	}
+
+	pw.t.Progress = pw.rows
+	pw.logger.Info("report progress", "rows", pw.rows)
+
+	if pw.updater == nil {
+		return
+	}
+	if err := pw.updater.UpdateTask(pw.t); err != nil {
+		pw.logger.Warn("failed to record report progress", "rows", pw.rows, "error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/nadmax/nexq/internal/logging"
+)
+
+const defaultMaxRowsInMemory = 10000
+
+// spillBuffer holds a rendered report in memory until more than maxRows rows
+// have been written, then moves it to a temporary file so that reports which
+// must be complete before they are handed off keep a bounded heap.
+type spillBuffer struct {
+	maxRows int
+	rows    int
+	mem     bytes.Buffer
+	file    *os.File
+}
+
+func newSpillBuffer(maxRows int) *spillBuffer {
+	return &spillBuffer{maxRows: maxRows}
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file != nil {
+		return b.file.Write(p)
+	}
+
+	return b.mem.Write(p)
+}
+
+func (b *spillBuffer) rowWritten() error {
+	b.rows++
+	if b.file != nil || b.rows <= b.maxRows {
+		return nil
+	}
+
+	return b.spill()
+}
+
+func (b *spillBuffer) spill() error {
+	file, err := os.CreateTemp("", "nexq-report-*")
+	if err != nil {
+		return err
+	}
+	b.file = file
+
+	if _, err := b.mem.WriteTo(file); err != nil {
+		return err
+	}
+	b.mem = bytes.Buffer{}
+
+	return nil
+}
+
+func (b *spillBuffer) spilled() bool {
+	return b.file != nil
+}
+
+func (b *spillBuffer) Reader() (io.Reader, error) {
+	if b.file == nil {
+		return &b.mem, nil
+	}
+
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return b.file, nil
+}
+
+func (b *spillBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+	if removeErr := os.Remove(b.file.Name()); removeErr != nil {
+		logging.Logger().Warn("failed to remove spilled report", "path", b.file.Name(), "error", removeErr)
+	}
+
+	return err
+}
+
+type spillingReportWriter struct {
+	reportWriter
+	buf *spillBuffer
+}
+
+func (sw *spillingReportWriter) Write(record []string) error {
+	if err := sw.reportWriter.Write(record); err != nil {
+		return err
+	}
+
+	return sw.buf.rowWritten()
+}
@@ -1,17 +1,26 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/worker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -60,6 +69,88 @@ func TestParsePayload(t *testing.T) {
 			payload:     map[string]any{},
 			expectError: true,
 		},
+		{
+			name: "s3 destination",
+			payload: map[string]any{
+				"report_type": "task_summary",
+				"destination": "s3",
+				"bucket":      "reports-bucket",
+			},
+			expected: &ReportPayload{
+				ReportType: "task_summary",
+				Format:     "csv",
+				OutputPath: "./reports",
+			},
+			expectError: false,
+		},
+		{
+			name: "s3 destination without bucket",
+			payload: map[string]any{
+				"report_type": "task_summary",
+				"destination": "s3",
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported destination",
+			payload: map[string]any{
+				"report_type": "task_summary",
+				"destination": "ftp",
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported report_type",
+			payload: map[string]any{
+				"report_type": "unsupported_type",
+			},
+			expectError: true,
+		},
+		{
+			name: "semicolon delimiter",
+			payload: map[string]any{
+				"report_type":   "task_summary",
+				"csv_delimiter": ";",
+			},
+			expected: &ReportPayload{
+				ReportType: "task_summary",
+				Format:     "csv",
+				OutputPath: "./reports",
+			},
+			expectError: false,
+		},
+		{
+			name: "multi-character delimiter",
+			payload: map[string]any{
+				"report_type":   "task_summary",
+				"csv_delimiter": ";;",
+			},
+			expectError: true,
+		},
+		{
+			name: "filename template with parent reference",
+			payload: map[string]any{
+				"report_type":       "task_summary",
+				"filename_template": "../../etc/{type}.{format}",
+			},
+			expectError: true,
+		},
+		{
+			name: "filename template with path separator",
+			payload: map[string]any{
+				"report_type":       "task_summary",
+				"filename_template": "nested/{type}.{format}",
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported json_style",
+			payload: map[string]any{
+				"report_type": "task_summary",
+				"json_style":  "ndjson",
+			},
+			expectError: true,
+		},
 		{
 			name: "json format",
 			payload: map[string]any{
@@ -77,7 +168,7 @@ func TestParsePayload(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parsePayload(tt.payload)
+			result, err := ParsePayload(tt.payload)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -88,6 +179,12 @@ func TestParsePayload(t *testing.T) {
 			assert.Equal(t, tt.expected.ReportType, result.ReportType)
 			assert.Equal(t, tt.expected.Format, result.Format)
 			assert.Equal(t, tt.expected.OutputPath, result.OutputPath)
+			if delimiter, ok := tt.payload["csv_delimiter"]; ok {
+				assert.Equal(t, delimiter, result.CSVDelimiter)
+			} else {
+				assert.Equal(t, ",", result.CSVDelimiter)
+			}
+			assert.Equal(t, "envelope", result.JSONStyle)
 		})
 	}
 }
@@ -166,9 +263,12 @@ func TestGenerateTaskSummary(t *testing.T) {
 		WithArgs(startTime, endTime).
 		WillReturnRows(rows)
 
-	data, err := rg.generateTaskSummary(context.Background(), startTime, endTime)
+	rec := &recordingWriter{}
+	rowCount, err := rg.generateTaskSummary(context.Background(), rec, reportWindow{start: startTime, end: endTime})
+	data := rec.records
 
 	require.NoError(t, err)
+	assert.Equal(t, len(data)-1, rowCount)
 	assert.Len(t, data, 3) // header + 2 rows
 	assert.Equal(t, "Task Type", data[0][0])
 	assert.Equal(t, "email", data[1][0])
@@ -198,9 +298,12 @@ func TestGenerateWorkerPerformance(t *testing.T) {
 		WithArgs(startTime, endTime).
 		WillReturnRows(rows)
 
-	data, err := rg.generateWorkerPerformance(context.Background(), startTime, endTime)
+	rec := &recordingWriter{}
+	rowCount, err := rg.generateWorkerPerformance(context.Background(), rec, reportWindow{start: startTime, end: endTime})
+	data := rec.records
 
 	require.NoError(t, err)
+	assert.Equal(t, len(data)-1, rowCount)
 	assert.Len(t, data, 3)
 	assert.Equal(t, "Worker ID", data[0][0])
 	assert.Equal(t, "worker-1", data[1][0])
@@ -220,23 +323,28 @@ func TestGenerateFailureAnalysis(t *testing.T) {
 	lastOccurrence := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 
 	rows := sqlmock.NewRows([]string{
-		"type", "error_type", "occurrences", "last_occurrence", "avg_retry_count",
+		"type", "category", "error_type", "occurrences", "last_occurrence", "avg_retry_count",
 	}).
-		AddRow("email", "connection timeout", 10, lastOccurrence, 2.5).
-		AddRow("report", "invalid data format", 5, lastOccurrence, 1.0)
+		AddRow("email", "timeout", "connection timeout", 10, lastOccurrence, 2.5).
+		AddRow("report", "validation", "invalid data format", 5, lastOccurrence, 1.0)
 
-	mock.ExpectQuery(`SELECT\s+type,\s+LEFT\(COALESCE.*FROM task_history.*WHERE.*status IN`).
+	mock.ExpectQuery(`SELECT\s+type,\s+COALESCE\(failure_category.*LEFT\(COALESCE.*FROM task_history.*WHERE.*status IN`).
 		WithArgs(startTime, endTime).
 		WillReturnRows(rows)
 
-	data, err := rg.generateFailureAnalysis(context.Background(), startTime, endTime)
+	rec := &recordingWriter{}
+	rowCount, err := rg.generateFailureAnalysis(context.Background(), rec, reportWindow{start: startTime, end: endTime})
+	data := rec.records
 
 	require.NoError(t, err)
+	assert.Equal(t, len(data)-1, rowCount)
 	assert.Len(t, data, 3)
 	assert.Equal(t, "Task Type", data[0][0])
+	assert.Equal(t, "Category", data[0][1])
 	assert.Equal(t, "email", data[1][0])
-	assert.Equal(t, "connection timeout", data[1][1])
-	assert.Equal(t, "10", data[1][2])
+	assert.Equal(t, "timeout", data[1][1])
+	assert.Equal(t, "connection timeout", data[1][2])
+	assert.Equal(t, "10", data[1][3])
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -257,19 +365,60 @@ func TestGenerateHourlyBreakdown(t *testing.T) {
 		AddRow(hour, 50, 48, 2, 150.0).
 		AddRow(hour.Add(-time.Hour), 45, 44, 1, 140.0)
 
-	mock.ExpectQuery(`SELECT\s+DATE_TRUNC\('hour', created_at\).*FROM task_history`).
-		WithArgs(startTime, endTime).
+	mock.ExpectQuery(`SELECT\s+DATE_TRUNC\('hour', created_at AT TIME ZONE .*FROM task_history`).
+		WithArgs(startTime, endTime, "").
 		WillReturnRows(rows)
 
-	data, err := rg.generateHourlyBreakdown(context.Background(), startTime, endTime)
+	rec := &recordingWriter{}
+	rowCount, err := rg.generateHourlyBreakdown(context.Background(), rec, reportWindow{start: startTime, end: endTime})
+	data := rec.records
 
 	require.NoError(t, err)
+	assert.Equal(t, len(data)-1, rowCount)
 	assert.Len(t, data, 3)
 	assert.Equal(t, "Hour", data[0][0])
 	assert.Equal(t, "2024-01-01 12:00", data[1][0])
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGenerateHourlyBreakdown_Timezone(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rg := NewReportGenerator(db)
+
+	payload, err := ParsePayload(map[string]any{
+		"report_type": "hourly_breakdown",
+		"timezone":    "America/New_York",
+	})
+	require.NoError(t, err)
+	location, err := time.LoadLocation(payload.Timezone)
+	require.NoError(t, err)
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endTime := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows([]string{"hour", "total_tasks", "completed", "failed", "avg_duration_ms"}).
+		AddRow(time.Date(2023, 12, 31, 19, 0, 0, 0, time.UTC), 5, 5, 0, 120.0)
+
+	mock.ExpectQuery(`DATE_TRUNC\('hour', created_at AT TIME ZONE COALESCE\(NULLIF\(\$3, ''\).*GROUP BY 1`).
+		WithArgs(startTime, endTime, "America/New_York").
+		WillReturnRows(rows)
+
+	rec := &recordingWriter{}
+	_, err = rg.generateHourlyBreakdown(context.Background(), rec, reportWindow{start: startTime, end: endTime, location: location})
+	require.NoError(t, err)
+	assert.Equal(t, "2023-12-31 19:00", rec.records[1][0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = ParsePayload(map[string]any{
+		"report_type": "hourly_breakdown",
+		"timezone":    "Mars/Olympus_Mons",
+	})
+	assert.ErrorContains(t, err, "invalid timezone")
+}
+
 func TestGenerateRetryAnalysis(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	require.NoError(t, err)
@@ -291,9 +440,12 @@ func TestGenerateRetryAnalysis(t *testing.T) {
 		WithArgs(startTime, endTime).
 		WillReturnRows(rows)
 
-	data, err := rg.generateRetryAnalysis(context.Background(), startTime, endTime)
+	rec := &recordingWriter{}
+	rowCount, err := rg.generateRetryAnalysis(context.Background(), rec, reportWindow{start: startTime, end: endTime})
+	data := rec.records
 
 	require.NoError(t, err)
+	assert.Equal(t, len(data)-1, rowCount)
 	assert.Len(t, data, 4)
 	assert.Equal(t, "Task Type", data[0][0])
 	assert.Equal(t, "email", data[1][0])
@@ -363,49 +515,56 @@ func TestFormatInt64(t *testing.T) {
 	}
 }
 
-func TestSaveAsCSV(t *testing.T) {
-	tmpDir := t.TempDir()
-	path := filepath.Join(tmpDir, "test.csv")
+func writeRecords(data [][]string) func(reportWriter) (int, error) {
+	return func(w reportWriter) (int, error) {
+		for _, record := range data {
+			if err := w.Write(record); err != nil {
+				return 0, err
+			}
+		}
 
+		return len(data) - 1, nil
+	}
+}
+
+func TestCSVReportWriter(t *testing.T) {
 	data := [][]string{
 		{"Header1", "Header2", "Header3"},
 		{"Value1", "Value2", "Value3"},
 		{"Value4", "Value5", "Value6"},
 	}
 
-	err := saveAsCSV(path, data)
+	var buf bytes.Buffer
+	w, err := newReportWriter(&ReportPayload{Format: "csv"}, &buf)
 	require.NoError(t, err)
 
-	// Verify file exists and can be read
-	file, err := os.Open(path)
+	_, err = writeRecords(data)(w)
 	require.NoError(t, err)
-	defer func() { _ = file.Close() }()
+	require.NoError(t, w.Close())
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	records, err := csv.NewReader(&buf).ReadAll()
 	require.NoError(t, err)
 
 	assert.Equal(t, data, records)
 }
 
-func TestSaveAsJSON(t *testing.T) {
-	tmpDir := t.TempDir()
-	path := filepath.Join(tmpDir, "test.json")
-
+func TestJSONReportWriter(t *testing.T) {
 	data := [][]string{
 		{"Name", "Age", "City"},
 		{"Alice", "30", "NYC"},
 		{"Bob", "25", "LA"},
 	}
 
-	err := saveAsJSON(path, data)
+	var buf bytes.Buffer
+	w, err := newReportWriter(&ReportPayload{Format: "json"}, &buf)
 	require.NoError(t, err)
 
-	content, err := os.ReadFile(path)
+	_, err = writeRecords(data)(w)
 	require.NoError(t, err)
+	require.NoError(t, w.Close())
 
 	var result map[string]any
-	err = json.Unmarshal(content, &result)
+	err = json.Unmarshal(buf.Bytes(), &result)
 	require.NoError(t, err)
 
 	assert.Contains(t, result, "generated_at")
@@ -415,17 +574,53 @@ func TestSaveAsJSON(t *testing.T) {
 
 	records := result["data"].([]any)
 	assert.Len(t, records, 2)
+	assert.Equal(t, map[string]any{"Name": "Alice", "Age": "30", "City": "NYC"}, records[0])
 }
 
-func TestSaveAsJSON_InsufficientData(t *testing.T) {
-	tmpDir := t.TempDir()
-	path := filepath.Join(tmpDir, "test.json")
+func TestCSVReportWriter_Delimiter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newReportWriter(&ReportPayload{Format: "csv", CSVDelimiter: ";"}, &buf)
+	require.NoError(t, err)
 
-	data := [][]string{
-		{"Header"},
-	}
+	_, err = writeRecords([][]string{
+		{"Name", "Note"},
+		{"Alice", "a;b"},
+	})(w)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "Name;Note\nAlice;\"a;b\"\n", buf.String())
+}
+
+func TestJSONReportWriter_ArrayStyle(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newReportWriter(&ReportPayload{Format: "json", JSONStyle: "array"}, &buf)
+	require.NoError(t, err)
+
+	_, err = writeRecords([][]string{
+		{"Name", "Age"},
+		{"Alice", "30"},
+		{"Bob", "25"},
+	})(w)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var result []map[string]string
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &result))
+	assert.Equal(t, []map[string]string{
+		{"Name": "Alice", "Age": "30"},
+		{"Name": "Bob", "Age": "25"},
+	}, result)
+}
+
+func TestJSONReportWriter_InsufficientData(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newReportWriter(&ReportPayload{Format: "json"}, &buf)
+	require.NoError(t, err)
 
-	err := saveAsJSON(path, data)
+	require.NoError(t, w.Write([]string{"Header"}))
+
+	err = w.Close()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "insufficient data")
 }
@@ -481,7 +676,7 @@ func TestSaveReport(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			path, err := saveReport(tt.payload, tt.data)
+			path, rowCount, err := saveReport(tt.payload, writeRecords(tt.data))
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -491,6 +686,7 @@ func TestSaveReport(t *testing.T) {
 			require.NoError(t, err)
 			assert.Contains(t, path, "nexq_test_report")
 			assert.Contains(t, path, tt.payload.Format)
+			assert.Equal(t, len(tt.data)-1, rowCount)
 
 			// Verify file exists
 			_, err = os.Stat(path)
@@ -499,12 +695,203 @@ func TestSaveReport(t *testing.T) {
 	}
 }
 
+func TestSaveReport_FilenameTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	payload, err := ParsePayload(map[string]any{
+		"report_type":       "task_summary",
+		"output_path":       tmpDir,
+		"filename_template": "ops-{type}-{timestamp}.{format}",
+	})
+	require.NoError(t, err)
+
+	path, _, err := saveReport(payload, writeRecords([][]string{{"Col1"}, {"Val1"}}))
+	require.NoError(t, err)
+	assert.Equal(t, tmpDir, filepath.Dir(path))
+	assert.Regexp(t, `^ops-task_summary-\d{8}_\d{6}\.csv$`, filepath.Base(path))
+}
+
+func TestSaveReport_RemovesIncompleteFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	payload := &ReportPayload{
+		ReportType: "test_report",
+		Format:     "json",
+		OutputPath: tmpDir,
+	}
+
+	_, _, err := saveReport(payload, writeRecords([][]string{{"Col1"}}))
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSaveReport_Compressed(t *testing.T) {
+	data := [][]string{
+		{"Col1", "Col2"},
+		{"Val1", "Val2"},
+		{"Val3", "Val4"},
+	}
+
+	for _, format := range []string{"csv", "json"} {
+		t.Run(format, func(t *testing.T) {
+			payload := &ReportPayload{
+				ReportType: "test_report",
+				Format:     format,
+				OutputPath: t.TempDir(),
+				Compress:   true,
+			}
+
+			path, rowCount, err := saveReport(payload, writeRecords(data))
+			require.NoError(t, err)
+			assert.True(t, strings.HasSuffix(path, "."+format+".gz"), path)
+			assert.Equal(t, 2, rowCount)
+
+			file, err := os.Open(path)
+			require.NoError(t, err)
+			defer func() { _ = file.Close() }()
+
+			gz, err := gzip.NewReader(file)
+			require.NoError(t, err)
+			content, err := io.ReadAll(gz)
+			require.NoError(t, err)
+
+			var expected bytes.Buffer
+			uncompressed := &ReportPayload{Format: format}
+			_, err = renderReport(&expected, uncompressed, writeRecords(data))
+			require.NoError(t, err)
+
+			if format == "csv" {
+				assert.Equal(t, expected.String(), string(content))
+				return
+			}
+
+			var result map[string]any
+			require.NoError(t, json.Unmarshal(content, &result))
+			assert.Equal(t, float64(2), result["total_rows"])
+			assert.Equal(t, []any{
+				map[string]any{"Col1": "Val1", "Col2": "Val2"},
+				map[string]any{"Col1": "Val3", "Col2": "Val4"},
+			}, result["data"])
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestRenderReport_WriteError(t *testing.T) {
+	data := [][]string{
+		{"Col1", "Col2"},
+		{"Val1", "Val2"},
+	}
+
+	for name, payload := range map[string]*ReportPayload{
+		"csv":         {Format: "csv"},
+		"json":        {Format: "json"},
+		"gzipped csv": {Format: "csv", Compress: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := renderReport(failingWriter{}, payload, writeRecords(data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "no space left on device")
+		})
+	}
+}
+
+type countingWriter struct {
+	rows          int
+	heapAtStart   uint64
+	heapAtEnd     uint64
+	sampleAt      int
+	finalSampleAt int
+	out           reportWriter
+}
+
+func (cw *countingWriter) Write(record []string) error {
+	if err := cw.out.Write(record); err != nil {
+		return err
+	}
+
+	switch cw.rows {
+	case cw.sampleAt:
+		cw.heapAtStart = heapInUse()
+	case cw.finalSampleAt:
+		cw.heapAtEnd = heapInUse()
+	}
+
+	cw.rows++
+	return nil
+}
+
+func (cw *countingWriter) Close() error {
+	return cw.out.Close()
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestGenerateHourlyBreakdown_StreamsRows(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rg := NewReportGenerator(db)
+
+	const rowCount = 100000
+	hour := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"hour", "total_tasks", "completed", "failed", "avg_duration_ms"})
+	for i := range rowCount {
+		rows.AddRow(hour.Add(time.Duration(i)*time.Hour), 100, 95, 5, 150.0)
+	}
+
+	mock.ExpectQuery(`SELECT\s+DATE_TRUNC.*FROM task_history`).WillReturnRows(rows)
+
+	out, err := newReportWriter(&ReportPayload{Format: "csv"}, io.Discard)
+	require.NoError(t, err)
+
+	w := &countingWriter{out: out, sampleAt: rowCount / 10, finalSampleAt: rowCount}
+	written, err := rg.generateHourlyBreakdown(context.Background(), w, reportWindow{start: hour, end: hour.Add(rowCount * time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, rowCount, written)
+	assert.Equal(t, rowCount+1, w.rows)
+
+	// Collecting the remaining 90k rows would retain several megabytes.
+	growth := int64(w.heapAtEnd) - int64(w.heapAtStart)
+	assert.Less(t, growth, int64(1<<20))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type recordingWriter struct {
+	records [][]string
+}
+
+func (rw *recordingWriter) Write(record []string) error {
+	rw.records = append(rw.records, record)
+	return nil
+}
+
+func (rw *recordingWriter) Close() error {
+	return nil
+}
+
 func TestGenerateReportHandler(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	require.NoError(t, err)
 	defer func() { _ = db.Close() }()
 
 	rg := NewReportGenerator(db)
+	store := &fakeReportStore{}
+	rg.SetReportStore(store)
 	tmpDir := t.TempDir()
 
 	t.Run("successful task_summary report", func(t *testing.T) {
@@ -530,6 +917,39 @@ func TestGenerateReportHandler(t *testing.T) {
 		err := rg.GenerateReportHandler(context.Background(), tsk)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, store.reports, 1)
+		assert.Equal(t, "test-task-1", store.reports[0].ID)
+		assert.Equal(t, "csv", store.reports[0].Format)
+		assert.FileExists(t, store.reports[0].Path)
+	})
+
+	t.Run("compressed report is recorded as gz", func(t *testing.T) {
+		tsk := &task.Task{
+			ID:   "test-task-gz",
+			Type: "generate_report",
+			Payload: map[string]any{
+				"report_type": "task_summary",
+				"start_time":  "2024-01-01T00:00:00Z",
+				"end_time":    "2024-01-02T00:00:00Z",
+				"format":      "csv",
+				"compress":    true,
+				"output_path": tmpDir,
+			},
+		}
+
+		rows := sqlmock.NewRows([]string{
+			"type", "total_tasks", "completed", "failed", "moved_to_dlq",
+			"avg_retries", "avg_duration_ms", "max_duration_ms", "min_duration_ms", "success_rate",
+		}).AddRow("email", 10, 9, 1, 0, 0.5, 100.0, 200, 50, 90.0)
+
+		mock.ExpectQuery(`SELECT\s+type,.*FROM task_history`).WillReturnRows(rows)
+
+		require.NoError(t, rg.GenerateReportHandler(context.Background(), tsk))
+
+		require.Len(t, store.reports, 2)
+		assert.Equal(t, "gz", store.reports[1].Format)
+		assert.Equal(t, ".gz", filepath.Ext(store.reports[1].Path))
 	})
 
 	t.Run("invalid payload", func(t *testing.T) {
@@ -542,6 +962,7 @@ func TestGenerateReportHandler(t *testing.T) {
 		err := rg.GenerateReportHandler(context.Background(), tsk)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid payload")
+		assert.ErrorIs(t, err, worker.ErrPermanent)
 	})
 
 	t.Run("unsupported report type", func(t *testing.T) {
@@ -557,9 +978,366 @@ func TestGenerateReportHandler(t *testing.T) {
 		err := rg.GenerateReportHandler(context.Background(), tsk)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unsupported report type")
+		assert.ErrorIs(t, err, worker.ErrPermanent)
 	})
 }
 
+type fakeTaskUpdater struct {
+	progress []int
+}
+
+func (u *fakeTaskUpdater) UpdateTask(t *task.Task) error {
+	u.progress = append(u.progress, t.Progress)
+	return nil
+}
+
+func TestGenerateReportHandler_Progress(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rg := NewReportGenerator(db)
+	updater := &fakeTaskUpdater{}
+	rg.SetTaskUpdater(updater)
+	// A zero interval records progress after every row, standing in for a
+	// cursor slow enough that each row crosses the interval.
+	rg.progressInterval = 0
+
+	tsk := &task.Task{
+		ID:   "test-task-progress",
+		Type: "generate_report",
+		Payload: map[string]any{
+			"report_type": "hourly_breakdown",
+			"output_path": t.TempDir(),
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"hour", "total_tasks", "completed", "failed", "avg_duration_ms"})
+	for i := range 5 {
+		rows.AddRow(time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC), 10, 9, 1, 100.0)
+	}
+	mock.ExpectQuery(`SELECT\s+DATE_TRUNC`).WillReturnRows(rows)
+
+	require.NoError(t, rg.GenerateReportHandler(context.Background(), tsk))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, updater.progress)
+	assert.Equal(t, 5, tsk.Progress)
+}
+
+func TestProgressReportWriter_SkipsUpdates(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		interval time.Duration
+	}{
+		{"within interval", context.Background(), time.Hour},
+		{"context done", cancelled, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := &fakeTaskUpdater{}
+			pw := &progressReportWriter{
+				reportWriter: &recordingWriter{},
+				ctx:          tt.ctx,
+				t:            &task.Task{ID: "test-task"},
+				updater:      updater,
+				logger:       logging.Logger(),
+				interval:     tt.interval,
+			}
+
+			require.NoError(t, writeRepeated(pw, 3, []string{"a", "b"}))
+			assert.Empty(t, updater.progress)
+		})
+	}
+}
+
+type fakeReportStore struct {
+	reports []queue.ReportInfo
+}
+
+func (s *fakeReportStore) SaveReportInfo(info queue.ReportInfo) error {
+	s.reports = append(s.reports, info)
+	return nil
+}
+
+func TestReportContentType(t *testing.T) {
+	assert.Equal(t, "text/csv", ReportContentType("csv"))
+	assert.Equal(t, "application/json", ReportContentType("json"))
+	assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ReportContentType("xlsx"))
+	assert.Equal(t, "application/gzip", ReportContentType("gz"))
+	assert.Equal(t, "application/octet-stream", ReportContentType("pdf"))
+}
+
+type fakeUploader struct {
+	bucket string
+	key    string
+	body   []byte
+	err    error
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, bucket, key string, body io.Reader) error {
+	if u.err != nil {
+		return u.err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	u.bucket = bucket
+	u.key = key
+	u.body = data
+	return nil
+}
+
+func TestGenerateReportHandler_S3Destination(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	tsk := &task.Task{
+		ID:   "test-task-s3",
+		Type: "generate_report",
+		Payload: map[string]any{
+			"report_type": "task_summary",
+			"start_time":  "2024-01-01T00:00:00Z",
+			"end_time":    "2024-01-02T00:00:00Z",
+			"format":      "csv",
+			"output_path": "nexq/reports",
+			"destination": "s3",
+			"bucket":      "reports-bucket",
+		},
+	}
+
+	summaryRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{
+			"type", "total_tasks", "completed", "failed", "moved_to_dlq",
+			"avg_retries", "avg_duration_ms", "max_duration_ms", "min_duration_ms", "success_rate",
+		}).AddRow("email", 10, 9, 1, 0, 0.5, 100.0, 200, 50, 90.0)
+	}
+
+	t.Run("uploads rendered report", func(t *testing.T) {
+		uploader := &fakeUploader{}
+		rg := NewReportGenerator(db)
+		rg.SetUploader(uploader)
+
+		mock.ExpectQuery(`SELECT\s+type,.*FROM task_history`).WillReturnRows(summaryRows())
+
+		require.NoError(t, rg.GenerateReportHandler(context.Background(), tsk))
+		assert.Equal(t, "reports-bucket", uploader.bucket)
+		assert.Regexp(t, `^nexq/reports/nexq_task_summary_\d{8}_\d{6}\.csv$`, uploader.key)
+
+		records, err := csv.NewReader(bytes.NewReader(uploader.body)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "email", records[1][0])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("upload failure", func(t *testing.T) {
+		rg := NewReportGenerator(db)
+		rg.SetUploader(&fakeUploader{err: errors.New("access denied")})
+
+		mock.ExpectQuery(`SELECT\s+type,.*FROM task_history`).WillReturnRows(summaryRows())
+
+		err := rg.GenerateReportHandler(context.Background(), tsk)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("uploader not configured", func(t *testing.T) {
+		rg := NewReportGenerator(db)
+
+		err := rg.GenerateReportHandler(context.Background(), tsk)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "s3 destination is not configured")
+		assert.ErrorIs(t, err, worker.ErrPermanent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGenerateReportHandler_UnwritableOutputPath(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rg := NewReportGenerator(db)
+
+	generate := func(outputPath string) error {
+		return rg.GenerateReportHandler(context.Background(), &task.Task{
+			ID:   "test-task-unwritable",
+			Type: "generate_report",
+			Payload: map[string]any{
+				"report_type": "task_summary",
+				"output_path": outputPath,
+			},
+		})
+	}
+
+	t.Run("read-only directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root bypasses directory permissions")
+		}
+
+		readOnly := t.TempDir()
+		require.NoError(t, os.Chmod(readOnly, 0555))
+		defer func() { _ = os.Chmod(readOnly, 0755) }()
+
+		err := generate(readOnly + "/reports")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "output path not writable")
+	})
+
+	t.Run("parent is a file", func(t *testing.T) {
+		file, err := os.CreateTemp(t.TempDir(), "not-a-dir")
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		err = generate(file.Name() + "/reports")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "output path not writable")
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type peakWriter struct {
+	reportWriter
+	buf     *spillBuffer
+	peakMem int
+}
+
+func (pw *peakWriter) Write(record []string) error {
+	if err := pw.reportWriter.Write(record); err != nil {
+		return err
+	}
+
+	pw.peakMem = max(pw.peakMem, pw.buf.mem.Len())
+	return nil
+}
+
+func writeRepeated(w reportWriter, n int, record []string) error {
+	for range n {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestSpillBuffer(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	const rowCount = 10000
+	record := []string{"2024-01-01T00:00:00Z", "100", "95", "5", "150.00"}
+
+	var expected bytes.Buffer
+	_, err := renderReport(&expected, &ReportPayload{Format: "csv"}, func(w reportWriter) (int, error) {
+		return rowCount, writeRepeated(w, rowCount, record)
+	})
+	require.NoError(t, err)
+
+	t.Run("spills past the row cap", func(t *testing.T) {
+		buf := newSpillBuffer(100)
+
+		var pw *peakWriter
+		_, err := renderReport(buf, &ReportPayload{Format: "csv"}, func(w reportWriter) (int, error) {
+			pw = &peakWriter{reportWriter: &spillingReportWriter{reportWriter: w, buf: buf}, buf: buf}
+			return rowCount, writeRepeated(pw, rowCount, record)
+		})
+		require.NoError(t, err)
+
+		assert.True(t, buf.spilled())
+		assert.Zero(t, buf.mem.Len())
+		// The CSV writer flushes in 4KiB chunks, so the in-memory portion never
+		// holds more than the capped rows plus one pending chunk.
+		assert.Less(t, pw.peakMem, 100*len(strings.Join(record, ","))+8192)
+
+		body, err := buf.Reader()
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), string(data))
+
+		name := buf.file.Name()
+		require.NoError(t, buf.Close())
+		_, err = os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("stays in memory below the row cap", func(t *testing.T) {
+		buf := newSpillBuffer(rowCount + 1)
+
+		_, err := renderReport(buf, &ReportPayload{Format: "csv"}, func(w reportWriter) (int, error) {
+			return rowCount, writeRepeated(&spillingReportWriter{reportWriter: w, buf: buf}, rowCount, record)
+		})
+		require.NoError(t, err)
+		assert.False(t, buf.spilled())
+
+		body, err := buf.Reader()
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), string(data))
+		assert.NoError(t, buf.Close())
+	})
+}
+
+func TestGenerateReportHandler_S3SpillsLargeReports(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	const rowCount = 50000
+	hour := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"hour", "total_tasks", "completed", "failed", "avg_duration_ms"})
+	for i := range rowCount {
+		rows.AddRow(hour.Add(time.Duration(i)*time.Hour), 100, 95, 5, 150.0)
+	}
+	mock.ExpectQuery(`SELECT\s+DATE_TRUNC.*FROM task_history`).WillReturnRows(rows)
+
+	uploader := &fakeUploader{}
+	rg := NewReportGenerator(db)
+	rg.SetUploader(uploader)
+	rg.SetMaxRowsInMemory(1000)
+
+	tsk := &task.Task{
+		ID:   "test-task-s3-spill",
+		Type: "generate_report",
+		Payload: map[string]any{
+			"report_type": "hourly_breakdown",
+			"start_time":  "2024-01-01T00:00:00Z",
+			"end_time":    "2030-01-01T00:00:00Z",
+			"format":      "csv",
+			"output_path": "nexq/reports",
+			"destination": "s3",
+			"bucket":      "reports-bucket",
+		},
+	}
+
+	require.NoError(t, rg.GenerateReportHandler(context.Background(), tsk))
+
+	records, err := csv.NewReader(bytes.NewReader(uploader.body)).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, rowCount+1)
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "spilled report should be removed after upload")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestNewReportGenerator(t *testing.T) {
 	db, _, err := sqlmock.New()
 	require.NoError(t, err)
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+type reportGenerateFunc func(*ReportGenerator, context.Context, reportWriter, reportWindow) (int, error)
+
+type reportDefinition struct {
+	description string
+	generate    reportGenerateFunc
+}
+
+// reportDefinitions is the single list of report types: GenerateReportHandler
+// dispatches through it and the API describes it to clients.
+var reportDefinitions = map[string]reportDefinition{
+	"task_summary": {
+		description: "Totals, success rate, retries and durations per task type",
+		generate:    (*ReportGenerator).generateTaskSummary,
+	},
+	"worker_performance": {
+		description: "Tasks processed, success rate and durations per worker",
+		generate:    (*ReportGenerator).generateWorkerPerformance,
+	},
+	"failure_analysis": {
+		description: "The 50 most frequent failures grouped by task type, category and error",
+		generate:    (*ReportGenerator).generateFailureAnalysis,
+	},
+	"hourly_breakdown": {
+		description: "Task counts and average duration per hour",
+		generate:    (*ReportGenerator).generateHourlyBreakdown,
+	},
+	"retry_analysis": {
+		description: "Outcomes of retried tasks per task type and retry count",
+		generate:    (*ReportGenerator).generateRetryAnalysis,
+	},
+}
+
+// ReportField describes a field of the generate_report payload.
+type ReportField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// ReportType describes a report type and the payload fields it accepts.
+type ReportType struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Fields      []ReportField `json:"fields"`
+}
+
+// reportFields are accepted by every report type.
+var reportFields = []ReportField{
+	{Name: "report_type", Type: "string", Required: true, Description: "One of the report type names"},
+	{Name: "start_time", Type: "string", Description: "RFC 3339 start of the window; defaults to 24 hours ago"},
+	{Name: "end_time", Type: "string", Description: "RFC 3339 end of the window; defaults to now"},
+	{Name: "format", Type: "string", Description: "csv (default) or json"},
+	{Name: "output_path", Type: "string", Description: "Directory, or S3 key prefix, the report is written to; defaults to ./reports"},
+	{Name: "destination", Type: "string", Description: "local (default) or s3"},
+	{Name: "bucket", Type: "string", Description: "S3 bucket; required for the s3 destination"},
+	{Name: "compress", Type: "boolean", Description: "Gzip the report"},
+	{Name: "csv_delimiter", Type: "string", Description: "Single character separating CSV fields; defaults to a comma"},
+	{Name: "json_style", Type: "string", Description: "envelope (default) or array"},
+	{Name: "filename_template", Type: "string", Description: "Output filename with {type}, {timestamp} and {format} placeholders"},
+	{Name: "timezone", Type: "string", Description: "IANA time zone hourly buckets and timestamps are reported in; defaults to the database's"},
+	{Name: "schedule_in", Type: "integer", Description: "Seconds to wait before generating the report"},
+}
+
+// ReportTypes returns the names of the supported report types, sorted.
+func ReportTypes() []string {
+	return slices.Sorted(maps.Keys(reportDefinitions))
+}
+
+// DescribeReportTypes returns every supported report type with the payload
+// fields it accepts, sorted by name.
+func DescribeReportTypes() []ReportType {
+	var types []ReportType
+	for _, name := range ReportTypes() {
+		types = append(types, ReportType{
+			Name:        name,
+			Description: reportDefinitions[name].description,
+			Fields:      slices.Clone(reportFields),
+		})
+	}
+
+	return types
+}
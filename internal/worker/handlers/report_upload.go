@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type ReportUploader interface {
+	Upload(ctx context.Context, bucket, key string, body io.Reader) error
+}
+
+type S3Uploader struct {
+	client *s3.Client
+}
+
+func NewS3Uploader(ctx context.Context) (*S3Uploader, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Uploader{client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (u *S3Uploader) Upload(ctx context.Context, bucket, key string, body io.Reader) error {
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+
+	return err
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// reportWriter receives the header record followed by one record per row,
+// writing each through to the underlying output instead of buffering the report.
+type reportWriter interface {
+	Write(record []string) error
+	Close() error
+}
+
+var reportFormats = []string{"csv", "json"}
+
+const (
+	jsonEnvelopeStyle = "envelope"
+	jsonArrayStyle    = "array"
+)
+
+var jsonStyles = []string{jsonEnvelopeStyle, jsonArrayStyle}
+
+func ReportFormats() []string {
+	return slices.Clone(reportFormats)
+}
+
+// newReportWriter creates a writer for the payload's format. Empty
+// CSVDelimiter and JSONStyle fall back to a comma and the envelope style.
+func newReportWriter(payload *ReportPayload, w io.Writer) (reportWriter, error) {
+	switch payload.Format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if payload.CSVDelimiter != "" {
+			if err := validateCSVDelimiter(payload.CSVDelimiter); err != nil {
+				return nil, err
+			}
+			cw.Comma, _ = utf8.DecodeRuneInString(payload.CSVDelimiter)
+		}
+		return &csvReportWriter{w: cw}, nil
+	case "json":
+		return &jsonReportWriter{w: w, array: payload.JSONStyle == jsonArrayStyle}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", payload.Format)
+	}
+}
+
+func validateCSVDelimiter(delimiter string) error {
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return fmt.Errorf("invalid csv_delimiter %q: must be a single character other than a quote or newline", delimiter)
+	}
+
+	return nil
+}
+
+type csvReportWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvReportWriter) Write(record []string) error {
+	return cw.w.Write(record)
+}
+
+func (cw *csvReportWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonReportWriter writes rows as objects keyed by header, either wrapped in
+// a {generated_at, data, total_rows} envelope or, with array set, as a bare
+// array.
+type jsonReportWriter struct {
+	w       io.Writer
+	array   bool
+	headers []string
+	rows    int
+}
+
+func (jw *jsonReportWriter) Write(record []string) error {
+	if jw.headers == nil {
+		jw.headers = record
+		if jw.array {
+			_, err := io.WriteString(jw.w, "[")
+			return err
+		}
+
+		generatedAt, err := json.Marshal(time.Now().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(jw.w, "{\n  \"generated_at\": %s,\n  \"data\": [", generatedAt)
+		return err
+	}
+
+	row := make(map[string]string, len(jw.headers))
+	for i, header := range jw.headers {
+		if i < len(record) {
+			row[header] = record[i]
+		}
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	indent := "\n    "
+	if jw.array {
+		indent = "\n  "
+	}
+	separator := "," + indent
+	if jw.rows == 0 {
+		separator = indent
+	}
+	if _, err := io.WriteString(jw.w, separator); err != nil {
+		return err
+	}
+	if _, err := jw.w.Write(data); err != nil {
+		return err
+	}
+
+	jw.rows++
+	return nil
+}
+
+func (jw *jsonReportWriter) Close() error {
+	if jw.rows == 0 {
+		return errors.New("insufficient data for JSON export")
+	}
+
+	if jw.array {
+		_, err := io.WriteString(jw.w, "\n]\n")
+		return err
+	}
+
+	_, err := fmt.Fprintf(jw.w, "\n  ],\n  \"total_rows\": %d\n}\n", jw.rows)
+	return err
+}
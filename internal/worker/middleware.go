@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/task"
+)
+
+// HandlerMiddleware wraps a TaskHandler with behaviour shared by every task
+// type, such as logging or timeouts.
+type HandlerMiddleware func(TaskHandler) TaskHandler
+
+// Use adds mw to the chain applied around every handler. Middleware runs in
+// the order it was added, so the first one registered is the outermost.
+func (w *Worker) Use(mw HandlerMiddleware) {
+	w.middleware = append(w.middleware, mw)
+}
+
+func (w *Worker) wrapHandler(handler TaskHandler) TaskHandler {
+	for i := len(w.middleware) - 1; i >= 0; i-- {
+		handler = w.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// LoggingMiddleware logs the start and outcome of every handler call along
+// with how long the handler ran.
+func LoggingMiddleware(next TaskHandler) TaskHandler {
+	return func(ctx context.Context, t *task.Task) error {
+		logger := logging.Logger().With("task_id", t.ID, "type", t.Type, "attempt", t.RetryCount+1)
+		logger.Info("handler started")
+
+		start := time.Now()
+		err := next(ctx, t)
+		durationMs := time.Since(start).Milliseconds()
+
+		if err != nil {
+			logger.Warn("handler failed", "duration_ms", durationMs, "error", err)
+		} else {
+			logger.Info("handler finished", "duration_ms", durationMs)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTaskSpan starts the span covering one processTask call. Tasks carry no
+// trace context across the queue, so the correlation ID attribute is what
+// ties the span to the request that created the task.
+func (w *Worker) startTaskSpan(t *task.Task) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(context.Background(), "process "+t.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String(tracing.TaskIDKey, t.ID),
+			attribute.String(tracing.TaskTypeKey, t.Type),
+			attribute.String(tracing.TaskPriorityKey, t.Priority.String()),
+			attribute.String(tracing.CorrelationIDKey, t.CorrelationID),
+			attribute.String("nexq.worker.id", w.id),
+		))
+}
+
+// endTaskSpan records the status the task was left in and ends the span.
+func endTaskSpan(span trace.Span, t *task.Task) {
+	span.SetAttributes(attribute.String(tracing.TaskStatusKey, string(t.Status)))
+	switch t.Status {
+	case task.FailedStatus, task.DeadLetterStatus:
+		span.SetStatus(codes.Error, t.Error)
+	}
+	span.End()
+}
@@ -3,22 +3,85 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/metrics"
 	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/webhook"
 )
 
 type TaskHandler func(context.Context, *task.Task) error
 
+const defaultHeartbeatInterval = 5 * time.Second
+
+// dependencyWaitDelay is how long a task waiting on its dependencies is
+// deferred before it is looked at again.
+var dependencyWaitDelay = 5 * time.Second
+
+// RetryLaterError asks the worker to reschedule a task after the given delay
+// without counting the attempt against its retries.
+type RetryLaterError struct {
+	After time.Duration
+}
+
+func RetryLater(after time.Duration) error {
+	return &RetryLaterError{After: after}
+}
+
+func (e *RetryLaterError) Error() string {
+	return fmt.Sprintf("retry later after %s", e.After)
+}
+
+// ErrPermanent matches errors wrapped with Permanent.
+var ErrPermanent = errors.New("permanent failure")
+
+// PermanentError tells the worker that retrying the task cannot succeed, so
+// it is dead-lettered immediately instead of using up its retries.
+type PermanentError struct {
+	Err error
+}
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PermanentError) Is(target error) bool {
+	return target == ErrPermanent
+}
+
 type Worker struct {
 	id           string
 	queue        *queue.Queue
 	handlers     map[string]TaskHandler
+	middleware   []HandlerMiddleware
 	stop         chan bool
 	pollInterval time.Duration
+	backoff      BackoffStrategy
+
+	heartbeatInterval time.Duration
+	mu                sync.Mutex
+	currentTask       string
+
+	webhooks  *webhook.Client
+	callbacks sync.WaitGroup
 }
 
 func NewWorker(id string, q *queue.Queue) *Worker {
@@ -27,6 +90,10 @@ func NewWorker(id string, q *queue.Queue) *Worker {
 		queue:    q,
 		handlers: make(map[string]TaskHandler),
 		stop:     make(chan bool),
+		backoff:  defaultBackoff,
+
+		heartbeatInterval: defaultHeartbeatInterval,
+		webhooks:          webhook.NewClient(webhook.DefaultTimeout),
 	}
 }
 
@@ -38,8 +105,54 @@ func (w *Worker) SetPollInterval(d time.Duration) {
 	w.pollInterval = d
 }
 
+func (w *Worker) SetBackoff(b BackoffStrategy) {
+	w.backoff = b
+}
+
+// SetWebhookClient replaces the client used to deliver task callbacks, e.g.
+// to allow callbacks to internal networks.
+func (w *Worker) SetWebhookClient(c *webhook.Client) {
+	w.webhooks = c
+}
+
+// SetHeartbeatInterval controls how often the worker refreshes its entry in
+// the worker registry. The entry expires after three missed heartbeats.
+func (w *Worker) SetHeartbeatInterval(d time.Duration) {
+	w.heartbeatInterval = d
+}
+
+func (w *Worker) logger() *slog.Logger {
+	return logging.Logger().With("worker_id", w.id)
+}
+
+func (w *Worker) taskLogger(t *task.Task) *slog.Logger {
+	logger := w.logger().With("task_id", t.ID, "type", t.Type)
+	if t.CorrelationID != "" {
+		logger = logger.With("correlation_id", t.CorrelationID)
+	}
+
+	return logger
+}
+
 func (w *Worker) Start() {
-	log.Printf("Worker %s started", w.id)
+	w.logger().Info("worker started")
+	w.registerTaskTypes()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.runHeartbeat(done)
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+		w.callbacks.Wait()
+		if err := w.queue.RemoveWorker(w.id); err != nil {
+			w.logger().Error("failed to deregister worker", "error", err)
+		}
+	}()
 
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
@@ -47,7 +160,7 @@ func (w *Worker) Start() {
 	for {
 		select {
 		case <-w.stop:
-			log.Printf("Worker %s stopped", w.id)
+			w.logger().Info("worker stopped")
 			return
 		case <-ticker.C:
 			w.processNextTask()
@@ -55,40 +168,109 @@ func (w *Worker) Start() {
 	}
 }
 
+// registerTaskTypes publishes the types this worker handles so the API can
+// reject tasks no worker would ever run.
+func (w *Worker) registerTaskTypes() {
+	types := slices.Sorted(maps.Keys(w.handlers))
+	if err := w.queue.RegisterTaskTypes(types...); err != nil {
+		w.logger().Error("failed to register task types", "error", err)
+	}
+}
+
+// runHeartbeat publishes the worker's heartbeat until done is closed. It runs
+// on its own goroutine so long-running tasks do not let the entry expire.
+func (w *Worker) runHeartbeat(done <-chan struct{}) {
+	w.heartbeat()
+
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.heartbeat()
+		}
+	}
+}
+
+func (w *Worker) heartbeat() {
+	w.mu.Lock()
+	info := queue.WorkerInfo{ID: w.id, LastSeen: time.Now(), CurrentTask: w.currentTask}
+	w.mu.Unlock()
+
+	if err := w.queue.Heartbeat(info, 3*w.heartbeatInterval); err != nil {
+		w.logger().Error("failed to send heartbeat", "error", err)
+	}
+}
+
+func (w *Worker) setCurrentTask(taskID string) {
+	w.mu.Lock()
+	w.currentTask = taskID
+	w.mu.Unlock()
+}
+
 func (w *Worker) processNextTask() {
-	task, err := w.queue.Dequeue()
+	task, err := w.queue.Claim(w.id)
 	if err != nil || task == nil {
 		return
 	}
 
+	w.setCurrentTask(task.ID)
+	defer w.setCurrentTask("")
+
+	defer func() {
+		if err := w.queue.Settle(task.ID); err != nil {
+			w.taskLogger(task).Error("failed to settle in-flight task", "error", err)
+		}
+		if err := w.queue.ReleaseLease(task.ID); err != nil {
+			w.taskLogger(task).Error("failed to release lease", "error", err)
+		}
+	}()
+
 	w.processTask(task)
 }
 
 func (w *Worker) processTask(t *task.Task) {
-	log.Printf("Worker %s processing task %s (type: %s)", w.id, t.ID, t.Type)
+	inFlight := metrics.WorkerTasksInFlight.WithLabelValues(w.id)
+	inFlight.Inc()
+	// Deferred so a panicking handler still releases its slot.
+	defer inFlight.Dec()
+
+	spanCtx, span := w.startTaskSpan(t)
+	defer endTaskSpan(span, t)
+
+	logger := w.taskLogger(t)
+	logger.Info("processing task")
 
 	cancelled, err := w.queue.IsCancelled(t.ID)
 	if err == nil && cancelled {
-		log.Printf("Task %s was cancelled, skipping execution", t.ID)
+		logger.Info("task was cancelled, skipping execution", "status", task.CancelledStatus)
 		return
 	}
 
+	if len(t.DependsOn) > 0 {
+		ready, err := w.queue.DependenciesReady(t)
+		if err != nil {
+			w.handlePermanentFailure(t, err, time.Now())
+			return
+		}
+		if !ready {
+			logger.Info("task is waiting on dependencies, deferring", "delay", dependencyWaitDelay)
+			if err := w.queue.Defer(t, dependencyWaitDelay); err != nil {
+				logger.Error("failed to defer task", "error", err)
+			}
+			return
+		}
+	}
+
 	startTime := time.Now()
 	t.Status = task.RunningStatus
 	t.StartedAt = &startTime
-	if err := w.queue.UpdateTask(t); err != nil {
-		log.Printf("Failed to update task status to running: %v", err)
-	}
-
-	if err := w.queue.LogExecution(
-		t.ID,
-		t.RetryCount+1,
-		string(task.RunningStatus),
-		0,
-		"",
-		w.id,
-	); err != nil {
-		log.Printf("Warning: failed to log execution start: %v", err)
+	w.queue.PublishEvent(events.Started, t, "")
+	if err := w.queue.StartTask(t, w.id); err != nil {
+		logger.Error("failed to update task status", "status", task.RunningStatus, "error", err)
 	}
 
 	handler, exists := w.handlers[t.Type]
@@ -97,23 +279,24 @@ func (w *Worker) processTask(t *task.Task) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(spanCtx, 5*time.Minute)
 	defer cancel()
 
-	err = handler(ctx, t)
+	err = w.runHandler(ctx, w.wrapHandler(handler), t)
 
-	log.Printf("Handler returned for task %s, err=%v, ctx.Err()=%v", t.ID, err, ctx.Err())
+	logger.Debug("handler returned", "error", err, "context_error", ctx.Err())
 
 	if ctx.Err() == context.Canceled {
-		log.Printf("Task %s was cancelled during execution", t.ID)
 		completedAt := time.Now()
 		t.CompletedAt = &completedAt
 		t.Status = task.CancelledStatus // Assuming you have this status
 
 		durationMs := int(completedAt.Sub(startTime).Milliseconds())
 
+		logger.Info("task was cancelled during execution", "status", task.CancelledStatus, "duration_ms", durationMs)
+
 		if err := w.queue.UpdateTask(t); err != nil {
-			log.Printf("Failed to update cancelled task: %v", err)
+			logger.Error("failed to update cancelled task", "error", err)
 		}
 
 		if err := w.queue.LogExecution(
@@ -124,9 +307,16 @@ func (w *Worker) processTask(t *task.Task) {
 			"Task cancelled during execution",
 			w.id,
 		); err != nil {
-			log.Printf("Warning: failed to log cancelled execution: %v", err)
+			logger.Warn("failed to log cancelled execution", "error", err)
 		}
 
+		w.notifyCallback(t)
+		return
+	}
+
+	var retryLater *RetryLaterError
+	if errors.As(err, &retryLater) {
+		w.handleRetryLater(t, retryLater.After, startTime)
 		return
 	}
 
@@ -134,20 +324,37 @@ func (w *Worker) processTask(t *task.Task) {
 	t.CompletedAt = &completedAt
 	durationMs := int(completedAt.Sub(startTime).Milliseconds())
 
-	if err != nil {
+	if errors.Is(err, ErrPermanent) {
+		w.handlePermanentFailure(t, err, startTime)
+	} else if err != nil {
 		w.handleTaskFailure(t, err, startTime)
 	} else {
 		w.handleTaskSuccess(t, durationMs)
 	}
 }
 
+// runHandler converts a handler panic into an error so it goes through the
+// same retry and dead-letter path as a returned one.
+func (w *Worker) runHandler(ctx context.Context, handler TaskHandler, t *task.Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.taskLogger(t).Error("handler panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, t)
+}
+
 func (w *Worker) handleTaskSuccess(t *task.Task, durationMs int) {
+	logger := w.taskLogger(t)
+
 	t.Status = task.CompletedStatus
 	if err := w.queue.UpdateTask(t); err != nil {
-		log.Printf("Failed to update completed task: %v", err)
+		logger.Error("failed to update completed task", "error", err)
 	}
 	if err := w.queue.CompleteTask(t, durationMs); err != nil {
-		log.Printf("Warning: failed to mark task as completed in history: %v", err)
+		logger.Warn("failed to mark task as completed in history", "error", err)
 	}
 	if err := w.queue.LogExecution(
 		t.ID,
@@ -157,16 +364,43 @@ func (w *Worker) handleTaskSuccess(t *task.Task, durationMs int) {
 		"",
 		w.id,
 	); err != nil {
-		log.Printf("Warning: failed to log execution: %v", err)
+		logger.Warn("failed to log execution", "error", err)
 	}
 
-	log.Printf("Worker %s completed task %s successfully in %dms", w.id, t.ID, durationMs)
+	logger.Info("task completed", "status", t.Status, "duration_ms", durationMs)
+	w.notifyCallback(t)
+}
+
+func (w *Worker) handleRetryLater(t *task.Task, delay time.Duration, startTime time.Time) {
+	logger := w.taskLogger(t)
+	durationMs := int(time.Since(startTime).Milliseconds())
+
+	if err := w.queue.LogExecution(
+		t.ID,
+		t.RetryCount+1,
+		string(task.PendingStatus),
+		durationMs,
+		fmt.Sprintf("rescheduled after %s", delay),
+		w.id,
+	); err != nil {
+		logger.Warn("failed to log execution", "error", err)
+	}
+
+	t.StartedAt = nil
+	if err := w.queue.Defer(t, delay); err != nil {
+		logger.Error("failed to reschedule task", "error", err)
+		return
+	}
+
+	logger.Info("task rescheduled", "status", task.PendingStatus, "delay", delay, "duration_ms", durationMs)
 }
 
 func (w *Worker) handleTaskFailure(t *task.Task, taskErr error, startTime time.Time) {
+	logger := w.taskLogger(t)
 	durationMs := int(time.Since(startTime).Milliseconds())
 	t.RetryCount++
-	t.Error = taskErr.Error()
+	w.queue.RecordFailure(t, taskErr.Error())
+	w.queue.PublishEvent(events.Failed, t, t.Error)
 
 	if err := w.queue.LogExecution(
 		t.ID,
@@ -176,40 +410,84 @@ func (w *Worker) handleTaskFailure(t *task.Task, taskErr error, startTime time.T
 		taskErr.Error(),
 		w.id,
 	); err != nil {
-		log.Printf("Warning: failed to log execution: %v", err)
+		logger.Warn("failed to log execution", "error", err)
 	}
 
 	if t.RetryCount < t.MaxRetries {
 		t.Status = task.PendingStatus
-		backoffDuration := time.Duration(t.RetryCount) * 10 * time.Second
+		backoffDuration := w.backoff.Next(t.RetryCount)
+		if t.RetryDelaySeconds != nil {
+			backoffDuration = time.Duration(*t.RetryDelaySeconds) * time.Second
+		}
 		t.ScheduledAt = time.Now().Add(backoffDuration)
 
 		if err := w.queue.Enqueue(t); err != nil {
-			log.Printf("Failed to re-enqueue task: %v", err)
+			logger.Error("failed to re-enqueue task", "error", err)
 		}
 		if err := w.queue.IncrementRetryCount(t.ID); err != nil {
-			log.Printf("Warning: failed to increment retry count: %v", err)
+			logger.Warn("failed to increment retry count", "error", err)
 		}
+		metrics.RecordTaskRetried(t.Type)
 		if err := w.queue.FailTask(t, taskErr.Error(), durationMs); err != nil {
-			log.Printf("Warning: failed to record task failure: %v", err)
+			logger.Warn("failed to record task failure", "error", err)
 		}
 
-		log.Printf("Worker %s: Task %s failed, will retry (%d/%d) in %s",
-			w.id, t.ID, t.RetryCount, t.MaxRetries, backoffDuration)
+		logger.Warn("task failed, will retry",
+			"status", t.Status,
+			"duration_ms", durationMs,
+			"attempt", t.RetryCount,
+			"max_retries", t.MaxRetries,
+			"backoff", backoffDuration,
+			"error", taskErr)
 	} else {
-		t.Status = task.FailedStatus
-		if err := w.queue.UpdateTask(t); err != nil {
-			log.Printf("Failed to update failed task: %v", err)
-		}
-		if err := w.queue.MoveToDeadLetter(t, taskErr.Error()); err != nil {
-			log.Printf("Failed to move task to DLQ: %v", err)
-		}
+		w.deadLetter(t, taskErr)
 
-		log.Printf("Worker %s: Task %s failed permanently after %d attempts: %v",
-			w.id, t.ID, t.RetryCount, taskErr)
+		logger.Error("task failed permanently",
+			"status", t.Status,
+			"duration_ms", durationMs,
+			"attempts", t.RetryCount,
+			"error", taskErr)
 	}
 }
 
+func (w *Worker) handlePermanentFailure(t *task.Task, taskErr error, startTime time.Time) {
+	logger := w.taskLogger(t)
+	durationMs := int(time.Since(startTime).Milliseconds())
+	w.queue.RecordFailure(t, taskErr.Error())
+	w.queue.PublishEvent(events.Failed, t, t.Error)
+
+	if err := w.queue.LogExecution(
+		t.ID,
+		t.RetryCount+1,
+		string(task.FailedStatus),
+		durationMs,
+		taskErr.Error(),
+		w.id,
+	); err != nil {
+		logger.Warn("failed to log execution", "error", err)
+	}
+
+	w.deadLetter(t, taskErr)
+
+	logger.Error("task failed permanently without retry", "status", t.Status, "duration_ms", durationMs, "error", taskErr)
+}
+
+func (w *Worker) deadLetter(t *task.Task, taskErr error) {
+	logger := w.taskLogger(t)
+
+	t.Status = task.FailedStatus
+	if err := w.queue.UpdateTask(t); err != nil {
+		logger.Error("failed to update failed task", "error", err)
+	}
+	if !t.DeadLetterEnabled() {
+		logger.Info("dead-lettering disabled, leaving task failed", "status", t.Status)
+	} else if err := w.queue.MoveToDeadLetter(t, taskErr.Error()); err != nil {
+		logger.Error("failed to move task to dead letter queue", "error", err)
+	}
+
+	w.notifyCallback(t)
+}
+
 func (w *Worker) Stop() {
 	w.stop <- true
 }
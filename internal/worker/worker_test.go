@@ -1,17 +1,36 @@
 package worker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/nadmax/nexq/internal/events"
+	"github.com/nadmax/nexq/internal/logging"
+	"github.com/nadmax/nexq/internal/metrics"
 	"github.com/nadmax/nexq/internal/queue"
 	"github.com/nadmax/nexq/internal/repository/mocks"
 	"github.com/nadmax/nexq/internal/task"
+	"github.com/nadmax/nexq/internal/tracing"
+	"github.com/nadmax/nexq/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func setupTestWorker(t *testing.T) (*Worker, *queue.Queue, *miniredis.Miniredis) {
@@ -40,6 +59,22 @@ func setupTestWorkerWithMockRepo(t *testing.T) (*Worker, *queue.Queue, *mocks.Mo
 	return w, q, mockRepo, mr
 }
 
+type capturingSink struct {
+	events []events.TaskEvent
+}
+
+func (s *capturingSink) Publish(event events.TaskEvent) {
+	s.events = append(s.events, event)
+}
+
+func (s *capturingSink) types() []events.Type {
+	var types []events.Type
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
 func TestNewWorker(t *testing.T) {
 	w, q, mr := setupTestWorker(t)
 	defer mr.Close()
@@ -89,6 +124,131 @@ func TestProcessTask_Success(t *testing.T) {
 	assert.NotNil(t, updated.CompletedAt)
 }
 
+func TestProcessTask_InFlightGauge(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	gauge := metrics.WorkerTasksInFlight.WithLabelValues("test-worker")
+	gauge.Set(0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	w.RegisterHandler("slow_task", func(ctx context.Context, tsk *task.Task) error {
+		close(started)
+		<-release
+		return nil
+	})
+	w.RegisterHandler("panicking_task", func(ctx context.Context, tsk *task.Task) error {
+		panic("boom")
+	})
+
+	slow := task.NewTask("slow_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(slow))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.processTask(slow)
+	}()
+
+	<-started
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	close(release)
+	<-done
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+
+	panicking := task.NewTask("panicking_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(panicking))
+	assert.NotPanics(t, func() { w.processTask(panicking) })
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+}
+
+func TestProcessTask_TraceSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var handlerSpan bool
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		handlerSpan = trace.SpanFromContext(ctx).SpanContext().IsValid()
+		return nil
+	})
+	w.RegisterHandler("failing_task", func(ctx context.Context, tsk *task.Task) error {
+		return ErrPermanent
+	})
+
+	succeeded := task.NewTask("test_task", nil, task.HighPriority)
+	succeeded.CorrelationID = "req-123"
+	require.NoError(t, q.Enqueue(succeeded))
+	w.processTask(succeeded)
+
+	failed := task.NewTask("failing_task", nil, task.LowPriority)
+	require.NoError(t, q.Enqueue(failed))
+	w.processTask(failed)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.True(t, handlerSpan, "handlers run inside the task span")
+
+	assert.Equal(t, "process test_task", spans[0].Name())
+	assert.Subset(t, spans[0].Attributes(), []attribute.KeyValue{
+		attribute.String(tracing.TaskIDKey, succeeded.ID),
+		attribute.String(tracing.TaskTypeKey, "test_task"),
+		attribute.String(tracing.TaskPriorityKey, "high"),
+		attribute.String(tracing.CorrelationIDKey, "req-123"),
+		attribute.String(tracing.TaskStatusKey, string(task.CompletedStatus)),
+	})
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, "process failing_task", spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), attribute.String(tracing.TaskStatusKey, string(task.DeadLetterStatus)))
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestProcessTask_StructuredLogs(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var buf bytes.Buffer
+	previous := logging.Logger()
+	logging.SetLogger(logging.New(&buf, logging.JSONFormat))
+	defer logging.SetLogger(previous)
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.CorrelationID = "req-abc-123"
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processTask(tsk)
+
+	var completed map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "task completed" {
+			completed = entry
+		}
+	}
+
+	require.NotNil(t, completed, "expected a task completed log entry")
+	assert.Equal(t, tsk.ID, completed["task_id"])
+	assert.Equal(t, "test_task", completed["type"])
+	assert.Equal(t, w.id, completed["worker_id"])
+	assert.Equal(t, string(task.CompletedStatus), completed["status"])
+	assert.Contains(t, completed, "duration_ms")
+	assert.Equal(t, "req-abc-123", completed["correlation_id"])
+}
+
 func TestProcessTask_Failure(t *testing.T) {
 	w, q, mr := setupTestWorker(t)
 	defer mr.Close()
@@ -105,7 +265,8 @@ func TestProcessTask_Failure(t *testing.T) {
 
 	w.processTask(tsk)
 
-	updated, _ := q.GetTask(tsk.ID)
+	updated, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
 	assert.Equal(t, 1, updated.RetryCount)
 }
 
@@ -126,11 +287,225 @@ func TestProcessTask_MaxRetriesExceeded(t *testing.T) {
 
 	w.processTask(tsk)
 
-	updated, _ := q.GetTask(tsk.ID)
-	assert.Equal(t, task.FailedStatus, updated.Status)
+	assert.False(t, mr.Exists("task:"+tsk.ID))
+
+	updated, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.DeadLetterStatus, updated.Status)
 	assert.Contains(t, updated.Error, "task failed")
 }
 
+func TestProcessTask_PanicDeadLettersAfterRetries(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("panicking_task", func(ctx context.Context, tsk *task.Task) error {
+		panic("boom")
+	})
+	metrics.TasksRetried.Reset()
+
+	tsk := task.NewTask("panicking_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 2
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processTask(tsk)
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated.RetryCount)
+	assert.Equal(t, task.PendingStatus, updated.Status)
+	assert.Contains(t, updated.Error, "handler panicked: boom")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TasksRetried.WithLabelValues("panicking_task")))
+
+	w.processTask(tsk)
+
+	dlqTask, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, dlqTask.RetryCount)
+	assert.Equal(t, task.DeadLetterStatus, dlqTask.Status)
+	assert.Contains(t, dlqTask.Error, "handler panicked: boom")
+}
+
+func TestProcessTask_MiddlewareOrder(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var calls []string
+	record := func(name string) HandlerMiddleware {
+		return func(next TaskHandler) TaskHandler {
+			return func(ctx context.Context, tsk *task.Task) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, tsk)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	w.Use(record("first"))
+	w.Use(record("second"))
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processTask(tsk)
+
+	assert.Equal(t, []string{"first before", "second before", "handler", "second after", "first after"}, calls)
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, updated.Status)
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	previous := logging.Logger()
+	logging.SetLogger(logging.New(&buf, logging.JSONFormat))
+	defer logging.SetLogger(previous)
+
+	handlerErr := errors.New("boom")
+	handler := LoggingMiddleware(func(ctx context.Context, tsk *task.Task) error {
+		return handlerErr
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	err := handler(context.Background(), tsk)
+	assert.ErrorIs(t, err, handlerErr)
+
+	var messages []string
+	for line := range strings.Lines(buf.String()) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, tsk.ID, entry["task_id"])
+		messages = append(messages, entry["msg"].(string))
+	}
+	assert.Equal(t, []string{"handler started", "handler failed"}, messages)
+}
+
+func TestProcessTask_DeadLetterFlag(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name       string
+		deadLetter *bool
+		expectDLQ  bool
+	}{
+		{name: "default", deadLetter: nil, expectDLQ: true},
+		{name: "enabled", deadLetter: &enabled, expectDLQ: true},
+		{name: "disabled", deadLetter: &disabled, expectDLQ: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, q, mr := setupTestWorker(t)
+			defer mr.Close()
+			defer func() { _ = q.Close() }()
+
+			w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+				return errors.New("task failed")
+			})
+
+			tsk := task.NewTask("test_task", nil, task.MediumPriority)
+			tsk.MaxRetries = 1
+			tsk.DeadLetter = tt.deadLetter
+			require.NoError(t, q.Enqueue(tsk))
+
+			w.processTask(tsk)
+
+			_, err := q.GetDeadLetterTask(tsk.ID)
+			if tt.expectDLQ {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+
+			updated, err := q.GetTask(tsk.ID)
+			require.NoError(t, err)
+			assert.Equal(t, task.FailedStatus, updated.Status)
+			assert.Equal(t, 1, updated.RetryCount)
+			assert.Contains(t, updated.Error, "task failed")
+		})
+	}
+}
+
+func TestProcessTask_PermanentError(t *testing.T) {
+	w, q, mockRepo, mr := setupTestWorkerWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	calls := 0
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		calls++
+		return Permanent(errors.New("missing 'to' field"))
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, mockRepo.GetFailTaskCallCount(), "permanent errors should not be retried")
+
+	dlqTask, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, dlqTask.RetryCount)
+	assert.Equal(t, "missing 'to' field", dlqTask.FailureReason)
+	assert.Equal(t, task.ValidationFailure, dlqTask.FailureCategory)
+
+	depth, err := q.Depth()
+	require.NoError(t, err)
+	assert.Zero(t, depth)
+}
+
+func TestProcessTask_WrappedPermanentError(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return fmt.Errorf("send failed: %w", Permanent(errors.New("bad request")))
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+
+	_, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+}
+
+func TestProcessTask_NormalErrorStillRetries(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("smtp server unavailable")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+
+	retried, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, retried.Status)
+	assert.Equal(t, 1, retried.RetryCount)
+
+	_, err = q.GetDeadLetterTask(tsk.ID)
+	assert.Error(t, err)
+}
+
 func TestProcessTask_NoHandler(t *testing.T) {
 	w, q, mr := setupTestWorker(t)
 	defer mr.Close()
@@ -178,6 +553,221 @@ func TestWorkerStartStop(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 }
 
+func TestWorkerEvents_Success(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	sink := &capturingSink{}
+	q.SetEventSink(sink)
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+	w.processNextTask()
+
+	assert.Equal(t, []events.Type{events.Enqueued, events.Started, events.Completed}, sink.types())
+	for _, e := range sink.events {
+		assert.Equal(t, tsk.ID, e.TaskID)
+		assert.Equal(t, "test_task", e.TaskType)
+	}
+}
+
+func TestWorkerEvents_Failure(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	sink := &capturingSink{}
+	q.SetEventSink(sink)
+	w.SetBackoff(ConstantBackoff{Delay: 0})
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("boom")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 2
+	require.NoError(t, q.Enqueue(tsk))
+	w.processNextTask()
+	w.processNextTask()
+
+	assert.Equal(t, []events.Type{
+		events.Enqueued,
+		events.Started,
+		events.Failed,
+		events.Retrying,
+		events.Started,
+		events.Failed,
+		events.DeadLettered,
+	}, sink.types())
+
+	last := sink.events[len(sink.events)-1]
+	assert.Equal(t, 2, last.RetryCount)
+	assert.Equal(t, "boom", last.Error)
+}
+
+// callbackServer starts a callback receiver and lets w deliver to it, since
+// the default webhook client refuses loopback addresses.
+func callbackServer(t *testing.T, w *Worker, status int) (*httptest.Server, <-chan CallbackPayload) {
+	loopback, err := webhook.ParseAllowedNetworks("127.0.0.0/8,::1/128")
+	require.NoError(t, err)
+	w.SetWebhookClient(webhook.NewClientWithAllowlist(webhook.DefaultTimeout, loopback))
+
+	received := make(chan CallbackPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload CallbackPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+		w.WriteHeader(status)
+	}))
+
+	return server, received
+}
+
+func awaitCallback(t *testing.T, received <-chan CallbackPayload) CallbackPayload {
+	select {
+	case payload := <-received:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+		return CallbackPayload{}
+	}
+}
+
+func TestWorkerCallback_Completed(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	server, received := callbackServer(t, w, http.StatusOK)
+	defer server.Close()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.CallbackURL = server.URL
+	require.NoError(t, q.Enqueue(tsk))
+	w.processNextTask()
+
+	payload := awaitCallback(t, received)
+	assert.Equal(t, tsk.ID, payload.TaskID)
+	assert.Equal(t, task.CompletedStatus, payload.Status)
+	assert.NotNil(t, payload.CompletedAt)
+	assert.Empty(t, payload.Error)
+}
+
+func TestWorkerCallback_PermanentFailure(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	server, received := callbackServer(t, w, http.StatusInternalServerError)
+	defer server.Close()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("boom")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 1
+	tsk.CallbackURL = server.URL
+	require.NoError(t, q.Enqueue(tsk))
+	w.processNextTask()
+
+	payload := awaitCallback(t, received)
+	assert.Equal(t, tsk.ID, payload.TaskID)
+	assert.Equal(t, task.DeadLetterStatus, payload.Status)
+	assert.Equal(t, "boom", payload.Error)
+
+	w.callbacks.Wait()
+	dead, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err, "a failing callback must not change the task outcome")
+	assert.Equal(t, task.DeadLetterStatus, dead.Status)
+}
+
+func TestWorkerCallback_NotSentWhileRetrying(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	server, received := callbackServer(t, w, http.StatusOK)
+	defer server.Close()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("boom")
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 3
+	tsk.CallbackURL = server.URL
+	require.NoError(t, q.Enqueue(tsk))
+	w.processNextTask()
+	w.callbacks.Wait()
+
+	assert.Empty(t, received)
+}
+
+func TestWorkerHeartbeat(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.SetHeartbeatInterval(10 * time.Millisecond)
+
+	release := make(chan struct{})
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		<-release
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	go w.Start()
+
+	require.Eventually(t, func() bool {
+		workers, err := q.ActiveWorkers()
+		return err == nil && len(workers) == 1 && workers[0].CurrentTask == tsk.ID
+	}, 5*time.Second, 10*time.Millisecond, "worker should report its current task")
+
+	close(release)
+	require.Eventually(t, func() bool {
+		workers, err := q.ActiveWorkers()
+		return err == nil && len(workers) == 1 && workers[0].CurrentTask == ""
+	}, 5*time.Second, 10*time.Millisecond, "worker should clear its current task")
+
+	w.Stop()
+	require.Eventually(t, func() bool {
+		workers, err := q.ActiveWorkers()
+		return err == nil && len(workers) == 0
+	}, 5*time.Second, 10*time.Millisecond, "stopped worker should deregister")
+}
+
+func TestWorkerRegistersTaskTypes(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error { return nil })
+	w.RegisterHandler("other_task", func(ctx context.Context, tsk *task.Task) error { return nil })
+
+	go w.Start()
+	defer w.Stop()
+
+	require.Eventually(t, func() bool {
+		types, err := q.KnownTaskTypes()
+		return err == nil && len(types) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	types, err := q.KnownTaskTypes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other_task", "test_task"}, types)
+}
+
 func TestWorkerProcessMultipleTasks(t *testing.T) {
 	w, q, mr := setupTestWorker(t)
 	defer mr.Close()
@@ -289,6 +879,34 @@ func TestWorkerProcessTaskFailurePermanent(t *testing.T) {
 	assert.Equal(t, 0, mockRepo.GetFailTaskCallCount())
 }
 
+func TestWorkerFailureCategory(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("smtp: i/o timeout")
+	})
+
+	tsk := task.NewTask("test_task", map[string]any{}, task.MediumPriority)
+	tsk.MaxRetries = 2
+	require.NoError(t, q.Enqueue(tsk))
+
+	retrievedTask, err := q.Dequeue()
+	require.NoError(t, err)
+	w.processTask(retrievedTask)
+
+	retried, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.TimeoutFailure, retried.FailureCategory)
+
+	w.processTask(retried)
+
+	dlqTask, err := q.GetDeadLetterTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.TimeoutFailure, dlqTask.FailureCategory)
+}
+
 func TestWorkerProcessTaskNoHandler(t *testing.T) {
 	w, q, mockRepo, mr := setupTestWorkerWithMockRepo(t)
 	defer mr.Close()
@@ -338,7 +956,8 @@ func TestWorkerMultipleTasks(t *testing.T) {
 	}
 
 	assert.Equal(t, 5, processedTasks, "All tasks should be processed")
-	assert.Equal(t, 15, mockRepo.GetSaveTaskCallCount(), "All tasks should be saved")
+	assert.Equal(t, 10, mockRepo.GetSaveTaskCallCount(), "All tasks should be saved on enqueue and completion")
+	assert.Equal(t, 5, mockRepo.GetStartTaskCallCount(), "All tasks should be started")
 	assert.Equal(t, 5, mockRepo.GetCompleteTaskCallCount(), "All tasks should be completed")
 }
 
@@ -391,3 +1010,220 @@ func TestWorkerIDTracking(t *testing.T) {
 		assert.Equal(t, "test-worker", log.WorkerID, "Worker ID should be tracked")
 	}
 }
+
+func TestProcessTask_DependencyChain(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	var order []string
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		order = append(order, tsk.Payload["name"].(string))
+		return nil
+	})
+
+	parent := task.NewTask("test_task", map[string]any{"name": "parent"}, task.MediumPriority)
+	child := task.NewTask("test_task", map[string]any{"name": "child"}, task.MediumPriority)
+	child.DependsOn = []string{parent.ID}
+
+	defer func(d time.Duration) { dependencyWaitDelay = d }(dependencyWaitDelay)
+	dependencyWaitDelay = 100 * time.Millisecond
+
+	require.NoError(t, q.Enqueue(child))
+	require.NoError(t, q.Enqueue(parent))
+
+	for range 3 {
+		w.processNextTask()
+	}
+
+	assert.Equal(t, []string{"parent"}, order, "the deferred child is not picked up before its delay")
+
+	time.Sleep(dependencyWaitDelay)
+	w.processNextTask()
+
+	assert.Equal(t, []string{"parent", "child"}, order)
+
+	updated, err := q.GetTask(child.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, updated.Status)
+	assert.Equal(t, 0, updated.RetryCount)
+}
+
+func TestProcessTask_DependencyFailurePropagates(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	childExecuted := false
+	w.RegisterHandler("parent_task", func(ctx context.Context, tsk *task.Task) error {
+		return errors.New("parent failed")
+	})
+	w.RegisterHandler("child_task", func(ctx context.Context, tsk *task.Task) error {
+		childExecuted = true
+		return nil
+	})
+
+	parent := task.NewTask("parent_task", nil, task.MediumPriority)
+	parent.MaxRetries = 1
+	child := task.NewTask("child_task", nil, task.MediumPriority)
+	child.DependsOn = []string{parent.ID}
+
+	require.NoError(t, q.Enqueue(parent))
+	require.NoError(t, q.Enqueue(child))
+
+	for range 2 {
+		w.processNextTask()
+	}
+
+	assert.False(t, childExecuted)
+
+	dlqChild, err := q.GetDeadLetterTask(child.ID)
+	require.NoError(t, err)
+	assert.Contains(t, dlqChild.FailureReason, parent.ID)
+}
+
+func TestProcessTask_MissingDependencyFails(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	executed := false
+	w.RegisterHandler("child_task", func(ctx context.Context, tsk *task.Task) error {
+		executed = true
+		return nil
+	})
+
+	child := task.NewTask("child_task", nil, task.MediumPriority)
+	child.DependsOn = []string{"missing"}
+	require.NoError(t, q.Enqueue(child))
+
+	w.processNextTask()
+
+	assert.False(t, executed)
+
+	dlqChild, err := q.GetDeadLetterTask(child.ID)
+	require.NoError(t, err)
+	assert.Contains(t, dlqChild.FailureReason, "dependency missing not found")
+}
+
+func TestProcessNextTask_ReleasesLease(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	q.SetMaxLeasesPerWorker(1)
+
+	var leasesDuringRun int
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		leasesDuringRun, _ = q.LeaseCount("test-worker")
+		return nil
+	})
+
+	first := task.NewTask("test_task", nil, task.MediumPriority)
+	second := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(first))
+	require.NoError(t, q.Enqueue(second))
+
+	w.processNextTask()
+	assert.Equal(t, 1, leasesDuringRun)
+
+	leases, err := q.LeaseCount("test-worker")
+	require.NoError(t, err)
+	assert.Equal(t, 0, leases)
+
+	w.processNextTask()
+
+	updated, err := q.GetTask(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, updated.Status)
+}
+
+func TestProcessTask_RetryLater(t *testing.T) {
+	w, q, mockRepo, mr := setupTestWorkerWithMockRepo(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	attempts := 0
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		attempts++
+		return RetryLater(time.Minute)
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 1
+	require.NoError(t, q.Enqueue(tsk))
+
+	before := time.Now()
+	w.processNextTask()
+
+	assert.Equal(t, 1, attempts)
+	assert.Empty(t, mockRepo.IncrementRetryCalls)
+	assert.Empty(t, mockRepo.FailTaskCalls)
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, updated.Status)
+	assert.Equal(t, 0, updated.RetryCount)
+	assert.Empty(t, updated.Error)
+	assert.Nil(t, updated.CompletedAt)
+	assert.WithinDuration(t, before.Add(time.Minute), updated.ScheduledAt, 5*time.Second)
+
+	w.processNextTask()
+	assert.Equal(t, 1, attempts, "the task is not run again before its delay")
+}
+
+func TestProcessTask_RetryLaterRunsAfterDelay(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	const delay = 100 * time.Millisecond
+	attempts := 0
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		attempts++
+		if attempts == 1 {
+			return RetryLater(delay)
+		}
+		return nil
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+	w.processNextTask()
+	assert.Equal(t, 1, attempts)
+
+	time.Sleep(delay)
+	w.processNextTask()
+	assert.Equal(t, 2, attempts)
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.CompletedStatus, updated.Status)
+}
+
+func TestProcessTask_RetryLaterWrapped(t *testing.T) {
+	w, q, mr := setupTestWorker(t)
+	defer mr.Close()
+	defer func() { _ = q.Close() }()
+
+	w.RegisterHandler("test_task", func(ctx context.Context, tsk *task.Task) error {
+		return fmt.Errorf("upstream busy: %w", RetryLater(30*time.Second))
+	})
+
+	tsk := task.NewTask("test_task", nil, task.MediumPriority)
+	tsk.MaxRetries = 1
+	tsk.RetryCount = 1
+	require.NoError(t, q.Enqueue(tsk))
+
+	w.processNextTask()
+
+	updated, err := q.GetTask(tsk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.PendingStatus, updated.Status)
+	assert.Equal(t, 1, updated.RetryCount)
+
+	_, err = q.GetDeadLetterTask(tsk.ID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,3 @@
+ALTER TABLE task_history ADD COLUMN tags TEXT[] DEFAULT '{}';
+
+CREATE INDEX idx_task_history_tags ON task_history USING gin(tags);
@@ -0,0 +1,3 @@
+ALTER TABLE task_history ADD COLUMN expected_duration_ms INTEGER;
+
+CREATE INDEX idx_task_history_expected_duration ON task_history(created_at DESC) WHERE expected_duration_ms IS NOT NULL;
@@ -0,0 +1 @@
+CREATE INDEX idx_task_history_type_created_at ON task_history(type, created_at DESC);
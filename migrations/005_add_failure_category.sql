@@ -0,0 +1,3 @@
+ALTER TABLE task_history ADD COLUMN failure_category VARCHAR(50);
+
+CREATE INDEX idx_task_history_failure_category ON task_history(failure_category) WHERE failure_category IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE task_history ADD COLUMN max_retries INTEGER DEFAULT 3;
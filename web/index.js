@@ -261,6 +261,9 @@ function copyCode(button, index) {
 }
 
 function getPriorityLabel(priority) {
+    if (typeof priority === 'string') {
+        return priority;
+    }
     const labels = ['low', 'medium', 'high'];
     return labels[priority] || 'medium';
 }
@@ -279,7 +282,7 @@ document.getElementById('taskForm').addEventListener('submit', async (e) => {
         const data = {
             type: document.getElementById('taskType').value,
             payload: payload,
-            priority: parseInt(document.getElementById('priority').value)
+            priority: document.getElementById('priority').value
         };
 
         await fetch(`${API_URL}/tasks`, {